@@ -10,17 +10,23 @@ import (
 
 // Config 应用配置
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	Redis     RedisConfig     `mapstructure:"redis"`
-	JWT       JWTConfig       `mapstructure:"jwt"`
-	Log       LogConfig       `mapstructure:"log"`
-	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	JWT        JWTConfig        `mapstructure:"jwt"`
+	Log        LogConfig        `mapstructure:"log"`
+	Telemetry  TelemetryConfig  `mapstructure:"telemetry"`
+	Datasource DatasourceConfig `mapstructure:"datasource"`
+	Export     ExportConfig     `mapstructure:"export"`
+	Dataset    DatasetConfig    `mapstructure:"dataset"`
 }
 
 type ServerConfig struct {
 	Port int    `mapstructure:"port"`
 	Mode string `mapstructure:"mode"`
+	// AllowedOrigins are the origins besides the server's own whose pages
+	// may open a websocket.
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
 }
 
 type DatabaseConfig struct {
@@ -56,6 +62,40 @@ type TelemetryConfig struct {
 	Endpoint string `mapstructure:"endpoint"`
 }
 
+type DatasourceConfig struct {
+	UploadDir     string `mapstructure:"upload_dir"`
+	MaxUploadSize int64  `mapstructure:"max_upload_size"`
+	SecretKey     string `mapstructure:"secret_key"`
+	// HealthInterval is the health check period in seconds and
+	// HealthRetention the days of check history kept.
+	HealthInterval  int `mapstructure:"health_interval"`
+	HealthRetention int `mapstructure:"health_retention"`
+	// QueryTimeout caps every datasource query in seconds, and
+	// UserQueryTimeouts overrides it by username.
+	QueryTimeout      int            `mapstructure:"query_timeout"`
+	UserQueryTimeouts map[string]int `mapstructure:"user_query_timeouts"`
+	// DeniedSQLFunctions are the functions custom SQL may not call; empty
+	// uses the built-in list.
+	DeniedSQLFunctions []string `mapstructure:"denied_sql_functions"`
+	// RemoteFileHosts are the hosts Excel files may be loaded from; empty
+	// accepts any host with a public address.
+	RemoteFileHosts []string `mapstructure:"remote_file_hosts"`
+}
+
+type ExportConfig struct {
+	// Dir keeps the exported files; empty uses a directory under the system
+	// temporary directory.
+	Dir string `mapstructure:"dir"`
+	// Limit is the most rows a dataset export writes.
+	Limit int64 `mapstructure:"limit"`
+}
+
+type DatasetConfig struct {
+	// MaskExemptAdmins lets admins read the fields hidden or masked by
+	// column permissions in clear.
+	MaskExemptAdmins bool `mapstructure:"mask_exempt_admins"`
+}
+
 // LoadConfig 加载配置
 func LoadConfig() (*Config, error) {
 	configPath := os.Getenv("CONFIG_PATH")
@@ -1,5 +1,7 @@
 package chart
 
+import "dataease/backend/internal/domain/dataset"
+
 type CoreChartView struct {
 	ID           int64   `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
 	Title        *string `gorm:"column:title" json:"title"`
@@ -28,10 +30,16 @@ type ChartQueryRequest struct {
 	ID int64 `json:"id" binding:"required"`
 }
 
+// ChartDataRequest asks for the data of a chart. The args of the share
+// Ticket, Parameters, set by the dashboard filters, and OuterParams bind the
+// variables of a custom SQL dataset, in that order of precedence.
 type ChartDataRequest struct {
-	ID          int64  `json:"id" binding:"required"`
-	ResultCount *int   `json:"resultCount"`
-	ResultMode  string `json:"resultMode"`
+	ID          int64                  `json:"id" binding:"required"`
+	ResultCount *int                   `json:"resultCount"`
+	ResultMode  string                 `json:"resultMode"`
+	Parameters  []dataset.SQLParameter `json:"parameters"`
+	OuterParams []dataset.SQLParameter `json:"outerParams"`
+	Ticket      string                 `json:"ticket"`
 }
 
 type ChartDataResponse struct {
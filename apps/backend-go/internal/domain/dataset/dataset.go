@@ -1,5 +1,7 @@
 package dataset
 
+import "strings"
+
 const (
 	NodeTypeFolder  = "folder"
 	NodeTypeDataset = "dataset"
@@ -13,6 +15,14 @@ type CoreDatasetGroup struct {
 	NodeType *string `gorm:"column:node_type" json:"nodeType"`
 	Type     *string `gorm:"column:type" json:"type"`
 	DelFlag  *int    `gorm:"column:del_flag" json:"delFlag"`
+	CreateBy *string `gorm:"column:create_by" json:"createBy"`
+	// CreateTime and LastUpdateTime are in milliseconds; CreateBy and
+	// UpdateBy hold user ids.
+	CreateTime     *int64  `gorm:"column:create_time" json:"createTime"`
+	UpdateBy       *string `gorm:"column:update_by" json:"updateBy"`
+	LastUpdateTime *int64  `gorm:"column:last_update_time" json:"lastUpdateTime"`
+	// Info holds the JSON Model of datasets joining several tables.
+	Info *string `gorm:"column:info" json:"info,omitempty"`
 }
 
 func (CoreDatasetGroup) TableName() string {
@@ -26,7 +36,11 @@ type CoreDatasetTable struct {
 	DatasetGroupID int64   `gorm:"column:dataset_group_id" json:"datasetGroupId"`
 	PhysicalTable  *string `gorm:"column:table_name" json:"tableName"`
 	Type           *string `gorm:"column:type" json:"type"`
+	Info           *string `gorm:"column:info" json:"info"`
 	SQLVariables   *string `gorm:"column:sql_variable_details" json:"sqlVariableDetails"`
+	// Status and LastUpdate are written by the datasource health monitor.
+	Status     *string `gorm:"column:status;size:50" json:"status"`
+	LastUpdate *int64  `gorm:"column:last_update" json:"lastUpdate"`
 }
 
 func (CoreDatasetTable) TableName() string {
@@ -50,12 +64,25 @@ type CoreDatasetTableField struct {
 	ExtField       *int    `gorm:"column:ext_field" json:"extField"`
 	Checked        *bool   `gorm:"column:checked" json:"checked"`
 	Params         *string `gorm:"column:params" json:"params"`
+	DateFormat     *string `gorm:"column:date_format" json:"dateFormat"`
+	DateFormatType *string `gorm:"column:date_format_type" json:"dateFormatType"`
 }
 
 func (CoreDatasetTableField) TableName() string {
 	return "core_dataset_table_field"
 }
 
+// DisplayName is the name of the field, or its origin name when it has
+// none.
+func (f *CoreDatasetTableField) DisplayName() string {
+	for _, name := range []*string{f.Name, f.OriginName} {
+		if name != nil && strings.TrimSpace(*name) != "" {
+			return strings.TrimSpace(*name)
+		}
+	}
+	return ""
+}
+
 type TreeRequest struct {
 	Keyword *string `json:"keyword"`
 }
@@ -89,12 +116,21 @@ type WriteRequest struct {
 	NodeType string  `json:"nodeType"`
 	Type     *string `json:"type"`
 	IsCross  *bool   `json:"isCross"`
+	Model    *Model  `json:"model"`
+	// UserID is the user saving the dataset.
+	UserID int64 `json:"-"`
 }
 
 type SQLPreviewRequest struct {
 	DatasourceID int64  `json:"datasourceId"`
 	SQL          string `json:"sql"`
 	IsCross      bool   `json:"isCross"`
+	// TableID is the SQL table being edited, whose query history records
+	// the preview once the table is saved.
+	TableID string `json:"tableId"`
+	// SQLVariableDetails are the variables of the SQL, as JSON, bound with
+	// their defaults before the SQL is checked and run.
+	SQLVariableDetails string `json:"sqlVariableDetails"`
 }
 
 type SQLPreviewField struct {
@@ -0,0 +1,53 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FilterTree is a condition on the rows of a dataset as the filter editor
+// writes it: items joined by logic, "and" or "or".
+type FilterTree struct {
+	Logic string       `json:"logic"`
+	Items []FilterItem `json:"items"`
+}
+
+// FilterItem is a condition on one field, or a nested tree when Type is
+// "tree". An "enum" item matches the values of EnumValue; a "logic" item
+// compares the field with Value using Term: eq, not_eq, lt, le, gt, ge,
+// in, not in, like, not like, null, not_null, empty, not_empty or between.
+// In and between take comma separated values.
+type FilterItem struct {
+	Type string `json:"type"`
+	// FieldID is written as a number or, for large ids, as a string.
+	FieldID    json.Number `json:"fieldId"`
+	FilterType string      `json:"filterType"`
+	Term       string      `json:"term"`
+	Value      string      `json:"value"`
+	EnumValue  []string    `json:"enumValue"`
+	SubTree    *FilterTree `json:"subTree"`
+}
+
+// ParseFilterTree reads a filter tree from its JSON; an empty text is no
+// filter.
+func ParseFilterTree(raw string) (*FilterTree, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var tree FilterTree
+	if err := json.Unmarshal([]byte(raw), &tree); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return &tree, nil
+}
+
+// ExportRequest asks for the rows of a dataset, filtered by ExpressionTree,
+// to be exported to a file named Filename in Format, "xlsx" by default or
+// "csv".
+type ExportRequest struct {
+	ID             int64  `json:"id" binding:"required"`
+	Filename       string `json:"filename"`
+	Format         string `json:"format"`
+	ExpressionTree string `json:"expressionTree"`
+}
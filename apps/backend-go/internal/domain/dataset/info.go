@@ -0,0 +1,41 @@
+package dataset
+
+// Kinds of dataset reported by BarInfo.
+const (
+	KindSQL   = "sql"
+	KindTable = "table"
+	KindJoin  = "join"
+	KindExcel = "excel"
+)
+
+// BarInfo is the info panel of a dataset: who owns it, where its data comes
+// from, how fresh it is and what depends on it.
+type BarInfo struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	NodeType string `json:"nodeType"`
+	// DatasetType is one of the Kind constants.
+	DatasetType    string          `json:"datasetType"`
+	CreateBy       string          `json:"createBy"`
+	Creator        string          `json:"creator"`
+	CreateTime     *int64          `json:"createTime"`
+	UpdateBy       string          `json:"updateBy"`
+	Updater        string          `json:"updater"`
+	LastUpdateTime *int64          `json:"lastUpdateTime"`
+	Datasources    []BarDatasource `json:"datasourceDTOList"`
+	IsCross        bool            `json:"isCross"`
+	FieldCount     int             `json:"fieldCount"`
+	// LastSyncTime and SyncStatus come from the sync task of a synced
+	// datasource, else from the last check of the dataset tables.
+	LastSyncTime   *int64 `json:"lastSyncTime"`
+	SyncStatus     string `json:"syncStatus"`
+	ChartCount     int    `json:"chartCount"`
+	DashboardCount int    `json:"dashboardCount"`
+}
+
+type BarDatasource struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
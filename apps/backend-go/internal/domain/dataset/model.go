@@ -0,0 +1,188 @@
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Join types of a model node.
+const (
+	JoinInner = "inner"
+	JoinLeft  = "left"
+	JoinRight = "right"
+	JoinFull  = "full"
+)
+
+var modelTableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Model is the relational shape of a dataset, persisted in the info column
+// of its group. Each entry of Union is a tree of joined tables; several
+// entries are combined with UNION ALL, or UNION when Distinct is set.
+type Model struct {
+	Union    []ModelNode `json:"union"`
+	Distinct bool        `json:"distinct,omitempty"`
+}
+
+// ModelNode is one table of a join tree. Join tells how a child node joins
+// its parent and is empty on the root. Alias tells apart the occurrences of
+// a table joined to itself. TableID is the core_dataset_table row assigned
+// when the model is saved.
+type ModelNode struct {
+	TableID      int64       `json:"tableId,omitempty"`
+	DatasourceID int64       `json:"datasourceId"`
+	TableName    string      `json:"tableName"`
+	Alias        string      `json:"alias,omitempty"`
+	Join         *Join       `json:"join,omitempty"`
+	Children     []ModelNode `json:"children,omitempty"`
+}
+
+type Join struct {
+	Type       string          `json:"type"`
+	Conditions []JoinCondition `json:"conditions"`
+}
+
+// JoinCondition equates a column of the parent table with one of the child.
+type JoinCondition struct {
+	ParentColumn string `json:"parentColumn"`
+	Column       string `json:"column"`
+}
+
+// Definition is what a query on a dataset is built from. Model is nil for
+// datasets reading a single table. Fields excludes the chart fields.
+type Definition struct {
+	Group  *CoreDatasetGroup
+	Model  *Model
+	Tables []*CoreDatasetTable
+	Fields []*CoreDatasetTableField
+}
+
+// ParseModel decodes the model stored in a group. Empty values and the
+// union lists written by the Java backend yield no model.
+func ParseModel(info *string) (*Model, error) {
+	if info == nil {
+		return nil, nil
+	}
+	text := strings.TrimSpace(*info)
+	if text == "" || !strings.HasPrefix(text, "{") {
+		return nil, nil
+	}
+	var model Model
+	if err := json.Unmarshal([]byte(text), &model); err != nil {
+		return nil, fmt.Errorf("invalid dataset model: %w", err)
+	}
+	if len(model.Union) == 0 {
+		return nil, nil
+	}
+	return &model, nil
+}
+
+// Validate checks the structure of the model: table names, join types and
+// conditions, and that every table reads from the same datasource.
+func (m *Model) Validate() error {
+	if m == nil || len(m.Union) == 0 {
+		return fmt.Errorf("dataset model has no table")
+	}
+	datasourceID := int64(0)
+	var err error
+	m.Walk(func(node *ModelNode, parent *ModelNode) bool {
+		if err = node.validate(parent != nil); err != nil {
+			return false
+		}
+		if datasourceID == 0 {
+			datasourceID = node.DatasourceID
+		} else if node.DatasourceID != datasourceID {
+			err = fmt.Errorf("tables of a dataset model must share one datasource")
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+func (n *ModelNode) validate(child bool) error {
+	if n.DatasourceID <= 0 {
+		return fmt.Errorf("datasource of table %s is required", n.TableName)
+	}
+	if !modelTableNamePattern.MatchString(n.TableName) {
+		return fmt.Errorf("invalid dataset table name: %s", n.TableName)
+	}
+	if n.Alias != "" && !modelTableNamePattern.MatchString(n.Alias) {
+		return fmt.Errorf("invalid alias of table %s: %s", n.TableName, n.Alias)
+	}
+	if !child {
+		if n.Join != nil {
+			return fmt.Errorf("table %s is a root and cannot be joined", n.TableName)
+		}
+		return nil
+	}
+	if n.Join == nil {
+		return fmt.Errorf("join of table %s is required", n.TableName)
+	}
+	switch n.Join.Type {
+	case JoinInner, JoinLeft, JoinRight, JoinFull:
+	default:
+		return fmt.Errorf("unsupported join type: %s", n.Join.Type)
+	}
+	if len(n.Join.Conditions) == 0 {
+		return fmt.Errorf("join of table %s has no condition", n.TableName)
+	}
+	for _, cond := range n.Join.Conditions {
+		if strings.TrimSpace(cond.ParentColumn) == "" || strings.TrimSpace(cond.Column) == "" {
+			return fmt.Errorf("join condition of table %s is incomplete", n.TableName)
+		}
+	}
+	return nil
+}
+
+// Name is the alias of the node, or its table name without one.
+func (n *ModelNode) Name() string {
+	if n.Alias != "" {
+		return n.Alias
+	}
+	return n.TableName
+}
+
+// Walk visits the nodes depth first, parents before their children, until
+// fn returns false. parent is nil for the roots of the union.
+func (m *Model) Walk(fn func(node *ModelNode, parent *ModelNode) bool) {
+	var visit func(node *ModelNode, parent *ModelNode) bool
+	visit = func(node *ModelNode, parent *ModelNode) bool {
+		if !fn(node, parent) {
+			return false
+		}
+		for i := range node.Children {
+			if !visit(&node.Children[i], node) {
+				return false
+			}
+		}
+		return true
+	}
+	for i := range m.Union {
+		if !visit(&m.Union[i], nil) {
+			return
+		}
+	}
+}
+
+// DatasourceID returns the datasource the dataset reads from: the one of
+// the model, or of the first table otherwise.
+func (d *Definition) DatasourceID() int64 {
+	if d.Model != nil && len(d.Model.Union) > 0 {
+		return d.Model.Union[0].DatasourceID
+	}
+	if len(d.Tables) > 0 && d.Tables[0].DatasourceID != nil {
+		return *d.Tables[0].DatasourceID
+	}
+	return 0
+}
+
+// TableIDs lists the ids of the tables of the dataset.
+func (d *Definition) TableIDs() []int64 {
+	ids := make([]int64, 0, len(d.Tables))
+	for _, table := range d.Tables {
+		ids = append(ids, table.ID)
+	}
+	return ids
+}
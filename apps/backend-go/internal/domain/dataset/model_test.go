@@ -0,0 +1,65 @@
+package dataset
+
+import "testing"
+
+func TestParseModel(t *testing.T) {
+	for _, info := range []string{"", "  ", `[{"currentDs":{}}]`} {
+		model, err := ParseModel(&info)
+		if err != nil || model != nil {
+			t.Errorf("ParseModel(%q) = %v, %v; want no model", info, model, err)
+		}
+	}
+
+	info := `{"union":[{"datasourceId":1,"tableName":"orders"}],"distinct":true}`
+	model, err := ParseModel(&info)
+	if err != nil {
+		t.Fatalf("ParseModel: %v", err)
+	}
+	if model == nil || len(model.Union) != 1 || !model.Distinct {
+		t.Fatalf("unexpected model: %+v", model)
+	}
+
+	bad := `{"union":`
+	if _, err := ParseModel(&bad); err == nil {
+		t.Error("expected error for malformed model")
+	}
+}
+
+func TestModelValidate(t *testing.T) {
+	join := &Join{Type: JoinLeft, Conditions: []JoinCondition{{ParentColumn: "customer_id", Column: "id"}}}
+	valid := &Model{Union: []ModelNode{{
+		DatasourceID: 1, TableName: "orders",
+		Children: []ModelNode{{DatasourceID: 1, TableName: "customers", Join: join}},
+	}}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	invalid := map[string]*Model{
+		"empty":          {},
+		"bad table name": {Union: []ModelNode{{DatasourceID: 1, TableName: "orders; drop"}}},
+		"bad alias":      {Union: []ModelNode{{DatasourceID: 1, TableName: "orders", Alias: "o o"}}},
+		"joined root":    {Union: []ModelNode{{DatasourceID: 1, TableName: "orders", Join: join}}},
+		"missing join": {Union: []ModelNode{{
+			DatasourceID: 1, TableName: "orders",
+			Children: []ModelNode{{DatasourceID: 1, TableName: "customers"}},
+		}}},
+		"unknown join type": {Union: []ModelNode{{
+			DatasourceID: 1, TableName: "orders",
+			Children: []ModelNode{{DatasourceID: 1, TableName: "customers", Join: &Join{Type: "cross", Conditions: join.Conditions}}},
+		}}},
+		"incomplete condition": {Union: []ModelNode{{
+			DatasourceID: 1, TableName: "orders",
+			Children: []ModelNode{{DatasourceID: 1, TableName: "customers", Join: &Join{Type: JoinInner, Conditions: []JoinCondition{{Column: "id"}}}}},
+		}}},
+		"two datasources": {Union: []ModelNode{
+			{DatasourceID: 1, TableName: "orders"},
+			{DatasourceID: 2, TableName: "orders"},
+		}},
+	}
+	for name, model := range invalid {
+		if err := model.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
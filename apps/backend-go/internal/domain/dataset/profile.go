@@ -0,0 +1,89 @@
+package dataset
+
+import "encoding/json"
+
+// Statuses of a dataset profile.
+const (
+	ProfileRunning = "running"
+	ProfileSuccess = "success"
+	ProfileFailed  = "failed"
+)
+
+// CoreDatasetProfile caches the latest profile of a dataset. A profile
+// being refreshed keeps the previous result until the new one is ready.
+type CoreDatasetProfile struct {
+	DatasetGroupID int64  `gorm:"column:dataset_group_id;primaryKey;autoIncrement:false" json:"datasetGroupId"`
+	Status         string `gorm:"column:status;size:20" json:"status"`
+	Result         string `gorm:"column:result;type:longtext" json:"-"`
+	Msg            string `gorm:"column:msg;type:text" json:"msg"`
+	// ProfiledAt is when Result was computed, UpdateTime when the status
+	// last changed, both in milliseconds.
+	ProfiledAt int64 `gorm:"column:profiled_at" json:"profiledAt"`
+	UpdateTime int64 `gorm:"column:update_time" json:"updateTime"`
+}
+
+func (CoreDatasetProfile) TableName() string {
+	return "core_dataset_profile"
+}
+
+// Profile describes the rows of a dataset and the values of each field.
+type Profile struct {
+	RowCount int64          `json:"rowCount"`
+	Fields   []FieldProfile `json:"fields"`
+}
+
+// FieldProfile describes the values of a field. Min and Max are set for
+// numbers and dates; Histogram for numbers and dates with values, dates
+// being bucketed on seconds since the epoch. DistinctCount is approximate
+// on the datasources with an approximate count.
+type FieldProfile struct {
+	FieldID       int64             `json:"fieldId"`
+	Name          string            `json:"name"`
+	DeType        *int              `json:"deType"`
+	NullCount     int64             `json:"nullCount"`
+	NullRatio     float64           `json:"nullRatio"`
+	DistinctCount int64             `json:"distinctCount"`
+	Min           interface{}       `json:"min"`
+	Max           interface{}       `json:"max"`
+	TopValues     []ValueCount      `json:"topValues"`
+	Histogram     []HistogramBucket `json:"histogram,omitempty"`
+	// Error is set when the field could not be profiled.
+	Error string `json:"error,omitempty"`
+}
+
+type ValueCount struct {
+	Value interface{} `json:"value"`
+	Count int64       `json:"count"`
+}
+
+// HistogramBucket counts the values from Lower, included, to Upper,
+// excluded but for the last bucket.
+type HistogramBucket struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int64   `json:"count"`
+}
+
+// ProfileResponse is the cached profile of a dataset with its status;
+// Profile is nil until a profiling job has succeeded.
+type ProfileResponse struct {
+	DatasetGroupID int64    `json:"datasetGroupId"`
+	Status         string   `json:"status"`
+	Msg            string   `json:"msg,omitempty"`
+	ProfiledAt     int64    `json:"profiledAt"`
+	UpdateTime     int64    `json:"updateTime"`
+	Profile        *Profile `json:"profile"`
+}
+
+// ParseProfile reads the result of a profiling job; an empty result is no
+// profile.
+func ParseProfile(raw string) (*Profile, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var profile Profile
+	if err := json.Unmarshal([]byte(raw), &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
@@ -0,0 +1,39 @@
+package dataset
+
+// Statuses of a logged dataset query, as the Java backend writes them.
+const (
+	SQLLogCompleted = "Completed"
+	SQLLogError     = "Error"
+)
+
+// CoreDatasetTableSQLLog is a query run for a dataset table, with its
+// duration in milliseconds.
+type CoreDatasetTableSQLLog struct {
+	ID        string `gorm:"column:id;primaryKey;size:50" json:"id"`
+	TableID   string `gorm:"column:table_id;size:50;not null;index" json:"tableId"`
+	StartTime int64  `gorm:"column:start_time" json:"startTime"`
+	EndTime   int64  `gorm:"column:end_time" json:"endTime"`
+	Spend     int64  `gorm:"column:spend" json:"spend"`
+	SQL       string `gorm:"column:sql;type:longtext;not null" json:"sql"`
+	Status    string `gorm:"column:status;size:45" json:"status"`
+}
+
+func (CoreDatasetTableSQLLog) TableName() string {
+	return "core_dataset_table_sql_log"
+}
+
+// SQLLogRequest pages the queries of a dataset table, latest first. MinSpend,
+// in milliseconds, keeps the slow queries only.
+type SQLLogRequest struct {
+	TableID  string `json:"tableId" binding:"required"`
+	GoPage   int    `json:"goPage"`
+	PageSize int    `json:"pageSize"`
+	MinSpend int64  `json:"minSpend"`
+}
+
+type SQLLogPage struct {
+	List     []*CoreDatasetTableSQLLog `json:"records"`
+	Total    int64                     `json:"total"`
+	PageNum  int                       `json:"current"`
+	PageSize int                       `json:"size"`
+}
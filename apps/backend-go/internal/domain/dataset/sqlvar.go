@@ -0,0 +1,117 @@
+package dataset
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Table types of core_dataset_table.
+const (
+	TableTypeDB  = "db"
+	TableTypeSQL = "sql"
+)
+
+// Default value scopes of a SQL variable. An EDIT default only applies while
+// the dataset is edited; ALLSCOPE defaults also apply to charts.
+const (
+	DefaultScopeEdit = "EDIT"
+	DefaultScopeAll  = "ALLSCOPE"
+)
+
+// TableInfo is the info column of a dataset table. SQL is base64 encoded, as
+// written by the Java backend.
+type TableInfo struct {
+	Table string `json:"table"`
+	SQL   string `json:"sql"`
+}
+
+// ParseSQL returns the decoded query of a custom SQL table.
+func (t *CoreDatasetTable) ParseSQL() (string, error) {
+	if t.Info == nil || strings.TrimSpace(*t.Info) == "" {
+		return "", fmt.Errorf("sql of dataset table %d is empty", t.ID)
+	}
+	var info TableInfo
+	if err := json.Unmarshal([]byte(*t.Info), &info); err != nil {
+		return "", fmt.Errorf("invalid info of dataset table %d: %w", t.ID, err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(info.SQL)
+	if err != nil {
+		return "", fmt.Errorf("invalid sql of dataset table %d: %w", t.ID, err)
+	}
+	sql := strings.TrimSuffix(strings.TrimSpace(string(decoded)), ";")
+	if strings.TrimSpace(sql) == "" {
+		return "", fmt.Errorf("sql of dataset table %d is empty", t.ID)
+	}
+	return sql, nil
+}
+
+// SQLVariable is a variable declared by a custom SQL table and referenced as
+// ${variableName} in its query.
+type SQLVariable struct {
+	VariableName      string        `json:"variableName"`
+	Type              []string      `json:"type"`
+	Params            []interface{} `json:"params"`
+	DefaultValue      *string       `json:"defaultValue"`
+	DefaultValueScope string        `json:"defaultValueScope"`
+}
+
+// ParseSQLVariables decodes the sql_variable_details column of a table.
+func ParseSQLVariables(raw *string) ([]SQLVariable, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return nil, nil
+	}
+	variables := make([]SQLVariable, 0)
+	if err := json.Unmarshal([]byte(*raw), &variables); err != nil {
+		return nil, fmt.Errorf("invalid sql variables: %w", err)
+	}
+	return variables, nil
+}
+
+// DeType maps the declared type of the variable to a deType: 1 for dates,
+// 2 for integers, 3 for decimals, 4 for booleans and 0 for text.
+func (v *SQLVariable) DeType() int {
+	return SQLVariableDeType(v.Type)
+}
+
+// SQLVariableDeType maps a declared variable type to a deType.
+func SQLVariableDeType(typeList []string) int {
+	if len(typeList) == 0 {
+		return 0
+	}
+	typeText := strings.ToUpper(strings.TrimSpace(typeList[0]))
+	if typeText == "" {
+		return 0
+	}
+	if strings.Contains(typeText, "DATETIME") || strings.Contains(typeText, "TIMESTAMP") || strings.Contains(typeText, "DATE") || strings.Contains(typeText, "TIME") || strings.Contains(typeText, "YEAR") {
+		return 1
+	}
+	if strings.Contains(typeText, "DOUBLE") || strings.Contains(typeText, "FLOAT") || strings.Contains(typeText, "DECIMAL") || strings.Contains(typeText, "NUMERIC") || strings.Contains(typeText, "REAL") {
+		return 3
+	}
+	if strings.Contains(typeText, "INT") || strings.Contains(typeText, "LONG") || strings.Contains(typeText, "SHORT") || strings.Contains(typeText, "BIGINT") || strings.Contains(typeText, "SMALLINT") || strings.Contains(typeText, "TINYINT") {
+		return 2
+	}
+	if strings.Contains(typeText, "BOOL") {
+		return 4
+	}
+	return 0
+}
+
+// SQLParameter is a value given to a SQL variable at query time. ID is the
+// "tableId|DE|variableName" id listed by getSqlParams and binds the variable
+// of one table; a bare VariableName binds it in every table of the query.
+type SQLParameter struct {
+	ID           string        `json:"id"`
+	VariableName string        `json:"variableName"`
+	Value        []interface{} `json:"value"`
+}
+
+// Matches reports whether the parameter binds the named variable of a table.
+func (p *SQLParameter) Matches(tableID int64, name string) bool {
+	if p.ID != "" {
+		return strings.EqualFold(p.ID, fmt.Sprintf("%d|DE|%s", tableID, name))
+	}
+	return strings.EqualFold(strings.TrimSpace(p.VariableName), name)
+}
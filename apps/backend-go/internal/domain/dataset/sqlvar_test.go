@@ -0,0 +1,50 @@
+package dataset
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestSQLVariableDeType(t *testing.T) {
+	cases := []struct {
+		name     string
+		types    []string
+		expected int
+	}{
+		{name: "datetime", types: []string{"DATETIME"}, expected: 1},
+		{name: "double", types: []string{"DOUBLE"}, expected: 3},
+		{name: "bigint", types: []string{"BIGINT"}, expected: 2},
+		{name: "text", types: []string{"TEXT"}, expected: 0},
+	}
+
+	for _, tc := range cases {
+		if actual := SQLVariableDeType(tc.types); actual != tc.expected {
+			t.Fatalf("%s expected %d, got %d", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestCoreDatasetTable_ParseSQL(t *testing.T) {
+	info := `{"table":"t","sql":"` + base64.StdEncoding.EncodeToString([]byte(" SELECT 1; ")) + `"}`
+	table := &CoreDatasetTable{ID: 1, Info: &info}
+	sql, err := table.ParseSQL()
+	if err != nil || sql != "SELECT 1" {
+		t.Fatalf("ParseSQL = %q, %v", sql, err)
+	}
+
+	empty := `{"table":"t","sql":""}`
+	if _, err = (&CoreDatasetTable{ID: 2, Info: &empty}).ParseSQL(); err == nil {
+		t.Fatal("expected empty sql error")
+	}
+}
+
+func TestSQLParameter_Matches(t *testing.T) {
+	byID := SQLParameter{ID: "7|DE|region"}
+	if !byID.Matches(7, "region") || byID.Matches(8, "region") {
+		t.Fatal("unexpected match for a parameter bound by id")
+	}
+	byName := SQLParameter{VariableName: "Region"}
+	if !byName.Matches(8, "region") {
+		t.Fatal("expected a bare name to match every table")
+	}
+}
@@ -0,0 +1,204 @@
+package dataset
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// CoreDatasetVersion is a snapshot of a dataset taken when it is saved.
+// Versions of a dataset are numbered from 1.
+type CoreDatasetVersion struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	DatasetGroupID int64  `gorm:"column:dataset_group_id;index" json:"datasetGroupId"`
+	Version        int    `gorm:"column:version" json:"version"`
+	Snapshot       string `gorm:"column:snapshot;type:longtext" json:"-"`
+	CreateTime     int64  `gorm:"column:create_time" json:"createTime"`
+}
+
+func (CoreDatasetVersion) TableName() string {
+	return "core_dataset_version"
+}
+
+// Snapshot is the definition of a dataset as a version records it: the
+// group with its model, its tables and the fields that do not belong to a
+// chart.
+type Snapshot struct {
+	Group  *CoreDatasetGroup        `json:"group"`
+	Tables []*CoreDatasetTable      `json:"tables"`
+	Fields []*CoreDatasetTableField `json:"fields"`
+}
+
+// ParseSnapshot reads the snapshot of a version.
+func ParseSnapshot(raw string) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+type VersionDiffRequest struct {
+	DatasetGroupID int64 `json:"datasetGroupId" binding:"required"`
+	From           int   `json:"from" binding:"required"`
+	To             int   `json:"to" binding:"required"`
+}
+
+type VersionRollbackRequest struct {
+	DatasetGroupID int64 `json:"datasetGroupId" binding:"required"`
+	Version        int   `json:"version" binding:"required"`
+	// DryRun reports what the rollback would remove without applying it.
+	DryRun bool `json:"dryRun"`
+}
+
+// FieldChange is a field added, removed or retyped between two versions.
+type FieldChange struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	OldDeType *int   `json:"oldDeType,omitempty"`
+	NewDeType *int   `json:"newDeType,omitempty"`
+}
+
+// SQLChange is a custom SQL table whose query changed between two
+// versions. The old or the new SQL is empty when the table was added or
+// removed.
+type SQLChange struct {
+	TableID int64  `json:"tableId"`
+	Name    string `json:"name"`
+	OldSQL  string `json:"oldSql"`
+	NewSQL  string `json:"newSql"`
+}
+
+type VersionDiff struct {
+	From          int           `json:"from"`
+	To            int           `json:"to"`
+	AddedFields   []FieldChange `json:"addedFields"`
+	RemovedFields []FieldChange `json:"removedFields"`
+	RetypedFields []FieldChange `json:"retypedFields"`
+	SQLChanges    []SQLChange   `json:"sqlChanges"`
+	ModelChanged  bool          `json:"modelChanged"`
+}
+
+// ChartImpact is a chart that references fields a rollback removes.
+type ChartImpact struct {
+	ID       int64   `json:"id"`
+	Title    string  `json:"title"`
+	FieldIDs []int64 `json:"fieldIds"`
+}
+
+type VersionRollbackResponse struct {
+	// Version is the version recorded for the rolled back dataset; it is 0
+	// for a dry run.
+	Version        int           `json:"version"`
+	RemovedFields  []FieldChange `json:"removedFields"`
+	AffectedCharts []ChartImpact `json:"affectedCharts"`
+}
+
+// DiffSnapshots compares the snapshots of two versions. Fields are matched
+// by id; a field is retyped when its deType changed.
+func DiffSnapshots(from *Snapshot, to *Snapshot) *VersionDiff {
+	diff := &VersionDiff{
+		AddedFields:   []FieldChange{},
+		RemovedFields: []FieldChange{},
+		RetypedFields: []FieldChange{},
+		SQLChanges:    []SQLChange{},
+	}
+
+	oldFields := fieldsByID(from.Fields)
+	newFields := fieldsByID(to.Fields)
+	for id, field := range newFields {
+		old, ok := oldFields[id]
+		if !ok {
+			diff.AddedFields = append(diff.AddedFields, FieldChange{ID: id, Name: field.DisplayName(), NewDeType: field.DeType})
+			continue
+		}
+		if intValue(old.DeType) != intValue(field.DeType) {
+			diff.RetypedFields = append(diff.RetypedFields, FieldChange{
+				ID: id, Name: field.DisplayName(), OldDeType: old.DeType, NewDeType: field.DeType,
+			})
+		}
+	}
+	for id, field := range oldFields {
+		if _, ok := newFields[id]; !ok {
+			diff.RemovedFields = append(diff.RemovedFields, FieldChange{ID: id, Name: field.DisplayName(), OldDeType: field.DeType})
+		}
+	}
+	for _, changes := range [][]FieldChange{diff.AddedFields, diff.RemovedFields, diff.RetypedFields} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	}
+
+	oldTables := tablesByID(from.Tables)
+	newTables := tablesByID(to.Tables)
+	ids := make([]int64, 0, len(oldTables)+len(newTables))
+	for id := range oldTables {
+		ids = append(ids, id)
+	}
+	for id := range newTables {
+		if _, ok := oldTables[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		oldSQL, oldName := tableSQL(oldTables[id])
+		newSQL, newName := tableSQL(newTables[id])
+		if oldSQL == newSQL {
+			continue
+		}
+		name := newName
+		if name == "" {
+			name = oldName
+		}
+		diff.SQLChanges = append(diff.SQLChanges, SQLChange{TableID: id, Name: name, OldSQL: oldSQL, NewSQL: newSQL})
+	}
+
+	diff.ModelChanged = groupInfo(from.Group) != groupInfo(to.Group)
+	return diff
+}
+
+func fieldsByID(fields []*CoreDatasetTableField) map[int64]*CoreDatasetTableField {
+	result := make(map[int64]*CoreDatasetTableField, len(fields))
+	for _, field := range fields {
+		result[field.ID] = field
+	}
+	return result
+}
+
+func tablesByID(tables []*CoreDatasetTable) map[int64]*CoreDatasetTable {
+	result := make(map[int64]*CoreDatasetTable, len(tables))
+	for _, table := range tables {
+		result[table.ID] = table
+	}
+	return result
+}
+
+// tableSQL returns the query and name of a custom SQL table; other tables
+// have no SQL.
+func tableSQL(table *CoreDatasetTable) (string, string) {
+	if table == nil || table.Type == nil || *table.Type != TableTypeSQL {
+		return "", ""
+	}
+	query, err := table.ParseSQL()
+	if err != nil {
+		return "", ""
+	}
+	name := ""
+	if table.Name != nil {
+		name = *table.Name
+	}
+	return strings.TrimSpace(query), name
+}
+
+func groupInfo(group *CoreDatasetGroup) string {
+	if group == nil || group.Info == nil {
+		return ""
+	}
+	return *group.Info
+}
+
+func intValue(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,51 @@
+package dataset
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	field := func(id int64, name string, deType int) *CoreDatasetTableField {
+		return &CoreDatasetTableField{ID: id, Name: &name, DeType: &deType}
+	}
+	sqlTable := func(id int64, query string) *CoreDatasetTable {
+		name, tableType := "orders", TableTypeSQL
+		info := `{"table":"orders","sql":"` + base64.StdEncoding.EncodeToString([]byte(query)) + `"}`
+		return &CoreDatasetTable{ID: id, Name: &name, Type: &tableType, Info: &info}
+	}
+	oldInfo, newInfo := `{"union":[]}`, `{"union":[{}]}`
+
+	from := &Snapshot{
+		Group:  &CoreDatasetGroup{Info: &oldInfo},
+		Tables: []*CoreDatasetTable{sqlTable(1, "SELECT a, b FROM t")},
+		Fields: []*CoreDatasetTableField{field(10, "a", 0), field(11, "b", 0), field(12, "c", 2)},
+	}
+	to := &Snapshot{
+		Group:  &CoreDatasetGroup{Info: &newInfo},
+		Tables: []*CoreDatasetTable{sqlTable(1, "SELECT a, d FROM t")},
+		Fields: []*CoreDatasetTableField{field(10, "a", 1), field(12, "c", 2), field(13, "d", 3)},
+	}
+
+	diff := DiffSnapshots(from, to)
+	if len(diff.AddedFields) != 1 || diff.AddedFields[0].ID != 13 || *diff.AddedFields[0].NewDeType != 3 {
+		t.Fatalf("added = %+v", diff.AddedFields)
+	}
+	if len(diff.RemovedFields) != 1 || diff.RemovedFields[0].ID != 11 || diff.RemovedFields[0].Name != "b" {
+		t.Fatalf("removed = %+v", diff.RemovedFields)
+	}
+	if len(diff.RetypedFields) != 1 || *diff.RetypedFields[0].OldDeType != 0 || *diff.RetypedFields[0].NewDeType != 1 {
+		t.Fatalf("retyped = %+v", diff.RetypedFields)
+	}
+	if len(diff.SQLChanges) != 1 || diff.SQLChanges[0].OldSQL != "SELECT a, b FROM t" || diff.SQLChanges[0].NewSQL != "SELECT a, d FROM t" {
+		t.Fatalf("sql changes = %+v", diff.SQLChanges)
+	}
+	if !diff.ModelChanged {
+		t.Fatal("expected model change")
+	}
+
+	same := DiffSnapshots(to, to)
+	if len(same.AddedFields)+len(same.RemovedFields)+len(same.RetypedFields)+len(same.SQLChanges) != 0 || same.ModelChanged {
+		t.Fatalf("expected no changes, got %+v", same)
+	}
+}
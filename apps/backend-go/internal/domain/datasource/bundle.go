@@ -0,0 +1,82 @@
+package datasource
+
+import "dataease/backend/internal/domain/dataset"
+
+// BundleVersion is the format version written into exported bundles.
+const BundleVersion = 1
+
+// Name conflict strategies of a bundle import.
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+// Outcomes of the items of a bundle import.
+const (
+	ImportCreated     = "created"
+	ImportSkipped     = "skipped"
+	ImportOverwritten = "overwritten"
+	ImportFailed      = "failed"
+)
+
+// Bundle is a portable copy of a datasource subtree. IDs are those of the
+// exporting environment and only link the entries of the bundle together.
+type Bundle struct {
+	Version     int                `json:"version"`
+	ExportTime  int64              `json:"exportTime"`
+	Datasources []BundleDatasource `json:"datasources"`
+	Datasets    []BundleDataset    `json:"datasets,omitempty"`
+}
+
+// BundleDatasource is one datasource or folder of a bundle. PID is 0 for the
+// root of the exported subtree. Secrets in Configuration are placeholders.
+type BundleDatasource struct {
+	ID             int64   `json:"id"`
+	PID            int64   `json:"pid"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Description    *string `json:"description,omitempty"`
+	EditType       *string `json:"editType,omitempty"`
+	Configuration  *string `json:"configuration,omitempty"`
+	EnableDataFill *bool   `json:"enableDataFill,omitempty"`
+}
+
+// BundleDataset is a dataset whose tables all read from bundled datasources.
+type BundleDataset struct {
+	ID     int64                            `json:"id"`
+	Name   string                           `json:"name"`
+	Type   *string                          `json:"type,omitempty"`
+	Tables []*dataset.CoreDatasetTable      `json:"tables"`
+	Fields []*dataset.CoreDatasetTableField `json:"fields"`
+}
+
+type ExportRequest struct {
+	ID           int64 `json:"id"`
+	WithDatasets bool  `json:"withDatasets"`
+}
+
+// ImportRequest places the datasources of a bundle under PID and its
+// datasets under DatasetPID. Secrets resolves the placeholders by key.
+type ImportRequest struct {
+	Bundle     *Bundle           `json:"bundle"`
+	PID        int64             `json:"pid"`
+	DatasetPID int64             `json:"datasetPid"`
+	Conflict   string            `json:"conflict"`
+	Secrets    map[string]string `json:"secrets"`
+	// CreateBy is the username of the importer, set by the handler.
+	CreateBy string `json:"-"`
+}
+
+type ImportItem struct {
+	Kind     string `json:"kind"`
+	SourceID int64  `json:"sourceId"`
+	ID       int64  `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	Message  string `json:"message,omitempty"`
+}
+
+type ImportResult struct {
+	Items []ImportItem `json:"items"`
+}
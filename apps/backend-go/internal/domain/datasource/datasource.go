@@ -1,11 +1,39 @@
 package datasource
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var jdbcHostPortPattern = regexp.MustCompile(`(?i)://([^:/?#]+):(\d+)`)
+
 const (
 	StatusSuccess = "Success"
 	StatusError   = "Error"
 
 	TypeFolder = "folder"
 	TypeExcel  = "Excel"
+	TypeAPI    = "API"
+
+	EditTypeReplace = "0"
+	EditTypeAppend  = "1"
+)
+
+// Validation failure categories reported in ValidateResponse.ErrorType.
+const (
+	ErrorTypeConfig           = "config"
+	ErrorTypeNetwork          = "network"
+	ErrorTypeAuth             = "auth"
+	ErrorTypeDatabaseNotFound = "database_not_found"
+	ErrorTypePermission       = "permission"
+	ErrorTypeTLS              = "tls"
+	ErrorTypeHostKey          = "host_key"
+	ErrorTypeTimeout          = "timeout"
+	ErrorTypeUnknown          = "unknown"
 )
 
 type CoreDatasource struct {
@@ -31,6 +59,147 @@ func (CoreDatasource) TableName() string {
 	return "core_datasource"
 }
 
+// Sync task schedules (sync_rate), simple interval units and end limits.
+const (
+	SyncRateRightNow   = "RIGHTNOW"
+	SyncRateCron       = "CRON"
+	SyncRateSimpleCron = "SIMPLE_CRON"
+
+	SimpleCronMinute = "minute"
+	SimpleCronHour   = "hour"
+	SimpleCronDay    = "day"
+
+	EndLimitNone = "0"
+	EndLimitTime = "1"
+
+	UpdateTypeFull        = "full"
+	UpdateTypeIncremental = "incremental"
+
+	// LastValuePlaceholder is replaced by the stored watermark in custom
+	// incremental SQL and in the request of incremental API tables.
+	LastValuePlaceholder = "${last_value}"
+)
+
+// Sync task states, run results and log trigger types.
+const (
+	TaskStatusWaiting = "WaitingForExecution"
+	TaskStatusRunning = "UnderExecution"
+	TaskStatusPaused  = "Suspend"
+	TaskStatusStopped = "Stopped"
+
+	ExecStatusRunning   = "UnderExecution"
+	ExecStatusCompleted = "Completed"
+	ExecStatusError     = "Error"
+
+	TriggerCron   = "Cron"
+	TriggerManual = "Manual"
+)
+
+// CoreDatasourceTask is a scheduled sync of a datasource into the engine.
+type CoreDatasourceTask struct {
+	ID              int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	DsID            int64  `gorm:"column:ds_id" json:"dsId"`
+	Name            string `gorm:"column:name" json:"name"`
+	UpdateType      string `gorm:"column:update_type" json:"updateType"`
+	StartTime       int64  `gorm:"column:start_time" json:"startTime"`
+	SyncRate        string `gorm:"column:sync_rate" json:"syncRate"`
+	Cron            string `gorm:"column:cron" json:"cron"`
+	SimpleCronValue int64  `gorm:"column:simple_cron_value" json:"simpleCronValue"`
+	SimpleCronType  string `gorm:"column:simple_cron_type" json:"simpleCronType"`
+	EndLimit        string `gorm:"column:end_limit" json:"endLimit"`
+	EndTime         int64  `gorm:"column:end_time" json:"endTime"`
+	CreateTime      int64  `gorm:"column:create_time" json:"createTime"`
+	LastExecTime    int64  `gorm:"column:last_exec_time" json:"lastExecTime"`
+	LastExecStatus  string `gorm:"column:last_exec_status" json:"lastExecStatus"`
+	ExtraData       string `gorm:"column:extra_data" json:"extraData"`
+	TaskStatus      string `gorm:"column:task_status" json:"taskStatus"`
+}
+
+func (CoreDatasourceTask) TableName() string {
+	return "core_datasource_task"
+}
+
+// CoreDatasourceTaskLog records one run of a sync task.
+type CoreDatasourceTaskLog struct {
+	ID                int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	DsID              int64  `gorm:"column:ds_id" json:"dsId"`
+	TaskID            int64  `gorm:"column:task_id" json:"taskId"`
+	StartTime         int64  `gorm:"column:start_time" json:"startTime"`
+	EndTime           int64  `gorm:"column:end_time" json:"endTime"`
+	TaskStatus        string `gorm:"column:task_status" json:"taskStatus"`
+	PhysicalTableName string `gorm:"column:table_name" json:"tableName"`
+	Info              string `gorm:"column:info" json:"info"`
+	CreateTime        int64  `gorm:"column:create_time" json:"createTime"`
+	TriggerType       string `gorm:"column:trigger_type" json:"triggerType"`
+}
+
+func (CoreDatasourceTaskLog) TableName() string {
+	return "core_datasource_task_log"
+}
+
+// SyncTaskExtra is the extra_data of a sync task. TableName selects the API
+// table, or the source table of SQL datasources which are extracted into
+// TargetTable. TargetTable and LastValue are written by runs only. Incremental runs only load rows whose IncrementalField is
+// greater than LastValue, or the rows of IncrementalSQL; they are upserted on
+// KeyFields or appended when no key is set.
+type SyncTaskExtra struct {
+	TableName        string   `json:"tableName"`
+	TargetTable      string   `json:"targetTable,omitempty"`
+	IncrementalField string   `json:"incrementalField"`
+	IncrementalSQL   string   `json:"incrementalSql"`
+	KeyFields        []string `json:"keyFields"`
+	LastValue        string   `json:"lastValue"`
+}
+
+// DecodeSyncTaskExtra parses the extra_data of a sync task.
+func DecodeSyncTaskExtra(raw string) (*SyncTaskExtra, error) {
+	extra := &SyncTaskExtra{}
+	if strings.TrimSpace(raw) == "" {
+		return extra, nil
+	}
+	if err := json.Unmarshal([]byte(raw), extra); err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
+// CoreDatasourceHealth records one health check of a datasource.
+type CoreDatasourceHealth struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	DsID      int64  `gorm:"column:ds_id;index" json:"dsId"`
+	Status    string `gorm:"column:status;size:50" json:"status"`
+	ErrorType string `gorm:"column:error_type;size:50" json:"errorType,omitempty"`
+	Message   string `gorm:"column:message;type:text" json:"message"`
+	Latency   int64  `gorm:"column:latency" json:"latency"`
+	CheckTime int64  `gorm:"column:check_time;index" json:"checkTime"`
+}
+
+func (CoreDatasourceHealth) TableName() string {
+	return "core_datasource_health"
+}
+
+type TaskWriteRequest struct {
+	ID              int64  `json:"id"`
+	DsID            int64  `json:"dsId"`
+	Name            string `json:"name"`
+	UpdateType      string `json:"updateType"`
+	SyncRate        string `json:"syncRate"`
+	StartTime       int64  `json:"startTime"`
+	Cron            string `json:"cron"`
+	SimpleCronValue int64  `json:"simpleCronValue"`
+	SimpleCronType  string `json:"simpleCronType"`
+	EndLimit        string `json:"endLimit"`
+	EndTime         int64  `json:"endTime"`
+	ExtraData       string `json:"extraData"`
+}
+
+type TaskLogListResponse struct {
+	List    []CoreDatasourceTaskLog `json:"list"`
+	Total   int64                   `json:"total"`
+	Current int                     `json:"current"`
+	Size    int                     `json:"size"`
+}
+
 type ListRequest struct {
 	Keyword *string `json:"keyword"`
 	Current int     `json:"current"`
@@ -51,8 +220,9 @@ type ValidateRequest struct {
 }
 
 type ValidateResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	ErrorType string `json:"errorType,omitempty"`
 }
 
 type TableRequest struct {
@@ -85,21 +255,288 @@ type PreviewDataResponse struct {
 }
 
 type WriteRequest struct {
-	ID             int64   `json:"id"`
-	PID            *int64  `json:"pid"`
-	Name           string  `json:"name"`
-	Description    *string `json:"description"`
-	Type           string  `json:"type"`
-	NodeType       string  `json:"nodeType"`
-	EditType       *string `json:"editType"`
-	Configuration  *string `json:"configuration"`
-	EnableDataFill *bool   `json:"enableDataFill"`
+	ID             int64        `json:"id"`
+	PID            *int64       `json:"pid"`
+	Name           string       `json:"name"`
+	Description    *string      `json:"description"`
+	Type           string       `json:"type"`
+	NodeType       string       `json:"nodeType"`
+	EditType       *string      `json:"editType"`
+	Configuration  *string      `json:"configuration"`
+	EnableDataFill *bool        `json:"enableDataFill"`
+	FileID         string       `json:"fileId"`
+	Sheets         []ExcelSheet `json:"sheets"`
+	// CreateBy is the username of the creator, set by the handler.
+	CreateBy string `json:"-"`
+}
+
+// ExcelField is one column of an uploaded sheet. Name is the physical column
+// in the engine table, OriginName the header read from the file.
+type ExcelField struct {
+	OriginName string `json:"originName"`
+	Name       string `json:"name"`
+	DeType     int    `json:"deType"`
+	Checked    bool   `json:"checked"`
+}
+
+// ExcelSheet is a sheet preview on upload and the sheet to table mapping
+// stored in the configuration of Excel datasources.
+type ExcelSheet struct {
+	TableName   string                   `json:"tableName"`
+	DeTableName string                   `json:"deTableName"`
+	Fields      []ExcelField             `json:"fields"`
+	Data        []map[string]interface{} `json:"data,omitempty"`
+	Total       int                      `json:"total"`
+}
+
+type ExcelFileData struct {
+	FileID   string       `json:"fileId"`
+	FileName string       `json:"fileName"`
+	Sheets   []ExcelSheet `json:"sheets"`
+}
+
+// ExcelConfig is the configuration stored for Excel datasources.
+type ExcelConfig struct {
+	FileName string       `json:"fileName"`
+	Sheets   []ExcelSheet `json:"sheets"`
 }
 
+type RemoteFileRequest struct {
+	URL      string `json:"url"`
+	UserName string `json:"userName"`
+	Passwd   string `json:"passwd"`
+}
+
+// API authentication modes.
+const (
+	APIAuthNone   = ""
+	APIAuthBasic  = "basic"
+	APIAuthBearer = "bearer"
+	APIAuthKey    = "apiKey"
+)
+
+// API pagination strategies.
+const (
+	APIPageNone   = ""
+	APIPageNumber = "page"
+	APIPageOffset = "offset"
+	APIPageCursor = "cursor"
+)
+
+// APIKeyValue is a request header or query parameter.
+type APIKeyValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// APIAuth holds the credentials of an API table. KeyIn is "header" (default)
+// or "query" for API key authentication.
+type APIAuth struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	KeyName  string `json:"keyName,omitempty"`
+	KeyValue string `json:"keyValue,omitempty"`
+	KeyIn    string `json:"keyIn,omitempty"`
+}
+
+// APIPagination describes how successive pages are requested. Page and
+// offset strategies stop on the first page returning fewer than PageSize
+// records; the cursor strategy stops once CursorPath resolves to nothing.
+type APIPagination struct {
+	Type        string `json:"type"`
+	PageParam   string `json:"pageParam,omitempty"`
+	SizeParam   string `json:"sizeParam,omitempty"`
+	StartPage   int    `json:"startPage,omitempty"`
+	PageSize    int    `json:"pageSize,omitempty"`
+	CursorParam string `json:"cursorParam,omitempty"`
+	CursorPath  string `json:"cursorPath,omitempty"`
+	MaxPages    int    `json:"maxPages,omitempty"`
+}
+
+// APIField maps a JSONPath, evaluated against each record, onto a column.
+type APIField struct {
+	Name     string `json:"name"`
+	JSONPath string `json:"jsonPath"`
+	DeType   int    `json:"deType"`
+}
+
+// APIDefinition is one table of an API datasource. The configuration of API
+// datasources is a JSON array of definitions.
+type APIDefinition struct {
+	Name        string        `json:"name"`
+	DeTableName string        `json:"deTableName"`
+	URL         string        `json:"url"`
+	Method      string        `json:"method"`
+	Headers     []APIKeyValue `json:"headers"`
+	Params      []APIKeyValue `json:"params"`
+	Body        string        `json:"body"`
+	Auth        APIAuth       `json:"auth"`
+	Pagination  APIPagination `json:"pagination"`
+	RootPath    string        `json:"rootPath"`
+	Fields      []APIField    `json:"fields"`
+	Timeout     int           `json:"timeout"`
+}
+
+// APICheckResponse is the result of checkApiDatasource: the definition with
+// its fields filled in and a preview of the extracted rows.
+type APICheckResponse struct {
+	Definition APIDefinition            `json:"definition"`
+	Data       []map[string]interface{} `json:"data"`
+	Total      int                      `json:"total"`
+}
+
+// APISyncRequest selects the API datasource, and optionally the table, to
+// refresh.
+type APISyncRequest struct {
+	DatasourceID int64  `json:"datasourceId"`
+	TableName    string `json:"tableName"`
+}
+
+// DecodeAPIDefinitions parses the configuration of an API datasource.
+func DecodeAPIDefinitions(raw string) ([]APIDefinition, error) {
+	var defs []APIDefinition
+	if strings.TrimSpace(raw) == "" {
+		return defs, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// SSH authentication types and host key policies of a tunnel.
+const (
+	SSHTypePassword = "password"
+	SSHTypeKey      = "sshkey"
+
+	// SSHHostKeyStrict checks the bastion host key against SSHKnownHosts.
+	SSHHostKeyStrict = "strict"
+	// SSHHostKeyInsecure accepts any bastion host key.
+	SSHHostKeyInsecure = "insecure"
+)
+
 type ConnectionConfig struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	JDBCUrl  string `json:"jdbcUrl"`
 	Database string `json:"dataBase"`
 	Schema   string `json:"schema"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// SSH tunnel through a bastion host. SSHKnownHosts holds known_hosts
+	// lines for the bastion and is required by the strict policy.
+	UseSSH           bool   `json:"useSSH"`
+	SSHHost          string `json:"sshHost"`
+	SSHPort          int    `json:"sshPort"`
+	SSHUserName      string `json:"sshUserName"`
+	SSHType          string `json:"sshType"`
+	SSHPassword      string `json:"sshPassword"`
+	SSHKey           string `json:"sshKey"`
+	SSHKeyPassword   string `json:"sshKeyPassword"`
+	SSHHostKeyPolicy string `json:"sshHostKeyPolicy"`
+	SSHKnownHosts    string `json:"sshKnownHosts"`
+
+	// SSLMode is the PostgreSQL sslmode, disable when unset.
+	SSLMode string `json:"sslMode"`
+
+	// QueryTimeout bounds every query run on the datasource, in seconds.
+	QueryTimeout int `json:"queryTimeout"`
+}
+
+// RunningQuery is a statement currently executing on a datasource.
+type RunningQuery struct {
+	ID           string `json:"id"`
+	DatasourceID int64  `json:"datasourceId"`
+	UserID       int64  `json:"userId"`
+	Username     string `json:"username"`
+	SQL          string `json:"sql"`
+	StartTime    int64  `json:"startTime"`
+	Timeout      int64  `json:"timeout"`
+}
+
+// ConfigField describes one entry of a datasource type's connection form.
+// Type is one of the FieldType constants. Min and Max bound int fields and
+// Options lists the accepted values of a field.
+type ConfigField struct {
+	Name     string      `json:"name"`
+	Label    string      `json:"label"`
+	Type     string      `json:"type"`
+	Required bool        `json:"required"`
+	Secret   bool        `json:"secret,omitempty"`
+	Default  interface{} `json:"default,omitempty"`
+	Min      *int64      `json:"min,omitempty"`
+	Max      *int64      `json:"max,omitempty"`
+	Options  []string    `json:"options,omitempty"`
+	// RequiredUnless names a field whose value waives Required, as a JDBC
+	// URL does for the host and port.
+	RequiredUnless string `json:"requiredUnless,omitempty"`
+	// DependsOn names a bool field that enables this one; the field is not
+	// checked while it is false.
+	DependsOn string `json:"dependsOn,omitempty"`
+}
+
+// TypeInfo is one entry of the /datasource/types catalogue.
+type TypeInfo struct {
+	Type         string        `json:"type"`
+	Name         string        `json:"name"`
+	ConfigSchema []ConfigField `json:"configSchema"`
+}
+
+// DecodeConfig parses a datasource configuration that is stored either as
+// base64 encoded JSON (Java compatible) or as raw JSON.
+func DecodeConfig(raw string) (*ConnectionConfig, error) {
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		decoded = []byte(raw)
+	}
+
+	var cfg ConnectionConfig
+	if err = json.Unmarshal(decoded, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// HostPort returns the explicit host/port, falling back to the JDBC URL.
+func (c *ConnectionConfig) HostPort() (string, int) {
+	if c.Host != "" && c.Port > 0 {
+		return c.Host, c.Port
+	}
+
+	jdbc := strings.TrimSpace(c.JDBCUrl)
+	if jdbc == "" {
+		return "", 0
+	}
+
+	matches := jdbcHostPortPattern.FindStringSubmatch(jdbc)
+	if len(matches) != 3 {
+		return "", 0
+	}
+	port, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0
+	}
+	return matches[1], port
+}
+
+// DatabaseName returns the configured database, falling back to the path of
+// the JDBC URL.
+func (c *ConnectionConfig) DatabaseName() string {
+	if db := strings.TrimSpace(c.Database); db != "" {
+		return db
+	}
+
+	jdbc := strings.TrimSpace(c.JDBCUrl)
+	idx := strings.Index(jdbc, "://")
+	if idx < 0 {
+		return ""
+	}
+	parsed, err := url.Parse("tcp" + jdbc[idx:])
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(parsed.Path, "/")
 }
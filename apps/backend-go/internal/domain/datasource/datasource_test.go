@@ -1,6 +1,7 @@
 package datasource
 
 import (
+	"encoding/base64"
 	"testing"
 )
 
@@ -299,3 +300,50 @@ func TestConnectionConfig_Fields(t *testing.T) {
 		t.Errorf("Expected Database 'test', got '%s'", cfg.Database)
 	}
 }
+
+func TestDecodeConfig_Base64(t *testing.T) {
+	raw := `{"host":"127.0.0.1","port":3306}`
+	encoded := base64.StdEncoding.EncodeToString([]byte(raw))
+
+	cfg, err := DecodeConfig(encoded)
+	if err != nil {
+		t.Fatalf("DecodeConfig failed: %v", err)
+	}
+	if cfg.Host != "127.0.0.1" || cfg.Port != 3306 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestDecodeConfig_RawJSON(t *testing.T) {
+	cfg, err := DecodeConfig(`{"host":"db.local","port":5432,"username":"reader","password":"secret"}`)
+	if err != nil {
+		t.Fatalf("DecodeConfig failed: %v", err)
+	}
+	if cfg.Host != "db.local" || cfg.Port != 5432 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.Username != "reader" || cfg.Password != "secret" {
+		t.Fatalf("unexpected credentials: %+v", cfg)
+	}
+}
+
+func TestConnectionConfig_HostPortFromJDBCUrl(t *testing.T) {
+	cfg := &ConnectionConfig{JDBCUrl: "jdbc:mysql://10.0.0.8:3306/dataease"}
+	host, port := cfg.HostPort()
+
+	if host != "10.0.0.8" || port != 3306 {
+		t.Fatalf("unexpected host/port: %s:%d", host, port)
+	}
+}
+
+func TestConnectionConfig_DatabaseNameFromJDBCUrl(t *testing.T) {
+	cfg := &ConnectionConfig{JDBCUrl: "jdbc:postgresql://10.0.0.9:5432/warehouse?sslmode=disable"}
+	if name := cfg.DatabaseName(); name != "warehouse" {
+		t.Fatalf("expected database 'warehouse', got '%s'", name)
+	}
+
+	cfg.Database = "explicit"
+	if name := cfg.DatabaseName(); name != "explicit" {
+		t.Fatalf("expected database 'explicit', got '%s'", name)
+	}
+}
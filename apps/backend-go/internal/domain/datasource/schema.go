@@ -0,0 +1,176 @@
+package datasource
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value types of a ConfigField.
+const (
+	FieldTypeString   = "string"
+	FieldTypeText     = "text"
+	FieldTypePassword = "password"
+	FieldTypeInt      = "int"
+	FieldTypeBool     = "bool"
+)
+
+// FieldError is a configuration member rejected by its schema.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ConfigError lists every invalid member of a configuration.
+type ConfigError struct {
+	Fields []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// ValidateConfig checks a configuration, stored either as base64 encoded
+// JSON or as raw JSON, against the schema of its type. Members missing from
+// the schema are kept unchecked for compatibility with the Java forms.
+func ValidateConfig(schema []ConfigField, raw string) error {
+	values, err := decodeConfigObject(raw)
+	if err != nil {
+		return &ConfigError{Fields: []FieldError{{Field: "configuration", Message: err.Error()}}}
+	}
+
+	var errs []FieldError
+	for _, field := range schema {
+		if field.DependsOn != "" {
+			enabled, _ := boolValue(values[field.DependsOn])
+			if !enabled {
+				continue
+			}
+		}
+		value, present := values[field.Name]
+		if present && isBlank(value) {
+			present = false
+		}
+		if !present {
+			if field.Required && (field.RequiredUnless == "" || isBlank(values[field.RequiredUnless])) {
+				errs = append(errs, FieldError{Field: field.Name, Message: "is required"})
+			}
+			continue
+		}
+		if message := checkField(field, value); message != "" {
+			errs = append(errs, FieldError{Field: field.Name, Message: message})
+		}
+	}
+	if len(errs) > 0 {
+		return &ConfigError{Fields: errs}
+	}
+	return nil
+}
+
+func checkField(field ConfigField, value interface{}) string {
+	switch field.Type {
+	case FieldTypeInt:
+		n, ok := intValue(value)
+		if !ok {
+			return "must be an integer"
+		}
+		if field.Min != nil && n < *field.Min {
+			if field.Max != nil {
+				return fmt.Sprintf("must be between %d and %d", *field.Min, *field.Max)
+			}
+			return fmt.Sprintf("must be at least %d", *field.Min)
+		}
+		if field.Max != nil && n > *field.Max {
+			if field.Min != nil {
+				return fmt.Sprintf("must be between %d and %d", *field.Min, *field.Max)
+			}
+			return fmt.Sprintf("must be at most %d", *field.Max)
+		}
+		return checkOption(field, strconv.FormatInt(n, 10))
+	case FieldTypeBool:
+		if _, ok := boolValue(value); !ok {
+			return "must be a boolean"
+		}
+		return ""
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		return checkOption(field, text)
+	}
+}
+
+func checkOption(field ConfigField, value string) string {
+	if len(field.Options) == 0 {
+		return ""
+	}
+	for _, option := range field.Options {
+		if value == option {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(field.Options, ", ")
+}
+
+// intValue accepts JSON integers and the numeric strings sent by text inputs.
+func intValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+		f, err := v.Float64()
+		if err != nil || f != math.Trunc(f) {
+			return 0, false
+		}
+		return int64(f), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func boolValue(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	return false, false
+}
+
+func isBlank(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	text, ok := value.(string)
+	return ok && strings.TrimSpace(text) == ""
+}
+
+func decodeConfigObject(raw string) (map[string]interface{}, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("is required")
+	}
+	data := []byte(raw)
+	if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+		data = decoded
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil || values == nil {
+		return nil, fmt.Errorf("must be a JSON object")
+	}
+	return values, nil
+}
@@ -0,0 +1,81 @@
+package datasource
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testSchema() []ConfigField {
+	one, max := int64(1), int64(65535)
+	return []ConfigField{
+		{Name: "host", Type: FieldTypeString, Required: true, RequiredUnless: "jdbcUrl"},
+		{Name: "port", Type: FieldTypeInt, Required: true, RequiredUnless: "jdbcUrl", Min: &one, Max: &max},
+		{Name: "jdbcUrl", Type: FieldTypeString},
+		{Name: "urlType", Type: FieldTypeString, Options: []string{"hostName", "jdbcUrl"}},
+		{Name: "password", Type: FieldTypePassword, Secret: true},
+		{Name: "useSSH", Type: FieldTypeBool},
+		{Name: "sshHost", Type: FieldTypeString, Required: true, DependsOn: "useSSH"},
+	}
+}
+
+func fieldErrors(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	fields := make(map[string]string)
+	for _, field := range cfgErr.Fields {
+		fields[field.Field] = field.Message
+	}
+	return fields
+}
+
+func TestValidateConfig_Accepts(t *testing.T) {
+	valid := []string{
+		`{"host": "db", "port": 3306, "password": "******", "extra": [1]}`,
+		`{"host": "db", "port": "3306", "useSSH": false}`,
+		`{"jdbcUrl": "jdbc:mysql://db:3306/demo", "port": ""}`,
+		base64.StdEncoding.EncodeToString([]byte(`{"host": "db", "port": 1, "useSSH": true, "sshHost": "jump"}`)),
+	}
+	for _, raw := range valid {
+		if err := ValidateConfig(testSchema(), raw); err != nil {
+			t.Errorf("%s: unexpected error %v", raw, err)
+		}
+	}
+}
+
+func TestValidateConfig_ReportsEveryField(t *testing.T) {
+	err := ValidateConfig(testSchema(), `{"port": 70000, "urlType": "dsn", "password": 5, "useSSH": true}`)
+	fields := fieldErrors(t, err)
+	want := map[string]string{
+		"host":     "is required",
+		"port":     "must be between 1 and 65535",
+		"urlType":  "must be one of hostName, jdbcUrl",
+		"password": "must be a string",
+		"sshHost":  "is required",
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("expected %d field errors, got %v", len(want), fields)
+	}
+	for name, message := range want {
+		if fields[name] != message {
+			t.Errorf("%s: expected %q, got %q", name, message, fields[name])
+		}
+	}
+
+	fields = fieldErrors(t, ValidateConfig(testSchema(), `{"host": "db", "port": 1.5, "useSSH": "maybe"}`))
+	if fields["port"] != "must be an integer" || fields["useSSH"] != "must be a boolean" || len(fields) != 2 {
+		t.Errorf("unexpected field errors %v", fields)
+	}
+}
+
+func TestValidateConfig_RejectsNonObjects(t *testing.T) {
+	for _, raw := range []string{"", "[]", "not json"} {
+		fields := fieldErrors(t, ValidateConfig(testSchema(), raw))
+		if _, ok := fields["configuration"]; !ok {
+			t.Errorf("%q: expected a configuration error, got %v", raw, fields)
+		}
+	}
+}
@@ -1,5 +1,16 @@
 package export
 
+// Statuses of an export task.
+const (
+	StatusPending    = "PENDING"
+	StatusInProgress = "IN_PROGRESS"
+	StatusSuccess    = "SUCCESS"
+	StatusFailed     = "FAILED"
+)
+
+// FromTypeDataset marks the tasks exporting the rows of a dataset.
+const FromTypeDataset = "dataset"
+
 type ExportTask struct {
 	ID                string  `json:"id"`
 	UserID            int64   `json:"userId"`
@@ -15,6 +26,8 @@ type ExportTask struct {
 	ExportMachineName string  `json:"exportMachineName"`
 	ExportFromName    string  `json:"exportFromName"`
 	OrgName           string  `json:"orgName"`
+	// Params is the request of the task, kept to run it again on retry.
+	Params string `json:"-"`
 }
 
 type ExportTasksRequest struct{}
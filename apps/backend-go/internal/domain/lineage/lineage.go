@@ -0,0 +1,98 @@
+package lineage
+
+import "fmt"
+
+// Node types, from the most upstream to the most downstream.
+const (
+	TypeDatasource    = "datasource"
+	TypeDatasetTable  = "dataset_table"
+	TypeDataset       = "dataset"
+	TypeChart         = "chart"
+	TypeVisualization = "visualization"
+)
+
+const (
+	DirectionRoot       = "root"
+	DirectionUpstream   = "upstream"
+	DirectionDownstream = "downstream"
+	DirectionBoth       = "both"
+)
+
+// Node is one resource of the lineage graph. Direction tells on which side
+// of the requested resource it was found.
+type Node struct {
+	Key       string `json:"key"`
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Owner     string `json:"owner"`
+	Direction string `json:"direction"`
+}
+
+// Edge links a resource to a resource that depends on it.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Graph struct {
+	Root  string `json:"root"`
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Link is a resource found next to another one while walking the graph.
+type Link struct {
+	ID     int64
+	Name   string
+	Owner  string
+	LinkID int64
+}
+
+// DeleteImpact is the delete confirmation of a datasource or dataset: the
+// resources that break when it is deleted.
+type DeleteImpact struct {
+	InUse  bool   `json:"inUse"`
+	Impact []Node `json:"impact"`
+}
+
+type Request struct {
+	Type      string `form:"type" json:"type"`
+	ID        int64  `form:"id" json:"id"`
+	Direction string `form:"direction" json:"direction"`
+}
+
+// Key identifies a node across resource types.
+func Key(nodeType string, id int64) string {
+	return fmt.Sprintf("%s:%d", nodeType, id)
+}
+
+// Downstream returns the type of the resources depending on nodeType.
+func Downstream(nodeType string) string {
+	switch nodeType {
+	case TypeDatasource:
+		return TypeDatasetTable
+	case TypeDatasetTable:
+		return TypeDataset
+	case TypeDataset:
+		return TypeChart
+	case TypeChart:
+		return TypeVisualization
+	}
+	return ""
+}
+
+// Upstream returns the type of the resources nodeType depends on.
+func Upstream(nodeType string) string {
+	switch nodeType {
+	case TypeVisualization:
+		return TypeChart
+	case TypeChart:
+		return TypeDataset
+	case TypeDataset:
+		return TypeDatasetTable
+	case TypeDatasetTable:
+		return TypeDatasource
+	}
+	return ""
+}
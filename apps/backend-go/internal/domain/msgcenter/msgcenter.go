@@ -44,3 +44,25 @@ type ReadBatchResponse struct {
 	Success bool `json:"success"`
 	Updated int  `json:"updated"`
 }
+
+// CoreMessage is a message delivered to one user.
+type CoreMessage struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	UserID     int64  `gorm:"column:user_id;index" json:"userId"`
+	Title      string `gorm:"column:title;size:255" json:"title"`
+	Content    string `gorm:"column:content;type:text" json:"content"`
+	Type       string `gorm:"column:type;size:50" json:"type"`
+	Level      string `gorm:"column:level;size:20" json:"level"`
+	CreateTime int64  `gorm:"column:create_time;index" json:"createTime"`
+}
+
+func (CoreMessage) TableName() string {
+	return "core_message"
+}
+
+const (
+	TypeDatasource = "datasource"
+
+	LevelInfo  = "info"
+	LevelError = "error"
+)
@@ -0,0 +1,220 @@
+package permission
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 列权限规则类型常量
+const (
+	ColumnRuleHide      = "hide"
+	ColumnRuleFull      = "full"
+	ColumnRuleKeepFirst = "keep_first"
+	ColumnRuleKeepLast  = "keep_last"
+	ColumnRulePattern   = "pattern"
+)
+
+// 列权限授权目标类型常量
+const (
+	TargetUser = "user"
+	TargetRole = "role"
+	TargetOrg  = "org"
+)
+
+// fullMask 完全脱敏后的值，不暴露原值长度
+const fullMask = "******"
+
+// CoreColumnPermission 列权限规则 - 映射 core_dataset_column_permission 表
+// 对某个用户、角色或组织隐藏或脱敏数据集字段
+type CoreColumnPermission struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	DatasetGroupID int64  `gorm:"column:dataset_group_id;index" json:"datasetGroupId"`
+	FieldID        int64  `gorm:"column:field_id" json:"fieldId"`
+	TargetType     string `gorm:"column:target_type;size:20" json:"targetType"`
+	TargetID       int64  `gorm:"column:target_id" json:"targetId"`
+	RuleType       string `gorm:"column:rule_type;size:20" json:"ruleType"`
+	// Length 保留首尾字符数 (keep_first, keep_last)
+	Length int `gorm:"column:length" json:"length"`
+	// Pattern 自定义正则 (pattern)，匹配部分替换为 Replacement，支持 $1 引用分组
+	Pattern     string `gorm:"column:pattern;size:255" json:"pattern"`
+	Replacement string `gorm:"column:replacement;size:255" json:"replacement"`
+	UpdateTime  int64  `gorm:"column:update_time" json:"updateTime"`
+}
+
+func (CoreColumnPermission) TableName() string {
+	return "core_dataset_column_permission"
+}
+
+// Validate 校验规则的目标与参数
+func (p *CoreColumnPermission) Validate() error {
+	if p.DatasetGroupID <= 0 || p.FieldID <= 0 {
+		return fmt.Errorf("dataset and field are required")
+	}
+	switch p.TargetType {
+	case TargetUser, TargetRole, TargetOrg:
+	default:
+		return fmt.Errorf("unknown target type: %s", p.TargetType)
+	}
+	if p.TargetID <= 0 {
+		return fmt.Errorf("target id is required")
+	}
+	switch p.RuleType {
+	case ColumnRuleHide, ColumnRuleFull:
+	case ColumnRuleKeepFirst, ColumnRuleKeepLast:
+		if p.Length < 0 {
+			return fmt.Errorf("length must not be negative")
+		}
+	case ColumnRulePattern:
+		if strings.TrimSpace(p.Pattern) == "" {
+			return fmt.Errorf("pattern is required")
+		}
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown rule type: %s", p.RuleType)
+	}
+	return nil
+}
+
+// Mask 按规则脱敏一个值；nil 保持不变，其他值按文本脱敏
+func (p *CoreColumnPermission) Mask(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	text := maskText(v)
+	switch p.RuleType {
+	case ColumnRuleKeepFirst, ColumnRuleKeepLast:
+		runes := []rune(text)
+		if p.Length >= len(runes) {
+			return strings.Repeat("*", len(runes))
+		}
+		if p.RuleType == ColumnRuleKeepFirst {
+			return string(runes[:p.Length]) + strings.Repeat("*", len(runes)-p.Length)
+		}
+		return strings.Repeat("*", len(runes)-p.Length) + string(runes[len(runes)-p.Length:])
+	case ColumnRulePattern:
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fullMask
+		}
+		return re.ReplaceAllString(text, p.Replacement)
+	}
+	return fullMask
+}
+
+func maskText(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		if utf8.Valid(t) {
+			return string(t)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// ColumnMasks 某用户在一个数据集上生效的列权限，按字段ID索引
+type ColumnMasks map[int64]*CoreColumnPermission
+
+// columnRuleRank 多条规则作用于同一字段时，取最严格的一条
+var columnRuleRank = map[string]int{
+	ColumnRuleHide:      4,
+	ColumnRuleFull:      3,
+	ColumnRulePattern:   2,
+	ColumnRuleKeepFirst: 1,
+	ColumnRuleKeepLast:  1,
+}
+
+// NewColumnMasks 合并作用于同一用户的规则
+func NewColumnMasks(rules []*CoreColumnPermission) ColumnMasks {
+	masks := make(ColumnMasks, len(rules))
+	for _, rule := range rules {
+		if current, ok := masks[rule.FieldID]; !ok || columnRuleRank[rule.RuleType] > columnRuleRank[current.RuleType] {
+			masks[rule.FieldID] = rule
+		}
+	}
+	return masks
+}
+
+// Hidden 字段是否对用户隐藏
+func (m ColumnMasks) Hidden(fieldID int64) bool {
+	rule, ok := m[fieldID]
+	return ok && rule.RuleType == ColumnRuleHide
+}
+
+// Masked 字段是否脱敏 (不含隐藏)
+func (m ColumnMasks) Masked(fieldID int64) bool {
+	rule, ok := m[fieldID]
+	return ok && rule.RuleType != ColumnRuleHide
+}
+
+// Value 脱敏字段的一个值
+func (m ColumnMasks) Value(fieldID int64, v interface{}) interface{} {
+	if rule, ok := m[fieldID]; ok && rule.RuleType != ColumnRuleHide {
+		return rule.Mask(v)
+	}
+	return v
+}
+
+// Rows 按字段所在的列处理查询结果：删除隐藏列，脱敏其他受控列
+// columns 为字段ID到结果列名的映射
+func (m ColumnMasks) Rows(rows []map[string]interface{}, columns map[int64]string) {
+	for fieldID, rule := range m {
+		column, ok := columns[fieldID]
+		if !ok || column == "" {
+			continue
+		}
+		for _, row := range rows {
+			value, exists := row[column]
+			if !exists {
+				continue
+			}
+			if rule.RuleType == ColumnRuleHide {
+				delete(row, column)
+			} else {
+				row[column] = rule.Mask(value)
+			}
+		}
+	}
+}
+
+// MaskSource 返回读取数据的用户在一个数据集上的列权限
+type MaskSource func(datasetGroupID int64) (ColumnMasks, error)
+
+// Derive 将规则扩展到引用受控字段的计算字段，可经由其他计算字段间接引用：
+// 引用隐藏字段的计算字段隐藏，引用脱敏字段的计算字段完全脱敏
+// refs 为计算字段ID到其公式引用字段ID的映射
+func (m ColumnMasks) Derive(refs map[int64][]int64) ColumnMasks {
+	if len(m) == 0 {
+		return m
+	}
+	derived := make(ColumnMasks, len(m))
+	for fieldID, rule := range m {
+		derived[fieldID] = rule
+	}
+	for changed := true; changed; {
+		changed = false
+		for fieldID, ids := range refs {
+			for _, id := range ids {
+				rule, ok := derived[id]
+				if !ok {
+					continue
+				}
+				ruleType := ColumnRuleFull
+				if rule.RuleType == ColumnRuleHide {
+					ruleType = ColumnRuleHide
+				}
+				if current, ok := derived[fieldID]; ok && columnRuleRank[current.RuleType] >= columnRuleRank[ruleType] {
+					continue
+				}
+				derived[fieldID] = &CoreColumnPermission{DatasetGroupID: rule.DatasetGroupID, FieldID: fieldID, RuleType: ruleType}
+				changed = true
+			}
+		}
+	}
+	return derived
+}
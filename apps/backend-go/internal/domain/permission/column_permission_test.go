@@ -0,0 +1,122 @@
+package permission
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestColumnPermissionMask(t *testing.T) {
+	cases := []struct {
+		rule CoreColumnPermission
+		in   interface{}
+		want interface{}
+	}{
+		{CoreColumnPermission{RuleType: ColumnRuleFull}, "13800138000", "******"},
+		{CoreColumnPermission{RuleType: ColumnRuleKeepFirst, Length: 3}, "13800138000", "138********"},
+		{CoreColumnPermission{RuleType: ColumnRuleKeepLast, Length: 4}, "13800138000", "*******8000"},
+		{CoreColumnPermission{RuleType: ColumnRuleKeepLast, Length: 2}, "张三丰", "*三丰"},
+		{CoreColumnPermission{RuleType: ColumnRuleKeepFirst, Length: 5}, "abc", "***"},
+		{CoreColumnPermission{RuleType: ColumnRuleKeepFirst, Length: 1}, int64(4521), "4***"},
+		{CoreColumnPermission{RuleType: ColumnRuleFull}, nil, nil},
+	}
+	for _, tc := range cases {
+		if got := tc.rule.Mask(tc.in); got != tc.want {
+			t.Errorf("%s(%d) of %v = %v, want %v", tc.rule.RuleType, tc.rule.Length, tc.in, got, tc.want)
+		}
+	}
+}
+
+// TestColumnPermissionMask_EmailFixture checks a pattern rule against the
+// masked email the security contract fixture expects.
+func TestColumnPermissionMask_EmailFixture(t *testing.T) {
+	raw, err := os.ReadFile("../../../testdata/contract-diff/security-fixtures/column-masking/ns-007-email-masking.json")
+	if err != nil {
+		t.Fatalf("read fixture failed: %v", err)
+	}
+	var fixture struct {
+		ExpectedResponse struct {
+			DataValidation struct {
+				MaskedPattern string `json:"maskedPattern"`
+			} `json:"dataValidation"`
+		} `json:"expectedResponse"`
+	}
+	if err = json.Unmarshal(raw, &fixture); err != nil {
+		t.Fatalf("parse fixture failed: %v", err)
+	}
+	expected := regexp.MustCompile(fixture.ExpectedResponse.DataValidation.MaskedPattern)
+
+	rule := CoreColumnPermission{
+		DatasetGroupID: 1, FieldID: 2, TargetType: TargetRole, TargetID: 3,
+		RuleType: ColumnRulePattern, Pattern: `^([^@]{1,3})[^@]*(@.*)$`, Replacement: "$1***$2",
+	}
+	if err = rule.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, email := range []string{"alice@example.com", "bo@example.com"} {
+		if got := rule.Mask(email).(string); !expected.MatchString(got) {
+			t.Errorf("masked %s = %s, does not match %s", email, got, expected)
+		}
+	}
+}
+
+func TestColumnMasks(t *testing.T) {
+	masks := NewColumnMasks([]*CoreColumnPermission{
+		{FieldID: 1, RuleType: ColumnRuleKeepFirst, Length: 1},
+		{FieldID: 1, RuleType: ColumnRuleFull},
+		{FieldID: 2, RuleType: ColumnRuleHide},
+		{FieldID: 2, RuleType: ColumnRuleKeepLast, Length: 2},
+	})
+	if !masks.Masked(1) || masks.Hidden(1) || !masks.Hidden(2) || masks.Masked(2) || masks.Masked(3) {
+		t.Fatalf("the strictest rule of each field should win, got %+v", masks)
+	}
+
+	rows := []map[string]interface{}{{"name": "alice", "phone": "123", "city": "Paris"}}
+	masks.Rows(rows, map[int64]string{1: "name", 2: "phone", 3: "city"})
+	if len(rows[0]) != 2 || rows[0]["name"] != "******" || rows[0]["city"] != "Paris" {
+		t.Errorf("unexpected masked row: %v", rows[0])
+	}
+}
+
+func TestColumnPermissionValidate(t *testing.T) {
+	valid := CoreColumnPermission{DatasetGroupID: 1, FieldID: 2, TargetType: TargetUser, TargetID: 3, RuleType: ColumnRuleHide}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, invalid := range []CoreColumnPermission{
+		{DatasetGroupID: 1, FieldID: 2, TargetType: "group", TargetID: 3, RuleType: ColumnRuleHide},
+		{DatasetGroupID: 1, FieldID: 2, TargetType: TargetUser, TargetID: 3, RuleType: "blur"},
+		{DatasetGroupID: 1, FieldID: 2, TargetType: TargetUser, TargetID: 3, RuleType: ColumnRulePattern, Pattern: "("},
+		{DatasetGroupID: 1, FieldID: 2, TargetType: TargetUser, TargetID: 3, RuleType: ColumnRuleKeepFirst, Length: -1},
+		{FieldID: 2, TargetType: TargetUser, TargetID: 3, RuleType: ColumnRuleHide},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+func TestColumnMasksDerive(t *testing.T) {
+	masks := NewColumnMasks([]*CoreColumnPermission{
+		{FieldID: 1, RuleType: ColumnRuleHide},
+		{FieldID: 2, RuleType: ColumnRuleKeepLast, Length: 4},
+		{FieldID: 5, RuleType: ColumnRuleKeepFirst, Length: 1},
+	})
+	derived := masks.Derive(map[int64][]int64{
+		10: {1},    // references a hidden field
+		11: {2, 3}, // references a masked field
+		12: {11},   // through another calculated field
+		13: {3},    // not controlled
+		5:  {1},    // its own rule is looser
+	})
+	if !derived.Hidden(10) || !derived.Hidden(5) || derived.Hidden(11) {
+		t.Errorf("fields referencing hidden fields should be hidden, got %+v", derived)
+	}
+	if derived[11].RuleType != ColumnRuleFull || derived[12].RuleType != ColumnRuleFull {
+		t.Errorf("fields referencing masked fields should be fully masked, got %+v", derived)
+	}
+	if _, ok := derived[13]; ok || len(masks) != 3 {
+		t.Errorf("unexpected derived masks %+v from %+v", derived, masks)
+	}
+}
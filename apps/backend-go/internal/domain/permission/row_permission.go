@@ -1,5 +1,11 @@
 package permission
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // 行权限操作符常量
 const (
 	OperatorEq      = "eq"
@@ -62,6 +68,73 @@ type RowPermissionFilter struct {
 	Rules []RowPermissionTree `json:"rules"`
 }
 
+// CoreRowPermission 行权限规则 - 映射 core_dataset_row_permission 表
+// 限定某个用户、角色或组织可读的数据集行，白名单中的用户、角色和组织不受限
+type CoreRowPermission struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	DatasetID      int64  `gorm:"column:dataset_id;index" json:"datasetId"`
+	AuthTargetType string `gorm:"column:auth_target_type;size:20" json:"authTargetType"`
+	AuthTargetID   int64  `gorm:"column:auth_target_id" json:"authTargetId"`
+	Enable         bool   `gorm:"column:enable" json:"enable"`
+	// ExpressionTree 权限树 RowPermissionTree 的 JSON
+	ExpressionTree string `gorm:"column:expression_tree;type:text" json:"expressionTree"`
+	// WhiteListUser 等为白名单ID列表JSON
+	WhiteListUser string `gorm:"column:white_list_user;type:text" json:"whiteListUser"`
+	WhiteListRole string `gorm:"column:white_list_role;type:text" json:"whiteListRole"`
+	WhiteListDept string `gorm:"column:white_list_dept;type:text" json:"whiteListDept"`
+	UpdateTime    int64  `gorm:"column:update_time" json:"updateTime"`
+}
+
+func (CoreRowPermission) TableName() string {
+	return "core_dataset_row_permission"
+}
+
+// Tree 解析规则的权限树，无法解析的规则返回错误而不是放行
+func (p *CoreRowPermission) Tree() (*RowPermissionTree, error) {
+	if strings.TrimSpace(p.ExpressionTree) == "" {
+		return nil, fmt.Errorf("row permission %d has no expression tree", p.ID)
+	}
+	var tree RowPermissionTree
+	if err := json.Unmarshal([]byte(p.ExpressionTree), &tree); err != nil {
+		return nil, fmt.Errorf("invalid expression tree of row permission %d: %w", p.ID, err)
+	}
+	return &tree, nil
+}
+
+// Exempts 判断用户或其角色、组织是否在规则的白名单中
+func (p *CoreRowPermission) Exempts(userID int64, roleIDs, orgIDs []int64) (bool, error) {
+	lists := []struct {
+		text string
+		ids  []int64
+	}{
+		{p.WhiteListUser, []int64{userID}},
+		{p.WhiteListRole, roleIDs},
+		{p.WhiteListDept, orgIDs},
+	}
+	for _, list := range lists {
+		if strings.TrimSpace(list.text) == "" {
+			continue
+		}
+		// ID 可能以数字或字符串保存
+		var white []json.Number
+		if err := json.Unmarshal([]byte(list.text), &white); err != nil {
+			return false, fmt.Errorf("invalid white list of row permission %d: %w", p.ID, err)
+		}
+		for _, w := range white {
+			id, err := w.Int64()
+			if err != nil {
+				return false, fmt.Errorf("invalid white list of row permission %d: %w", p.ID, err)
+			}
+			for _, target := range list.ids {
+				if id == target {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
 // RowPermissionDTO 行权限数据传输对象
 // 映射Java端的DataSetRowPermissionsTreeDTO结构
 type RowPermissionDTO struct {
@@ -0,0 +1,43 @@
+package permission
+
+import "testing"
+
+func TestRowPermissionTree(t *testing.T) {
+	rule := &CoreRowPermission{ID: 1, ExpressionTree: `{"type":"tree","logic":"or","children":[{"type":"item","fieldId":10,"operator":"eq","value":"north"}]}`}
+	tree, err := rule.Tree()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tree.Logic != LogicOr || len(tree.Children) != 1 || tree.Children[0].FieldID != 10 {
+		t.Errorf("unexpected tree: %+v", tree)
+	}
+
+	for _, expr := range []string{"", "  ", "{not json"} {
+		if _, err = (&CoreRowPermission{ExpressionTree: expr}).Tree(); err == nil {
+			t.Errorf("expected expression %q to fail", expr)
+		}
+	}
+}
+
+func TestRowPermissionExempts(t *testing.T) {
+	rule := &CoreRowPermission{WhiteListUser: `[5]`, WhiteListRole: `["7"]`, WhiteListDept: `[]`}
+	cases := []struct {
+		userID  int64
+		roleIDs []int64
+		exempt  bool
+	}{
+		{5, nil, true},
+		{6, []int64{7}, true},
+		{6, []int64{8}, false},
+	}
+	for _, tc := range cases {
+		exempt, err := rule.Exempts(tc.userID, tc.roleIDs, nil)
+		if err != nil || exempt != tc.exempt {
+			t.Errorf("Exempts(%d, %v) = %v, %v, want %v", tc.userID, tc.roleIDs, exempt, err, tc.exempt)
+		}
+	}
+
+	if _, err := (&CoreRowPermission{WhiteListUser: `[5`}).Exempts(5, nil, nil); err == nil {
+		t.Error("expected an unreadable white list to fail")
+	}
+}
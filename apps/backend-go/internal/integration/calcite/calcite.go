@@ -7,6 +7,8 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+
+	"dataease/backend/internal/pkg/sqlparse"
 )
 
 type Client struct {
@@ -53,6 +55,11 @@ func (c *Client) ParseSQL(ctx context.Context, sql string) (string, error) {
 	return "", fmt.Errorf("not implemented: ParseSQL")
 }
 
+// ValidateSQL checks that sql is a single read-only query calling none of the
+// default denied functions. It runs locally and needs no Calcite server.
 func (c *Client) ValidateSQL(ctx context.Context, sql string) (bool, error) {
-	return false, fmt.Errorf("not implemented: ValidateSQL")
+	if _, err := sqlparse.NewValidator(nil).Validate(sql); err != nil {
+		return false, err
+	}
+	return true, nil
 }
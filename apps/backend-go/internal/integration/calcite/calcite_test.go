@@ -49,12 +49,17 @@ func TestClient_ParseSQL_NotImplemented(t *testing.T) {
 	}
 }
 
-func TestClient_ValidateSQL_NotImplemented(t *testing.T) {
+func TestClient_ValidateSQL(t *testing.T) {
 	c := &Client{}
 	ctx := context.Background()
 
-	_, err := c.ValidateSQL(ctx, "SELECT 1")
-	if err == nil {
-		t.Error("Expected error for unimplemented ValidateSQL")
+	ok, err := c.ValidateSQL(ctx, "SELECT 1")
+	if err != nil || !ok {
+		t.Errorf("Expected SELECT to be valid, got %v, %v", ok, err)
+	}
+
+	ok, err = c.ValidateSQL(ctx, "DELETE FROM t")
+	if err == nil || ok {
+		t.Error("Expected DELETE to be rejected")
 	}
 }
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseCron parses a cron expression with an optional seconds field, which
+// also accepts Quartz style `?` for the day fields.
+func ParseCron(spec string) (cron.Schedule, error) {
+	return cronParser.Parse(spec)
+}
+
+// Once fires a single time at the given instant, or immediately when it has
+// already passed.
+func Once(at time.Time) cron.Schedule {
+	return &onceSchedule{at: at}
+}
+
+type onceSchedule struct {
+	at    time.Time
+	fired bool
+}
+
+func (s *onceSchedule) Next(t time.Time) time.Time {
+	if s.fired {
+		return time.Time{}
+	}
+	s.fired = true
+	if s.at.Before(t) {
+		return t
+	}
+	return s.at
+}
+
+// Every fires at start and then at every interval after it.
+func Every(start time.Time, interval time.Duration) cron.Schedule {
+	return &intervalSchedule{start: start, interval: interval}
+}
+
+type intervalSchedule struct {
+	start    time.Time
+	interval time.Duration
+}
+
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.start) {
+		return s.start
+	}
+	steps := t.Sub(s.start)/s.interval + 1
+	return s.start.Add(steps * s.interval)
+}
+
+// Bounded restricts a schedule to activations at or after start and, when
+// end is not zero, at or before end. Past the end it never fires again.
+func Bounded(schedule cron.Schedule, start time.Time, end time.Time) cron.Schedule {
+	return &boundedSchedule{inner: schedule, start: start, end: end}
+}
+
+type boundedSchedule struct {
+	inner cron.Schedule
+	start time.Time
+	end   time.Time
+}
+
+func (s *boundedSchedule) Next(t time.Time) time.Time {
+	if !s.start.IsZero() && t.Before(s.start) {
+		t = s.start.Add(-time.Second)
+	}
+	next := s.inner.Next(t)
+	if next.IsZero() || (!s.end.IsZero() && next.After(s.end)) {
+		return time.Time{}
+	}
+	return next
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_QuartzStyle(t *testing.T) {
+	schedule, err := ParseCron("0 30 2 * * ?")
+	if err != nil {
+		t.Fatalf("ParseCron failed: %v", err)
+	}
+	from := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+	if next := schedule.Next(from); !next.Equal(time.Date(2024, 1, 2, 2, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected next activation %v", next)
+	}
+	if _, err = ParseCron("*/5 * * * *"); err != nil {
+		t.Errorf("Expected five field spec to parse: %v", err)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	at := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	schedule := Once(at)
+	if next := schedule.Next(at.Add(-time.Hour)); !next.Equal(at) {
+		t.Errorf("Expected %v, got %v", at, next)
+	}
+	if next := schedule.Next(at); !next.IsZero() {
+		t.Errorf("Expected no further activation, got %v", next)
+	}
+}
+
+func TestEveryBounded(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	schedule := Bounded(Every(start, 30*time.Minute), start, end)
+
+	if next := schedule.Next(start.Add(-time.Hour)); !next.Equal(start) {
+		t.Errorf("Expected first activation at start, got %v", next)
+	}
+	if next := schedule.Next(start.Add(31 * time.Minute)); !next.Equal(start.Add(60 * time.Minute)) {
+		t.Errorf("Unexpected next activation %v", next)
+	}
+	if next := schedule.Next(start.Add(90 * time.Minute)); !next.IsZero() {
+		t.Errorf("Expected schedule to end, got %v", next)
+	}
+}
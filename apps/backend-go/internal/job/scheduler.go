@@ -35,6 +35,12 @@ func (s *Scheduler) AddJob(spec string, job cron.Job) error {
 	return err
 }
 
+// Schedule registers cmd on an arbitrary schedule and returns its entry ID
+// so it can be removed later.
+func (s *Scheduler) Schedule(schedule cron.Schedule, cmd func()) cron.EntryID {
+	return s.cron.Schedule(schedule, cron.FuncJob(cmd))
+}
+
 func (s *Scheduler) AddDistributedFunc(name, spec string, cmd func()) error {
 	wrappedCmd := func() {
 		if s.redis != nil {
@@ -0,0 +1,277 @@
+// Package apisource fetches records from HTTP/JSON APIs described by a
+// datasource.APIDefinition and maps them onto columns with JSONPath.
+package apisource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+const (
+	defaultTimeout  = 30 * time.Second
+	defaultMaxPages = 100
+	defaultPageSize = 100
+	maxResponseSize = 50 << 20
+	maxFieldDepth   = 3
+)
+
+// StatusError is a non-2xx response of the API.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("api request failed with status %d", e.Code)
+}
+
+// Fetch requests every page of the definition and returns the records found
+// under RootPath. Fetching stops after maxRecords records when it is positive.
+func Fetch(ctx context.Context, client *http.Client, def *datasource.APIDefinition, maxRecords int) ([]interface{}, error) {
+	if def == nil || strings.TrimSpace(def.URL) == "" {
+		return nil, fmt.Errorf("api url is required")
+	}
+	base, err := url.Parse(strings.TrimSpace(def.URL))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("api url must be an http(s) address")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: timeout(def)}
+	}
+
+	paging := def.Pagination
+	maxPages := paging.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	pageSize := paging.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if paging.Type == datasource.APIPageNone {
+		maxPages = 1
+	}
+
+	records := make([]interface{}, 0)
+	cursor := ""
+	for page := 0; page < maxPages; page++ {
+		query := base.Query()
+		for _, param := range def.Params {
+			if param.Name != "" {
+				query.Set(param.Name, param.Value)
+			}
+		}
+		switch paging.Type {
+		case datasource.APIPageNone:
+		case datasource.APIPageNumber:
+			query.Set(paramOr(paging.PageParam, "page"), strconv.Itoa(paging.StartPage+page))
+			query.Set(paramOr(paging.SizeParam, "size"), strconv.Itoa(pageSize))
+		case datasource.APIPageOffset:
+			query.Set(paramOr(paging.PageParam, "offset"), strconv.Itoa(page*pageSize))
+			query.Set(paramOr(paging.SizeParam, "limit"), strconv.Itoa(pageSize))
+		case datasource.APIPageCursor:
+			if page > 0 {
+				query.Set(paramOr(paging.CursorParam, "cursor"), cursor)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported pagination type: %s", paging.Type)
+		}
+
+		doc, err := request(ctx, client, def, base, query)
+		if err != nil {
+			return nil, err
+		}
+		found, err := Eval(doc, paramOr(def.RootPath, "$"))
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 1 {
+			if list, ok := found[0].([]interface{}); ok {
+				found = list
+			}
+		}
+		records = append(records, found...)
+		if maxRecords > 0 && len(records) >= maxRecords {
+			return records[:maxRecords], nil
+		}
+
+		switch paging.Type {
+		case datasource.APIPageNumber, datasource.APIPageOffset:
+			if len(found) < pageSize {
+				return records, nil
+			}
+		case datasource.APIPageCursor:
+			next, err := First(doc, paging.CursorPath)
+			if err != nil {
+				return nil, err
+			}
+			text, ok := Text(next)
+			if !ok || text == "" || len(found) == 0 {
+				return records, nil
+			}
+			cursor = text
+		}
+	}
+	return records, nil
+}
+
+func request(ctx context.Context, client *http.Client, def *datasource.APIDefinition, base *url.URL, query url.Values) (interface{}, error) {
+	auth := def.Auth
+	if auth.Type == datasource.APIAuthKey && strings.EqualFold(auth.KeyIn, "query") {
+		query.Set(auth.KeyName, auth.KeyValue)
+	}
+	target := *base
+	target.RawQuery = query.Encode()
+
+	method := strings.ToUpper(strings.TrimSpace(def.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if def.Body != "" && method != http.MethodGet {
+		body = bytes.NewBufferString(def.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, header := range def.Headers {
+		if header.Name != "" {
+			req.Header.Set(header.Name, header.Value)
+		}
+	}
+
+	switch auth.Type {
+	case datasource.APIAuthNone:
+	case datasource.APIAuthBasic:
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case datasource.APIAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case datasource.APIAuthKey:
+		if auth.KeyName == "" {
+			return nil, fmt.Errorf("api key name is required")
+		}
+		if !strings.EqualFold(auth.KeyIn, "query") {
+			req.Header.Set(auth.KeyName, auth.KeyValue)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", auth.Type)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize))
+	decoder.UseNumber()
+	var doc interface{}
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("api response is not valid JSON: %w", err)
+	}
+	return doc, nil
+}
+
+// Extract evaluates the field paths against every record. Each cell holds the
+// text of the first match, or nil when the path does not resolve.
+func Extract(records []interface{}, fields []datasource.APIField) ([][]interface{}, error) {
+	rows := make([][]interface{}, 0, len(records))
+	for _, record := range records {
+		row := make([]interface{}, len(fields))
+		for i, field := range fields {
+			value, err := First(record, field.JSONPath)
+			if err != nil {
+				return nil, err
+			}
+			if text, ok := Text(value); ok {
+				row[i] = text
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// InferFields lists the leaf members of a record as fields. Nested objects
+// are flattened with `_` up to a fixed depth; arrays are kept as JSON text.
+func InferFields(record interface{}) []datasource.APIField {
+	fields := make([]datasource.APIField, 0)
+	var walk func(value interface{}, path string, name string, depth int)
+	walk = func(value interface{}, path string, name string, depth int) {
+		obj, ok := value.(map[string]interface{})
+		if !ok || depth >= maxFieldDepth {
+			if name == "" {
+				name = "value"
+			}
+			fields = append(fields, datasource.APIField{Name: name, JSONPath: path})
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childName := key
+			if name != "" {
+				childName = name + "_" + key
+			}
+			walk(obj[key], path+"['"+key+"']", childName, depth+1)
+		}
+	}
+	walk(record, "$", "", 0)
+	return fields
+}
+
+// Text renders a decoded JSON value as text. Objects and arrays are encoded
+// back to JSON; nil reports false.
+func Text(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+}
+
+func timeout(def *datasource.APIDefinition) time.Duration {
+	if def.Timeout > 0 {
+		return time.Duration(def.Timeout) * time.Second
+	}
+	return defaultTimeout
+}
+
+func paramOr(value string, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
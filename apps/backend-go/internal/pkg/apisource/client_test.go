@@ -0,0 +1,127 @@
+package apisource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+func TestEval(t *testing.T) {
+	var doc interface{}
+	_ = json.Unmarshal([]byte(`{"data":{"items":[{"id":1,"tags":["a","b"]},{"id":2,"tags":[]}]},"next":"c1"}`), &doc)
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"$.data.items[*]", 2},
+		{"$.data.items[-1].id", 1},
+		{"$['data']['items'][0].tags[*]", 2},
+		{"data.items[*].id", 2},
+		{"$.missing", 0},
+	}
+	for _, tc := range cases {
+		got, err := Eval(doc, tc.path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.path, err)
+		}
+		if len(got) != tc.want {
+			t.Fatalf("%s: expected %d matches, got %v", tc.path, tc.want, got)
+		}
+	}
+	if _, err := Eval(doc, "$..id"); err == nil {
+		t.Fatal("expected recursive descent to be rejected")
+	}
+}
+
+func TestFetch_PageNumberWithBearer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		items := []map[string]interface{}{}
+		if page < 3 {
+			items = append(items, map[string]interface{}{"id": page*2 + 1}, map[string]interface{}{"id": page*2 + 2})
+		}
+		if page == 2 {
+			items = items[:1]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": items})
+	}))
+	defer server.Close()
+
+	def := &datasource.APIDefinition{
+		URL:        server.URL,
+		Auth:       datasource.APIAuth{Type: datasource.APIAuthBearer, Token: "secret"},
+		Pagination: datasource.APIPagination{Type: datasource.APIPageNumber, StartPage: 1, PageSize: 2},
+		RootPath:   "$.data[*]",
+		Fields:     []datasource.APIField{{Name: "id", JSONPath: "$.id", DeType: 2}},
+	}
+	records, err := Fetch(context.Background(), server.Client(), def, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records over a full page and a short one, got %d", len(records))
+	}
+	rows, err := Extract(records, def.Fields)
+	if err != nil || rows[2][0] != "5" {
+		t.Fatalf("unexpected rows %v (%v)", rows, err)
+	}
+
+	def.Auth.Token = "wrong"
+	if _, err = Fetch(context.Background(), server.Client(), def, 0); err == nil {
+		t.Fatal("expected unauthorized request to fail")
+	}
+}
+
+func TestFetch_CursorWithAPIKeyAndBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("key") != "k1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["region"] != "eu" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("after") {
+		case "":
+			_, _ = w.Write([]byte(`{"rows":[{"user":{"name":"a"}}],"meta":{"next":"n1"}}`))
+		case "n1":
+			_, _ = w.Write([]byte(`{"rows":[{"user":{"name":"b"}}],"meta":{"next":null}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	def := &datasource.APIDefinition{
+		URL:        server.URL,
+		Method:     "post",
+		Body:       `{"region":"eu"}`,
+		Auth:       datasource.APIAuth{Type: datasource.APIAuthKey, KeyName: "key", KeyValue: "k1", KeyIn: "query"},
+		Pagination: datasource.APIPagination{Type: datasource.APIPageCursor, CursorParam: "after", CursorPath: "$.meta.next"},
+		RootPath:   "$.rows",
+	}
+	records, err := Fetch(context.Background(), server.Client(), def, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", records)
+	}
+	fields := InferFields(records[0])
+	if len(fields) != 1 || fields[0].Name != "user_name" || fields[0].JSONPath != "$['user']['name']" {
+		t.Fatalf("unexpected inferred fields %+v", fields)
+	}
+}
@@ -0,0 +1,143 @@
+package apisource
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// segment is one step of a compiled JSONPath: a member name, an array index
+// or a wildcard over members/elements.
+type segment struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Eval evaluates a JSONPath against a decoded JSON document. The supported
+// subset is the root `$`, `.name`, `['name']`, `[n]` (negative counts from
+// the end), `[*]` and `.*`. A path without a leading `$` is relative to the
+// document root.
+func Eval(doc interface{}, path string) ([]interface{}, error) {
+	segments, err := compile(path)
+	if err != nil {
+		return nil, err
+	}
+	current := []interface{}{doc}
+	for _, seg := range segments {
+		next := make([]interface{}, 0, len(current))
+		for _, node := range current {
+			next = append(next, step(node, seg)...)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// First returns the first match of a JSONPath, or nil when nothing matches.
+func First(doc interface{}, path string) (interface{}, error) {
+	matches, err := Eval(doc, path)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	return matches[0], nil
+}
+
+func step(node interface{}, seg segment) []interface{} {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			result := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				result = append(result, value[key])
+			}
+			return result
+		}
+		if seg.isIndex {
+			return nil
+		}
+		if child, ok := value[seg.name]; ok {
+			return []interface{}{child}
+		}
+	case []interface{}:
+		if seg.wildcard {
+			return value
+		}
+		if seg.isIndex {
+			idx := seg.index
+			if idx < 0 {
+				idx += len(value)
+			}
+			if idx >= 0 && idx < len(value) {
+				return []interface{}{value[idx]}
+			}
+		}
+	}
+	return nil
+}
+
+func compile(path string) ([]segment, error) {
+	text := strings.TrimSpace(path)
+	if text == "" || text == "$" {
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "$") {
+		text = text[1:]
+	} else if !strings.HasPrefix(text, "[") && !strings.HasPrefix(text, ".") {
+		text = "." + text
+	}
+
+	var segments []segment
+	for len(text) > 0 {
+		switch text[0] {
+		case '.':
+			text = text[1:]
+			if strings.HasPrefix(text, ".") {
+				return nil, fmt.Errorf("jsonpath %q: recursive descent is not supported", path)
+			}
+			end := strings.IndexAny(text, ".[")
+			if end < 0 {
+				end = len(text)
+			}
+			name := text[:end]
+			if name == "" {
+				return nil, fmt.Errorf("jsonpath %q: empty member name", path)
+			}
+			if name == "*" {
+				segments = append(segments, segment{wildcard: true})
+			} else {
+				segments = append(segments, segment{name: name})
+			}
+			text = text[end:]
+		case '[':
+			end := strings.Index(text, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unterminated bracket", path)
+			}
+			inner := strings.TrimSpace(text[1:end])
+			text = text[end+1:]
+			switch {
+			case inner == "*":
+				segments = append(segments, segment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, segment{name: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q: unsupported selector [%s]", path, inner)
+				}
+				segments = append(segments, segment{index: idx, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected character %q", path, text[0])
+		}
+	}
+	return segments, nil
+}
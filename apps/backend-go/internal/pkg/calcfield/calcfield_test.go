@@ -0,0 +1,179 @@
+package calcfield
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestParse_Refs(t *testing.T) {
+	formula, err := Parse("IF([1] > 0, [2] * [1], CONCAT('a', [3]))")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := formula.Refs(); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("Refs = %v", got)
+	}
+}
+
+func TestParse_ErrorPositions(t *testing.T) {
+	cases := map[string]int{
+		"":                0,
+		"[1] +":           6,
+		"[1] + * 2":       7,
+		"ROUND([1], 2":    13,
+		"'open":           1,
+		"[1] $ 2":         5,
+		"CASE WHEN [1] 1": 15,
+		"[x]":             1,
+	}
+	for text, pos := range cases {
+		_, err := Parse(text)
+		formulaErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Parse(%q): expected *Error, got %v", text, err)
+			continue
+		}
+		if pos > 0 && formulaErr.Pos != pos {
+			t.Errorf("Parse(%q): position %d, want %d (%s)", text, formulaErr.Pos, pos, formulaErr.Msg)
+		}
+	}
+}
+
+func testSet() *Set {
+	return NewSet([]Field{
+		{ID: 1, DeType: TypeInt},
+		{ID: 2, DeType: TypeFloat},
+		{ID: 3, DeType: TypeText},
+		{ID: 4, DeType: TypeDate},
+		{ID: 10, Formula: "[1] * [2]"},
+		{ID: 11, Formula: "SUM([10]) / COUNT([1])"},
+		{ID: 12, Formula: "[13] + 1"},
+		{ID: 13, Formula: "[12] + 1"},
+		{ID: 14, Formula: "[1] + SUM([2])"},
+		{ID: 15, Formula: "CASE WHEN [1] > 1 THEN 'big' ELSE [3] END"},
+		{ID: 16, Formula: "[1] > 1 AND NOT ISNULL([3])"},
+		{ID: 17, Formula: "[3] + 1"},
+		{ID: 18, Formula: "[12] * 2"},
+		{ID: 19, Formula: "YEAR([4]) = 2024"},
+	})
+}
+
+func TestCheck_Types(t *testing.T) {
+	set := testSet()
+	cases := map[int64]Info{
+		10: {Type: TypeFloat},
+		11: {Type: TypeFloat, Aggregate: true},
+		15: {Type: TypeText},
+		16: {Type: TypeBool},
+		19: {Type: TypeBool},
+	}
+	for id, want := range cases {
+		info, err := set.Check(id)
+		if err != nil {
+			t.Errorf("Check(%d): %v", id, err)
+			continue
+		}
+		if *info != want {
+			t.Errorf("Check(%d) = %+v, want %+v", id, *info, want)
+		}
+	}
+}
+
+func TestCheck_Errors(t *testing.T) {
+	set := testSet()
+	cases := map[int64]struct {
+		pos int
+		msg string
+	}{
+		12: {1, "circular reference through [13]"},
+		14: {1, "cannot mix aggregated and row values"},
+		17: {5, "use CONCAT"},
+		18: {1, "field [12] is invalid"},
+	}
+	for id, want := range cases {
+		_, err := set.Check(id)
+		formulaErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Check(%d): expected *Error, got %v", id, err)
+			continue
+		}
+		if formulaErr.Pos != want.pos || !strings.Contains(formulaErr.Msg, want.msg) {
+			t.Errorf("Check(%d) = %v, want position %d containing %q", id, err, want.pos, want.msg)
+		}
+	}
+}
+
+func TestSQL_Dialects(t *testing.T) {
+	set := NewSet([]Field{
+		{ID: 1, DeType: TypeInt},
+		{ID: 2, DeType: TypeInt},
+		{ID: 3, DeType: TypeText},
+		{ID: 10, Formula: "[1] / [2]"},
+		{ID: 11, Formula: "[1] > [2]"},
+		{ID: 12, Formula: "IF([11], 'a\\b', [3])"},
+		{ID: 13, Formula: "[1] % 3"},
+	})
+	column := func(id int64) (string, error) { return fmt.Sprintf("c%d", id), nil }
+	cases := []struct {
+		db   string
+		id   int64
+		want string
+	}{
+		{"mysql", 10, "(c1 * 1.0 / NULLIF(c2, 0))"},
+		{"pg", 11, "(c1 > c2)"},
+		{"sqlServer", 11, "CASE WHEN c1 > c2 THEN 1 ELSE 0 END"},
+		{"oracle", 13, "MOD(c1, 3)"},
+		{"mysql", 12, `'a\\b'`},
+		{"pg", 12, `'a\b'`},
+	}
+	for _, tc := range cases {
+		got, err := set.SQL(tc.db, tc.id, column)
+		if err != nil {
+			t.Errorf("SQL(%s, %d): %v", tc.db, tc.id, err)
+			continue
+		}
+		if !strings.Contains(got, tc.want) {
+			t.Errorf("SQL(%s, %d) = %s, want it to contain %s", tc.db, tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestSQL_RunsOnSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	set := NewSet([]Field{
+		{ID: 1, DeType: TypeInt},
+		{ID: 2, DeType: TypeInt},
+		{ID: 3, DeType: TypeText},
+		{ID: 10, Formula: "ROUND([1] / [2], 2)"},
+		{ID: 11, Formula: "CASE WHEN [1] >= [2] THEN UPPER([3]) ELSE LOWER([3]) END"},
+		{ID: 12, Formula: "CONCAT([3], '-', LENGTH([3]))"},
+		{ID: 13, Formula: "[1] / 0"},
+	})
+	column := func(id int64) (string, error) {
+		return map[int64]string{1: "7", 2: "2", 3: "'Ab'"}[id], nil
+	}
+	want := map[int64]string{10: "3.5", 11: "AB", 12: "Ab-2", 13: "<nil>"}
+	for id, expected := range want {
+		expr, err := set.SQL(dbSQLite, id, column)
+		if err != nil {
+			t.Fatalf("SQL(%d): %v", id, err)
+		}
+		var value interface{}
+		if err = db.QueryRow("SELECT " + expr).Scan(&value); err != nil {
+			t.Fatalf("run %s: %v", expr, err)
+		}
+		if got := fmt.Sprint(value); got != expected {
+			t.Errorf("%s = %s, want %s", expr, got, expected)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package calcfield
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a field formulas may reference: a column of the dataset, or a
+// calculated field when Formula is set.
+type Field struct {
+	ID      int64
+	DeType  int
+	Formula string
+}
+
+// Info is the result of checking a field. Aggregate fields summarize rows
+// and can only be queried grouped.
+type Info struct {
+	Type      int
+	Aggregate bool
+}
+
+// Set holds the fields of a dataset so that formulas can be checked and
+// compiled with the fields they reference. Results are cached, so a set
+// should not outlive the fields it was built from.
+type Set struct {
+	fields map[int64]*Field
+	parsed map[int64]*Formula
+	infos  map[int64]*Info
+	errs   map[int64]error
+	active map[int64]bool
+	types  map[node]int
+}
+
+func NewSet(fields []Field) *Set {
+	s := &Set{
+		fields: make(map[int64]*Field, len(fields)),
+		parsed: make(map[int64]*Formula),
+		infos:  make(map[int64]*Info),
+		errs:   make(map[int64]error),
+		active: make(map[int64]bool),
+		types:  make(map[node]int),
+	}
+	for i := range fields {
+		s.fields[fields[i].ID] = &fields[i]
+	}
+	return s
+}
+
+// cycleError reports a reference back to target, a field being checked. Pos
+// and Ref locate the reference in the formula the error is returned for.
+type cycleError struct {
+	pos    int
+	ref    int64
+	target int64
+}
+
+func (e *cycleError) Error() string { return "circular reference" }
+
+// Check type checks a field and the calculated fields it references. Errors
+// in the formula of the field are *Error values with the position of the
+// offending token; a reference to an invalid or circular field is reported
+// at the position of the reference.
+func (s *Set) Check(id int64) (*Info, error) {
+	info, err := s.check(id)
+	if cycle, ok := err.(*cycleError); ok {
+		if cycle.target != id {
+			return nil, &Error{Pos: cycle.pos, Msg: fmt.Sprintf("field [%d] is invalid: circular reference", cycle.ref)}
+		}
+		return nil, &Error{Pos: cycle.pos, Msg: fmt.Sprintf("circular reference through [%d]", cycle.ref)}
+	}
+	return info, err
+}
+
+func (s *Set) check(id int64) (*Info, error) {
+	field, ok := s.fields[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown field [%d]", id)
+	}
+	if strings.TrimSpace(field.Formula) == "" {
+		return &Info{Type: field.DeType}, nil
+	}
+	if info, ok := s.infos[id]; ok {
+		return info, nil
+	}
+	if err, ok := s.errs[id]; ok {
+		return nil, err
+	}
+	if s.active[id] {
+		return nil, &cycleError{target: id}
+	}
+
+	s.active[id] = true
+	info, err := s.checkFormula(id, field.Formula)
+	delete(s.active, id)
+	if err != nil {
+		// A cycle is reported relative to the field the check started from,
+		// so it is not cached.
+		if _, ok := err.(*cycleError); !ok {
+			s.errs[id] = err
+		}
+		return nil, err
+	}
+	s.infos[id] = info
+	return info, nil
+}
+
+func (s *Set) checkFormula(id int64, text string) (*Info, error) {
+	formula, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	s.parsed[id] = formula
+	t, level, err := s.checkNode(formula.root)
+	if err != nil {
+		return nil, err
+	}
+	if t == typeNull {
+		t = TypeText
+	}
+	return &Info{Type: t, Aggregate: level == levelAggregate}, nil
+}
+
+// Levels of a value: constant, per row or aggregated over rows.
+const (
+	levelConst = iota
+	levelRow
+	levelAggregate
+)
+
+func mixLevels(nodes []node, levels []int) (int, error) {
+	result := levelConst
+	for _, level := range levels {
+		if level > result {
+			result = level
+		}
+	}
+	if result == levelAggregate {
+		for i, level := range levels {
+			if level == levelRow {
+				return 0, &Error{Pos: nodes[i].position(), Msg: "cannot mix aggregated and row values, aggregate this value"}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *Set) checkNode(n node) (int, int, error) {
+	t, level, err := s.checkType(n)
+	if err == nil {
+		s.types[n] = t
+	}
+	return t, level, err
+}
+
+func (s *Set) checkType(n node) (int, int, error) {
+	switch n := n.(type) {
+	case *literal:
+		if n.kind == tokString {
+			return TypeText, levelConst, nil
+		}
+		if strings.Contains(n.text, ".") {
+			return TypeFloat, levelConst, nil
+		}
+		return TypeInt, levelConst, nil
+	case *boolLit:
+		return TypeBool, levelConst, nil
+	case *nullLit:
+		return typeNull, levelConst, nil
+	case *fieldRef:
+		info, err := s.check(n.id)
+		if err != nil {
+			switch err := err.(type) {
+			case *cycleError:
+				return 0, 0, &cycleError{pos: n.pos, ref: n.id, target: err.target}
+			case *Error:
+				return 0, 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("field [%d] is invalid: %s", n.id, err.Error())}
+			}
+			return 0, 0, &Error{Pos: n.pos, Msg: err.Error()}
+		}
+		if info.Aggregate {
+			return info.Type, levelAggregate, nil
+		}
+		return info.Type, levelRow, nil
+	case *unary:
+		t, level, err := s.checkNode(n.x)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n.op == "NOT" {
+			if !boolParam.ok(t) {
+				return 0, 0, &Error{Pos: n.x.position(), Msg: fmt.Sprintf("NOT expects a condition, got %s", typeName(t))}
+			}
+			return TypeBool, level, nil
+		}
+		if !isNumeric(t) {
+			return 0, 0, &Error{Pos: n.x.position(), Msg: fmt.Sprintf("- expects a number, got %s", typeName(t))}
+		}
+		return t, level, nil
+	case *binary:
+		return s.checkBinary(n)
+	case *call:
+		return s.checkCall(n)
+	case *caseExpr:
+		return s.checkCase(n)
+	}
+	return 0, 0, fmt.Errorf("unsupported formula node %T", n)
+}
+
+func (s *Set) checkBinary(n *binary) (int, int, error) {
+	lt, ll, err := s.checkNode(n.l)
+	if err != nil {
+		return 0, 0, err
+	}
+	rt, rl, err := s.checkNode(n.r)
+	if err != nil {
+		return 0, 0, err
+	}
+	level, err := mixLevels([]node{n.l, n.r}, []int{ll, rl})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	operand := func(accept param) error {
+		if !accept.ok(lt) {
+			return &Error{Pos: n.l.position(), Msg: fmt.Sprintf("%s expects %s, got %s", n.op, accept.name, typeName(lt))}
+		}
+		if !accept.ok(rt) {
+			return &Error{Pos: n.r.position(), Msg: fmt.Sprintf("%s expects %s, got %s", n.op, accept.name, typeName(rt))}
+		}
+		return nil
+	}
+	switch n.op {
+	case "AND", "OR":
+		return TypeBool, level, operand(boolParam)
+	case "+", "-", "*":
+		if err = operand(numParam); err != nil {
+			if n.op == "+" && (lt == TypeText || rt == TypeText) {
+				err = &Error{Pos: n.pos, Msg: "+ does not join text, use CONCAT"}
+			}
+			return 0, 0, err
+		}
+		if lt == TypeInt && rt == TypeInt {
+			return TypeInt, level, nil
+		}
+		return TypeFloat, level, nil
+	case "/":
+		return TypeFloat, level, operand(numParam)
+	case "%":
+		return TypeInt, level, operand(intParam)
+	}
+
+	// Comparisons. Dates compare with text literals such as '2024-01-01'.
+	if _, ok := unify(lt, rt); !ok {
+		_, lLiteral := n.l.(*literal)
+		_, rLiteral := n.r.(*literal)
+		dateText := (lt == TypeDate && rt == TypeText && rLiteral) || (rt == TypeDate && lt == TypeText && lLiteral)
+		if !dateText {
+			return 0, 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("cannot compare %s with %s", typeName(lt), typeName(rt))}
+		}
+	}
+	return TypeBool, level, nil
+}
+
+func (s *Set) checkCall(n *call) (int, int, error) {
+	fn, ok := functions[n.name]
+	if !ok {
+		return 0, 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("unknown function %s", n.name)}
+	}
+	min := len(fn.params) - fn.optional
+	if len(n.args) < min || (!fn.variadic && len(n.args) > len(fn.params)) {
+		return 0, 0, &Error{Pos: n.pos, Msg: fmt.Sprintf("wrong number of arguments, usage: %s", fn.usage)}
+	}
+
+	types := make([]int, len(n.args))
+	levels := make([]int, len(n.args))
+	for i, arg := range n.args {
+		t, level, err := s.checkNode(arg)
+		if err != nil {
+			return 0, 0, err
+		}
+		expected := fn.params[len(fn.params)-1]
+		if i < len(fn.params) {
+			expected = fn.params[i]
+		}
+		if !expected.ok(t) {
+			return 0, 0, &Error{Pos: arg.position(), Msg: fmt.Sprintf("argument %d of %s must be %s, got %s", i+1, n.name, expected.name, typeName(t))}
+		}
+		if fn.aggregate && level == levelAggregate {
+			return 0, 0, &Error{Pos: arg.position(), Msg: "aggregate functions cannot be nested"}
+		}
+		types[i], levels[i] = t, level
+	}
+	level, err := mixLevels(n.args, levels)
+	if err != nil {
+		return 0, 0, err
+	}
+	if fn.aggregate {
+		level = levelAggregate
+	}
+	t, err := fn.result(types)
+	if err != nil {
+		if argErr, ok := err.(*argError); ok {
+			return 0, 0, &Error{Pos: n.args[argErr.index].position(), Msg: argErr.msg}
+		}
+		return 0, 0, &Error{Pos: n.pos, Msg: err.Error()}
+	}
+	return t, level, nil
+}
+
+func (s *Set) checkCase(n *caseExpr) (int, int, error) {
+	nodes := make([]node, 0, 2*len(n.whens)+2)
+	levels := make([]int, 0, cap(nodes))
+	operandType := TypeBool
+	if n.operand != nil {
+		t, level, err := s.checkNode(n.operand)
+		if err != nil {
+			return 0, 0, err
+		}
+		operandType = t
+		nodes, levels = append(nodes, n.operand), append(levels, level)
+	}
+
+	result := typeNull
+	for _, when := range n.whens {
+		t, level, err := s.checkNode(when.cond)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, ok := unify(operandType, t); !ok {
+			return 0, 0, &Error{Pos: when.cond.position(), Msg: fmt.Sprintf("WHEN expects %s, got %s", typeName(operandType), typeName(t))}
+		}
+		nodes, levels = append(nodes, when.cond), append(levels, level)
+
+		if t, level, err = s.checkNode(when.result); err != nil {
+			return 0, 0, err
+		}
+		unified, ok := unify(result, t)
+		if !ok {
+			return 0, 0, &Error{Pos: when.result.position(), Msg: fmt.Sprintf("THEN expects %s, got %s", typeName(result), typeName(t))}
+		}
+		result = unified
+		nodes, levels = append(nodes, when.result), append(levels, level)
+	}
+	if n.els != nil {
+		t, level, err := s.checkNode(n.els)
+		if err != nil {
+			return 0, 0, err
+		}
+		unified, ok := unify(result, t)
+		if !ok {
+			return 0, 0, &Error{Pos: n.els.position(), Msg: fmt.Sprintf("ELSE expects %s, got %s", typeName(result), typeName(t))}
+		}
+		result = unified
+		nodes, levels = append(nodes, n.els), append(levels, level)
+	}
+	level, err := mixLevels(nodes, levels)
+	if err != nil {
+		return 0, 0, err
+	}
+	return result, level, nil
+}
@@ -0,0 +1,236 @@
+package calcfield
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQL compiles a calculated field to an expression of the datasource type
+// db, such as "mysql" or "pg". Column returns the SQL of the column field id
+// refers to; referenced calculated fields are compiled inline.
+func (s *Set) SQL(db string, id int64, column func(id int64) (string, error)) (string, error) {
+	if _, err := s.Check(id); err != nil {
+		return "", err
+	}
+	c := &compiler{set: s, db: db, column: column, memo: make(map[int64]string)}
+	sql, err := c.field(id)
+	if err != nil {
+		return "", err
+	}
+	if c.refIsPredicate(id) {
+		if !c.boolValues() {
+			return "CASE WHEN " + sql + " THEN 1 ELSE 0 END", nil
+		}
+		return "(" + sql + ")", nil
+	}
+	return sql, nil
+}
+
+type compiler struct {
+	set    *Set
+	db     string
+	column func(id int64) (string, error)
+	memo   map[int64]string
+	err    error
+}
+
+// boolValues reports whether conditions are values in the dialect. SQL
+// Server and Oracle only accept them in WHERE, ON, CASE WHEN and the like,
+// so condition values are written as 1 and 0 there.
+func (c *compiler) boolValues() bool {
+	return c.db != dbSQLServer && c.db != dbOracle
+}
+
+func (c *compiler) field(id int64) (string, error) {
+	if sql, ok := c.memo[id]; ok {
+		return sql, nil
+	}
+	field := c.set.fields[id]
+	if field == nil || strings.TrimSpace(field.Formula) == "" {
+		return c.column(id)
+	}
+	formula := c.set.parsed[id]
+	if formula == nil {
+		return "", fmt.Errorf("field [%d] is not checked", id)
+	}
+	var sql string
+	if isPredicate(formula.root) {
+		// Kept in condition form so that it can be used as a condition
+		// again; value and SQL convert it where a value is needed.
+		sql = c.pred(formula.root)
+	} else {
+		sql = c.value(formula.root)
+	}
+	if c.err != nil {
+		return "", c.err
+	}
+	c.memo[id] = sql
+	return sql, nil
+}
+
+// isPredicate reports whether a node is naturally a condition rather than
+// a value.
+func isPredicate(n node) bool {
+	switch n := n.(type) {
+	case *boolLit:
+		return true
+	case *unary:
+		return n.op == "NOT"
+	case *binary:
+		switch n.op {
+		case "AND", "OR", "=", "<>", "<", "<=", ">", ">=":
+			return true
+		}
+	case *call:
+		return n.name == "ISNULL"
+	}
+	return false
+}
+
+// pred renders a node used as a condition.
+func (c *compiler) pred(n node) string {
+	switch n := n.(type) {
+	case *boolLit:
+		if c.boolValues() {
+			if n.value {
+				return "TRUE"
+			}
+			return "FALSE"
+		}
+		if n.value {
+			return "1 = 1"
+		}
+		return "1 = 0"
+	case *unary:
+		if n.op == "NOT" {
+			return "NOT (" + c.pred(n.x) + ")"
+		}
+	case *binary:
+		switch n.op {
+		case "AND", "OR":
+			return "(" + c.pred(n.l) + " " + n.op + " " + c.pred(n.r) + ")"
+		case "=", "<>", "<", "<=", ">", ">=":
+			return c.value(n.l) + " " + n.op + " " + c.value(n.r)
+		}
+	case *call:
+		if n.name == "ISNULL" {
+			return c.value(n.args[0]) + " IS NULL"
+		}
+	case *fieldRef:
+		if c.refIsPredicate(n.id) {
+			sql, err := c.field(n.id)
+			if err != nil {
+				c.fail(err)
+			}
+			return "(" + sql + ")"
+		}
+	}
+	// A value of a condition type, such as a boolean column.
+	if c.boolValues() {
+		return c.value(n)
+	}
+	return c.value(n) + " = 1"
+}
+
+func (c *compiler) refIsPredicate(id int64) bool {
+	formula := c.set.parsed[id]
+	return formula != nil && isPredicate(formula.root)
+}
+
+func (c *compiler) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// value renders a node used as a value.
+func (c *compiler) value(n node) string {
+	if isPredicate(n) {
+		if c.boolValues() {
+			return "(" + c.pred(n) + ")"
+		}
+		return "CASE WHEN " + c.pred(n) + " THEN 1 ELSE 0 END"
+	}
+
+	switch n := n.(type) {
+	case *literal:
+		if n.kind == tokNumber {
+			return n.text
+		}
+		return c.quote(n.text)
+	case *nullLit:
+		return "NULL"
+	case *fieldRef:
+		sql, err := c.field(n.id)
+		if err != nil {
+			c.fail(err)
+			return ""
+		}
+		if c.set.parsed[n.id] == nil {
+			// A column.
+			return sql
+		}
+		if c.refIsPredicate(n.id) && !c.boolValues() {
+			return "CASE WHEN " + sql + " THEN 1 ELSE 0 END"
+		}
+		return "(" + sql + ")"
+	case *unary:
+		return "(-" + c.value(n.x) + ")"
+	case *binary:
+		l, r := c.value(n.l), c.value(n.r)
+		switch n.op {
+		case "/":
+			if c.set.types[n.l] == TypeInt && c.set.types[n.r] == TypeInt {
+				l += " * 1.0"
+			}
+			return "(" + l + " / NULLIF(" + r + ", 0))"
+		case "%":
+			if c.db == dbOracle {
+				return "MOD(" + l + ", " + r + ")"
+			}
+		}
+		return "(" + l + " " + n.op + " " + r + ")"
+	case *call:
+		fn := functions[n.name]
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			if i < len(fn.params) && fn.params[i].name == boolParam.name {
+				args[i] = c.pred(arg)
+			} else {
+				args[i] = c.value(arg)
+			}
+		}
+		return fn.sql(c.db, args)
+	case *caseExpr:
+		var b strings.Builder
+		b.WriteString("CASE")
+		if n.operand != nil {
+			b.WriteString(" " + c.value(n.operand))
+		}
+		for _, when := range n.whens {
+			if n.operand != nil {
+				b.WriteString(" WHEN " + c.value(when.cond))
+			} else {
+				b.WriteString(" WHEN " + c.pred(when.cond))
+			}
+			b.WriteString(" THEN " + c.value(when.result))
+		}
+		if n.els != nil {
+			b.WriteString(" ELSE " + c.value(n.els))
+		}
+		b.WriteString(" END")
+		return b.String()
+	}
+	c.fail(fmt.Errorf("unsupported formula node %T", n))
+	return ""
+}
+
+// quote renders a text literal. MySQL and ClickHouse read backslashes in
+// literals as escapes, so they are doubled there.
+func (c *compiler) quote(text string) string {
+	text = strings.ReplaceAll(text, "'", "''")
+	if c.db == "mysql" || c.db == dbClickHouse {
+		text = strings.ReplaceAll(text, `\`, `\\`)
+	}
+	return "'" + text + "'"
+}
@@ -0,0 +1,336 @@
+package calcfield
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Types of a formula, the deTypes of dataset fields.
+const (
+	TypeText  = 0
+	TypeDate  = 1
+	TypeInt   = 2
+	TypeFloat = 3
+	TypeBool  = 4
+
+	// typeNull is the type of NULL, accepted wherever a value is.
+	typeNull = -1
+)
+
+// Datasource types whose SQL differs from the MySQL-like default.
+const (
+	dbPostgres   = "pg"
+	dbSQLServer  = "sqlServer"
+	dbOracle     = "oracle"
+	dbClickHouse = "ck"
+	dbSQLite     = "sqlite"
+)
+
+func typeName(t int) string {
+	switch t {
+	case TypeText:
+		return "text"
+	case TypeDate:
+		return "date"
+	case TypeInt:
+		return "integer"
+	case TypeFloat:
+		return "decimal"
+	case TypeBool:
+		return "boolean"
+	case typeNull:
+		return "null"
+	}
+	return fmt.Sprintf("type %d", t)
+}
+
+func isNumeric(t int) bool {
+	return t == TypeInt || t == TypeFloat || t == typeNull
+}
+
+// unify returns the type values of types a and b share, promoting integers
+// to decimals.
+func unify(a, b int) (int, bool) {
+	switch {
+	case a == typeNull:
+		return b, true
+	case b == typeNull || a == b:
+		return a, true
+	case isNumeric(a) && isNumeric(b):
+		return TypeFloat, true
+	}
+	return 0, false
+}
+
+type param struct {
+	name string
+	ok   func(int) bool
+}
+
+var (
+	anyParam  = param{"a value", func(int) bool { return true }}
+	numParam  = param{"a number", isNumeric}
+	intParam  = param{"an integer", func(t int) bool { return t == TypeInt || t == typeNull }}
+	textParam = param{"text", func(t int) bool { return t == TypeText || t == typeNull }}
+	dateParam = param{"a date", func(t int) bool { return t == TypeDate || t == typeNull }}
+	boolParam = param{"a condition", func(t int) bool { return t == TypeBool || t == typeNull }}
+)
+
+// argError is a type error of the argument at index.
+type argError struct {
+	index int
+	msg   string
+}
+
+func (e *argError) Error() string { return e.msg }
+
+// function is an entry of the function table. Params lists the arguments,
+// the last one repeating when variadic; Optional is the number of trailing
+// params that may be left out. Result derives the type from the argument
+// types and SQL renders the call for a datasource type.
+type function struct {
+	name      string
+	usage     string
+	desc      string
+	category  string
+	params    []param
+	optional  int
+	variadic  bool
+	aggregate bool
+	result    func(args []int) (int, error)
+	sql       func(db string, args []string) string
+}
+
+func returns(t int) func([]int) (int, error) {
+	return func([]int) (int, error) { return t, nil }
+}
+
+// sameAs returns the type of an argument, decimals for NULL numbers.
+func sameAs(index int) func([]int) (int, error) {
+	return func(args []int) (int, error) {
+		if args[index] == typeNull {
+			return TypeFloat, nil
+		}
+		return args[index], nil
+	}
+}
+
+// common returns the type shared by the arguments from index on.
+func common(from int) func([]int) (int, error) {
+	return func(args []int) (int, error) {
+		result := typeNull
+		for i := from; i < len(args); i++ {
+			t, ok := unify(result, args[i])
+			if !ok {
+				return 0, &argError{index: i, msg: fmt.Sprintf("expected %s, got %s", typeName(result), typeName(args[i]))}
+			}
+			result = t
+		}
+		if result == typeNull {
+			result = TypeText
+		}
+		return result, nil
+	}
+}
+
+func named(name string) func(string, []string) string {
+	return func(_ string, args []string) string {
+		return name + "(" + strings.Join(args, ", ") + ")"
+	}
+}
+
+func byDB(fallback func(string, []string) string, overrides map[string]func([]string) string) func(string, []string) string {
+	return func(db string, args []string) string {
+		if render, ok := overrides[db]; ok {
+			return render(args)
+		}
+		return fallback(db, args)
+	}
+}
+
+func call1(name string) func([]string) string {
+	return func(args []string) string { return name + "(" + strings.Join(args, ", ") + ")" }
+}
+
+func datePart(mysql string, ck string, sqlite string) func(string, []string) string {
+	return byDB(named(mysql), map[string]func([]string) string{
+		dbPostgres:   func(a []string) string { return "CAST(EXTRACT(" + mysql + " FROM " + a[0] + ") AS INTEGER)" },
+		dbOracle:     func(a []string) string { return "EXTRACT(" + mysql + " FROM " + a[0] + ")" },
+		dbClickHouse: call1(ck),
+		dbSQLite:     func(a []string) string { return "CAST(strftime('" + sqlite + "', " + a[0] + ") AS INTEGER)" },
+	})
+}
+
+var functions = map[string]*function{}
+
+func register(fns ...*function) {
+	for _, fn := range fns {
+		functions[fn.name] = fn
+	}
+}
+
+func init() {
+	register(
+		&function{name: "ABS", usage: "ABS(x)", desc: "Absolute value of x", category: "number",
+			params: []param{numParam}, result: sameAs(0), sql: named("ABS")},
+		&function{name: "ROUND", usage: "ROUND(x[, digits])", desc: "x rounded to digits decimals, 0 by default", category: "number",
+			params: []param{numParam, intParam}, optional: 1,
+			result: func(args []int) (int, error) {
+				if len(args) == 1 && args[0] == TypeInt {
+					return TypeInt, nil
+				}
+				return TypeFloat, nil
+			}, sql: named("ROUND")},
+		&function{name: "FLOOR", usage: "FLOOR(x)", desc: "Largest integer not greater than x", category: "number",
+			params: []param{numParam}, result: returns(TypeInt), sql: named("FLOOR")},
+		&function{name: "CEIL", usage: "CEIL(x)", desc: "Smallest integer not less than x", category: "number",
+			params: []param{numParam}, result: returns(TypeInt),
+			sql: byDB(named("CEIL"), map[string]func([]string) string{dbSQLServer: call1("CEILING")})},
+		&function{name: "POWER", usage: "POWER(x, y)", desc: "x raised to the power y", category: "number",
+			params: []param{numParam, numParam}, result: returns(TypeFloat), sql: named("POWER")},
+		&function{name: "SQRT", usage: "SQRT(x)", desc: "Square root of x", category: "number",
+			params: []param{numParam}, result: returns(TypeFloat), sql: named("SQRT")},
+
+		&function{name: "CONCAT", usage: "CONCAT(a, b, ...)", desc: "Text values joined together", category: "text",
+			params: []param{anyParam}, variadic: true, result: returns(TypeText),
+			sql: byDB(func(_ string, args []string) string {
+				if len(args) == 1 {
+					return "CONCAT(" + args[0] + ", '')"
+				}
+				return "CONCAT(" + strings.Join(args, ", ") + ")"
+			}, map[string]func([]string) string{
+				dbOracle: func(a []string) string { return "(" + strings.Join(a, " || ") + ")" },
+				dbSQLite: func(a []string) string { return "(" + strings.Join(a, " || ") + ")" },
+			})},
+		&function{name: "UPPER", usage: "UPPER(text)", desc: "Text in upper case", category: "text",
+			params: []param{textParam}, result: returns(TypeText), sql: named("UPPER")},
+		&function{name: "LOWER", usage: "LOWER(text)", desc: "Text in lower case", category: "text",
+			params: []param{textParam}, result: returns(TypeText), sql: named("LOWER")},
+		&function{name: "TRIM", usage: "TRIM(text)", desc: "Text without leading and trailing spaces", category: "text",
+			params: []param{textParam}, result: returns(TypeText), sql: named("TRIM")},
+		&function{name: "LENGTH", usage: "LENGTH(text)", desc: "Number of characters of text", category: "text",
+			params: []param{textParam}, result: returns(TypeInt),
+			sql: byDB(named("LENGTH"), map[string]func([]string) string{
+				"mysql":      call1("CHAR_LENGTH"),
+				dbSQLServer:  call1("LEN"),
+				dbClickHouse: call1("lengthUTF8"),
+			})},
+		&function{name: "SUBSTRING", usage: "SUBSTRING(text, start[, length])", desc: "Part of text from the 1-based start", category: "text",
+			params: []param{textParam, intParam, intParam}, optional: 1, result: returns(TypeText),
+			sql: byDB(named("SUBSTRING"), map[string]func([]string) string{
+				dbOracle: call1("SUBSTR"),
+				dbSQLite: call1("SUBSTR"),
+				dbSQLServer: func(a []string) string {
+					if len(a) == 2 {
+						a = append(a, "LEN("+a[0]+")")
+					}
+					return "SUBSTRING(" + strings.Join(a, ", ") + ")"
+				},
+			})},
+		&function{name: "LEFT", usage: "LEFT(text, n)", desc: "First n characters of text", category: "text",
+			params: []param{textParam, intParam}, result: returns(TypeText),
+			sql: byDB(named("LEFT"), map[string]func([]string) string{
+				dbOracle: func(a []string) string { return "SUBSTR(" + a[0] + ", 1, " + a[1] + ")" },
+				dbSQLite: func(a []string) string { return "SUBSTR(" + a[0] + ", 1, " + a[1] + ")" },
+			})},
+		&function{name: "RIGHT", usage: "RIGHT(text, n)", desc: "Last n characters of text", category: "text",
+			params: []param{textParam, intParam}, result: returns(TypeText),
+			sql: byDB(named("RIGHT"), map[string]func([]string) string{
+				dbOracle: func(a []string) string { return "SUBSTR(" + a[0] + ", -(" + a[1] + "))" },
+				dbSQLite: func(a []string) string { return "SUBSTR(" + a[0] + ", -(" + a[1] + "))" },
+			})},
+		&function{name: "REPLACE", usage: "REPLACE(text, from, to)", desc: "Text with every from replaced by to", category: "text",
+			params: []param{textParam, textParam, textParam}, result: returns(TypeText),
+			sql: byDB(named("REPLACE"), map[string]func([]string) string{dbClickHouse: call1("replaceAll")})},
+
+		&function{name: "YEAR", usage: "YEAR(date)", desc: "Year of date", category: "date",
+			params: []param{dateParam}, result: returns(TypeInt), sql: datePart("YEAR", "toYear", "%Y")},
+		&function{name: "MONTH", usage: "MONTH(date)", desc: "Month of date, from 1 to 12", category: "date",
+			params: []param{dateParam}, result: returns(TypeInt), sql: datePart("MONTH", "toMonth", "%m")},
+		&function{name: "DAY", usage: "DAY(date)", desc: "Day of the month of date", category: "date",
+			params: []param{dateParam}, result: returns(TypeInt), sql: datePart("DAY", "toDayOfMonth", "%d")},
+		&function{name: "NOW", usage: "NOW()", desc: "Current date and time", category: "date",
+			result: returns(TypeDate),
+			sql: byDB(named("NOW"), map[string]func([]string) string{
+				dbSQLServer:  func([]string) string { return "GETDATE()" },
+				dbOracle:     func([]string) string { return "SYSDATE" },
+				dbClickHouse: call1("now"),
+				dbSQLite:     func([]string) string { return "datetime('now')" },
+			})},
+		&function{name: "DATEDIFF", usage: "DATEDIFF(end, start)", desc: "Days from start to end", category: "date",
+			params: []param{dateParam, dateParam}, result: returns(TypeInt),
+			sql: byDB(named("DATEDIFF"), map[string]func([]string) string{
+				dbPostgres:   func(a []string) string { return "(CAST(" + a[0] + " AS DATE) - CAST(" + a[1] + " AS DATE))" },
+				dbSQLServer:  func(a []string) string { return "DATEDIFF(day, " + a[1] + ", " + a[0] + ")" },
+				dbOracle:     func(a []string) string { return "(TRUNC(" + a[0] + ") - TRUNC(" + a[1] + "))" },
+				dbClickHouse: func(a []string) string { return "dateDiff('day', " + a[1] + ", " + a[0] + ")" },
+				dbSQLite: func(a []string) string {
+					return "CAST(julianday(date(" + a[0] + ")) - julianday(date(" + a[1] + ")) AS INTEGER)"
+				},
+			})},
+		&function{name: "DATEADD", usage: "DATEADD(date, days)", desc: "date moved by a number of days", category: "date",
+			params: []param{dateParam, intParam}, result: returns(TypeDate),
+			sql: byDB(func(_ string, a []string) string {
+				return "DATE_ADD(" + a[0] + ", INTERVAL " + a[1] + " DAY)"
+			}, map[string]func([]string) string{
+				dbPostgres:   func(a []string) string { return "(" + a[0] + " + " + a[1] + " * INTERVAL '1 day')" },
+				dbSQLServer:  func(a []string) string { return "DATEADD(day, " + a[1] + ", " + a[0] + ")" },
+				dbOracle:     func(a []string) string { return "(" + a[0] + " + " + a[1] + ")" },
+				dbClickHouse: call1("addDays"),
+				dbSQLite:     func(a []string) string { return "datetime(" + a[0] + ", printf('%+d days', " + a[1] + "))" },
+			})},
+
+		&function{name: "IF", usage: "IF(condition, then, else)", desc: "then when condition holds, else otherwise", category: "logic",
+			params: []param{boolParam, anyParam, anyParam}, result: common(1),
+			sql: func(_ string, a []string) string {
+				return "CASE WHEN " + a[0] + " THEN " + a[1] + " ELSE " + a[2] + " END"
+			}},
+		&function{name: "IFNULL", usage: "IFNULL(x, fallback)", desc: "fallback when x is null", category: "logic",
+			params: []param{anyParam, anyParam}, result: common(0), sql: named("COALESCE")},
+		&function{name: "COALESCE", usage: "COALESCE(a, b, ...)", desc: "First value that is not null", category: "logic",
+			params: []param{anyParam}, variadic: true, result: common(0), sql: named("COALESCE")},
+		&function{name: "ISNULL", usage: "ISNULL(x)", desc: "Whether x is null", category: "logic",
+			params: []param{anyParam}, result: returns(TypeBool),
+			sql: func(_ string, a []string) string { return "(" + a[0] + " IS NULL)" }},
+
+		&function{name: "SUM", usage: "SUM(x)", desc: "Sum of x", category: "aggregate",
+			params: []param{numParam}, aggregate: true, result: sameAs(0), sql: named("SUM")},
+		&function{name: "AVG", usage: "AVG(x)", desc: "Average of x", category: "aggregate",
+			params: []param{numParam}, aggregate: true, result: returns(TypeFloat), sql: named("AVG")},
+		&function{name: "MIN", usage: "MIN(x)", desc: "Smallest x", category: "aggregate",
+			params: []param{anyParam}, aggregate: true, result: sameAs(0), sql: named("MIN")},
+		&function{name: "MAX", usage: "MAX(x)", desc: "Largest x", category: "aggregate",
+			params: []param{anyParam}, aggregate: true, result: sameAs(0), sql: named("MAX")},
+		&function{name: "COUNT", usage: "COUNT(x)", desc: "Number of rows where x is not null", category: "aggregate",
+			params: []param{anyParam}, aggregate: true, result: returns(TypeInt), sql: named("COUNT")},
+		&function{name: "COUNT_DISTINCT", usage: "COUNT_DISTINCT(x)", desc: "Number of distinct values of x", category: "aggregate",
+			params: []param{anyParam}, aggregate: true, result: returns(TypeInt),
+			sql: func(_ string, a []string) string { return "COUNT(DISTINCT " + a[0] + ")" }},
+	)
+}
+
+// FunctionDoc describes a function of the formula language for the formula
+// editor.
+type FunctionDoc struct {
+	Name      string `json:"name"`
+	Func      string `json:"func"`
+	Desc      string `json:"desc"`
+	Category  string `json:"category"`
+	Aggregate bool   `json:"aggregate"`
+}
+
+// Functions lists the functions formulas may call, by category and name.
+func Functions() []FunctionDoc {
+	docs := make([]FunctionDoc, 0, len(functions))
+	for _, fn := range functions {
+		docs = append(docs, FunctionDoc{Name: fn.name, Func: fn.usage, Desc: fn.desc, Category: fn.category, Aggregate: fn.aggregate})
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Category != docs[j].Category {
+			return docs[i].Category < docs[j].Category
+		}
+		return docs[i].Name < docs[j].Name
+	})
+	return docs
+}
@@ -0,0 +1,519 @@
+// Package calcfield implements the formulas of calculated fields. A formula
+// references other fields of its dataset as [id] and combines them with
+// arithmetic, comparisons, CASE and the functions of the function table; it
+// is type checked into a deType and compiled to the SQL of a datasource.
+package calcfield
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error is an invalid formula. Pos is the 1-based character position of the
+// offending token.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokField
+	tokNumber
+	tokString
+	tokName
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of formula"
+	}
+	return strconv.Quote(t.text)
+}
+
+var operators = []string{"<=", ">=", "<>", "!=", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ","}
+
+func lex(text string) ([]token, error) {
+	tokens := make([]token, 0, len(text)/2)
+	pos := 1
+	for i := 0; i < len(text); {
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		start := pos
+		switch {
+		case unicode.IsSpace(ch):
+			i += size
+			pos++
+		case ch == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, &Error{Pos: start, Msg: "unterminated field reference"}
+			}
+			id := strings.TrimSpace(text[i+1 : i+end])
+			if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+				return nil, &Error{Pos: start, Msg: fmt.Sprintf("invalid field reference [%s]", id)}
+			}
+			tokens = append(tokens, token{kind: tokField, text: id, pos: start})
+			pos += utf8.RuneCountInString(text[i : i+end+1])
+			i += end + 1
+		case ch == '\'' || ch == '"':
+			var value strings.Builder
+			j := i + 1
+			for {
+				if j >= len(text) {
+					return nil, &Error{Pos: start, Msg: "unterminated string"}
+				}
+				if text[j] == byte(ch) {
+					if j+1 < len(text) && text[j+1] == byte(ch) {
+						value.WriteByte(byte(ch))
+						j += 2
+						continue
+					}
+					break
+				}
+				value.WriteByte(text[j])
+				j++
+			}
+			tokens = append(tokens, token{kind: tokString, text: value.String(), pos: start})
+			pos += utf8.RuneCountInString(text[i : j+1])
+			i = j + 1
+		case ch >= '0' && ch <= '9' || (ch == '.' && i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9'):
+			j := i
+			for j < len(text) && (text[j] >= '0' && text[j] <= '9' || text[j] == '.') {
+				j++
+			}
+			number := text[i:j]
+			if _, err := strconv.ParseFloat(number, 64); err != nil {
+				return nil, &Error{Pos: start, Msg: fmt.Sprintf("invalid number %s", number)}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: number, pos: start})
+			pos += j - i
+			i = j
+		case ch == '_' || unicode.IsLetter(ch):
+			j := i
+			for j < len(text) {
+				r, n := utf8.DecodeRuneInString(text[j:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += n
+			}
+			tokens = append(tokens, token{kind: tokName, text: strings.ToUpper(text[i:j]), pos: start})
+			pos += utf8.RuneCountInString(text[i:j])
+			i = j
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(text[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &Error{Pos: start, Msg: fmt.Sprintf("unexpected character %q", ch)}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: start})
+			pos += len(op)
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: pos}), nil
+}
+
+// Syntax tree of a formula. Every node records the position it starts at.
+type node interface {
+	position() int
+}
+
+type (
+	literal struct {
+		pos  int
+		kind tokenKind
+		text string
+	}
+	boolLit struct {
+		pos   int
+		value bool
+	}
+	nullLit struct {
+		pos int
+	}
+	fieldRef struct {
+		pos int
+		id  int64
+	}
+	unary struct {
+		pos int
+		op  string
+		x   node
+	}
+	binary struct {
+		pos  int
+		op   string
+		l, r node
+	}
+	call struct {
+		pos  int
+		name string
+		args []node
+	}
+	caseExpr struct {
+		pos     int
+		operand node
+		whens   []whenClause
+		els     node
+	}
+	whenClause struct {
+		cond, result node
+	}
+)
+
+func (n *literal) position() int  { return n.pos }
+func (n *boolLit) position() int  { return n.pos }
+func (n *nullLit) position() int  { return n.pos }
+func (n *fieldRef) position() int { return n.pos }
+func (n *unary) position() int    { return n.pos }
+func (n *binary) position() int   { return n.pos }
+func (n *call) position() int     { return n.pos }
+func (n *caseExpr) position() int { return n.pos }
+
+// Formula is a parsed formula.
+type Formula struct {
+	root node
+}
+
+// Parse parses a formula.
+func Parse(text string) (*Formula, error) {
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, &Error{Pos: 1, Msg: "formula is empty"}
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", tok)
+	}
+	return &Formula{root: root}, nil
+}
+
+// Refs returns the ids of the fields the formula references, in order of
+// first appearance.
+func (f *Formula) Refs() []int64 {
+	ids := make([]int64, 0)
+	seen := make(map[int64]bool)
+	walk(f.root, func(n node) {
+		if ref, ok := n.(*fieldRef); ok && !seen[ref.id] {
+			seen[ref.id] = true
+			ids = append(ids, ref.id)
+		}
+	})
+	return ids
+}
+
+func walk(n node, fn func(node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	switch n := n.(type) {
+	case *unary:
+		walk(n.x, fn)
+	case *binary:
+		walk(n.l, fn)
+		walk(n.r, fn)
+	case *call:
+		for _, arg := range n.args {
+			walk(arg, fn)
+		}
+	case *caseExpr:
+		walk(n.operand, fn)
+		for _, when := range n.whens {
+			walk(when.cond, fn)
+			walk(when.result, fn)
+		}
+		walk(n.els, fn)
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Pos: p.peek().pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isName(name string) bool {
+	tok := p.peek()
+	return tok.kind == tokName && tok.text == name
+}
+
+func (p *parser) acceptName(name string) bool {
+	if p.isName(name) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectName(name string) error {
+	if !p.acceptName(name) {
+		return p.errorf("expected %s, got %s", name, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("expected %s, got %s", op, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isName("OR") {
+		tok := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{pos: tok.pos, op: "OR", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isName("AND") {
+		tok := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{pos: tok.pos, op: "AND", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isName("NOT") {
+		tok := p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{pos: tok.pos, op: "NOT", x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("=", "!=", "<>", "<", "<=", ">", ">=") {
+		tok := p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		op := tok.text
+		if op == "!=" {
+			op = "<>"
+		}
+		return &binary{pos: tok.pos, op: op, l: left, r: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		tok := p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{pos: tok.pos, op: tok.text, l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") {
+		tok := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{pos: tok.pos, op: tok.text, l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-", "+") {
+		tok := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if tok.text == "+" {
+			return x, nil
+		}
+		return &unary{pos: tok.pos, op: "-", x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokField:
+		p.next()
+		id, _ := strconv.ParseInt(tok.text, 10, 64)
+		return &fieldRef{pos: tok.pos, id: id}, nil
+	case tokNumber, tokString:
+		p.next()
+		return &literal{pos: tok.pos, kind: tok.kind, text: tok.text}, nil
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expectOp(")")
+		}
+	case tokName:
+		switch tok.text {
+		case "TRUE", "FALSE":
+			p.next()
+			return &boolLit{pos: tok.pos, value: tok.text == "TRUE"}, nil
+		case "NULL":
+			p.next()
+			return &nullLit{pos: tok.pos}, nil
+		case "CASE":
+			p.next()
+			return p.parseCase(tok.pos)
+		}
+		p.next()
+		if !p.isOp("(") {
+			return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unknown name %s, fields are referenced as [id]", tok.text)}
+		}
+		p.next()
+		c := &call{pos: tok.pos, name: tok.text}
+		if p.isOp(")") {
+			p.next()
+			return c, nil
+		}
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		return c, p.expectOp(")")
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+func (p *parser) parseCase(pos int) (node, error) {
+	c := &caseExpr{pos: pos}
+	var err error
+	if !p.isName("WHEN") {
+		if c.operand, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptName("WHEN") {
+		var when whenClause
+		if when.cond, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if err = p.expectName("THEN"); err != nil {
+			return nil, err
+		}
+		if when.result, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		c.whens = append(c.whens, when)
+	}
+	if len(c.whens) == 0 {
+		return nil, p.errorf("expected WHEN, got %s", p.peek())
+	}
+	if p.acceptName("ELSE") {
+		if c.els, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	return c, p.expectName("END")
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/domain/msgcenter"
 	"dataease/backend/internal/domain/org"
 	"dataease/backend/internal/domain/permission"
 	"dataease/backend/internal/domain/role"
@@ -23,11 +26,19 @@ func AutoMigrate(db *gorm.DB) error {
 		&static.StaticResource{},
 		&static.Store{},
 		&static.Typeface{},
+		&datasource.CoreDatasourceHealth{},
+		&msgcenter.CoreMessage{},
+		&dataset.CoreDatasetVersion{},
+		&dataset.CoreDatasetProfile{},
+		&permission.CoreColumnPermission{},
 	}
 
 	if err := db.AutoMigrate(models...); err != nil {
 		return err
 	}
+	if err := addMissingColumns(db, &dataset.CoreDatasetTable{}, "Status", "LastUpdate", "Info"); err != nil {
+		return err
+	}
 
 	applogger.Info("Database migration completed",
 		zap.Int("tables", len(models)),
@@ -35,3 +46,21 @@ func AutoMigrate(db *gorm.DB) error {
 
 	return nil
 }
+
+// addMissingColumns adds the given fields to a table shared with the Java
+// schema without migrating the rest of its model.
+func addMissingColumns(db *gorm.DB, model interface{}, fields ...string) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(model) {
+		return nil
+	}
+	for _, field := range fields {
+		if migrator.HasColumn(model, field) {
+			continue
+		}
+		if err := migrator.AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
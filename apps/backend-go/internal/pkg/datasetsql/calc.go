@@ -0,0 +1,48 @@
+package datasetsql
+
+import (
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/calcfield"
+)
+
+// ExtFieldCalculated marks a calculated field. Its origin name holds the
+// formula instead of a column name.
+const ExtFieldCalculated = 2
+
+func isCalculated(field *dataset.CoreDatasetTableField) bool {
+	return field.ExtField != nil && *field.ExtField == ExtFieldCalculated
+}
+
+// CalcFields returns the fields of a dataset in the form formulas are
+// checked and compiled with.
+func CalcFields(fields []*dataset.CoreDatasetTableField) []calcfield.Field {
+	result := make([]calcfield.Field, 0, len(fields))
+	for _, field := range fields {
+		f := calcfield.Field{ID: field.ID}
+		if field.DeType != nil {
+			f.DeType = *field.DeType
+		}
+		if isCalculated(field) && field.OriginName != nil {
+			f.Formula = *field.OriginName
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// FormulaRefs maps each calculated field to the fields its formula
+// references. Formulas that do not parse are left out.
+func FormulaRefs(fields []*dataset.CoreDatasetTableField) map[int64][]int64 {
+	refs := make(map[int64][]int64)
+	for _, field := range fields {
+		if !isCalculated(field) || field.OriginName == nil {
+			continue
+		}
+		formula, err := calcfield.Parse(*field.OriginName)
+		if err != nil {
+			continue
+		}
+		refs[field.ID] = formula.Refs()
+	}
+	return refs
+}
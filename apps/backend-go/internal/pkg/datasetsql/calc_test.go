@@ -0,0 +1,92 @@
+package datasetsql
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func calcField(id int64, formula string, dataeaseName string) *dataset.CoreDatasetTableField {
+	extField := ExtFieldCalculated
+	return &dataset.CoreDatasetTableField{
+		ID:           id,
+		OriginName:   strPtr(formula),
+		DataeaseName: strPtr(dataeaseName),
+		ExtField:     &extField,
+	}
+}
+
+func TestCompile_CalcFields(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/calc.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE orders (id INTEGER, amount INTEGER, customer_id INTEGER)`,
+		`CREATE TABLE customers (id INTEGER, name TEXT)`,
+		`INSERT INTO orders VALUES (1, 10, 1), (2, 20, 2)`,
+		`INSERT INTO customers VALUES (1, 'alice'), (2, 'bob')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	for _, model := range []*dataset.Model{nil, joinModel(dataset.JoinInner)} {
+		def := testDefinition(model)
+		deType := 2
+		def.Fields[1].DeType = &deType
+		def.Fields = append(def.Fields,
+			calcField(31, "[12] * 2", "double_amount"),
+			calcField(32, "SUM([12])", "total"),
+			calcField(33, "[99] + 1", "broken"),
+		)
+		if model != nil {
+			def.Fields = append(def.Fields, calcField(34, "CONCAT(UPPER([22]), '#', [31])", "label"))
+		}
+		source, err := Compile(testDialect{}, def, nil)
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		if !source.Exposes(def.Fields[5]) || source.Exposes(def.Fields[6]) || source.Exposes(def.Fields[7]) {
+			t.Fatalf("unexpected exposed calculated fields: %s", source.From)
+		}
+		if column := source.Column(def.Fields[5]); column != "double_amount" {
+			t.Fatalf("unexpected column: %s", column)
+		}
+
+		query := `SELECT SUM("double_amount") FROM ` + source.From
+		if model != nil {
+			query = `SELECT SUM("double_amount"), GROUP_CONCAT("label") FROM ` + source.From
+		}
+		var total int
+		var labels sql.NullString
+		dest := []interface{}{&total}
+		if model != nil {
+			dest = append(dest, &labels)
+		}
+		if err = db.QueryRow(query).Scan(dest...); err != nil {
+			t.Fatalf("query %s: %v", query, err)
+		}
+		if total != 60 {
+			t.Fatalf("unexpected total: %d", total)
+		}
+		if model != nil && !strings.Contains(labels.String, "ALICE#20") {
+			t.Fatalf("unexpected labels: %s", labels.String)
+		}
+	}
+}
+
+func TestFormulaRefs(t *testing.T) {
+	refs := FormulaRefs([]*dataset.CoreDatasetTableField{
+		{ID: 1, OriginName: strPtr("name")},
+		calcField(2, "CONCAT([1], [1], '')", "f_2"),
+		calcField(3, "[2] +", "f_3"),
+	})
+	if len(refs) != 1 || len(refs[2]) != 1 || refs[2][0] != 1 {
+		t.Errorf("unexpected refs: %v", refs)
+	}
+}
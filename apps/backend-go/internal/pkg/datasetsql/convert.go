@@ -0,0 +1,385 @@
+package datasetsql
+
+import (
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+// deTypes of dataset fields.
+const (
+	deText     = 0
+	deTime     = 1
+	deInt      = 2
+	deFloat    = 3
+	deBool     = 4
+	deLocation = 5
+	deURL      = 7
+)
+
+// DefaultDateFormat is the pattern text is parsed with when a field converted
+// to a date has no date format.
+const DefaultDateFormat = "yyyy-MM-dd HH:mm:ss"
+
+// converts reports whether a field is read as another type than the column
+// it is read from.
+func converts(field *dataset.CoreDatasetTableField) bool {
+	if isCalculated(field) || field.DeType == nil || field.DeExtractType == nil {
+		return false
+	}
+	return baseType(*field.DeType) != baseType(*field.DeExtractType)
+}
+
+// baseType folds the deTypes that are text in the database.
+func baseType(deType int) int {
+	switch deType {
+	case deLocation, deURL:
+		return deText
+	}
+	return deType
+}
+
+// CheckConversion reports whether the deType of a field can be read from its
+// column, and whether the date format of a text column read as a date is
+// valid.
+func CheckConversion(field *dataset.CoreDatasetTableField) error {
+	if !converts(field) {
+		return nil
+	}
+	_, err := convert("", field, "")
+	return err
+}
+
+// convert returns the SQL of the datasource type db reading expr, the column
+// of field, as the deType of the field. Values the column cannot be read as
+// become NULL rather than failing the query.
+func convert(db string, field *dataset.CoreDatasetTableField, expr string) (string, error) {
+	from, to := baseType(*field.DeExtractType), baseType(*field.DeType)
+	switch {
+	case from == deText && to == deTime:
+		format := DefaultDateFormat
+		if field.DateFormat != nil && strings.TrimSpace(*field.DateFormat) != "" {
+			format = strings.TrimSpace(*field.DateFormat)
+		}
+		pattern, err := parseDatePattern(format)
+		if err != nil {
+			return "", err
+		}
+		return pattern.parse(db, expr), nil
+	case from == deText && (to == deInt || to == deFloat):
+		return textToNumber(db, expr, to == deInt), nil
+	case from == deText && to == deBool:
+		trimmed := "LOWER(TRIM(" + expr + "))"
+		return "CASE WHEN " + trimmed + " IN ('1', 'true', 'yes', 'y') THEN 1 WHEN " +
+			trimmed + " IN ('0', 'false', 'no', 'n') THEN 0 END", nil
+	case (from == deInt || from == deFloat || from == deBool) && to == deText:
+		return numberToText(db, expr), nil
+	case (from == deInt || from == deBool) && to == deFloat:
+		return "(" + expr + " * 1.0)", nil
+	case (from == deFloat || from == deBool) && to == deInt:
+		return castInt(db, "ROUND("+expr+")"), nil
+	case (from == deInt || from == deFloat) && to == deTime:
+		return unixToTime(db, expr), nil
+	case from == deTime && (to == deInt || to == deFloat):
+		return timeToUnix(db, expr), nil
+	case from == deTime && to == deText:
+		return timeToText(db, expr), nil
+	}
+	return "", fmt.Errorf("cannot convert %s to %s", deTypeName(from), deTypeName(to))
+}
+
+func deTypeName(deType int) string {
+	switch deType {
+	case deText:
+		return "text"
+	case deTime:
+		return "date"
+	case deInt:
+		return "integer"
+	case deFloat:
+		return "decimal"
+	case deBool:
+		return "boolean"
+	}
+	return fmt.Sprintf("type %d", deType)
+}
+
+// textToNumber guards the cast with a pattern where the datasource has no
+// cast returning NULL.
+func textToNumber(db string, expr string, integer bool) string {
+	switch db {
+	case "sqlServer":
+		if integer {
+			return "TRY_CAST(" + expr + " AS BIGINT)"
+		}
+		return "TRY_CAST(" + expr + " AS FLOAT)"
+	case "oracle":
+		if integer {
+			return "CAST(" + expr + " AS NUMBER(19) DEFAULT NULL ON CONVERSION ERROR)"
+		}
+		return "CAST(" + expr + " AS BINARY_DOUBLE DEFAULT NULL ON CONVERSION ERROR)"
+	case "ck":
+		if integer {
+			return "toInt64OrNull(trimBoth(toString(" + expr + ")))"
+		}
+		return "toFloat64OrNull(trimBoth(toString(" + expr + ")))"
+	case "sqlite":
+		digits := "LTRIM(TRIM(" + expr + "), '+-')"
+		valid := digits + " GLOB '[0-9]*' AND " + digits + " NOT GLOB '*[^0-9]*'"
+		if integer {
+			return "CASE WHEN " + valid + " THEN CAST(TRIM(" + expr + ") AS INTEGER) END"
+		}
+		valid = digits + " GLOB '[0-9]*' AND " + digits + " NOT GLOB '*[^0-9.]*' AND " +
+			digits + " NOT GLOB '*.*.*'"
+		return "CASE WHEN " + valid + " THEN CAST(TRIM(" + expr + ") AS REAL) END"
+	}
+
+	pattern := "^[[:space:]]*[-+]?[0-9]+[[:space:]]*$"
+	if !integer {
+		pattern = "^[[:space:]]*[-+]?[0-9]+([.][0-9]+)?[[:space:]]*$"
+	}
+	if db == "pg" {
+		target := "BIGINT"
+		if !integer {
+			target = "DOUBLE PRECISION"
+		}
+		return "CASE WHEN " + expr + " ~ '" + pattern + "' THEN CAST(TRIM(" + expr + ") AS " + target + ") END"
+	}
+	target := "SIGNED"
+	if !integer {
+		target = "DECIMAL(38, 10)"
+	}
+	return "CASE WHEN " + expr + " REGEXP '" + pattern + "' THEN CAST(TRIM(" + expr + ") AS " + target + ") END"
+}
+
+func castInt(db string, expr string) string {
+	switch db {
+	case "mysql":
+		return "CAST(" + expr + " AS SIGNED)"
+	case "oracle":
+		return "CAST(" + expr + " AS NUMBER(19))"
+	case "ck":
+		return "toInt64(" + expr + ")"
+	case "sqlite":
+		return "CAST(" + expr + " AS INTEGER)"
+	}
+	return "CAST(" + expr + " AS BIGINT)"
+}
+
+func numberToText(db string, expr string) string {
+	switch db {
+	case "mysql":
+		return "CAST(" + expr + " AS CHAR)"
+	case "sqlServer":
+		return "CAST(" + expr + " AS VARCHAR(64))"
+	case "oracle":
+		return "TO_CHAR(" + expr + ")"
+	case "ck":
+		return "toString(" + expr + ")"
+	case "sqlite":
+		return "CAST(" + expr + " AS TEXT)"
+	}
+	return "CAST(" + expr + " AS VARCHAR)"
+}
+
+// unixToTime reads numbers as seconds since the epoch.
+func unixToTime(db string, expr string) string {
+	switch db {
+	case "mysql":
+		return "FROM_UNIXTIME(" + expr + ")"
+	case "pg":
+		return "TO_TIMESTAMP(" + expr + ")"
+	case "sqlServer":
+		return "DATEADD(SECOND, " + expr + ", CAST('1970-01-01' AS DATETIME2))"
+	case "oracle":
+		return "(TIMESTAMP '1970-01-01 00:00:00' + NUMTODSINTERVAL(" + expr + ", 'SECOND'))"
+	case "ck":
+		return "toDateTime(" + expr + ")"
+	case "sqlite":
+		return "DATETIME(" + expr + ", 'unixepoch')"
+	}
+	return expr
+}
+
+func timeToUnix(db string, expr string) string {
+	switch db {
+	case "mysql":
+		return "UNIX_TIMESTAMP(" + expr + ")"
+	case "pg":
+		return "CAST(EXTRACT(EPOCH FROM " + expr + ") AS BIGINT)"
+	case "sqlServer":
+		return "DATEDIFF_BIG(SECOND, CAST('1970-01-01' AS DATETIME2), " + expr + ")"
+	case "oracle":
+		return "ROUND((CAST(" + expr + " AS DATE) - DATE '1970-01-01') * 86400)"
+	case "ck":
+		return "toUnixTimestamp(" + expr + ")"
+	case "sqlite":
+		return "CAST(STRFTIME('%s', " + expr + ") AS INTEGER)"
+	}
+	return expr
+}
+
+func timeToText(db string, expr string) string {
+	switch db {
+	case "mysql":
+		return "DATE_FORMAT(" + expr + ", '%Y-%m-%d %H:%i:%s')"
+	case "pg", "oracle":
+		return "TO_CHAR(" + expr + ", 'YYYY-MM-DD HH24:MI:SS')"
+	case "sqlServer":
+		return "CONVERT(VARCHAR(19), " + expr + ", 120)"
+	case "ck":
+		return "formatDateTime(" + expr + ", '%Y-%m-%d %H:%M:%S')"
+	case "sqlite":
+		return "STRFTIME('%Y-%m-%d %H:%M:%S', " + expr + ")"
+	}
+	return expr
+}
+
+// datePattern is a date format such as yyyy/MM/dd HH:mm. Its fields are
+// fixed width, so every field sits at a known offset of the text.
+type datePattern struct {
+	parts []datePart
+	// offsets holds the 1-based offset of each field present, by token.
+	offsets map[string]int
+	length  int
+}
+
+// datePart is a field of a date pattern, or literal text when field is nil.
+type datePart struct {
+	field   *datePatternField
+	literal string
+}
+
+type datePatternField struct {
+	token    string
+	mysql    string
+	postgres string
+}
+
+var datePatternFields = []datePatternField{
+	{"yyyy", "%Y", "YYYY"},
+	{"MM", "%m", "MM"},
+	{"dd", "%d", "DD"},
+	{"HH", "%H", "HH24"},
+	{"mm", "%i", "MI"},
+	{"ss", "%s", "SS"},
+}
+
+func parseDatePattern(format string) (*datePattern, error) {
+	p := &datePattern{offsets: make(map[string]int)}
+	runes := []rune(format)
+next:
+	for i := 0; i < len(runes); {
+		for j := range datePatternFields {
+			field := &datePatternFields[j]
+			if !strings.HasPrefix(string(runes[i:]), field.token) {
+				continue
+			}
+			if _, dup := p.offsets[field.token]; dup {
+				return nil, fmt.Errorf("date format %q repeats %s", format, field.token)
+			}
+			p.offsets[field.token] = i + 1
+			p.parts = append(p.parts, datePart{field: field})
+			i += len(field.token)
+			continue next
+		}
+		r := runes[i]
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return nil, fmt.Errorf("date format %q: unsupported pattern letter %c, use yyyy, MM, dd, HH, mm and ss", format, r)
+		}
+		if r == '\'' || r == '%' || r == '\\' {
+			return nil, fmt.Errorf("date format %q: unsupported character %c", format, r)
+		}
+		p.parts = append(p.parts, datePart{literal: string(r)})
+		i++
+	}
+	if _, ok := p.offsets["yyyy"]; !ok {
+		return nil, fmt.Errorf("date format %q has no year (yyyy)", format)
+	}
+	p.length = len(runes)
+	return p, nil
+}
+
+// render writes the pattern with the code of each field and the literal
+// text between them.
+func (p *datePattern) render(field func(f *datePatternField) string, literal func(text string) string) string {
+	var b strings.Builder
+	for _, part := range p.parts {
+		if part.field != nil {
+			b.WriteString(field(part.field))
+		} else {
+			b.WriteString(literal(part.literal))
+		}
+	}
+	return b.String()
+}
+
+// regexp matches the text of the pattern.
+func (p *datePattern) regexp() string {
+	return "^" + p.render(
+		func(f *datePatternField) string { return fmt.Sprintf("[0-9]{%d}", len(f.token)) },
+		func(text string) string {
+			if strings.ContainsAny(text, ".^$*+?()[]{}|") {
+				return "[" + text + "]"
+			}
+			return text
+		}) + "$"
+}
+
+// iso rebuilds the text as yyyy-MM-dd HH:mm:ss from the fields of the
+// pattern, which the datasource then parses strictly.
+func (p *datePattern) iso(substr func(offset, width int) string) []string {
+	part := func(token string, fallback string) string {
+		offset, ok := p.offsets[token]
+		if !ok {
+			return "'" + fallback + "'"
+		}
+		return substr(offset, len(token))
+	}
+	return []string{
+		part("yyyy", ""), "'-'", part("MM", "01"), "'-'", part("dd", "01"), "' '",
+		part("HH", "00"), "':'", part("mm", "00"), "':'", part("ss", "00"),
+	}
+}
+
+func (p *datePattern) parse(db string, expr string) string {
+	keep := func(text string) string { return text }
+	switch db {
+	case "mysql", "ck":
+		format := p.render(func(f *datePatternField) string { return f.mysql }, keep)
+		if db == "ck" {
+			return "parseDateTimeOrNull(" + expr + ", '" + format + "')"
+		}
+		return "STR_TO_DATE(" + expr + ", '" + format + "')"
+	case "pg":
+		format := p.render(func(f *datePatternField) string { return f.postgres }, quotePostgresLiteral)
+		return "CASE WHEN " + expr + " ~ '" + p.regexp() + "' THEN TO_TIMESTAMP(" + expr + ", '" + format + "') END"
+	case "oracle":
+		format := p.render(func(f *datePatternField) string { return f.postgres }, quotePostgresLiteral)
+		return "TO_TIMESTAMP(" + expr + " DEFAULT NULL ON CONVERSION ERROR, '" + format + "')"
+	case "sqlServer":
+		iso := p.iso(func(offset, width int) string {
+			return fmt.Sprintf("SUBSTRING(%s, %d, %d)", expr, offset, width)
+		})
+		return fmt.Sprintf("CASE WHEN LEN(%s) = %d THEN TRY_CONVERT(DATETIME2, CONCAT(%s), 120) END",
+			expr, p.length, strings.Join(iso, ", "))
+	case "sqlite":
+		iso := p.iso(func(offset, width int) string {
+			return fmt.Sprintf("SUBSTR(%s, %d, %d)", expr, offset, width)
+		})
+		// DATETIME returns NULL for text that is not a valid date.
+		return fmt.Sprintf("CASE WHEN LENGTH(%s) = %d THEN DATETIME(%s) END",
+			expr, p.length, strings.Join(iso, " || "))
+	}
+	return expr
+}
+
+// quotePostgresLiteral quotes the literal text of a PostgreSQL or Oracle
+// date format, where letters would otherwise be read as fields.
+func quotePostgresLiteral(text string) string {
+	if strings.TrimSpace(text) == "" || strings.ContainsAny(text, "-/:.,;") {
+		return text
+	}
+	return `"` + text + `"`
+}
@@ -0,0 +1,142 @@
+package datasetsql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func convertedField(id int64, origin string, from, to int, dateFormat string) *dataset.CoreDatasetTableField {
+	field := testField(id, 1, origin)
+	field.DeExtractType, field.DeType = &from, &to
+	if dateFormat != "" {
+		field.DateFormat = &dateFormat
+	}
+	return field
+}
+
+func TestConvert_RunsOnSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/convert.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE raw (id INTEGER, day TEXT, stamp TEXT, qty TEXT, price TEXT, flag TEXT, code INTEGER)`,
+		`INSERT INTO raw VALUES
+			(1, '2024/03/15', '15.03.2024 08:30', ' 42 ', '3.50', 'Yes', 7),
+			(2, '2024/13/01', 'garbage', '4x', '1.2.3', 'maybe', 8),
+			(3, NULL, '01.02.2023 23:59', '-5', '-0.25', '0', 9)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	def := &dataset.Definition{
+		Tables: []*dataset.CoreDatasetTable{{ID: 1, PhysicalTable: strPtr("raw")}},
+		Fields: []*dataset.CoreDatasetTableField{
+			testField(10, 1, "id"),
+			convertedField(11, "day", deText, deTime, "yyyy/MM/dd"),
+			convertedField(12, "stamp", deText, deTime, "dd.MM.yyyy HH:mm"),
+			convertedField(13, "qty", deText, deInt, ""),
+			convertedField(14, "price", deText, deFloat, ""),
+			convertedField(15, "flag", deText, deBool, ""),
+			convertedField(16, "code", deInt, deText, ""),
+			calcField(17, "[13] * 2", "double_qty"),
+		},
+	}
+	source, err := Compile(testDialect{}, def, nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	columns := make([]string, 0)
+	for _, field := range def.Fields[1:] {
+		columns = append(columns, `"`+source.Column(field)+`"`)
+	}
+	rows, err := db.Query(`SELECT ` + strings.Join(columns, ", ") + ` FROM ` + source.From + ` ORDER BY "id"`)
+	if err != nil {
+		t.Fatalf("query %s: %v", source.From, err)
+	}
+	defer rows.Close()
+
+	want := []string{
+		"2024-03-15 00:00:00|2024-03-15 08:30:00|42|3.5|1|7|84",
+		"<nil>|<nil>|<nil>|<nil>|<nil>|8|<nil>",
+		"<nil>|2023-02-01 23:59:00|-5|-0.25|0|9|-10",
+	}
+	n := 0
+	for ; rows.Next(); n++ {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for j := range values {
+			dest[j] = &values[j]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		parts := make([]string, len(values))
+		for j, v := range values {
+			parts[j] = fmt.Sprint(v)
+		}
+		if got := strings.Join(parts, "|"); got != want[n] {
+			t.Errorf("row %d = %s, want %s", n, got, want[n])
+		}
+	}
+	if n != len(want) {
+		t.Fatalf("read %d rows, want %d", n, len(want))
+	}
+}
+
+func TestConvert_Dialects(t *testing.T) {
+	day := convertedField(1, "day", deText, deTime, "dd/MM/yyyy")
+	qty := convertedField(2, "qty", deText, deInt, "")
+	cases := []struct {
+		db    string
+		field *dataset.CoreDatasetTableField
+		want  string
+	}{
+		{"mysql", day, "STR_TO_DATE(c, '%d/%m/%Y')"},
+		{"ck", day, "parseDateTimeOrNull(c, '%d/%m/%Y')"},
+		{"pg", day, "CASE WHEN c ~ '^[0-9]{2}/[0-9]{2}/[0-9]{4}$' THEN TO_TIMESTAMP(c, 'DD/MM/YYYY') END"},
+		{"oracle", day, "TO_TIMESTAMP(c DEFAULT NULL ON CONVERSION ERROR, 'DD/MM/YYYY')"},
+		{"sqlServer", day, "CASE WHEN LEN(c) = 10 THEN TRY_CONVERT(DATETIME2, CONCAT(SUBSTRING(c, 7, 4), '-', SUBSTRING(c, 4, 2), '-', SUBSTRING(c, 1, 2), ' ', '00', ':', '00', ':', '00'), 120) END"},
+		{"mysql", qty, "CASE WHEN c REGEXP '^[[:space:]]*[-+]?[0-9]+[[:space:]]*$' THEN CAST(TRIM(c) AS SIGNED) END"},
+		{"sqlServer", qty, "TRY_CAST(c AS BIGINT)"},
+		{"ck", qty, "toInt64OrNull(trimBoth(toString(c)))"},
+	}
+	for _, tc := range cases {
+		got, err := convert(tc.db, tc.field, "c")
+		if err != nil {
+			t.Errorf("convert(%s, %s): %v", tc.db, *tc.field.OriginName, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("convert(%s, %s):\n got %s\nwant %s", tc.db, *tc.field.OriginName, got, tc.want)
+		}
+	}
+}
+
+func TestCheckConversion(t *testing.T) {
+	cases := map[*dataset.CoreDatasetTableField]string{
+		convertedField(1, "a", deText, deTime, "yyyy-MM-dd"):    "",
+		convertedField(2, "a", deText, deTime, "dd.MM.yy"):      "unsupported pattern letter y",
+		convertedField(3, "a", deText, deTime, "MM/dd"):         "has no year",
+		convertedField(4, "a", deText, deTime, "yyyy-MM-dd'T'"): "unsupported character '",
+		convertedField(5, "a", deBool, deTime, ""):              "cannot convert boolean to date",
+		convertedField(6, "a", deInt, deText, ""):               "",
+		convertedField(7, "a", deText, deLocation, ""):          "",
+	}
+	for field, want := range cases {
+		err := CheckConversion(field)
+		if want == "" && err != nil {
+			t.Errorf("CheckConversion(%d): %v", field.ID, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("CheckConversion(%d): expected error containing %q, got %v", field.ID, want, err)
+		}
+	}
+}
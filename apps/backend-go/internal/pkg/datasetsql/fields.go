@@ -0,0 +1,132 @@
+package datasetsql
+
+import (
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/calcfield"
+)
+
+// WithFields returns the source with the fields read as another type than
+// their column, and the row-level calculated fields, selected as extra
+// columns. Formulas read converted fields as converted. Fields that cannot
+// be converted or compiled, and calculated fields that aggregate and so can
+// only be queried grouped, are left out; their errors are reported when
+// they are saved.
+func WithFields(d Dialect, source *Source, fields []*dataset.CoreDatasetTableField) *Source {
+	byID := make(map[int64]*dataset.CoreDatasetTableField, len(fields))
+	for _, field := range fields {
+		byID[field.ID] = field
+	}
+	raw := func(field *dataset.CoreDatasetTableField) (string, bool) {
+		if isCalculated(field) || !source.Exposes(field) {
+			return "", false
+		}
+		name := source.Column(field)
+		if name == "" {
+			return "", false
+		}
+		return sourceAlias + "." + d.QuoteIdentifier(name), true
+	}
+
+	taken := make(map[string]bool, len(source.columns))
+	for _, name := range source.columns {
+		taken[strings.ToLower(name)] = true
+	}
+	exprs := make([]string, 0)
+	derived := make(map[int64]string)
+	add := func(field *dataset.CoreDatasetTableField, expr string) {
+		name := derivedColumn(field, taken)
+		exprs = append(exprs, expr+" AS "+d.QuoteIdentifier(name))
+		derived[field.ID] = name
+	}
+
+	converted := make(map[int64]string)
+	for _, field := range fields {
+		if !converts(field) {
+			continue
+		}
+		column, ok := raw(field)
+		if !ok {
+			continue
+		}
+		expr, err := convert(d.Type(), field, column)
+		if err != nil {
+			continue
+		}
+		converted[field.ID] = expr
+		add(field, expr)
+	}
+
+	column := func(id int64) (string, error) {
+		if expr, ok := converted[id]; ok {
+			return expr, nil
+		}
+		field, ok := byID[id]
+		if !ok {
+			return "", fmt.Errorf("field [%d] is not selected by the dataset", id)
+		}
+		expr, ok := raw(field)
+		if !ok {
+			return "", fmt.Errorf("field [%d] is not selected by the dataset", id)
+		}
+		return expr, nil
+	}
+	set := calcfield.NewSet(CalcFields(fields))
+	for _, field := range fields {
+		if !isCalculated(field) || (field.Checked != nil && !*field.Checked) {
+			continue
+		}
+		info, err := set.Check(field.ID)
+		if err != nil || info.Aggregate {
+			continue
+		}
+		expr, err := set.SQL(d.Type(), field.ID, column)
+		if err != nil {
+			continue
+		}
+		add(field, expr)
+	}
+	if len(exprs) == 0 {
+		return source
+	}
+
+	from := source.From
+	if !source.aliased {
+		from += " " + sourceAlias
+	}
+	return &Source{
+		From:    "(SELECT " + sourceAlias + ".*, " + strings.Join(exprs, ", ") + " FROM " + from + ") " + sourceAlias,
+		Args:    source.Args,
+		columns: source.columns,
+		derived: derived,
+		aliased: true,
+	}
+}
+
+// TableFields returns the fields read from a table and the calculated
+// fields among fields.
+func TableFields(fields []*dataset.CoreDatasetTableField, tableID int64) []*dataset.CoreDatasetTableField {
+	result := make([]*dataset.CoreDatasetTableField, 0, len(fields))
+	for _, field := range fields {
+		if isCalculated(field) || (field.DatasetTableID != nil && *field.DatasetTableID == tableID) {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// derivedColumn names the column of a converted or calculated field: its
+// dataease name unless the source already has a column of that name.
+func derivedColumn(field *dataset.CoreDatasetTableField, taken map[string]bool) string {
+	name := ""
+	if field.DataeaseName != nil {
+		name = strings.TrimSpace(*field.DataeaseName)
+	}
+	if name == "" || taken[strings.ToLower(name)] {
+		name = fmt.Sprintf("de_field_%d", field.ID)
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
@@ -0,0 +1,188 @@
+package datasetsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+// Where compiles filter trees on the fields of a source into a condition,
+// without the WHERE keyword, and its arguments, which follow the arguments
+// of the source. Trees are combined with AND; an empty condition filters
+// nothing. Values are bound as numbers for numeric fields and as text
+// otherwise.
+func Where(d Dialect, source *Source, fields []*dataset.CoreDatasetTableField, trees ...*dataset.FilterTree) (string, []interface{}, error) {
+	w := &where{d: d, source: source, fields: make(map[int64]*dataset.CoreDatasetTableField, len(fields))}
+	for _, field := range fields {
+		w.fields[field.ID] = field
+	}
+	parts := make([]string, 0, len(trees))
+	for _, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		part, err := w.tree(tree)
+		if err != nil {
+			return "", nil, err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " AND "), w.args, nil
+}
+
+type where struct {
+	d      Dialect
+	source *Source
+	fields map[int64]*dataset.CoreDatasetTableField
+	args   []interface{}
+}
+
+func (w *where) tree(tree *dataset.FilterTree) (string, error) {
+	parts := make([]string, 0, len(tree.Items))
+	for i := range tree.Items {
+		item := &tree.Items[i]
+		var part string
+		var err error
+		if strings.EqualFold(item.Type, "tree") {
+			if item.SubTree == nil {
+				continue
+			}
+			part, err = w.tree(item.SubTree)
+		} else {
+			part, err = w.item(item)
+		}
+		if err != nil {
+			return "", err
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0], nil
+	}
+	logic := " AND "
+	if strings.EqualFold(strings.TrimSpace(tree.Logic), "or") {
+		logic = " OR "
+	}
+	return "(" + strings.Join(parts, logic) + ")", nil
+}
+
+func (w *where) item(item *dataset.FilterItem) (string, error) {
+	id, err := item.FieldID.Int64()
+	if err != nil {
+		return "", fmt.Errorf("invalid filter field %q", item.FieldID)
+	}
+	field, ok := w.fields[id]
+	if !ok {
+		return "", fmt.Errorf("unknown filter field %d", id)
+	}
+	name := w.source.Column(field)
+	if name == "" || !w.source.Exposes(field) {
+		return "", fmt.Errorf("field %d cannot be filtered", id)
+	}
+	column := w.d.QuoteIdentifier(name)
+
+	if strings.EqualFold(item.FilterType, "enum") {
+		if len(item.EnumValue) == 0 {
+			return "", nil
+		}
+		return column + " IN (" + w.bind(field, item.EnumValue) + ")", nil
+	}
+
+	term := strings.ToLower(strings.TrimSpace(item.Term))
+	switch term {
+	case "null":
+		return column + " IS NULL", nil
+	case "not_null":
+		return column + " IS NOT NULL", nil
+	case "empty":
+		if fieldType(field) != deText {
+			return column + " IS NULL", nil
+		}
+		return "(" + column + " IS NULL OR " + column + " = '')", nil
+	case "not_empty":
+		if fieldType(field) != deText {
+			return column + " IS NOT NULL", nil
+		}
+		return "(" + column + " IS NOT NULL AND " + column + " <> '')", nil
+	case "like", "not like", "not_like":
+		w.args = append(w.args, "%"+item.Value+"%")
+		if term == "like" {
+			return column + " LIKE ?", nil
+		}
+		return column + " NOT LIKE ?", nil
+	case "in", "not in", "not_in":
+		values := splitValues(item.Value)
+		if len(values) == 0 {
+			return "", nil
+		}
+		if term == "in" {
+			return column + " IN (" + w.bind(field, values) + ")", nil
+		}
+		return column + " NOT IN (" + w.bind(field, values) + ")", nil
+	case "between":
+		values := splitValues(item.Value)
+		if len(values) != 2 {
+			return "", fmt.Errorf("between on field %d needs two values", id)
+		}
+		return column + " BETWEEN " + w.bind(field, values[:1]) + " AND " + w.bind(field, values[1:]), nil
+	}
+
+	operator, ok := comparisons[term]
+	if !ok {
+		return "", fmt.Errorf("unsupported filter term %q", item.Term)
+	}
+	return column + " " + operator + " " + w.bind(field, []string{item.Value}), nil
+}
+
+var comparisons = map[string]string{"eq": "=", "not_eq": "<>", "lt": "<", "le": "<=", "gt": ">", "ge": ">="}
+
+// bind adds values to the arguments and returns their placeholders.
+func (w *where) bind(field *dataset.CoreDatasetTableField, values []string) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = "?"
+		w.args = append(w.args, filterValue(field, value))
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func filterValue(field *dataset.CoreDatasetTableField, value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	switch fieldType(field) {
+	case deInt:
+		if n, err := strconv.ParseInt(trimmed, 10, 64); err == nil {
+			return n
+		}
+	case deFloat:
+		if n, err := strconv.ParseFloat(trimmed, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+func fieldType(field *dataset.CoreDatasetTableField) int {
+	if field.DeType == nil {
+		return deText
+	}
+	return *field.DeType
+}
+
+func splitValues(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
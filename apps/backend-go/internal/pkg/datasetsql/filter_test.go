@@ -0,0 +1,117 @@
+package datasetsql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func TestWhere_RunsOnSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/filter.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE orders (id INTEGER, region TEXT, amount REAL, note TEXT)`,
+		`INSERT INTO orders VALUES
+			(1, 'north', 10, 'rush'),
+			(2, 'south', 25.5, ''),
+			(3, 'north', 40, NULL),
+			(4, 'east', 5, 'rush order')`,
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	amount := testField(12, 1, "amount")
+	deFloatType := deFloat
+	amount.DeType = &deFloatType
+	def := &dataset.Definition{
+		Tables: []*dataset.CoreDatasetTable{{ID: 1, PhysicalTable: strPtr("orders")}},
+		Fields: []*dataset.CoreDatasetTableField{
+			testField(10, 1, "id"), testField(11, 1, "region"), amount, testField(13, 1, "note"),
+			calcField(14, "[12] * 2", "double_amount"),
+		},
+	}
+	def.Fields[4].DeType = &deFloatType
+	source, err := Compile(testDialect{}, def, nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	cases := []struct {
+		tree string
+		want string
+	}{
+		{`{"logic":"and","items":[{"type":"item","fieldId":"11","filterType":"enum","enumValue":["north"]}]}`, "1,3"},
+		{`{"logic":"or","items":[
+			{"type":"item","fieldId":12,"filterType":"logic","term":"ge","value":"40"},
+			{"type":"tree","subTree":{"logic":"and","items":[
+				{"type":"item","fieldId":11,"filterType":"logic","term":"eq","value":"south"},
+				{"type":"item","fieldId":13,"filterType":"logic","term":"empty"}]}}]}`, "2,3"},
+		{`{"items":[{"type":"item","fieldId":13,"filterType":"logic","term":"like","value":"rush"}]}`, "1,4"},
+		{`{"items":[{"type":"item","fieldId":12,"filterType":"logic","term":"between","value":"5,10"}]}`, "1,4"},
+		{`{"items":[{"type":"item","fieldId":11,"filterType":"logic","term":"not in","value":"north, east"}]}`, "2"},
+		{`{"items":[{"type":"item","fieldId":14,"filterType":"logic","term":"gt","value":"50"}]}`, "2,3"},
+		{`{"items":[]}`, "1,2,3,4"},
+	}
+	for _, tc := range cases {
+		tree, err := dataset.ParseFilterTree(tc.tree)
+		if err != nil {
+			t.Fatalf("ParseFilterTree: %v", err)
+		}
+		cond, args, err := Where(testDialect{}, source, def.Fields, tree)
+		if err != nil {
+			t.Fatalf("Where(%s): %v", tc.tree, err)
+		}
+		query := `SELECT "id" FROM ` + source.From
+		if cond != "" {
+			query += " WHERE " + cond
+		}
+		rows, err := db.Query(query+` ORDER BY "id"`, append(append([]interface{}{}, source.Args...), args...)...)
+		if err != nil {
+			t.Fatalf("query %s: %v", query, err)
+		}
+		ids := make([]string, 0)
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			ids = append(ids, fmt.Sprint(id))
+		}
+		rows.Close()
+		if got := strings.Join(ids, ","); got != tc.want {
+			t.Errorf("%s\n%s: got ids %s, want %s", tc.tree, cond, got, tc.want)
+		}
+	}
+}
+
+func TestWhere_Errors(t *testing.T) {
+	def := &dataset.Definition{
+		Tables: []*dataset.CoreDatasetTable{{ID: 1, PhysicalTable: strPtr("orders")}},
+		Fields: []*dataset.CoreDatasetTableField{testField(10, 1, "id")},
+	}
+	source, err := Compile(testDialect{}, def, nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	cases := map[string]dataset.FilterItem{
+		"unknown filter field 99":    {Type: "item", FieldID: json.Number("99"), Term: "eq"},
+		"unsupported filter term":    {Type: "item", FieldID: json.Number("10"), Term: "regex"},
+		"needs two values":           {Type: "item", FieldID: json.Number("10"), Term: "between", Value: "1"},
+		"invalid filter field \"x\"": {Type: "item", FieldID: json.Number("x"), Term: "eq"},
+	}
+	for want, item := range cases {
+		_, _, err := Where(testDialect{}, source, def.Fields, &dataset.FilterTree{Items: []dataset.FilterItem{item}})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package datasetsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+// branch is one join tree of a model with the columns it selects.
+type branch struct {
+	name    string
+	from    string
+	exprs   []string
+	fields  []*dataset.CoreDatasetTableField
+	aliases map[int64]string
+}
+
+// compileModel renders the union of the join trees of a model and maps
+// every selected field to its output column. Output columns keep the origin
+// name of the field unless it is ambiguous. The columns of later branches
+// are matched to those of the first by origin name.
+func compileModel(d Dialect, def *dataset.Definition) (string, map[int64]string, error) {
+	tables := make(map[int64]*dataset.CoreDatasetTable, len(def.Tables))
+	for _, table := range def.Tables {
+		tables[table.ID] = table
+	}
+
+	branches := make([]*branch, 0, len(def.Model.Union))
+	for i := range def.Model.Union {
+		b, err := compileBranch(d, &def.Model.Union[i], tables)
+		if err != nil {
+			return "", nil, err
+		}
+		b.name = def.Model.Union[i].Name()
+		for _, field := range def.Fields {
+			if !selectable(field) || field.DatasetTableID == nil {
+				continue
+			}
+			alias, ok := b.aliases[*field.DatasetTableID]
+			if !ok {
+				continue
+			}
+			b.exprs = append(b.exprs, alias+"."+d.QuoteIdentifier(strings.TrimSpace(*field.OriginName)))
+			b.fields = append(b.fields, field)
+		}
+		if len(b.fields) == 0 {
+			return "", nil, fmt.Errorf("dataset model has no field for table %s", def.Model.Union[i].TableName)
+		}
+		branches = append(branches, b)
+	}
+
+	names := outputNames(branches[0])
+	columns := make(map[int64]string)
+	parts := make([]string, 0, len(branches))
+	for _, b := range branches {
+		order, err := alignBranch(branches[0], b)
+		if err != nil {
+			return "", nil, err
+		}
+		selects := make([]string, len(order))
+		for i, j := range order {
+			selects[i] = b.exprs[j] + " AS " + d.QuoteIdentifier(names[i])
+			columns[b.fields[j].ID] = names[i]
+		}
+		parts = append(parts, "SELECT "+strings.Join(selects, ", ")+" FROM "+b.from)
+	}
+
+	union := " UNION ALL "
+	if def.Model.Distinct {
+		union = " UNION "
+	}
+	return strings.Join(parts, union), columns, nil
+}
+
+func compileBranch(d Dialect, root *dataset.ModelNode, tables map[int64]*dataset.CoreDatasetTable) (*branch, error) {
+	b := &branch{aliases: make(map[int64]string)}
+	var from strings.Builder
+	var visit func(node *dataset.ModelNode, parentAlias string) error
+	visit = func(node *dataset.ModelNode, parentAlias string) error {
+		table, ok := tables[node.TableID]
+		if !ok || table.PhysicalTable == nil {
+			return fmt.Errorf("table %s of the dataset model is not saved", node.TableName)
+		}
+		if !tableNamePattern.MatchString(*table.PhysicalTable) {
+			return fmt.Errorf("invalid dataset table name")
+		}
+		if _, dup := b.aliases[table.ID]; dup {
+			return fmt.Errorf("table %s appears twice in the dataset model, give each an alias", node.Name())
+		}
+		alias := "t" + strconv.Itoa(len(b.aliases))
+		b.aliases[table.ID] = alias
+
+		if parentAlias == "" {
+			from.WriteString(d.QualifiedTable(*table.PhysicalTable) + " " + alias)
+		} else {
+			keyword, err := joinKeyword(d, node.Join)
+			if err != nil {
+				return err
+			}
+			conditions := make([]string, 0, len(node.Join.Conditions))
+			for _, cond := range node.Join.Conditions {
+				conditions = append(conditions, fmt.Sprintf("%s.%s = %s.%s",
+					parentAlias, d.QuoteIdentifier(strings.TrimSpace(cond.ParentColumn)),
+					alias, d.QuoteIdentifier(strings.TrimSpace(cond.Column))))
+			}
+			from.WriteString(" " + keyword + " " + d.QualifiedTable(*table.PhysicalTable) + " " + alias +
+				" ON " + strings.Join(conditions, " AND "))
+		}
+		for i := range node.Children {
+			if err := visit(&node.Children[i], alias); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(root, ""); err != nil {
+		return nil, err
+	}
+	b.from = from.String()
+	return b, nil
+}
+
+// alignBranch returns, for every column of the first branch, the position
+// of the field of b with the same origin name. A name repeated in a branch
+// matches its occurrences in order. Every field of b must be matched.
+func alignBranch(first, b *branch) ([]int, error) {
+	positions := make(map[string][]int, len(b.fields))
+	for i, field := range b.fields {
+		key := strings.ToLower(strings.TrimSpace(*field.OriginName))
+		positions[key] = append(positions[key], i)
+	}
+	order := make([]int, len(first.fields))
+	for i, field := range first.fields {
+		key := strings.ToLower(strings.TrimSpace(*field.OriginName))
+		if len(positions[key]) == 0 {
+			return nil, fmt.Errorf("union branch %s has no column %s", b.name, strings.TrimSpace(*field.OriginName))
+		}
+		order[i] = positions[key][0]
+		positions[key] = positions[key][1:]
+	}
+	for _, field := range b.fields {
+		if left := positions[strings.ToLower(strings.TrimSpace(*field.OriginName))]; len(left) > 0 {
+			return nil, fmt.Errorf("column %s of union branch %s is not in union branch %s",
+				strings.TrimSpace(*b.fields[left[0]].OriginName), b.name, first.name)
+		}
+	}
+	return order, nil
+}
+
+func joinKeyword(d Dialect, join *dataset.Join) (string, error) {
+	if join == nil || len(join.Conditions) == 0 {
+		return "", fmt.Errorf("join condition is required")
+	}
+	switch join.Type {
+	case dataset.JoinInner:
+		return "INNER JOIN", nil
+	case dataset.JoinLeft:
+		return "LEFT JOIN", nil
+	case dataset.JoinRight:
+		return "RIGHT JOIN", nil
+	case dataset.JoinFull:
+		if !d.FullJoin() {
+			return "", fmt.Errorf("full join is not supported by this datasource")
+		}
+		return "FULL OUTER JOIN", nil
+	}
+	return "", fmt.Errorf("unsupported join type: %s", join.Type)
+}
+
+// outputNames names the columns of the first branch: the origin name when it
+// is unique, the dataease name or the origin name prefixed by the table
+// alias otherwise.
+func outputNames(b *branch) []string {
+	counts := make(map[string]int, len(b.fields))
+	for _, field := range b.fields {
+		counts[strings.ToLower(strings.TrimSpace(*field.OriginName))]++
+	}
+	names := make([]string, len(b.fields))
+	used := make(map[string]bool, len(b.fields))
+	for i, field := range b.fields {
+		origin := strings.TrimSpace(*field.OriginName)
+		name := origin
+		if counts[strings.ToLower(origin)] > 1 {
+			name = b.aliases[*field.DatasetTableID] + "_" + origin
+			if field.DataeaseName != nil && strings.TrimSpace(*field.DataeaseName) != "" {
+				name = strings.TrimSpace(*field.DataeaseName)
+			}
+		}
+		for base, n := name, 1; used[strings.ToLower(name)]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// selectable reports whether a field is a checked column of a table.
+func selectable(field *dataset.CoreDatasetTableField) bool {
+	if field.OriginName == nil || strings.TrimSpace(*field.OriginName) == "" {
+		return false
+	}
+	if field.ExtField != nil && *field.ExtField != 0 {
+		return false
+	}
+	return field.Checked == nil || *field.Checked
+}
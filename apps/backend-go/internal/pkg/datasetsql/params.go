@@ -0,0 +1,207 @@
+package datasetsql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+// Values are the values given to the variables of custom SQL tables when a
+// dataset is queried. Layers are searched in order, so that share ticket args
+// can take precedence over dashboard filters and outer params; a variable
+// without a value falls back to its declared default. Editing enables the
+// defaults restricted to the dataset editor. Check, when set, validates the
+// query of each custom SQL table once bound, before it runs.
+type Values struct {
+	Layers  [][]dataset.SQLParameter
+	Editing bool
+	Check   func(query string) error
+}
+
+func (v *Values) lookup(tableID int64, name string) ([]interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	for _, layer := range v.Layers {
+		for i := range layer {
+			if layer[i].Matches(tableID, name) && len(layer[i].Value) > 0 {
+				return layer[i].Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (v *Values) editing() bool {
+	return v != nil && v.Editing
+}
+
+func (v *Values) check(query string) error {
+	if v == nil || v.Check == nil {
+		return nil
+	}
+	return v.Check(query)
+}
+
+// Bind replaces the ${name} variables of the query of a custom SQL table by
+// driver placeholders and returns the typed arguments to run it with. A
+// variable with several values expands to a placeholder list, as in
+// IN (${ids}). Values are never spliced into the SQL text, which is why a
+// variable inside a quoted literal is rejected rather than substituted.
+func Bind(tableID int64, query string, variables []dataset.SQLVariable, values *Values) (string, []interface{}, error) {
+	declared := make(map[string]*dataset.SQLVariable, len(variables))
+	for i := range variables {
+		declared[strings.ToLower(strings.TrimSpace(variables[i].VariableName))] = &variables[i]
+	}
+
+	var out strings.Builder
+	args := make([]interface{}, 0)
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '$' && strings.HasPrefix(query[i:], "${") {
+				return "", nil, fmt.Errorf("sql variable at offset %d must not be quoted", i)
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			out.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated comment in sql")
+			}
+			out.WriteString(query[i : i+end+4])
+			i += end + 3
+			continue
+		case ch == '$' && strings.HasPrefix(query[i:], "${"):
+			end := strings.IndexByte(query[i:], '}')
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated sql variable at offset %d", i)
+			}
+			name := strings.TrimSpace(query[i+2 : i+end])
+			bound, err := bindVariable(tableID, name, declared[strings.ToLower(name)], values)
+			if err != nil {
+				return "", nil, err
+			}
+			placeholders := make([]string, len(bound))
+			for j := range bound {
+				placeholders[j] = "?"
+			}
+			out.WriteString(strings.Join(placeholders, ", "))
+			args = append(args, bound...)
+			i += end
+			continue
+		}
+		out.WriteByte(ch)
+	}
+	if quote != 0 {
+		return "", nil, fmt.Errorf("unterminated quoted literal in sql")
+	}
+	return out.String(), args, nil
+}
+
+func bindVariable(tableID int64, name string, variable *dataset.SQLVariable, values *Values) ([]interface{}, error) {
+	if variable == nil {
+		return nil, fmt.Errorf("sql variable %s is not declared", name)
+	}
+	raw, ok := values.lookup(tableID, variable.VariableName)
+	if !ok {
+		if variable.DefaultValue == nil || strings.TrimSpace(*variable.DefaultValue) == "" ||
+			(variable.DefaultValueScope == dataset.DefaultScopeEdit && !values.editing()) {
+			return nil, fmt.Errorf("sql variable %s has no value", name)
+		}
+		raw = []interface{}{*variable.DefaultValue}
+	}
+
+	deType := variable.DeType()
+	bound := make([]interface{}, 0, len(raw))
+	for _, value := range raw {
+		converted, err := convertValue(deType, value)
+		if err != nil {
+			return nil, fmt.Errorf("sql variable %s: %w", name, err)
+		}
+		bound = append(bound, converted)
+	}
+	return bound, nil
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"2006-01",
+	"2006",
+}
+
+// convertValue checks a value against the deType of its variable and returns
+// it in the Go type the drivers bind. Dates are accepted as text or as epoch
+// milliseconds, the form the dashboard filters send.
+func convertValue(deType int, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, fmt.Errorf("value is null")
+	}
+	text := strings.TrimSpace(fmt.Sprint(value))
+	switch deType {
+	case 1:
+		if n, ok := value.(float64); ok {
+			return time.UnixMilli(int64(n)), nil
+		}
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil && len(text) > 4 {
+			return time.UnixMilli(n), nil
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a date", text)
+	case 2:
+		if n, ok := value.(float64); ok {
+			if n != math.Trunc(n) {
+				return nil, fmt.Errorf("%v is not an integer", n)
+			}
+			return int64(n), nil
+		}
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", text)
+		}
+		return n, nil
+	case 3:
+		if n, ok := value.(float64); ok {
+			return n, nil
+		}
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", text)
+		}
+		return n, nil
+	case 4:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", text)
+		}
+		return b, nil
+	}
+	return fmt.Sprint(value), nil
+}
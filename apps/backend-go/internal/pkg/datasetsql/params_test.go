@@ -0,0 +1,145 @@
+package datasetsql
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func testVariables() []dataset.SQLVariable {
+	return []dataset.SQLVariable{
+		{VariableName: "region", Type: []string{"VARCHAR"}, DefaultValue: strPtr("east"), DefaultValueScope: dataset.DefaultScopeAll},
+		{VariableName: "minAmount", Type: []string{"INT"}, DefaultValue: strPtr("10"), DefaultValueScope: dataset.DefaultScopeEdit},
+		{VariableName: "day", Type: []string{"DATETIME-YEAR-MONTH-DAY"}},
+	}
+}
+
+func TestBind_UsesPlaceholders(t *testing.T) {
+	query := "SELECT * FROM sales WHERE region IN (${region}) AND amount > ${ minAmount } -- ${region}\n AND note <> '${'"
+	_, _, err := Bind(1, query, testVariables(), &Values{Layers: [][]dataset.SQLParameter{
+		{{VariableName: "region", Value: []interface{}{"north", "south"}}},
+		{{ID: "1|DE|minAmount", Value: []interface{}{float64(5)}}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "quoted") {
+		t.Fatalf("expected quoted variable error, got %v", err)
+	}
+
+	query = "SELECT * FROM sales WHERE region IN (${region}) AND amount > ${ minAmount } -- ${region}\n AND note <> 'x'"
+	bound, args, err := Bind(1, query, testVariables(), &Values{Layers: [][]dataset.SQLParameter{
+		{{VariableName: "region", Value: []interface{}{"north", "south"}}},
+		{{VariableName: "region", Value: []interface{}{"west"}}, {ID: "1|DE|minAmount", Value: []interface{}{float64(5)}}},
+	}})
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	want := "SELECT * FROM sales WHERE region IN (?, ?) AND amount > ? -- ${region}\n AND note <> 'x'"
+	if bound != want {
+		t.Fatalf("unexpected sql:\n got %s\nwant %s", bound, want)
+	}
+	if len(args) != 3 || args[0] != "north" || args[1] != "south" || args[2] != int64(5) {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestBind_Defaults(t *testing.T) {
+	query := "SELECT * FROM sales WHERE region = ${region} AND amount > ${minAmount}"
+	_, args, err := Bind(1, query, testVariables(), &Values{Editing: true})
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if len(args) != 2 || args[0] != "east" || args[1] != int64(10) {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+
+	// The editor default of minAmount does not apply outside the editor.
+	if _, _, err = Bind(1, query, testVariables(), nil); err == nil || !strings.Contains(err.Error(), "minAmount") {
+		t.Fatalf("expected missing value error, got %v", err)
+	}
+	// A parameter for another table does not bind this one.
+	values := &Values{Layers: [][]dataset.SQLParameter{{{ID: "2|DE|minAmount", Value: []interface{}{"1"}}}}}
+	if _, _, err = Bind(1, query, testVariables(), values); err == nil {
+		t.Fatal("expected missing value error")
+	}
+	if _, _, err = Bind(1, "SELECT ${unknown}", testVariables(), nil); err == nil {
+		t.Fatal("expected undeclared variable error")
+	}
+}
+
+func TestBind_ChecksTypes(t *testing.T) {
+	query := "SELECT * FROM sales WHERE amount > ${minAmount}"
+	for _, value := range []interface{}{"10 OR 1=1", 1.5, "abc"} {
+		values := &Values{Layers: [][]dataset.SQLParameter{{{VariableName: "minAmount", Value: []interface{}{value}}}}}
+		if _, _, err := Bind(1, query, testVariables(), values); err == nil {
+			t.Errorf("expected %v to be rejected", value)
+		}
+	}
+
+	values := &Values{Layers: [][]dataset.SQLParameter{{{VariableName: "day", Value: []interface{}{"2024-03-01"}}}}}
+	_, args, err := Bind(1, "SELECT * FROM sales WHERE day = ${day}", testVariables(), values)
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if day, ok := args[0].(time.Time); !ok || day.Format("2006-01-02") != "2024-03-01" {
+		t.Fatalf("unexpected date arg: %#v", args[0])
+	}
+}
+
+func TestForTable_RunsBoundSQL(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/params.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE sales (region TEXT, amount INTEGER)`,
+		`INSERT INTO sales VALUES ('east', 5), ('east', 50), ('west', 70)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	info := `{"table":"sales_sql","sql":"` + base64.StdEncoding.EncodeToString([]byte(
+		"SELECT region, amount FROM sales WHERE region = ${region} AND amount > ${minAmount};")) + `"}`
+	variables := `[{"variableName":"region","type":["VARCHAR"],"defaultValue":"east","defaultValueScope":"ALLSCOPE"},` +
+		`{"variableName":"minAmount","type":["INT"]}]`
+	table := &dataset.CoreDatasetTable{ID: 3, Type: strPtr(dataset.TableTypeSQL), Info: &info, SQLVariables: &variables}
+
+	source, err := ForTable(testDialect{}, table, &Values{Layers: [][]dataset.SQLParameter{
+		{{VariableName: "minAmount", Value: []interface{}{"1' OR '1'='1"}}},
+	}})
+	if err == nil {
+		t.Fatalf("expected the injected value to be rejected, got %s", source.From)
+	}
+
+	source, err = ForTable(testDialect{}, table, &Values{Layers: [][]dataset.SQLParameter{
+		{{VariableName: "minAmount", Value: []interface{}{float64(10)}}},
+	}})
+	if err != nil {
+		t.Fatalf("ForTable: %v", err)
+	}
+	var count int
+	if err = db.QueryRow("SELECT COUNT(1) FROM "+source.From, source.Args...).Scan(&count); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 row, got %d", count)
+	}
+
+	var checked string
+	refused := errors.New("refused")
+	_, err = ForTable(testDialect{}, table, &Values{Layers: [][]dataset.SQLParameter{
+		{{VariableName: "minAmount", Value: []interface{}{float64(10)}}},
+	}, Check: func(query string) error {
+		checked = query
+		return refused
+	}})
+	if !errors.Is(err, refused) || strings.Contains(checked, "${") {
+		t.Fatalf("expected the bound SQL to be checked, got %q (%v)", checked, err)
+	}
+}
@@ -0,0 +1,111 @@
+package datasetsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+// ProfileStats returns the query profiling the fields of a source in one
+// pass. Its single row holds the row count, then four columns per field:
+// the count of non-null values, the distinct count, approximate where the
+// datasource has such a count, and the minimum and maximum of numbers and
+// dates, NULL for other fields.
+func ProfileStats(d Dialect, source *Source, fields []*dataset.CoreDatasetTableField) (string, error) {
+	db := d.Type()
+	columns := []string{"COUNT(*)"}
+	for _, field := range fields {
+		column, err := profileColumn(d, source, field)
+		if err != nil {
+			return "", err
+		}
+		columns = append(columns, "COUNT("+column+")", distinctCount(db, column))
+		if Ranged(field) {
+			columns = append(columns, "MIN("+column+")", "MAX("+column+")")
+		} else {
+			columns = append(columns, "NULL", "NULL")
+		}
+	}
+	return "SELECT " + strings.Join(columns, ", ") + " FROM " + source.From, nil
+}
+
+// ProfileTopValues returns the query counting the values of a field, most
+// frequent first, as de_value and de_count. Callers limit it.
+func ProfileTopValues(d Dialect, source *Source, field *dataset.CoreDatasetTableField) (string, error) {
+	column, err := profileColumn(d, source, field)
+	if err != nil {
+		return "", err
+	}
+	return "SELECT " + column + " AS de_value, COUNT(*) AS de_count FROM " + source.From +
+		" WHERE " + column + " IS NOT NULL GROUP BY " + column + " ORDER BY de_count DESC", nil
+}
+
+// ProfileHistogram returns the query spreading the values of a number or
+// date field over buckets of equal width from their minimum to their
+// maximum, as de_bucket, from 0, de_count and the bounds de_lo and de_hi.
+// Dates are bucketed on seconds since the epoch. The source is read twice,
+// so the query takes its arguments twice.
+func ProfileHistogram(d Dialect, source *Source, field *dataset.CoreDatasetTableField, buckets int) (string, []interface{}, error) {
+	if !Ranged(field) {
+		return "", nil, fmt.Errorf("field %d has no histogram", field.ID)
+	}
+	if buckets < 1 {
+		return "", nil, fmt.Errorf("histogram needs at least one bucket")
+	}
+	column, err := profileColumn(d, source, field)
+	if err != nil {
+		return "", nil, err
+	}
+	value := column
+	if fieldType(field) == deTime {
+		value = timeToUnix(d.Type(), column)
+	}
+	n := strconv.Itoa(buckets)
+	bucket := "CASE WHEN de_hi = de_lo THEN 0 WHEN " + value + " >= de_hi THEN " + strconv.Itoa(buckets-1) +
+		" ELSE " + floor(d.Type(), "("+value+" - de_lo) * "+n+" / (de_hi - de_lo)") + " END"
+	bounds := "SELECT MIN(" + value + ") AS de_lo, MAX(" + value + ") AS de_hi FROM " + source.From +
+		" WHERE " + value + " IS NOT NULL"
+	query := "SELECT de_bucket, COUNT(*) AS de_count, MIN(de_lo) AS de_lo, MAX(de_hi) AS de_hi FROM (" +
+		"SELECT " + bucket + " AS de_bucket, de_lo, de_hi FROM " + source.From +
+		" CROSS JOIN (" + bounds + ") de_range WHERE " + value + " IS NOT NULL) de_hist" +
+		" GROUP BY de_bucket ORDER BY de_bucket"
+	args := append(append([]interface{}{}, source.Args...), source.Args...)
+	return query, args, nil
+}
+
+// Ranged reports whether a field has a minimum, a maximum and a histogram.
+func Ranged(field *dataset.CoreDatasetTableField) bool {
+	switch fieldType(field) {
+	case deInt, deFloat, deTime:
+		return true
+	}
+	return false
+}
+
+func profileColumn(d Dialect, source *Source, field *dataset.CoreDatasetTableField) (string, error) {
+	name := source.Column(field)
+	if name == "" || !source.Exposes(field) {
+		return "", fmt.Errorf("field %d cannot be profiled", field.ID)
+	}
+	return d.QuoteIdentifier(name), nil
+}
+
+func distinctCount(db string, column string) string {
+	switch db {
+	case "ck":
+		return "uniq(" + column + ")"
+	case "oracle":
+		return "APPROX_COUNT_DISTINCT(" + column + ")"
+	}
+	return "COUNT(DISTINCT " + column + ")"
+}
+
+// floor rounds down a value that is never negative.
+func floor(db string, expr string) string {
+	if db == "sqlite" {
+		return "CAST(" + expr + " AS INTEGER)"
+	}
+	return "FLOOR(" + expr + ")"
+}
@@ -0,0 +1,104 @@
+package datasetsql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func TestProfileQueries_RunOnSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/profile.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE orders (id INTEGER, region TEXT, amount REAL, ordered TEXT)`,
+		`INSERT INTO orders VALUES
+			(1, 'north', 0, '2024-01-01 00:00:00'),
+			(2, 'north', 10, '2024-01-02 00:00:00'),
+			(3, 'south', 25, NULL),
+			(4, NULL, 100, '2024-01-05 00:00:00')`,
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	region, amount, ordered := testField(11, 1, "region"), testField(12, 1, "amount"), testField(13, 1, "ordered")
+	floatType, timeType := deFloat, deTime
+	amount.DeType, ordered.DeType = &floatType, &timeType
+	fields := []*dataset.CoreDatasetTableField{region, amount, ordered}
+	source, err := Compile(testDialect{}, &dataset.Definition{
+		Tables: []*dataset.CoreDatasetTable{{ID: 1, PhysicalTable: strPtr("orders")}},
+		Fields: fields,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	query, err := ProfileStats(testDialect{}, source, fields)
+	if err != nil {
+		t.Fatalf("ProfileStats: %v", err)
+	}
+	values := make([]interface{}, 13)
+	pointers := make([]interface{}, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = db.QueryRow(query).Scan(pointers...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	if got := strings.TrimSpace(fmt.Sprintln(values...)); got != "4 3 2 <nil> <nil> 4 4 0 100 3 3 2024-01-01 00:00:00 2024-01-05 00:00:00" {
+		t.Errorf("unexpected stats: %s", got)
+	}
+
+	query, err = ProfileTopValues(testDialect{}, source, region)
+	if err != nil {
+		t.Fatalf("ProfileTopValues: %v", err)
+	}
+	var top string
+	var count int64
+	if err = db.QueryRow(query+" LIMIT 1").Scan(&top, &count); err != nil || top != "north" || count != 2 {
+		t.Errorf("top value = %s %d, %v", top, count, err)
+	}
+
+	cases := []struct {
+		field *dataset.CoreDatasetTableField
+		want  string
+	}{
+		{amount, "0:2 1:1 3:1 | 0-100"},
+		{ordered, "0:1 1:1 3:1 | 1704067200-1704412800"},
+	}
+	for _, tc := range cases {
+		query, args, err := ProfileHistogram(testDialect{}, source, tc.field, 4)
+		if err != nil {
+			t.Fatalf("ProfileHistogram: %v", err)
+		}
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		got, bounds := "", ""
+		for rows.Next() {
+			var bucket, n int64
+			var lo, hi float64
+			if err = rows.Scan(&bucket, &n, &lo, &hi); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			got += fmt.Sprintf("%d:%d ", bucket, n)
+			bounds = fmt.Sprintf("%.0f-%.0f", lo, hi)
+		}
+		rows.Close()
+		if got+"| "+bounds != tc.want {
+			t.Errorf("histogram of field %d = %s| %s, want %s", tc.field.ID, got, bounds, tc.want)
+		}
+	}
+
+	if _, _, err = ProfileHistogram(testDialect{}, source, region, 4); err == nil {
+		t.Error("text fields should have no histogram")
+	}
+}
@@ -0,0 +1,133 @@
+// Package datasetsql builds the FROM clause that the queries on a dataset
+// read from: its table, or the subquery compiled from its relational model.
+package datasetsql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+// sourceAlias names the compiled subquery in the FROM clause.
+const sourceAlias = "de_ds"
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Dialect is the part of a datasource connection the compiler needs.
+type Dialect interface {
+	Type() string
+	QuoteIdentifier(name string) string
+	QualifiedTable(table string) string
+	FullJoin() bool
+}
+
+// Source is what the queries on a dataset select from. From is ready to
+// follow FROM; fields are read from it under the name Column returns. Args
+// are the bound SQL variables of From and come first in the query arguments.
+type Source struct {
+	From    string
+	Args    []interface{}
+	columns map[int64]string
+	// derived maps the converted and calculated fields selected by the
+	// source to their columns; aliased is set when From already names the
+	// source de_ds.
+	derived map[int64]string
+	aliased bool
+}
+
+// Table returns the source of a single physical table.
+func Table(d Dialect, table string) (*Source, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name")
+	}
+	return &Source{From: d.QualifiedTable(table), columns: map[int64]string{}}, nil
+}
+
+// ForTable returns the source of a dataset table: the physical table, or the
+// query of a custom SQL table with its variables bound from values and
+// checked by them.
+func ForTable(d Dialect, table *dataset.CoreDatasetTable, values *Values) (*Source, error) {
+	if table.Type == nil || *table.Type != dataset.TableTypeSQL {
+		if table.PhysicalTable == nil || *table.PhysicalTable == "" {
+			return nil, fmt.Errorf("dataset table_name is empty")
+		}
+		return Table(d, *table.PhysicalTable)
+	}
+	query, err := table.ParseSQL()
+	if err != nil {
+		return nil, err
+	}
+	variables, err := dataset.ParseSQLVariables(table.SQLVariables)
+	if err != nil {
+		return nil, err
+	}
+	bound, args, err := Bind(table.ID, query, variables, values)
+	if err != nil {
+		return nil, err
+	}
+	if err = values.check(bound); err != nil {
+		return nil, fmt.Errorf("sql of dataset table %d: %w", table.ID, err)
+	}
+	return &Source{From: "(" + bound + ") " + sourceAlias, Args: args, columns: map[int64]string{}, aliased: true}, nil
+}
+
+// Column returns the column a field is exposed as, falling back to the
+// names of the field for the fields of a single table.
+func (s *Source) Column(field *dataset.CoreDatasetTableField) string {
+	if name, ok := s.derived[field.ID]; ok {
+		return name
+	}
+	if isCalculated(field) {
+		return ""
+	}
+	if name, ok := s.columns[field.ID]; ok {
+		return name
+	}
+	for _, name := range []*string{field.OriginName, field.DataeaseName, field.Name} {
+		if name != nil && strings.TrimSpace(*name) != "" {
+			return strings.TrimSpace(*name)
+		}
+	}
+	return ""
+}
+
+// Exposes reports whether a field is selected by a compiled model. Every
+// column of a single table is; calculated fields are when they compile.
+func (s *Source) Exposes(field *dataset.CoreDatasetTableField) bool {
+	if _, ok := s.derived[field.ID]; ok {
+		return true
+	}
+	if isCalculated(field) {
+		return false
+	}
+	if len(s.columns) == 0 {
+		return true
+	}
+	_, ok := s.columns[field.ID]
+	return ok
+}
+
+// Compile returns the source of a dataset with its converted and calculated
+// fields. Datasets without a model read their first table, as they always
+// have; values bind the variables of a custom SQL table.
+func Compile(d Dialect, def *dataset.Definition, values *Values) (*Source, error) {
+	if def.Model == nil {
+		if len(def.Tables) == 0 {
+			return nil, fmt.Errorf("dataset has no table")
+		}
+		source, err := ForTable(d, def.Tables[0], values)
+		if err != nil {
+			return nil, err
+		}
+		return WithFields(d, source, TableFields(def.Fields, def.Tables[0].ID)), nil
+	}
+
+	query, columns, err := compileModel(d, def)
+	if err != nil {
+		return nil, err
+	}
+	source := &Source{From: "(" + query + ") " + sourceAlias, columns: columns, aliased: true}
+	return WithFields(d, source, def.Fields), nil
+}
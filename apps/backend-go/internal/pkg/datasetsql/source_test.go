@@ -0,0 +1,201 @@
+package datasetsql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+type testDialect struct{ fullJoin bool }
+
+func (testDialect) Type() string                       { return "sqlite" }
+func (testDialect) QuoteIdentifier(name string) string { return `"` + name + `"` }
+func (testDialect) QualifiedTable(table string) string { return `"` + table + `"` }
+func (d testDialect) FullJoin() bool                   { return d.fullJoin }
+
+func strPtr(v string) *string { return &v }
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func testField(id, tableID int64, origin string) *dataset.CoreDatasetTableField {
+	return &dataset.CoreDatasetTableField{
+		ID:             id,
+		DatasetTableID: int64Ptr(tableID),
+		OriginName:     strPtr(origin),
+		DataeaseName:   strPtr(fmt.Sprintf("f_%s_%d", origin, tableID)),
+	}
+}
+
+func testDefinition(model *dataset.Model) *dataset.Definition {
+	return &dataset.Definition{
+		Model: model,
+		Tables: []*dataset.CoreDatasetTable{
+			{ID: 1, PhysicalTable: strPtr("orders")},
+			{ID: 2, PhysicalTable: strPtr("customers")},
+		},
+		Fields: []*dataset.CoreDatasetTableField{
+			testField(11, 1, "id"),
+			testField(12, 1, "amount"),
+			testField(13, 1, "customer_id"),
+			testField(21, 2, "id"),
+			testField(22, 2, "name"),
+		},
+	}
+}
+
+func joinModel(joinType string) *dataset.Model {
+	return &dataset.Model{Union: []dataset.ModelNode{{
+		TableID: 1, DatasourceID: 7, TableName: "orders",
+		Children: []dataset.ModelNode{{
+			TableID: 2, DatasourceID: 7, TableName: "customers",
+			Join: &dataset.Join{Type: joinType, Conditions: []dataset.JoinCondition{
+				{ParentColumn: "customer_id", Column: "id"},
+			}},
+		}},
+	}}}
+}
+
+func TestCompile_WithoutModelReadsFirstTable(t *testing.T) {
+	source, err := Compile(testDialect{}, testDefinition(nil), nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if source.From != `"orders"` {
+		t.Fatalf("unexpected from: %s", source.From)
+	}
+	if column := source.Column(testField(99, 1, "amount")); column != "amount" {
+		t.Fatalf("unexpected column: %s", column)
+	}
+}
+
+func TestCompile_Join(t *testing.T) {
+	source, err := Compile(testDialect{}, testDefinition(joinModel(dataset.JoinLeft)), nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	want := `(SELECT t0."id" AS "f_id_1", t0."amount" AS "amount", t0."customer_id" AS "customer_id", ` +
+		`t1."id" AS "f_id_2", t1."name" AS "name" ` +
+		`FROM "orders" t0 LEFT JOIN "customers" t1 ON t0."customer_id" = t1."id") de_ds`
+	if source.From != want {
+		t.Fatalf("unexpected from:\n got %s\nwant %s", source.From, want)
+	}
+
+	def := testDefinition(nil)
+	if column := source.Column(def.Fields[3]); column != "f_id_2" {
+		t.Fatalf("unexpected column for customers.id: %s", column)
+	}
+	if !source.Exposes(def.Fields[4]) || source.Exposes(testField(99, 3, "other")) {
+		t.Fatal("unexpected exposed fields")
+	}
+}
+
+func TestCompile_FullJoinNeedsDialectSupport(t *testing.T) {
+	_, err := Compile(testDialect{}, testDefinition(joinModel(dataset.JoinFull)), nil)
+	if err == nil || !strings.Contains(err.Error(), "full join") {
+		t.Fatalf("expected full join error, got %v", err)
+	}
+	source, err := Compile(testDialect{fullJoin: true}, testDefinition(joinModel(dataset.JoinFull)), nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !strings.Contains(source.From, "FULL OUTER JOIN") {
+		t.Fatalf("unexpected from: %s", source.From)
+	}
+}
+
+func TestCompile_UnionMatchesColumnsByName(t *testing.T) {
+	model := &dataset.Model{Union: []dataset.ModelNode{
+		{TableID: 1, DatasourceID: 7, TableName: "orders"},
+		{TableID: 2, DatasourceID: 7, TableName: "customers"},
+	}}
+	if _, err := Compile(testDialect{}, testDefinition(model), nil); err == nil || !strings.Contains(err.Error(), "no column amount") {
+		t.Fatalf("expected missing union column error, got %v", err)
+	}
+
+	model = &dataset.Model{Distinct: true, Union: []dataset.ModelNode{
+		{TableID: 1, DatasourceID: 7, TableName: "orders"},
+		{TableID: 3, DatasourceID: 7, TableName: "orders_archive"},
+	}}
+	def := testDefinition(model)
+	def.Tables = append(def.Tables, &dataset.CoreDatasetTable{ID: 3, PhysicalTable: strPtr("orders_archive")})
+	def.Fields = append(def.Fields, testField(31, 3, "customer_id"), testField(32, 3, "ID"), testField(33, 3, "amount"))
+	source, err := Compile(testDialect{}, def, nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	want := `SELECT t0."ID" AS "id", t0."amount" AS "amount", t0."customer_id" AS "customer_id" FROM "orders_archive" t0`
+	if !strings.Contains(source.From, `"orders" t0 UNION `+want) {
+		t.Fatalf("unexpected from: %s", source.From)
+	}
+	if column := source.Column(def.Fields[6]); column != "id" {
+		t.Fatalf("unexpected column for orders_archive.ID: %s", column)
+	}
+
+	def.Fields = append(def.Fields, testField(34, 3, "note"))
+	if _, err = Compile(testDialect{}, def, nil); err == nil || !strings.Contains(err.Error(), "column note") {
+		t.Fatalf("expected extra union column error, got %v", err)
+	}
+}
+
+func TestCompile_SelfJoin(t *testing.T) {
+	model := &dataset.Model{Union: []dataset.ModelNode{{
+		TableID: 1, DatasourceID: 7, TableName: "orders",
+		Children: []dataset.ModelNode{{
+			TableID: 1, DatasourceID: 7, TableName: "orders",
+			Join: &dataset.Join{Type: dataset.JoinLeft, Conditions: []dataset.JoinCondition{{ParentColumn: "id", Column: "customer_id"}}},
+		}},
+	}}}
+	if _, err := Compile(testDialect{}, testDefinition(model), nil); err == nil || !strings.Contains(err.Error(), "alias") {
+		t.Fatalf("expected a table joined to itself to need an alias, got %v", err)
+	}
+
+	model.Union[0].Children[0].TableID, model.Union[0].Children[0].Alias = 3, "parent"
+	def := testDefinition(model)
+	def.Tables = append(def.Tables, &dataset.CoreDatasetTable{ID: 3, Name: strPtr("parent"), PhysicalTable: strPtr("orders")})
+	def.Fields = append(def.Fields, testField(31, 3, "id"))
+	source, err := Compile(testDialect{}, def, nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if !strings.Contains(source.From, `FROM "orders" t0 LEFT JOIN "orders" t1 ON t0."id" = t1."customer_id"`) ||
+		!strings.Contains(source.From, `t1."id" AS "f_id_3"`) {
+		t.Fatalf("unexpected from: %s", source.From)
+	}
+}
+
+func TestCompile_RunsOnSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/model.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE orders (id INTEGER, amount INTEGER, customer_id INTEGER)`,
+		`CREATE TABLE customers (id INTEGER, name TEXT)`,
+		`INSERT INTO orders VALUES (1, 10, 1), (2, 20, 2), (3, 30, 9)`,
+		`INSERT INTO customers VALUES (1, 'alice'), (2, 'bob')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	source, err := Compile(testDialect{}, testDefinition(joinModel(dataset.JoinInner)), nil)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	var total int
+	var names string
+	err = db.QueryRow(`SELECT SUM("amount"), GROUP_CONCAT("name") FROM `+source.From).Scan(&total, &names)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if total != 30 || !strings.Contains(names, "alice") || !strings.Contains(names, "bob") {
+		t.Fatalf("unexpected result: %d %s", total, names)
+	}
+}
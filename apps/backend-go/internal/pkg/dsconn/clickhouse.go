@@ -0,0 +1,53 @@
+package dsconn
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"dataease/backend/internal/domain/datasource"
+
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func newClickHouseProvider() Provider {
+	return &sqlProvider{
+		typ:         "ck",
+		name:        "ClickHouse",
+		aliases:     []string{"clickhouse"},
+		schema:      networkSchema(9000, false),
+		driverName:  "clickhouse",
+		dsn:         clickHouseDSN,
+		quoteOpen:   "`",
+		quoteClose:  "`",
+		columnTypes: [5]string{"Nullable(String)", "Nullable(DateTime)", "Nullable(Int64)", "Nullable(Float64)", "Nullable(Bool)"},
+		deTypes: map[string]int{
+			"bool":    4,
+			"boolean": 4,
+		},
+		schemaSQL:  "SELECT count() FROM system.databases WHERE name = ?",
+		tablesSQL:  "SELECT name, comment FROM system.tables WHERE database = ? ORDER BY name",
+		columnsSQL: "SELECT name, type FROM system.columns WHERE database = ? AND table = ? ORDER BY position",
+	}
+}
+
+func clickHouseDSN(cfg *datasource.ConnectionConfig) (string, error) {
+	host, port := cfg.HostPort()
+	if host == "" || port <= 0 {
+		return "", fmt.Errorf("missing host/port in datasource configuration")
+	}
+
+	u := url.URL{
+		Scheme: "clickhouse",
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:   "/" + cfg.DatabaseName(),
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	query := url.Values{}
+	query.Set("dial_timeout", "10s")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,459 @@
+package dsconn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+// Table describes a physical table exposed by an external datasource.
+type Table struct {
+	Name   string
+	Remark string
+}
+
+// Column describes a physical column exposed by an external datasource.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Conn is a pooled connection to one external datasource.
+type Conn struct {
+	db       *sql.DB
+	provider Provider
+	cfg      *datasource.ConnectionConfig
+	// manager tracks the running queries and holds the query limits; dsID
+	// is 0 for the engine connection.
+	manager *Manager
+	dsID    int64
+}
+
+// NewConn wraps an already opened database, such as the engine database
+// shared with the application.
+func NewConn(db *sql.DB, dsType string, cfg *datasource.ConnectionConfig) (*Conn, error) {
+	provider, err := lookupProvider(dsType)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{db: db, provider: provider, cfg: cfg}, nil
+}
+
+func (c *Conn) DB() *sql.DB {
+	return c.db
+}
+
+func (c *Conn) Provider() Provider {
+	return c.provider
+}
+
+// Type returns the datasource type of the provider, such as "mysql" or "pg".
+func (c *Conn) Type() string {
+	return c.provider.Type()
+}
+
+func (c *Conn) QuoteIdentifier(name string) string {
+	return c.provider.QuoteIdentifier(name)
+}
+
+// QualifiedTable quotes a table name and prefixes the configured schema when
+// the provider requires it.
+func (c *Conn) QualifiedTable(table string) string {
+	return c.provider.QualifyTable(c.cfg, table)
+}
+
+// Limit appends the provider's row limit clause bound to a trailing `?`.
+func (c *Conn) Limit(query string, ordered bool) string {
+	return c.provider.Limit(query, ordered)
+}
+
+// Namespace returns the database or schema that holds the tables listed by
+// ListTables.
+func (c *Conn) Namespace() string {
+	if c.cfg == nil {
+		return ""
+	}
+	return c.provider.Namespace(c.cfg)
+}
+
+// FullJoin reports whether the datasource supports FULL OUTER JOIN.
+func (c *Conn) FullJoin() bool {
+	return c.provider.FullJoin()
+}
+
+func (c *Conn) Ping() error {
+	return c.db.Ping()
+}
+
+func (c *Conn) ListTables() ([]Table, error) {
+	return c.ListTablesContext(context.Background())
+}
+
+func (c *Conn) ListTablesContext(ctx context.Context) ([]Table, error) {
+	query, args := c.provider.TablesQuery(c.cfg)
+	result := make([]Table, 0)
+	err := c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		rows, err := session.QueryContext(ctx, rebind(c.provider, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name, remark sql.NullString
+			if err = rows.Scan(&name, &remark); err != nil {
+				return err
+			}
+			if !name.Valid || name.String == "" {
+				continue
+			}
+			result = append(result, Table{Name: name.String, Remark: remark.String})
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Conn) ListColumns(table string) ([]Column, error) {
+	return c.ListColumnsContext(context.Background(), table)
+}
+
+func (c *Conn) ListColumnsContext(ctx context.Context, table string) ([]Column, error) {
+	query, args := c.provider.ColumnsQuery(c.cfg, table)
+	result := make([]Column, 0)
+	err := c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		rows, err := session.QueryContext(ctx, rebind(c.provider, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name, typeName sql.NullString
+			if err = rows.Scan(&name, &typeName); err != nil {
+				return err
+			}
+			result = append(result, Column{Name: name.String, Type: typeName.String})
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	return result, nil
+}
+
+func (c *Conn) PreviewRows(table string, limit int) ([]map[string]interface{}, error) {
+	return c.PreviewRowsContext(context.Background(), table, limit)
+}
+
+func (c *Conn) PreviewRowsContext(ctx context.Context, table string, limit int) ([]map[string]interface{}, error) {
+	query := c.Limit(fmt.Sprintf("SELECT * FROM %s", c.QualifiedTable(table)), false)
+	return c.QueryRowsContext(ctx, query, limit)
+}
+
+func (c *Conn) CountRows(table string) (int64, error) {
+	return c.CountRowsContext(context.Background(), table)
+}
+
+func (c *Conn) CountRowsContext(ctx context.Context, table string) (int64, error) {
+	return c.QueryCountContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM %s", c.QualifiedTable(table)))
+}
+
+// QueryCountContext runs a `?` placeholder query returning a single count.
+func (c *Conn) QueryCountContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	var count int64
+	err := c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		return session.QueryRowContext(ctx, rebind(c.provider, query), args...).Scan(&count)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// QueryRows executes a query written with `?` placeholders and returns every
+// row keyed by column label.
+func (c *Conn) QueryRows(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return c.QueryRowsContext(context.Background(), query, args...)
+}
+
+// QueryRowsContext is QueryRows bound to ctx; the query is cancelled when
+// ctx ends.
+func (c *Conn) QueryRowsContext(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		rows, err := session.QueryContext(ctx, rebind(c.provider, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		result, err = scanRows(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// QueryEachContext runs a `?` placeholder query and calls fn with every row,
+// in column order, as it is read instead of collecting the result. The
+// values slice is reused between calls. The query stops at the first error
+// fn returns.
+func (c *Conn) QueryEachContext(ctx context.Context, query string, fn func(values []interface{}) error, args ...interface{}) error {
+	return c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		rows, err := session.QueryContext(ctx, rebind(c.provider, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		for rows.Next() {
+			if err = rows.Scan(pointers...); err != nil {
+				return err
+			}
+			for i := range values {
+				values[i] = normalizeValue(values[i])
+			}
+			if err = fn(values); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// QueryGrid executes a query written with `?` placeholders and returns the
+// result columns, typed with the driver's type names, and the rows in column
+// order.
+func (c *Conn) QueryGrid(query string, args ...interface{}) ([]Column, [][]interface{}, error) {
+	return c.QueryGridContext(context.Background(), query, args...)
+}
+
+// QueryGridContext is QueryGrid bound to ctx.
+func (c *Conn) QueryGridContext(ctx context.Context, query string, args ...interface{}) ([]Column, [][]interface{}, error) {
+	var columns []Column
+	var result [][]interface{}
+	err := c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		rows, err := session.QueryContext(ctx, rebind(c.provider, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		columns, result, err = scanGrid(rows)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, result, nil
+}
+
+// QueryBatchesContext runs a `?` placeholder query and calls fn with the
+// result columns, typed like QueryGrid, and the rows read, at most size at a
+// time, so that large results are never held in memory. fn is called at
+// least once, with no rows for an empty result. The query stops at the
+// first error fn returns.
+func (c *Conn) QueryBatchesContext(ctx context.Context, query string, size int, fn func(columns []Column, rows [][]interface{}) error, args ...interface{}) error {
+	return c.withSession(ctx, query, func(ctx context.Context, session *sql.Conn) error {
+		rows, err := session.QueryContext(ctx, rebind(c.provider, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		columns, err := gridColumns(rows)
+		if err != nil {
+			return err
+		}
+		batch := make([][]interface{}, 0, size)
+		flushed := false
+		for rows.Next() {
+			values, scanErr := scanGridRow(rows, len(columns))
+			if scanErr != nil {
+				return scanErr
+			}
+			if batch = append(batch, values); len(batch) >= size {
+				if err = fn(columns, batch); err != nil {
+					return err
+				}
+				batch, flushed = make([][]interface{}, 0, size), true
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) > 0 || !flushed {
+			return fn(columns, batch)
+		}
+		return nil
+	})
+}
+
+func scanGrid(rows *sql.Rows) ([]Column, [][]interface{}, error) {
+	columns, err := gridColumns(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := make([][]interface{}, 0)
+	for rows.Next() {
+		values, scanErr := scanGridRow(rows, len(columns))
+		if scanErr != nil {
+			return nil, nil, scanErr
+		}
+		result = append(result, values)
+	}
+	return columns, result, rows.Err()
+}
+
+func gridColumns(rows *sql.Rows) ([]Column, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]Column, len(types))
+	for i, columnType := range types {
+		columns[i] = Column{Name: columnType.Name(), Type: columnType.DatabaseTypeName()}
+	}
+	return columns, nil
+}
+
+func scanGridRow(rows *sql.Rows, width int) ([]interface{}, error) {
+	values := make([]interface{}, width)
+	pointers := make([]interface{}, width)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+	for i := range values {
+		values[i] = normalizeValue(values[i])
+	}
+	return values, nil
+}
+
+// Exec runs a statement written with `?` placeholders.
+func (c *Conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c *Conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(ctx, rebind(c.provider, query), args...)
+}
+
+// Tx is a transaction on the database of a connection.
+type Tx struct {
+	tx       *sql.Tx
+	provider Provider
+}
+
+// Begin starts a transaction.
+func (c *Conn) Begin() (*Tx, error) {
+	return c.BeginContext(context.Background())
+}
+
+// BeginContext starts a transaction, rolled back if ctx ends before it is
+// committed.
+func (c *Conn) BeginContext(ctx context.Context) (*Tx, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, provider: c.provider}, nil
+}
+
+func (t *Tx) QuoteIdentifier(name string) string {
+	return t.provider.QuoteIdentifier(name)
+}
+
+// Exec runs a statement written with `?` placeholders in the transaction.
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, rebind(t.provider, query), args...)
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = normalizeValue(values[i])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func normalizeValue(v interface{}) interface{} {
+	if value, ok := v.([]byte); ok {
+		return string(value)
+	}
+	return v
+}
+
+// DeType maps a native column type of this datasource onto the DataEase
+// field type.
+func (c *Conn) DeType(columnType string) int {
+	return c.provider.DeType(columnType)
+}
+
+// InferDeType maps a native column type onto the DataEase field type
+// (0 text, 1 time, 2 integer, 3 float, 4 boolean).
+func InferDeType(columnType string) int {
+	typeName := strings.ToLower(columnType)
+	switch {
+	case strings.Contains(typeName, "bool"):
+		return 4
+	case strings.Contains(typeName, "int"):
+		return 2
+	case strings.Contains(typeName, "decimal"), strings.Contains(typeName, "numeric"),
+		strings.Contains(typeName, "double"), strings.Contains(typeName, "float"), strings.Contains(typeName, "real"):
+		return 3
+	case strings.Contains(typeName, "date"), strings.Contains(typeName, "time"), strings.Contains(typeName, "year"):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,328 @@
+package dsconn
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/secret"
+)
+
+// Options controls the pool created for every external datasource.
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxOpenConns:    10,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+}
+
+type pool struct {
+	conn        *Conn
+	tunnel      *tunnel
+	fingerprint string
+}
+
+func (p *pool) close() {
+	_ = p.conn.db.Close()
+	if p.tunnel != nil {
+		_ = p.tunnel.Close()
+	}
+}
+
+// Manager keeps one database/sql pool, and SSH tunnel when configured, per
+// datasource ID and rebuilds them when the stored datasource definition
+// changes.
+type Manager struct {
+	mu      sync.Mutex
+	opts    Options
+	pools   map[int64]*pool
+	opening map[int64]*opening
+	engine  *Conn
+	keys    *secret.Keyring
+
+	queries *queryTracker
+	limits  QueryLimits
+	logger  func(QueryLog)
+}
+
+// engineTypes are datasource types whose data is materialized into the
+// DataEase engine database instead of being queried remotely.
+var engineTypes = map[string]struct{}{
+	"excel": {},
+	"api":   {},
+}
+
+// IsEngineType reports whether a datasource type is served by the engine.
+func IsEngineType(dsType string) bool {
+	_, ok := engineTypes[normalizeType(dsType)]
+	return ok
+}
+
+func NewManager(opts Options) *Manager {
+	return &Manager{
+		opts:    opts,
+		pools:   make(map[int64]*pool),
+		opening: make(map[int64]*opening),
+		queries: newQueryTracker(),
+	}
+}
+
+// opening is a pool being opened. Callers asking for the same definition
+// meanwhile wait for it instead of opening their own.
+type opening struct {
+	fingerprint string
+	done        chan struct{}
+	err         error
+}
+
+// Get returns the pooled connection for a datasource, opening it on first use.
+// Pools are opened, SSH tunnel included, without holding the lock of the
+// manager, so a slow datasource does not stall the others.
+func (m *Manager) Get(ds *datasource.CoreDatasource) (*Conn, error) {
+	if ds == nil {
+		return nil, fmt.Errorf("datasource is required")
+	}
+	if IsEngineType(ds.Type) {
+		return m.Engine()
+	}
+	provider, err := lookupProvider(ds.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := fingerprintOf(ds)
+	for {
+		m.mu.Lock()
+		if p, ok := m.pools[ds.ID]; ok && p.fingerprint == fingerprint {
+			m.mu.Unlock()
+			return p.conn, nil
+		}
+		if o, ok := m.opening[ds.ID]; ok && o.fingerprint == fingerprint {
+			m.mu.Unlock()
+			<-o.done
+			if o.err != nil {
+				return nil, o.err
+			}
+			continue
+		}
+		o := &opening{fingerprint: fingerprint, done: make(chan struct{})}
+		m.opening[ds.ID] = o
+		keys := m.keys
+		m.mu.Unlock()
+
+		p, openErr := m.open(ds, provider, keys)
+
+		m.mu.Lock()
+		// Invalidate drops the opening: the pool is of a stale definition.
+		current := m.opening[ds.ID] == o
+		if current {
+			delete(m.opening, ds.ID)
+		}
+		var replaced *pool
+		if openErr == nil && current {
+			p.fingerprint = fingerprint
+			replaced = m.pools[ds.ID]
+			m.pools[ds.ID] = p
+			if p.tunnel != nil {
+				go m.watchTunnel(ds.ID, p)
+			}
+		}
+		o.err = openErr
+		m.mu.Unlock()
+		close(o.done)
+
+		if replaced != nil {
+			replaced.close()
+		}
+		if openErr != nil {
+			return nil, openErr
+		}
+		if current {
+			return p.conn, nil
+		}
+		p.close()
+	}
+}
+
+// open connects to a datasource, through its SSH tunnel when configured.
+func (m *Manager) open(ds *datasource.CoreDatasource, provider Provider, keys *secret.Keyring) (*pool, error) {
+	configuration := ""
+	if ds.Configuration != nil {
+		configuration = *ds.Configuration
+	}
+	configuration, err := keys.OpenConfig(configuration)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := datasource.DecodeConfig(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid datasource configuration: %w", err)
+	}
+	var t *tunnel
+	dialCfg := cfg
+	if cfg.UseSSH {
+		if t, err = openTunnel(cfg); err != nil {
+			return nil, err
+		}
+		dialCfg = t.configure(cfg)
+	}
+	db, err := provider.Open(dialCfg)
+	if err != nil {
+		if t != nil {
+			_ = t.Close()
+		}
+		return nil, err
+	}
+	db.SetMaxOpenConns(m.opts.MaxOpenConns)
+	db.SetMaxIdleConns(m.opts.MaxIdleConns)
+	db.SetConnMaxLifetime(m.opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(m.opts.ConnMaxIdleTime)
+
+	conn := &Conn{db: db, provider: provider, cfg: cfg, manager: m, dsID: ds.ID}
+	return &pool{conn: conn, tunnel: t}, nil
+}
+
+// watchTunnel drops a pool once its SSH tunnel is lost, so the next Get
+// reconnects instead of failing on the dead tunnel.
+func (m *Manager) watchTunnel(id int64, p *pool) {
+	<-p.tunnel.done
+	m.mu.Lock()
+	current := m.pools[id] == p
+	if current {
+		delete(m.pools, id)
+	}
+	m.mu.Unlock()
+	if current {
+		p.close()
+	}
+}
+
+// SetEngine registers the connection to the engine database that stores
+// uploaded and materialized datasource tables.
+func (m *Manager) SetEngine(conn *Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conn.manager = m
+	m.engine = conn
+}
+
+// SetQueryLimits sets the timeouts applied to every query on top of the
+// timeout configured by each datasource.
+func (m *Manager) SetQueryLimits(limits QueryLimits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = limits
+}
+
+func (m *Manager) queryLimits() QueryLimits {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limits
+}
+
+// SetQueryLogger sets the function called with every finished query run for
+// dataset tables. It runs on the goroutine of the query.
+func (m *Manager) SetQueryLogger(logger func(QueryLog)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger = logger
+}
+
+func (m *Manager) queryLogger() func(QueryLog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.logger
+}
+
+// RunningQueries lists the queries running on the managed connections,
+// oldest first.
+func (m *Manager) RunningQueries() []datasource.RunningQuery {
+	return m.queries.list()
+}
+
+// KillQuery cancels a running query, which also stops it server side.
+func (m *Manager) KillQuery(id string) error {
+	return m.queries.kill(id)
+}
+
+// SetKeyring sets the keyring that seals and opens datasource secrets. Without
+// one, secrets are stored in plain text.
+func (m *Manager) SetKeyring(keys *secret.Keyring) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = keys
+}
+
+// SealConfiguration encrypts the secrets of a configuration before it is
+// stored, keeping the secrets of previous that raw omits or masks.
+func (m *Manager) SealConfiguration(raw string, previous string) (string, error) {
+	m.mu.Lock()
+	keys := m.keys
+	m.mu.Unlock()
+	return keys.SealConfig(raw, previous)
+}
+
+// OpenConfiguration decrypts a stored configuration for callers that talk to
+// the datasource without a pooled connection (probes and API fetches).
+func (m *Manager) OpenConfiguration(raw string) (string, error) {
+	m.mu.Lock()
+	keys := m.keys
+	m.mu.Unlock()
+	return keys.OpenConfig(raw)
+}
+
+func (m *Manager) Engine() (*Conn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.engine == nil {
+		return nil, fmt.Errorf("engine database is not configured")
+	}
+	return m.engine, nil
+}
+
+// Invalidate closes the pool of a datasource so the next Get reconnects.
+func (m *Manager) Invalidate(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.pools[id]; ok {
+		p.close()
+		delete(m.pools, id)
+	}
+	delete(m.opening, id)
+}
+
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, p := range m.pools {
+		p.close()
+		delete(m.pools, id)
+	}
+	for id := range m.opening {
+		delete(m.opening, id)
+	}
+}
+
+func fingerprintOf(ds *datasource.CoreDatasource) string {
+	var updateTime int64
+	if ds.UpdateTime != nil {
+		updateTime = *ds.UpdateTime
+	}
+	configuration := ""
+	if ds.Configuration != nil {
+		configuration = *ds.Configuration
+	}
+	return fmt.Sprintf("%s|%d|%s", ds.Type, updateTime, configuration)
+}
@@ -0,0 +1,54 @@
+package dsconn
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func newMySQLProvider() Provider {
+	return &sqlProvider{
+		typ:         "mysql",
+		name:        "MySQL",
+		aliases:     []string{"mariadb", "tidb", "starrocks", "doris"},
+		schema:      networkSchema(3306, false),
+		driverName:  "mysql",
+		dsn:         mysqlDSN,
+		quoteOpen:   "`",
+		quoteClose:  "`",
+		columnTypes: [5]string{"LONGTEXT", "DATETIME", "BIGINT", "DOUBLE", "TINYINT(1)"},
+		noFullJoin:  true,
+		deTypes: map[string]int{
+			"bit": 4,
+		},
+		schemaSQL: "SELECT COUNT(1) FROM information_schema.schemata WHERE schema_name = ?",
+		tablesSQL: "SELECT table_name, table_comment FROM information_schema.tables " +
+			"WHERE table_schema = ? AND table_type IN ('BASE TABLE', 'VIEW') ORDER BY table_name",
+		columnsSQL: "SELECT column_name, column_type FROM information_schema.columns " +
+			"WHERE table_schema = ? AND table_name = ? ORDER BY ordinal_position",
+		sessionSQL: "SELECT CONNECTION_ID()",
+		cancelSQL:  "KILL QUERY %d",
+	}
+}
+
+func mysqlDSN(cfg *datasource.ConnectionConfig) (string, error) {
+	host, port := cfg.HostPort()
+	if host == "" || port <= 0 {
+		return "", fmt.Errorf("missing host/port in datasource configuration")
+	}
+
+	mc := mysql.NewConfig()
+	mc.User = cfg.Username
+	mc.Passwd = cfg.Password
+	mc.Net = "tcp"
+	mc.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	mc.DBName = cfg.DatabaseName()
+	mc.ParseTime = true
+	mc.Timeout = 10 * time.Second
+	return mc.FormatDSN(), nil
+}
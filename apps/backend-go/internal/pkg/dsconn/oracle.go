@@ -0,0 +1,61 @@
+package dsconn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+
+	goora "github.com/sijms/go-ora/v2"
+)
+
+func newOracleProvider() Provider {
+	return &sqlProvider{
+		typ:          "oracle",
+		name:         "Oracle",
+		schema:       networkSchema(1521, true),
+		schemaScoped: true,
+		driverName:   "oracle",
+		dsn:          oracleDSN,
+		quoteOpen:    `"`,
+		quoteClose:   `"`,
+		columnTypes:  [5]string{"CLOB", "TIMESTAMP", "NUMBER(19)", "BINARY_DOUBLE", "NUMBER(1)"},
+		placeholder:  func(index int) string { return ":" + strconv.Itoa(index) },
+		limit: func(query string, _ bool) string {
+			return query + " FETCH FIRST ? ROWS ONLY"
+		},
+		deTypes: map[string]int{
+			"number":        3,
+			"binary_float":  3,
+			"binary_double": 3,
+		},
+		namespace: oracleNamespace,
+		qualified: true,
+		probeSQL:  "SELECT 1 FROM DUAL",
+		schemaSQL: "SELECT COUNT(1) FROM all_users WHERE username = ?",
+		tablesSQL: "SELECT table_name, comments FROM all_tab_comments " +
+			"WHERE owner = ? AND table_type IN ('TABLE', 'VIEW') ORDER BY table_name",
+		columnsSQL: "SELECT column_name, data_type FROM all_tab_columns " +
+			"WHERE owner = ? AND table_name = ? ORDER BY column_id",
+	}
+}
+
+// oracleNamespace falls back to the login user, matching Oracle's default of
+// one schema per user.
+func oracleNamespace(cfg *datasource.ConnectionConfig) string {
+	if schema := strings.TrimSpace(cfg.Schema); schema != "" {
+		return schema
+	}
+	return strings.ToUpper(strings.TrimSpace(cfg.Username))
+}
+
+func oracleDSN(cfg *datasource.ConnectionConfig) (string, error) {
+	host, port := cfg.HostPort()
+	if host == "" || port <= 0 {
+		return "", fmt.Errorf("missing host/port in datasource configuration")
+	}
+	return goora.BuildUrl(host, port, cfg.DatabaseName(), cfg.Username, cfg.Password, map[string]string{
+		"CONNECTION TIMEOUT": "10",
+	}), nil
+}
@@ -0,0 +1,78 @@
+package dsconn
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+
+	_ "github.com/lib/pq"
+)
+
+func newPostgresProvider() Provider {
+	return &sqlProvider{
+		typ:          "pg",
+		name:         "PostgreSQL",
+		aliases:      []string{"postgres", "postgresql", "redshift"},
+		schema:       postgresSchema(),
+		schemaScoped: true,
+		driverName:   "postgres",
+		dsn:          postgresDSN,
+		quoteOpen:    `"`,
+		quoteClose:   `"`,
+		columnTypes:  [5]string{"TEXT", "TIMESTAMP", "BIGINT", "DOUBLE PRECISION", "BOOLEAN"},
+		placeholder:  func(index int) string { return "$" + strconv.Itoa(index) },
+		deTypes: map[string]int{
+			"interval": 0,
+			"money":    3,
+		},
+		defaultSchema: "public",
+		qualified:     true,
+		schemaSQL:     "SELECT COUNT(1) FROM pg_catalog.pg_namespace WHERE nspname = ?",
+		tablesSQL: "SELECT t.table_name, COALESCE(obj_description(c.oid), '') FROM information_schema.tables t " +
+			"LEFT JOIN pg_catalog.pg_class c ON c.relname = t.table_name " +
+			"AND c.relnamespace = (SELECT oid FROM pg_catalog.pg_namespace WHERE nspname = t.table_schema) " +
+			"WHERE t.table_schema = ? AND t.table_type IN ('BASE TABLE', 'VIEW') ORDER BY t.table_name",
+		columnsSQL: "SELECT column_name, data_type FROM information_schema.columns " +
+			"WHERE table_schema = ? AND table_name = ? ORDER BY ordinal_position",
+		sessionSQL: "SELECT pg_backend_pid()",
+		cancelSQL:  "SELECT pg_cancel_backend(%d)",
+	}
+}
+
+// postgresSSLModes are the sslmode values supported by the driver.
+var postgresSSLModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+func postgresSchema() []datasource.ConfigField {
+	return append(networkSchema(5432, true), datasource.ConfigField{
+		Name: "sslMode", Label: "SSL mode", Type: datasource.FieldTypeString, Default: "disable", Options: postgresSSLModes,
+	})
+}
+
+func postgresDSN(cfg *datasource.ConnectionConfig) (string, error) {
+	host, port := cfg.HostPort()
+	if host == "" || port <= 0 {
+		return "", fmt.Errorf("missing host/port in datasource configuration")
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:   "/" + cfg.DatabaseName(),
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	sslMode := strings.TrimSpace(cfg.SSLMode)
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	query.Set("connect_timeout", "10")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
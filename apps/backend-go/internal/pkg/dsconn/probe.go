@@ -0,0 +1,204 @@
+package dsconn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/sijms/go-ora/v2/network"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ProbeError is a failed validation together with its failure category.
+type ProbeError struct {
+	Category string
+	Err      error
+}
+
+func (e *ProbeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// Probe opens a dedicated, unpooled session for the configuration, through its
+// SSH tunnel when configured, runs the provider probe query and checks that
+// the configured namespace exists.
+func Probe(ctx context.Context, dsType string, cfg *datasource.ConnectionConfig) error {
+	provider, err := lookupProvider(dsType)
+	if err != nil {
+		return &ProbeError{Category: datasource.ErrorTypeConfig, Err: err}
+	}
+	dialCfg := cfg
+	if cfg.UseSSH {
+		if _, err = sshClientConfig(cfg); err != nil {
+			return &ProbeError{Category: datasource.ErrorTypeConfig, Err: err}
+		}
+		t, tunnelErr := openTunnel(cfg)
+		if tunnelErr != nil {
+			return classify(ctx, tunnelErr)
+		}
+		defer t.Close()
+		dialCfg = t.configure(cfg)
+	}
+	db, err := provider.Open(dialCfg)
+	if err != nil {
+		return &ProbeError{Category: datasource.ErrorTypeConfig, Err: err}
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err = db.PingContext(ctx); err != nil {
+		return classify(ctx, err)
+	}
+	if _, err = db.ExecContext(ctx, provider.ProbeQuery()); err != nil {
+		return classify(ctx, err)
+	}
+
+	query, args := provider.SchemaQuery(cfg)
+	if query == "" {
+		return nil
+	}
+	var count int64
+	if err = db.QueryRowContext(ctx, rebind(provider, query), args...).Scan(&count); err != nil {
+		return classify(ctx, err)
+	}
+	if count == 0 {
+		return &ProbeError{
+			Category: datasource.ErrorTypeDatabaseNotFound,
+			Err:      fmt.Errorf("schema %s does not exist", provider.Namespace(cfg)),
+		}
+	}
+	return nil
+}
+
+func classify(ctx context.Context, err error) error {
+	return &ProbeError{Category: Classify(ctx, err), Err: err}
+}
+
+// Classify maps a driver or network error onto a validation failure category.
+func Classify(ctx context.Context, err error) string {
+	if (ctx != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)) || errors.Is(err, context.DeadlineExceeded) {
+		return datasource.ErrorTypeTimeout
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1045, 1251:
+			return datasource.ErrorTypeAuth
+		case 1049:
+			return datasource.ErrorTypeDatabaseNotFound
+		case 1044, 1142, 1227:
+			return datasource.ErrorTypePermission
+		}
+		return datasource.ErrorTypeUnknown
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "28P01", "28000":
+			return datasource.ErrorTypeAuth
+		case "3D000", "3F000":
+			return datasource.ErrorTypeDatabaseNotFound
+		case "42501":
+			return datasource.ErrorTypePermission
+		}
+		return datasource.ErrorTypeUnknown
+	}
+
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		switch mssqlErr.Number {
+		case 18456:
+			return datasource.ErrorTypeAuth
+		case 4060, 911:
+			return datasource.ErrorTypeDatabaseNotFound
+		case 229, 262, 916:
+			return datasource.ErrorTypePermission
+		}
+		return datasource.ErrorTypeUnknown
+	}
+
+	var oraErr *network.OracleError
+	if errors.As(err, &oraErr) {
+		switch oraErr.ErrCode {
+		case 1017, 28000:
+			return datasource.ErrorTypeAuth
+		case 12514, 12505:
+			return datasource.ErrorTypeDatabaseNotFound
+		case 1031, 942:
+			return datasource.ErrorTypePermission
+		case 12170:
+			return datasource.ErrorTypeTimeout
+		}
+		return datasource.ErrorTypeUnknown
+	}
+
+	var chErr *clickhouse.Exception
+	if errors.As(err, &chErr) {
+		switch chErr.Code {
+		case 516, 192:
+			return datasource.ErrorTypeAuth
+		case 81:
+			return datasource.ErrorTypeDatabaseNotFound
+		case 497:
+			return datasource.ErrorTypePermission
+		}
+		return datasource.ErrorTypeUnknown
+	}
+
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	if errors.As(err, &keyErr) || errors.As(err, &revokedErr) {
+		return datasource.ErrorTypeHostKey
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalid x509.CertificateInvalidError
+	var recordHeader tls.RecordHeaderError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &certInvalid) || errors.As(err, &recordHeader) {
+		return datasource.ErrorTypeTLS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return datasource.ErrorTypeTimeout
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return datasource.ErrorTypeNetwork
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "ssh: unable to authenticate"):
+		return datasource.ErrorTypeAuth
+	case strings.Contains(message, "tls") || strings.Contains(message, "x509") || strings.Contains(message, "certificate"):
+		return datasource.ErrorTypeTLS
+	case strings.Contains(message, "timeout") || strings.Contains(message, "timed out"):
+		return datasource.ErrorTypeTimeout
+	case strings.Contains(message, "connection refused") || strings.Contains(message, "no such host") ||
+		strings.Contains(message, "unreachable") || strings.Contains(message, "connection reset"):
+		return datasource.ErrorTypeNetwork
+	case strings.Contains(message, "unable to open database file") || strings.Contains(message, "no such file"):
+		return datasource.ErrorTypeDatabaseNotFound
+	}
+	return datasource.ErrorTypeUnknown
+}
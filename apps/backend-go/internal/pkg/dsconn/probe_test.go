@@ -0,0 +1,85 @@
+package dsconn
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestClassify_DriverErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied"}, datasource.ErrorTypeAuth},
+		{&mysql.MySQLError{Number: 1049, Message: "Unknown database"}, datasource.ErrorTypeDatabaseNotFound},
+		{fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1142}), datasource.ErrorTypePermission},
+		{&pq.Error{Code: "28P01"}, datasource.ErrorTypeAuth},
+		{&pq.Error{Code: "3D000"}, datasource.ErrorTypeDatabaseNotFound},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, datasource.ErrorTypeNetwork},
+		{context.DeadlineExceeded, datasource.ErrorTypeTimeout},
+		{errors.New("tls: first record does not look like a TLS handshake"), datasource.ErrorTypeTLS},
+		{errors.New("boom"), datasource.ErrorTypeUnknown},
+	}
+	for _, tc := range cases {
+		if got := Classify(context.Background(), tc.err); got != tc.want {
+			t.Fatalf("%v: expected %s, got %s", tc.err, tc.want, got)
+		}
+	}
+}
+
+func TestProbe_ClosedPortIsNetworkFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = Probe(ctx, "mysql", &datasource.ConnectionConfig{Host: "127.0.0.1", Port: port, Database: "demo"})
+	var probeErr *ProbeError
+	if !errors.As(err, &probeErr) || probeErr.Category != datasource.ErrorTypeNetwork {
+		t.Fatalf("expected network failure, got %v", err)
+	}
+}
+
+func TestProbe_SQLite(t *testing.T) {
+	path := t.TempDir() + "/probe.db"
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = db.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = db.Close()
+
+	if err = Probe(context.Background(), "sqlite", &datasource.ConnectionConfig{Database: path}); err != nil {
+		t.Fatalf("expected probe to pass, got %v", err)
+	}
+
+	err = Probe(context.Background(), "sqlite", &datasource.ConnectionConfig{Database: t.TempDir() + "/missing.db"})
+	var probeErr *ProbeError
+	if !errors.As(err, &probeErr) || probeErr.Category != datasource.ErrorTypeDatabaseNotFound {
+		t.Fatalf("expected database not found, got %v", err)
+	}
+}
+
+func TestProbe_UnsupportedType(t *testing.T) {
+	err := Probe(context.Background(), "mongo", &datasource.ConnectionConfig{})
+	var probeErr *ProbeError
+	if !errors.As(err, &probeErr) || probeErr.Category != datasource.ErrorTypeConfig {
+		t.Fatalf("expected config failure, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package dsconn
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+// Provider describes how to reach and introspect one family of SQL
+// datasources. Providers are registered by datasource type and looked up
+// case-insensitively by type or alias.
+type Provider interface {
+	// Type is the canonical datasource type stored in core_datasource.type.
+	Type() string
+	Name() string
+	Aliases() []string
+	ConfigSchema() []datasource.ConfigField
+	// SchemaScoped reports whether two datasources on the same database are
+	// only duplicates when they also point at the same schema.
+	SchemaScoped() bool
+
+	Open(cfg *datasource.ConnectionConfig) (*sql.DB, error)
+	QuoteIdentifier(name string) string
+	Placeholder(index int) string
+	// Limit appends a row limit bound to a trailing `?` placeholder.
+	Limit(query string, ordered bool) string
+	DeType(columnType string) int
+	// ColumnType is the native column type used to materialize a deType.
+	ColumnType(deType int) string
+	// FullJoin reports whether the dialect supports FULL OUTER JOIN.
+	FullJoin() bool
+
+	// Namespace returns the database or schema that holds the tables.
+	Namespace(cfg *datasource.ConnectionConfig) string
+	QualifyTable(cfg *datasource.ConnectionConfig, table string) string
+	// ProbeQuery is a cheap statement proving the session is usable.
+	ProbeQuery() string
+	// SchemaQuery counts the namespaces matching the configured one.
+	SchemaQuery(cfg *datasource.ConnectionConfig) (string, []interface{})
+	// TablesQuery and ColumnsQuery return `?` placeholder queries yielding
+	// (name, remark) and (name, type) rows respectively.
+	TablesQuery(cfg *datasource.ConnectionConfig) (string, []interface{})
+	ColumnsQuery(cfg *datasource.ConnectionConfig, table string) (string, []interface{})
+}
+
+// Canceler is implemented by providers whose drivers do not stop the server
+// side statement when the query context ends. SessionQuery returns the id of
+// the current session and CancelQuery the statement cancelling the query that
+// session runs; either being empty disables the cancellation.
+type Canceler interface {
+	SessionQuery() string
+	CancelQuery(session int64) string
+}
+
+var registry = struct {
+	sync.RWMutex
+	byType map[string]Provider
+	byKey  map[string]Provider
+}{
+	byType: make(map[string]Provider),
+	byKey:  make(map[string]Provider),
+}
+
+// Register adds a provider under its type and aliases, replacing any provider
+// previously registered under the same keys.
+func Register(p Provider) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.byType[p.Type()] = p
+	registry.byKey[normalizeType(p.Type())] = p
+	for _, alias := range p.Aliases() {
+		registry.byKey[normalizeType(alias)] = p
+	}
+}
+
+func Lookup(dsType string) (Provider, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	p, ok := registry.byKey[normalizeType(dsType)]
+	return p, ok
+}
+
+// Providers returns every registered provider ordered by type.
+func Providers() []Provider {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	result := make([]Provider, 0, len(registry.byType))
+	for _, p := range registry.byType {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Type() < result[j].Type()
+	})
+	return result
+}
+
+func lookupProvider(dsType string) (Provider, error) {
+	p, ok := Lookup(dsType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported datasource type: %s", dsType)
+	}
+	return p, nil
+}
+
+func normalizeType(dsType string) string {
+	return strings.ToLower(strings.TrimSpace(dsType))
+}
+
+func init() {
+	for _, p := range []Provider{
+		newMySQLProvider(),
+		newPostgresProvider(),
+		newSQLServerProvider(),
+		newOracleProvider(),
+		newClickHouseProvider(),
+		newSQLiteProvider(),
+	} {
+		Register(p)
+	}
+}
+
+// rebind rewrites `?` placeholders into the provider specific form, leaving
+// quoted literals and identifiers untouched.
+func rebind(p Provider, query string) string {
+	if p.Placeholder(1) == "?" || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	index := 0
+	var quote rune
+	for _, ch := range query {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			index++
+			b.WriteString(p.Placeholder(index))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
@@ -0,0 +1,191 @@
+package dsconn
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+func TestLookup_TypesAndAliases(t *testing.T) {
+	cases := map[string]string{
+		"mysql":      "mysql",
+		"MariaDB":    "mysql",
+		"postgresql": "pg",
+		"redshift":   "pg",
+		"sqlserver":  "sqlServer",
+		"oracle":     "oracle",
+		"clickhouse": "ck",
+		"sqlite":     "sqlite",
+	}
+	for dsType, want := range cases {
+		p, ok := Lookup(dsType)
+		if !ok {
+			t.Fatalf("%s: expected provider", dsType)
+		}
+		if p.Type() != want {
+			t.Fatalf("%s: expected %s, got %s", dsType, want, p.Type())
+		}
+	}
+	if _, ok := Lookup("Excel"); ok {
+		t.Fatal("expected no provider for excel")
+	}
+}
+
+func TestProviders_SortedWithSchema(t *testing.T) {
+	providers := Providers()
+	if len(providers) < 6 {
+		t.Fatalf("expected built-in providers, got %d", len(providers))
+	}
+	for i, p := range providers {
+		if i > 0 && providers[i-1].Type() > p.Type() {
+			t.Fatal("expected providers sorted by type")
+		}
+		if len(p.ConfigSchema()) == 0 {
+			t.Fatalf("%s: expected config schema", p.Type())
+		}
+	}
+}
+
+func TestProvider_SchemaScoped(t *testing.T) {
+	for dsType, want := range map[string]bool{"mysql": false, "pg": true, "sqlServer": true, "oracle": true, "ck": false} {
+		p, _ := Lookup(dsType)
+		if p.SchemaScoped() != want {
+			t.Fatalf("%s: expected schema scoped %v", dsType, want)
+		}
+	}
+}
+
+func TestProvider_QuotingAndQualify(t *testing.T) {
+	cfg := &datasource.ConnectionConfig{Username: "scott"}
+	cases := []struct {
+		dsType string
+		want   string
+	}{
+		{"mysql", "`orders`"},
+		{"pg", `"public"."orders"`},
+		{"sqlServer", "[dbo].[orders]"},
+		{"oracle", `"SCOTT"."orders"`},
+		{"ck", "`orders`"},
+		{"sqlite", `"orders"`},
+	}
+	for _, tc := range cases {
+		p, _ := Lookup(tc.dsType)
+		if got := p.QualifyTable(cfg, "orders"); got != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.dsType, tc.want, got)
+		}
+	}
+
+	sqlServer, _ := Lookup("sqlServer")
+	if got := sqlServer.QuoteIdentifier("a]b"); got != "[a]]b]" {
+		t.Fatalf("unexpected quoted identifier: %s", got)
+	}
+}
+
+func TestProvider_Limit(t *testing.T) {
+	cases := []struct {
+		dsType  string
+		ordered bool
+		want    string
+	}{
+		{"mysql", false, "SELECT 1 LIMIT ?"},
+		{"sqlServer", false, "SELECT 1 ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT ? ROWS ONLY"},
+		{"sqlServer", true, "SELECT 1 OFFSET 0 ROWS FETCH NEXT ? ROWS ONLY"},
+		{"oracle", false, "SELECT 1 FETCH FIRST ? ROWS ONLY"},
+	}
+	for _, tc := range cases {
+		p, _ := Lookup(tc.dsType)
+		if got := p.Limit("SELECT 1", tc.ordered); got != tc.want {
+			t.Fatalf("%s: expected %q, got %q", tc.dsType, tc.want, got)
+		}
+	}
+}
+
+func TestProvider_DeType(t *testing.T) {
+	cases := []struct {
+		dsType     string
+		columnType string
+		want       int
+	}{
+		{"oracle", "NUMBER", 3},
+		{"oracle", "TIMESTAMP(6)", 1},
+		{"sqlServer", "bit", 4},
+		{"pg", "interval", 0},
+		{"ck", "Nullable(Int64)", 2},
+		{"mysql", "varchar(20)", 0},
+	}
+	for _, tc := range cases {
+		p, _ := Lookup(tc.dsType)
+		if got := p.DeType(tc.columnType); got != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.dsType, tc.columnType, tc.want, got)
+		}
+	}
+}
+
+func TestSQLiteProvider_ReadsLocalFile(t *testing.T) {
+	path := t.TempDir() + "/demo.db"
+	cfg := &datasource.ConnectionConfig{Database: path}
+	p, _ := Lookup("sqlite")
+
+	writer, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = writer.Exec("CREATE TABLE orders (id INTEGER, amount REAL, note TEXT)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = writer.Exec("INSERT INTO orders VALUES (1, 9.5, 'a'), (2, 3.0, 'b')"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = writer.Close()
+
+	db, err := p.Open(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn := &Conn{db: db, provider: p, cfg: cfg}
+	defer db.Close()
+
+	tables, err := conn.ListTables()
+	if err != nil || len(tables) != 1 || tables[0].Name != "orders" {
+		t.Fatalf("unexpected tables: %v %v", tables, err)
+	}
+	columns, err := conn.ListColumns("orders")
+	if err != nil || len(columns) != 3 || columns[1].Name != "amount" {
+		t.Fatalf("unexpected columns: %v %v", columns, err)
+	}
+	if conn.DeType(columns[1].Type) != 3 {
+		t.Fatalf("expected REAL to map to float, got %d", conn.DeType(columns[1].Type))
+	}
+	rows, err := conn.PreviewRows("orders", 1)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("unexpected rows: %v %v", rows, err)
+	}
+	total, err := conn.CountRows("orders")
+	if err != nil || total != 2 {
+		t.Fatalf("unexpected count: %d %v", total, err)
+	}
+	notes := make([]string, 0)
+	err = conn.QueryEachContext(context.Background(), "SELECT note FROM orders WHERE id >= ? ORDER BY id", func(values []interface{}) error {
+		notes = append(notes, values[0].(string))
+		return nil
+	}, 1)
+	if err != nil || strings.Join(notes, ",") != "a,b" {
+		t.Fatalf("unexpected streamed rows: %v %v", notes, err)
+	}
+}
+
+func TestProvider_SchemaAcceptsDefaultForm(t *testing.T) {
+	p, _ := Lookup("mysql")
+	form := `{"dataBase": "demo", "jdbcUrl": "", "urlType": "hostName", "sshType": "password", "extraParams": "",
+		"username": "root", "password": "", "host": "db", "authMethod": "", "port": 3306,
+		"initialPoolSize": 50, "minPoolSize": 50, "maxPoolSize": 100, "queryTimeout": 30}`
+	if err := datasource.ValidateConfig(p.ConfigSchema(), form); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := datasource.ValidateConfig(p.ConfigSchema(), `{"host": "db", "port": 0, "dataBase": "demo"}`); err == nil {
+		t.Fatal("expected port 0 to be rejected")
+	}
+}
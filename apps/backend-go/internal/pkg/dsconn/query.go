@@ -0,0 +1,247 @@
+package dsconn
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+// cancelTimeout bounds the statement that cancels a query server side.
+const cancelTimeout = 5 * time.Second
+
+// QueryLimits are the timeouts applied to queries on top of the timeout of
+// their datasource; the shortest one wins. PerUser is keyed by username and
+// overrides Default.
+type QueryLimits struct {
+	Default time.Duration
+	PerUser map[string]time.Duration
+}
+
+func (l QueryLimits) forUser(username string) time.Duration {
+	if timeout, ok := l.PerUser[username]; ok {
+		return timeout
+	}
+	return l.Default
+}
+
+type queryUserKey struct{}
+
+type queryUser struct {
+	id       int64
+	username string
+}
+
+// WithUser records the user running the queries of ctx, for the per-user
+// timeout and the running queries list.
+func WithUser(ctx context.Context, userID int64, username string) context.Context {
+	return context.WithValue(ctx, queryUserKey{}, queryUser{id: userID, username: username})
+}
+
+func userFrom(ctx context.Context) queryUser {
+	user, _ := ctx.Value(queryUserKey{}).(queryUser)
+	return user
+}
+
+type queryTablesKey struct{}
+
+// WithDatasetTables records the dataset tables the queries of ctx run for,
+// so that they are passed to the query logger of the manager.
+func WithDatasetTables(ctx context.Context, tableIDs ...int64) context.Context {
+	return context.WithValue(ctx, queryTablesKey{}, tableIDs)
+}
+
+func tablesFrom(ctx context.Context) []int64 {
+	tables, _ := ctx.Value(queryTablesKey{}).([]int64)
+	return tables
+}
+
+// QueryLog is a finished query run for dataset tables. Err is nil when the
+// query succeeded.
+type QueryLog struct {
+	TableIDs []int64
+	SQL      string
+	Start    time.Time
+	End      time.Time
+	Err      error
+}
+
+type runningQuery struct {
+	info   datasource.RunningQuery
+	cancel context.CancelFunc
+}
+
+// queryTracker lists the queries running through the connections of a
+// Manager so that they can be killed.
+type queryTracker struct {
+	mu      sync.Mutex
+	running map[string]*runningQuery
+}
+
+func newQueryTracker() *queryTracker {
+	return &queryTracker{running: make(map[string]*runningQuery)}
+}
+
+func (t *queryTracker) add(info datasource.RunningQuery, cancel context.CancelFunc) string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	info.ID = hex.EncodeToString(buf)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running[info.ID] = &runningQuery{info: info, cancel: cancel}
+	return info.ID
+}
+
+func (t *queryTracker) remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.running, id)
+}
+
+func (t *queryTracker) list() []datasource.RunningQuery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]datasource.RunningQuery, 0, len(t.running))
+	for _, query := range t.running {
+		result = append(result, query.info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime < result[j].StartTime
+	})
+	return result
+}
+
+func (t *queryTracker) kill(id string) error {
+	t.mu.Lock()
+	query, ok := t.running[id]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("query not found")
+	}
+	query.cancel()
+	return nil
+}
+
+// withSession runs fn on a dedicated session of the pool under the timeouts
+// of the datasource and of the user of ctx. While fn runs the query is listed
+// by the manager, and when ctx ends first the statement is cancelled server
+// side for the providers that need it. Queries run for dataset tables are
+// passed to the query logger once finished.
+func (c *Conn) withSession(ctx context.Context, query string, fn func(ctx context.Context, session *sql.Conn) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	err := c.runSession(ctx, query, fn)
+	if tables := tablesFrom(ctx); len(tables) > 0 && c.manager != nil {
+		if log := c.manager.queryLogger(); log != nil {
+			log(QueryLog{TableIDs: tables, SQL: query, Start: start, End: time.Now(), Err: err})
+		}
+	}
+	return err
+}
+
+func (c *Conn) runSession(ctx context.Context, query string, fn func(ctx context.Context, session *sql.Conn) error) error {
+	user := userFrom(ctx)
+	timeout := c.timeout(user.username)
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	if c.manager != nil {
+		id := c.manager.queries.add(datasource.RunningQuery{
+			DatasourceID: c.dsID,
+			UserID:       user.id,
+			Username:     user.username,
+			SQL:          query,
+			StartTime:    time.Now().UnixMilli(),
+			Timeout:      timeout.Milliseconds(),
+		}, cancel)
+		defer c.manager.queries.remove(id)
+	}
+
+	session, err := c.db.Conn(ctx)
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	defer session.Close()
+
+	stop := c.watch(ctx, session)
+	err = fn(ctx, session)
+	stop()
+	if err != nil {
+		return queryError(ctx, err)
+	}
+	return nil
+}
+
+// timeout is the shortest positive timeout of the datasource and the user.
+func (c *Conn) timeout(username string) time.Duration {
+	var timeout time.Duration
+	if c.cfg != nil && c.cfg.QueryTimeout > 0 {
+		timeout = time.Duration(c.cfg.QueryTimeout) * time.Second
+	}
+	if c.manager != nil {
+		if limit := c.manager.queryLimits().forUser(username); limit > 0 && (timeout == 0 || limit < timeout) {
+			timeout = limit
+		}
+	}
+	return timeout
+}
+
+// watch cancels the statement running on session when ctx ends before the
+// returned stop function is called. stop waits for a pending cancellation so
+// that it never reaches the next user of the session.
+func (c *Conn) watch(ctx context.Context, session *sql.Conn) func() {
+	canceler, ok := c.provider.(Canceler)
+	if !ok || canceler.SessionQuery() == "" {
+		return func() {}
+	}
+	var id int64
+	if err := session.QueryRowContext(ctx, canceler.SessionQuery()).Scan(&id); err != nil {
+		return func() {}
+	}
+	statement := canceler.CancelQuery(id)
+	if statement == "" {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			cancelCtx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+			defer cancel()
+			_, _ = c.db.ExecContext(cancelCtx, statement)
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// queryError reports why a query stopped when its context ended.
+func queryError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("query timed out: %w", err)
+	case context.Canceled:
+		return fmt.Errorf("query canceled: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package dsconn
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+// endlessQuery keeps SQLite busy until the statement is interrupted.
+const endlessQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT COUNT(*) FROM c"
+
+func sqliteConn(t *testing.T, m *Manager, queryTimeout int) *Conn {
+	t.Helper()
+	path := t.TempDir() + "/query.db"
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	configuration := `{"dataBase": "` + path + `", "queryTimeout": ` + strconv.Itoa(queryTimeout) + `}`
+	conn, err := m.Get(&datasource.CoreDatasource{ID: 9, Type: "sqlite", Configuration: &configuration})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return conn
+}
+
+func TestConn_QueryTimeoutFromUserLimits(t *testing.T) {
+	m := NewManager(DefaultOptions())
+	defer m.Close()
+	m.SetQueryLimits(QueryLimits{Default: time.Minute, PerUser: map[string]time.Duration{"alice": 100 * time.Millisecond}})
+	conn := sqliteConn(t, m, 0)
+
+	ctx := WithUser(context.Background(), 3, "alice")
+	_, err := conn.QueryRowsContext(ctx, endlessQuery)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if running := m.RunningQueries(); len(running) != 0 {
+		t.Fatalf("expected finished query to be unlisted, got %+v", running)
+	}
+}
+
+func TestConn_KillRunningQuery(t *testing.T) {
+	m := NewManager(DefaultOptions())
+	defer m.Close()
+	conn := sqliteConn(t, m, 0)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.QueryRowsContext(WithUser(context.Background(), 3, "alice"), endlessQuery)
+		done <- err
+	}()
+
+	var running []datasource.RunningQuery
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if running = m.RunningQueries(); len(running) == 1 {
+			break
+		}
+	}
+	if len(running) != 1 || running[0].DatasourceID != 9 || running[0].Username != "alice" || running[0].SQL != endlessQuery {
+		t.Fatalf("expected the query to be listed, got %+v", running)
+	}
+	if err := m.KillQuery(running[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err == nil || !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected canceled query, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query was not cancelled")
+	}
+	if err := m.KillQuery(running[0].ID); err == nil {
+		t.Fatal("expected unknown query error")
+	}
+}
+
+func TestConn_DatasourceTimeoutWinsWhenShorter(t *testing.T) {
+	m := NewManager(DefaultOptions())
+	defer m.Close()
+	m.SetQueryLimits(QueryLimits{Default: time.Hour})
+	conn := sqliteConn(t, m, 1)
+	if got := conn.timeout(""); got != time.Second {
+		t.Fatalf("expected datasource timeout of 1s, got %v", got)
+	}
+}
+
+func TestConn_LogsDatasetQueries(t *testing.T) {
+	m := NewManager(DefaultOptions())
+	defer m.Close()
+	var logs []QueryLog
+	m.SetQueryLogger(func(log QueryLog) { logs = append(logs, log) })
+	conn := sqliteConn(t, m, 0)
+
+	if _, err := conn.QueryRowsContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := WithDatasetTables(context.Background(), 4, 5)
+	if _, err := conn.QueryRowsContext(ctx, "SELECT 2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := conn.QueryRowsContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+
+	if len(logs) != 2 {
+		t.Fatalf("expected the two dataset queries to be logged, got %+v", logs)
+	}
+	if logs[0].SQL != "SELECT 2" || logs[0].Err != nil || len(logs[0].TableIDs) != 2 || logs[0].End.Before(logs[0].Start) {
+		t.Errorf("unexpected log of successful query: %+v", logs[0])
+	}
+	if logs[1].SQL != "SELECT * FROM missing" || logs[1].Err == nil {
+		t.Errorf("unexpected log of failed query: %+v", logs[1])
+	}
+}
+
+func TestSQLProvider_CancelQuery(t *testing.T) {
+	mysql, _ := lookupProvider("mysql")
+	if got := mysql.(Canceler).CancelQuery(42); got != "KILL QUERY 42" {
+		t.Errorf("unexpected mysql cancel statement %q", got)
+	}
+	pg, _ := lookupProvider("pg")
+	if got := pg.(Canceler).CancelQuery(42); got != "SELECT pg_cancel_backend(42)" {
+		t.Errorf("unexpected postgres cancel statement %q", got)
+	}
+	sqlite, _ := lookupProvider("sqlite")
+	if canceler, ok := sqlite.(Canceler); ok && canceler.SessionQuery() != "" {
+		t.Errorf("expected sqlite to rely on the driver interrupt")
+	}
+}
+
+func TestConn_QueryBatches(t *testing.T) {
+	m := NewManager(DefaultOptions())
+	defer m.Close()
+	conn := sqliteConn(t, m, 0)
+
+	sizes := make([]int, 0)
+	err := conn.QueryBatchesContext(context.Background(), "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 5) SELECT x FROM c", 2, func(columns []Column, rows [][]interface{}) error {
+		if len(columns) != 1 || columns[0].Name != "x" {
+			t.Fatalf("unexpected columns %+v", columns)
+		}
+		sizes = append(sizes, len(rows))
+		return nil
+	})
+	if err != nil || len(sizes) != 3 || sizes[2] != 1 {
+		t.Fatalf("expected batches of 2, 2 and 1, got %v (%v)", sizes, err)
+	}
+
+	calls := 0
+	err = conn.QueryBatchesContext(context.Background(), "SELECT 1 AS x WHERE 1 = 0", 2, func(columns []Column, rows [][]interface{}) error {
+		calls++
+		return nil
+	})
+	if err != nil || calls != 1 {
+		t.Fatalf("expected an empty result to be reported once, got %d calls (%v)", calls, err)
+	}
+}
+
+func TestConn_ContextVariantsStopWithTheirContext(t *testing.T) {
+	// Pooled SQLite datasources are read only.
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/write.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	conn, err := NewConn(db, "sqlite", &datasource.ConnectionConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE orders (id INTEGER)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tables, err := conn.ListTablesContext(context.Background()); err != nil || len(tables) != 1 {
+		t.Fatalf("expected the orders table, got %+v (%v)", tables, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.ListTablesContext(ctx); err == nil {
+		t.Error("expected ListTablesContext to stop with its context")
+	}
+	if _, err := conn.ListColumnsContext(ctx, "orders"); err == nil {
+		t.Error("expected ListColumnsContext to stop with its context")
+	}
+	if _, err := conn.ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)"); err == nil {
+		t.Error("expected ExecContext to stop with its context")
+	}
+	if _, err := conn.BeginContext(ctx); err == nil {
+		t.Error("expected BeginContext to stop with its context")
+	}
+}
@@ -0,0 +1,194 @@
+package dsconn
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+// sqlProvider is the table driven Provider shared by the built-in types.
+type sqlProvider struct {
+	typ          string
+	name         string
+	aliases      []string
+	schema       []datasource.ConfigField
+	schemaScoped bool
+
+	driverName  string
+	dsn         func(cfg *datasource.ConnectionConfig) (string, error)
+	quoteOpen   string
+	quoteClose  string
+	placeholder func(index int) string
+	limit       func(query string, ordered bool) string
+	deTypes     map[string]int
+	// columnTypes is indexed by deType: text, time, integer, float, boolean.
+	columnTypes [5]string
+
+	// defaultSchema makes the provider schema based: tables are qualified by
+	// cfg.Schema, falling back to defaultSchema.
+	defaultSchema string
+	// namespace overrides how the table namespace is derived from the config.
+	namespace  func(cfg *datasource.ConnectionConfig) string
+	qualified  bool
+	probeSQL   string
+	schemaSQL  string
+	tablesSQL  string
+	columnsSQL string
+	// sessionSQL returns the server id of the current session and cancelSQL,
+	// formatted with that id, stops the statement it is running.
+	sessionSQL string
+	cancelSQL  string
+	// noFullJoin marks dialects without FULL OUTER JOIN.
+	noFullJoin bool
+}
+
+func (p *sqlProvider) Type() string                           { return p.typ }
+func (p *sqlProvider) Name() string                           { return p.name }
+func (p *sqlProvider) Aliases() []string                      { return p.aliases }
+func (p *sqlProvider) ConfigSchema() []datasource.ConfigField { return p.schema }
+func (p *sqlProvider) SchemaScoped() bool                     { return p.schemaScoped }
+func (p *sqlProvider) FullJoin() bool                         { return !p.noFullJoin }
+
+func (p *sqlProvider) Open(cfg *datasource.ConnectionConfig) (*sql.DB, error) {
+	dsn, err := p.dsn(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open(p.driverName, dsn)
+}
+
+func (p *sqlProvider) QuoteIdentifier(name string) string {
+	return p.quoteOpen + strings.ReplaceAll(name, p.quoteClose, p.quoteClose+p.quoteClose) + p.quoteClose
+}
+
+func (p *sqlProvider) Placeholder(index int) string {
+	if p.placeholder == nil {
+		return "?"
+	}
+	return p.placeholder(index)
+}
+
+func (p *sqlProvider) Limit(query string, ordered bool) string {
+	if p.limit == nil {
+		return query + " LIMIT ?"
+	}
+	return p.limit(query, ordered)
+}
+
+func (p *sqlProvider) DeType(columnType string) int {
+	base := strings.ToLower(strings.TrimSpace(columnType))
+	if idx := strings.IndexAny(base, "( "); idx >= 0 {
+		base = base[:idx]
+	}
+	if deType, ok := p.deTypes[base]; ok {
+		return deType
+	}
+	return InferDeType(columnType)
+}
+
+func (p *sqlProvider) ColumnType(deType int) string {
+	if deType < 0 || deType >= len(p.columnTypes) {
+		deType = 0
+	}
+	return p.columnTypes[deType]
+}
+
+func (p *sqlProvider) Namespace(cfg *datasource.ConnectionConfig) string {
+	if p.namespace != nil {
+		return p.namespace(cfg)
+	}
+	if p.defaultSchema != "" {
+		if schema := strings.TrimSpace(cfg.Schema); schema != "" {
+			return schema
+		}
+		return p.defaultSchema
+	}
+	return cfg.DatabaseName()
+}
+
+func (p *sqlProvider) QualifyTable(cfg *datasource.ConnectionConfig, table string) string {
+	if p.qualified {
+		return p.QuoteIdentifier(p.Namespace(cfg)) + "." + p.QuoteIdentifier(table)
+	}
+	return p.QuoteIdentifier(table)
+}
+
+func (p *sqlProvider) ProbeQuery() string {
+	if p.probeSQL == "" {
+		return "SELECT 1"
+	}
+	return p.probeSQL
+}
+
+func (p *sqlProvider) SchemaQuery(cfg *datasource.ConnectionConfig) (string, []interface{}) {
+	return p.schemaSQL, []interface{}{p.Namespace(cfg)}
+}
+
+func (p *sqlProvider) TablesQuery(cfg *datasource.ConnectionConfig) (string, []interface{}) {
+	return p.tablesSQL, []interface{}{p.Namespace(cfg)}
+}
+
+func (p *sqlProvider) ColumnsQuery(cfg *datasource.ConnectionConfig, table string) (string, []interface{}) {
+	return p.columnsSQL, []interface{}{p.Namespace(cfg), table}
+}
+
+func (p *sqlProvider) SessionQuery() string {
+	return p.sessionSQL
+}
+
+func (p *sqlProvider) CancelQuery(session int64) string {
+	if p.cancelSQL == "" {
+		return ""
+	}
+	return fmt.Sprintf(p.cancelSQL, session)
+}
+
+// networkSchema is the connection form shared by host based providers. The
+// host, port and database may be left out when a JDBC URL carries them.
+func networkSchema(defaultPort int, withSchema bool) []datasource.ConfigField {
+	fields := []datasource.ConfigField{
+		{Name: "urlType", Label: "Connection mode", Type: datasource.FieldTypeString, Default: "hostName", Options: []string{"hostName", "jdbcUrl"}},
+		{Name: "host", Label: "Host", Type: datasource.FieldTypeString, Required: true, RequiredUnless: "jdbcUrl"},
+		{Name: "port", Label: "Port", Type: datasource.FieldTypeInt, Required: true, RequiredUnless: "jdbcUrl", Default: defaultPort, Min: bound(1), Max: bound(65535)},
+		{Name: "dataBase", Label: "Database", Type: datasource.FieldTypeString, Required: true, RequiredUnless: "jdbcUrl"},
+		{Name: "username", Label: "Username", Type: datasource.FieldTypeString},
+		{Name: "password", Label: "Password", Type: datasource.FieldTypePassword, Secret: true},
+	}
+	if withSchema {
+		fields = append(fields, datasource.ConfigField{Name: "schema", Label: "Schema", Type: datasource.FieldTypeString})
+	}
+	fields = append(fields,
+		datasource.ConfigField{Name: "jdbcUrl", Label: "JDBC URL", Type: datasource.FieldTypeString},
+		datasource.ConfigField{Name: "extraParams", Label: "Extra parameters", Type: datasource.FieldTypeString},
+		datasource.ConfigField{Name: "initialPoolSize", Label: "Initial pool size", Type: datasource.FieldTypeInt, Default: 50, Min: bound(1), Max: bound(1000)},
+		datasource.ConfigField{Name: "minPoolSize", Label: "Min pool size", Type: datasource.FieldTypeInt, Default: 50, Min: bound(1), Max: bound(1000)},
+		datasource.ConfigField{Name: "maxPoolSize", Label: "Max pool size", Type: datasource.FieldTypeInt, Default: 100, Min: bound(1), Max: bound(1000)},
+		datasource.ConfigField{Name: "queryTimeout", Label: "Query timeout (s)", Type: datasource.FieldTypeInt, Default: 30, Min: bound(0), Max: bound(86400)},
+	)
+	return append(fields, sshSchema()...)
+}
+
+// sshSchema lists the SSH tunnel options shared by the network providers.
+// They are only checked when the tunnel is enabled.
+func sshSchema() []datasource.ConfigField {
+	return []datasource.ConfigField{
+		{Name: "useSSH", Label: "Use SSH tunnel", Type: datasource.FieldTypeBool, Default: false},
+		{Name: "sshHost", Label: "SSH host", Type: datasource.FieldTypeString, Required: true, DependsOn: "useSSH"},
+		{Name: "sshPort", Label: "SSH port", Type: datasource.FieldTypeInt, Default: sshDefaultPort, Min: bound(1), Max: bound(65535), DependsOn: "useSSH"},
+		{Name: "sshUserName", Label: "SSH user", Type: datasource.FieldTypeString, Required: true, DependsOn: "useSSH"},
+		{Name: "sshType", Label: "SSH auth type", Type: datasource.FieldTypeString, Default: datasource.SSHTypePassword,
+			Options: []string{datasource.SSHTypePassword, datasource.SSHTypeKey}, DependsOn: "useSSH"},
+		{Name: "sshPassword", Label: "SSH password", Type: datasource.FieldTypePassword, Secret: true, DependsOn: "useSSH"},
+		{Name: "sshKey", Label: "SSH private key", Type: datasource.FieldTypeText, Secret: true, DependsOn: "useSSH"},
+		{Name: "sshKeyPassword", Label: "SSH key passphrase", Type: datasource.FieldTypePassword, Secret: true, DependsOn: "useSSH"},
+		{Name: "sshHostKeyPolicy", Label: "SSH host key policy", Type: datasource.FieldTypeString, Default: datasource.SSHHostKeyStrict,
+			Options: []string{datasource.SSHHostKeyStrict, datasource.SSHHostKeyInsecure}, DependsOn: "useSSH"},
+		{Name: "sshKnownHosts", Label: "SSH known hosts", Type: datasource.FieldTypeText, DependsOn: "useSSH"},
+	}
+}
+
+func bound(n int64) *int64 {
+	return &n
+}
@@ -0,0 +1,53 @@
+package dsconn
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteProvider opens a database file on the server in read-only mode. It
+// only differs from sqlProvider in the argument order of pragma_table_info.
+type sqliteProvider struct {
+	*sqlProvider
+}
+
+func newSQLiteProvider() Provider {
+	return &sqliteProvider{sqlProvider: &sqlProvider{
+		typ:     "sqlite",
+		name:    "SQLite",
+		aliases: []string{"sqlite3"},
+		schema: []datasource.ConfigField{
+			{Name: "dataBase", Label: "Database file", Type: datasource.FieldTypeString, Required: true},
+		},
+		driverName:  "sqlite",
+		dsn:         sqliteDSN,
+		quoteOpen:   `"`,
+		quoteClose:  `"`,
+		columnTypes: [5]string{"TEXT", "TEXT", "INTEGER", "REAL", "INTEGER"},
+		namespace:   func(*datasource.ConnectionConfig) string { return "main" },
+		schemaSQL:   "SELECT COUNT(1) FROM pragma_database_list WHERE name = ?",
+		tablesSQL: "SELECT name, '' FROM pragma_table_list WHERE schema = ? " +
+			"AND type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name",
+		columnsSQL: "SELECT name, type FROM pragma_table_info(?, ?) ORDER BY cid",
+	}}
+}
+
+func (p *sqliteProvider) ColumnsQuery(cfg *datasource.ConnectionConfig, table string) (string, []interface{}) {
+	return p.columnsSQL, []interface{}{table, p.Namespace(cfg)}
+}
+
+func sqliteDSN(cfg *datasource.ConnectionConfig) (string, error) {
+	path := strings.TrimSpace(cfg.Database)
+	if path == "" {
+		path = strings.TrimPrefix(strings.TrimSpace(cfg.JDBCUrl), "jdbc:sqlite:")
+	}
+	if path == "" {
+		return "", fmt.Errorf("missing database file in datasource configuration")
+	}
+	return "file:" + path + "?mode=ro&_pragma=" + url.QueryEscape("busy_timeout(10000)"), nil
+}
@@ -0,0 +1,66 @@
+package dsconn
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"dataease/backend/internal/domain/datasource"
+
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+func newSQLServerProvider() Provider {
+	return &sqlProvider{
+		typ:          "sqlServer",
+		name:         "SQL Server",
+		aliases:      []string{"mssql"},
+		schema:       networkSchema(1433, true),
+		schemaScoped: true,
+		driverName:   "sqlserver",
+		dsn:          sqlServerDSN,
+		quoteOpen:    "[",
+		quoteClose:   "]",
+		columnTypes:  [5]string{"NVARCHAR(MAX)", "DATETIME2", "BIGINT", "FLOAT", "BIT"},
+		placeholder:  func(index int) string { return "@p" + strconv.Itoa(index) },
+		limit: func(query string, ordered bool) string {
+			if !ordered {
+				query += " ORDER BY (SELECT NULL)"
+			}
+			return query + " OFFSET 0 ROWS FETCH NEXT ? ROWS ONLY"
+		},
+		deTypes: map[string]int{
+			"bit":        4,
+			"money":      3,
+			"smallmoney": 3,
+		},
+		defaultSchema: "dbo",
+		qualified:     true,
+		schemaSQL:     "SELECT COUNT(1) FROM sys.schemas WHERE name = ?",
+		tablesSQL: "SELECT TABLE_NAME, '' FROM INFORMATION_SCHEMA.TABLES " +
+			"WHERE TABLE_SCHEMA = ? AND TABLE_TYPE IN ('BASE TABLE', 'VIEW') ORDER BY TABLE_NAME",
+		columnsSQL: "SELECT COLUMN_NAME, DATA_TYPE FROM INFORMATION_SCHEMA.COLUMNS " +
+			"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+	}
+}
+
+func sqlServerDSN(cfg *datasource.ConnectionConfig) (string, error) {
+	host, port := cfg.HostPort()
+	if host == "" || port <= 0 {
+		return "", fmt.Errorf("missing host/port in datasource configuration")
+	}
+
+	u := url.URL{
+		Scheme: "sqlserver",
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	query := url.Values{}
+	query.Set("database", cfg.DatabaseName())
+	query.Set("dial timeout", "10")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
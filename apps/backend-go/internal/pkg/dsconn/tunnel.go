@@ -0,0 +1,231 @@
+package dsconn
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	sshDefaultPort      = 22
+	sshDialTimeout      = 10 * time.Second
+	sshKeepAlive        = 30 * time.Second
+	sshKeepAliveTimeout = 15 * time.Second
+)
+
+// tunnel forwards the connections accepted on a loopback listener to the
+// database through an SSH client connected to the bastion host. The client
+// sends keepalives and done is closed once its connection is lost.
+type tunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	target   string
+	done     chan struct{}
+}
+
+// openTunnel connects to the bastion host of cfg and starts forwarding a
+// local port to the database host.
+func openTunnel(cfg *datasource.ConnectionConfig) (*tunnel, error) {
+	host, port := cfg.HostPort()
+	if host == "" || port <= 0 {
+		return nil, fmt.Errorf("missing host/port in datasource configuration")
+	}
+	clientConfig, err := sshClientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sshPort := cfg.SSHPort
+	if sshPort <= 0 {
+		sshPort = sshDefaultPort
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(strings.TrimSpace(cfg.SSHHost), strconv.Itoa(sshPort)), clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	t := &tunnel{client: client, listener: listener, target: net.JoinHostPort(host, strconv.Itoa(port)), done: make(chan struct{})}
+	go t.serve()
+	go t.watch()
+	go t.keepAlive(sshKeepAlive)
+	return t, nil
+}
+
+// configure returns a copy of cfg that points the driver at the local end
+// of the tunnel.
+func (t *tunnel) configure(cfg *datasource.ConnectionConfig) *datasource.ConnectionConfig {
+	addr := t.listener.Addr().(*net.TCPAddr)
+	local := *cfg
+	local.Host = addr.IP.String()
+	local.Port = addr.Port
+	return &local
+}
+
+func (t *tunnel) Close() error {
+	_ = t.listener.Close()
+	return t.client.Close()
+}
+
+// watch stops accepting connections once the SSH connection is closed, by
+// either end or a failed keepalive.
+func (t *tunnel) watch() {
+	_ = t.client.Wait()
+	_ = t.listener.Close()
+	close(t.done)
+}
+
+// keepAlive closes the SSH connection when the bastion stops answering,
+// which a silently dropped connection would not report otherwise.
+func (t *tunnel) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+		if !t.ping(sshKeepAliveTimeout) {
+			_ = t.client.Close()
+			return
+		}
+	}
+}
+
+// ping sends a keepalive request. Any reply, even a refusal, proves the
+// bastion is alive.
+func (t *tunnel) ping(timeout time.Duration) bool {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := t.client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-reply:
+		return err == nil
+	case <-timer.C:
+		return false
+	}
+}
+
+func (t *tunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.forward(local)
+	}
+}
+
+func (t *tunnel) forward(local net.Conn) {
+	remote, err := t.client.Dial("tcp", t.target)
+	if err != nil {
+		_ = local.Close()
+		return
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+	_ = local.Close()
+	_ = remote.Close()
+}
+
+func sshClientConfig(cfg *datasource.ConnectionConfig) (*ssh.ClientConfig, error) {
+	if strings.TrimSpace(cfg.SSHHost) == "" {
+		return nil, fmt.Errorf("ssh host is required")
+	}
+	if strings.TrimSpace(cfg.SSHUserName) == "" {
+		return nil, fmt.Errorf("ssh user is required")
+	}
+
+	var auth ssh.AuthMethod
+	switch cfg.SSHType {
+	case "", datasource.SSHTypePassword:
+		auth = ssh.Password(cfg.SSHPassword)
+	case datasource.SSHTypeKey:
+		if strings.TrimSpace(cfg.SSHKey) == "" {
+			return nil, fmt.Errorf("ssh private key is required")
+		}
+		var signer ssh.Signer
+		var err error
+		if cfg.SSHKeyPassword != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(cfg.SSHKey), []byte(cfg.SSHKeyPassword))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(cfg.SSHKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh private key: %w", err)
+		}
+		auth = ssh.PublicKeys(signer)
+	default:
+		return nil, fmt.Errorf("unsupported ssh auth type: %s", cfg.SSHType)
+	}
+
+	hostKeyCallback, err := sshHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            cfg.SSHUserName,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, nil
+}
+
+// sshHostKeyCallback checks the bastion host key against the known_hosts
+// lines of the configuration unless the policy is insecure.
+func sshHostKeyCallback(cfg *datasource.ConnectionConfig) (ssh.HostKeyCallback, error) {
+	switch cfg.SSHHostKeyPolicy {
+	case datasource.SSHHostKeyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case "", datasource.SSHHostKeyStrict:
+	default:
+		return nil, fmt.Errorf("unsupported ssh host key policy: %s", cfg.SSHHostKeyPolicy)
+	}
+	if strings.TrimSpace(cfg.SSHKnownHosts) == "" {
+		return nil, fmt.Errorf("ssh known hosts are required by the strict host key policy")
+	}
+
+	// knownhosts only reads files; it loads them fully in New.
+	file, err := os.CreateTemp("", "dsconn-known-hosts-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(cfg.SSHKnownHosts + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh known hosts: %w", err)
+	}
+	return callback, nil
+}
@@ -0,0 +1,243 @@
+package dsconn
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startEchoServer returns the address of a TCP server echoing every line.
+func startEchoServer(t *testing.T) (string, int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// startSSHServer runs a bastion accepting user/password and forwarding
+// direct-tcpip channels. It returns its address, host key and a function
+// dropping every connection accepted so far.
+func startSSHServer(t *testing.T) (string, int, ssh.PublicKey, func()) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "jump" && string(password) == "pw" {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go serveSSH(conn, config)
+		}
+	}()
+	drop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		conns = nil
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, signer.PublicKey(), drop
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err = ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		remote, dialErr := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if dialErr != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, dialErr.Error())
+			continue
+		}
+		channel, channelReqs, acceptErr := newChannel.Accept()
+		if acceptErr != nil {
+			_ = remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelReqs)
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+			go func() { _, _ = io.Copy(remote, channel) }()
+			_, _ = io.Copy(channel, remote)
+		}()
+	}
+}
+
+func TestTunnel_ForwardsThroughBastion(t *testing.T) {
+	dbHost, dbPort := startEchoServer(t)
+	sshHost, sshPort, hostKey, _ := startSSHServer(t)
+	knownHosts := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(sshHost, strconv.Itoa(sshPort)))}, hostKey)
+
+	cfg := &datasource.ConnectionConfig{
+		Host: dbHost, Port: dbPort,
+		UseSSH: true, SSHHost: sshHost, SSHPort: sshPort, SSHUserName: "jump", SSHPassword: "pw",
+		SSHKnownHosts: knownHosts,
+	}
+	tun, err := openTunnel(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tun.Close()
+
+	local := tun.configure(cfg)
+	if local.Host != "127.0.0.1" || local.Port == dbPort || cfg.Port != dbPort {
+		t.Fatalf("unexpected tunneled config %+v", local)
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(local.Host, strconv.Itoa(local.Port)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("expected echo through the tunnel, got %q (%v)", line, err)
+	}
+}
+
+func TestTunnel_ClassifiesFailures(t *testing.T) {
+	dbHost, dbPort := startEchoServer(t)
+	sshHost, sshPort, hostKey, _ := startSSHServer(t)
+	addr := knownhosts.Normalize(net.JoinHostPort(sshHost, strconv.Itoa(sshPort)))
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherSigner, _ := ssh.NewSignerFromKey(otherKey)
+
+	base := datasource.ConnectionConfig{
+		Host: dbHost, Port: dbPort, Database: "demo",
+		UseSSH: true, SSHHost: sshHost, SSHPort: sshPort, SSHUserName: "jump", SSHPassword: "pw",
+		SSHKnownHosts: knownhosts.Line([]string{addr}, hostKey),
+	}
+	cases := []struct {
+		name   string
+		mutate func(cfg *datasource.ConnectionConfig)
+		want   string
+	}{
+		{"wrong password", func(cfg *datasource.ConnectionConfig) { cfg.SSHPassword = "bad" }, datasource.ErrorTypeAuth},
+		{"changed host key", func(cfg *datasource.ConnectionConfig) {
+			cfg.SSHKnownHosts = knownhosts.Line([]string{addr}, otherSigner.PublicKey())
+		}, datasource.ErrorTypeHostKey},
+		{"missing known hosts", func(cfg *datasource.ConnectionConfig) { cfg.SSHKnownHosts = "" }, datasource.ErrorTypeConfig},
+	}
+	for _, tc := range cases {
+		cfg := base
+		tc.mutate(&cfg)
+		err := Probe(context.Background(), "mysql", &cfg)
+		probeErr, ok := err.(*ProbeError)
+		if !ok || probeErr.Category != tc.want {
+			t.Fatalf("%s: expected %s, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	insecure := base
+	insecure.SSHKnownHosts = ""
+	insecure.SSHHostKeyPolicy = datasource.SSHHostKeyInsecure
+	tun, err := openTunnel(&insecure)
+	if err != nil {
+		t.Fatalf("expected insecure policy to accept any host key: %v", err)
+	}
+	_ = tun.Close()
+}
+
+func TestTunnel_ClosesWhenTheBastionDrops(t *testing.T) {
+	dbHost, dbPort := startEchoServer(t)
+	sshHost, sshPort, _, drop := startSSHServer(t)
+	tun, err := openTunnel(&datasource.ConnectionConfig{
+		Host: dbHost, Port: dbPort,
+		UseSSH: true, SSHHost: sshHost, SSHPort: sshPort, SSHUserName: "jump", SSHPassword: "pw",
+		SSHHostKeyPolicy: datasource.SSHHostKeyInsecure,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tun.Close()
+
+	if !tun.ping(time.Second) {
+		t.Fatal("expected the bastion to answer keepalives")
+	}
+	drop()
+	select {
+	case <-tun.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the tunnel to close with its SSH connection")
+	}
+	if tun.ping(time.Second) {
+		t.Fatal("expected keepalives to fail once the bastion dropped")
+	}
+}
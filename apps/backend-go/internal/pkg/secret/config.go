@@ -0,0 +1,218 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Mask replaces secret values in configurations returned to clients. An
+// update that sends it back keeps the stored secret.
+const Mask = "******"
+
+var secretFields = map[string]struct{}{
+	"password":       {},
+	"passwd":         {},
+	"token":          {},
+	"keyvalue":       {},
+	"secretkey":      {},
+	"privatekey":     {},
+	"passphrase":     {},
+	"sshpassword":    {},
+	"sshkey":         {},
+	"sshkeypassword": {},
+}
+
+// IsSecretField reports whether a configuration member holds a secret.
+func IsSecretField(name string) bool {
+	_, ok := secretFields[strings.ToLower(name)]
+	return ok
+}
+
+// SealConfig encrypts the secret fields of a datasource configuration.
+// Secrets that are missing or masked are taken from previous, the stored
+// configuration, so clients never need to send them back; an empty secret
+// clears the stored one. Objects inside arrays are matched with previous by
+// their name member, or by index.
+func (k *Keyring) SealConfig(raw string, previous string) (string, error) {
+	doc, encoded, ok := parseConfig(raw)
+	if !ok {
+		return raw, nil
+	}
+	prev, _, _ := parseConfig(previous)
+
+	var sealErr error
+	doc = rewrite(doc, prev, func(value interface{}, stored interface{}) interface{} {
+		text, isText := value.(string)
+		if value == nil || (isText && text == Mask) {
+			return stored
+		}
+		if !isText || text == "" {
+			return value
+		}
+		sealed, err := k.Encrypt(text)
+		if err != nil && sealErr == nil {
+			sealErr = err
+		}
+		return sealed
+	})
+	if sealErr != nil {
+		return "", sealErr
+	}
+	return formatConfig(doc, encoded)
+}
+
+// OpenConfig decrypts every sealed value of a datasource configuration.
+func (k *Keyring) OpenConfig(raw string) (string, error) {
+	doc, encoded, ok := parseConfig(raw)
+	if !ok {
+		return raw, nil
+	}
+
+	var openErr error
+	doc = rewriteStrings(doc, func(text string) string {
+		plain, err := k.Decrypt(text)
+		if err != nil && openErr == nil {
+			openErr = err
+		}
+		return plain
+	})
+	if openErr != nil {
+		return "", openErr
+	}
+	return formatConfig(doc, encoded)
+}
+
+// RedactConfig replaces the non-empty secret fields of a configuration with
+// Mask. Configurations that are not JSON are returned unchanged.
+func RedactConfig(raw string) string {
+	doc, encoded, ok := parseConfig(raw)
+	if !ok {
+		return raw
+	}
+	doc = rewrite(doc, nil, func(value interface{}, _ interface{}) interface{} {
+		if text, isText := value.(string); isText && text != "" {
+			return Mask
+		}
+		return value
+	})
+	redacted, err := formatConfig(doc, encoded)
+	if err != nil {
+		return raw
+	}
+	return redacted
+}
+
+// rewrite replaces the secret members of doc with the result of visit, which
+// receives the member value (nil when absent) and the matching member of
+// previous.
+func rewrite(node interface{}, previous interface{}, visit func(value interface{}, stored interface{}) interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		prevObj, _ := previous.(map[string]interface{})
+		for key, child := range v {
+			if IsSecretField(key) {
+				v[key] = visit(child, prevObj[key])
+				continue
+			}
+			v[key] = rewrite(child, prevObj[key], visit)
+		}
+		for key, stored := range prevObj {
+			if _, ok := v[key]; ok || !IsSecretField(key) {
+				continue
+			}
+			if value := visit(nil, stored); value != nil {
+				v[key] = value
+			}
+		}
+		return v
+	case []interface{}:
+		prevList, _ := previous.([]interface{})
+		for i, child := range v {
+			v[i] = rewrite(child, matchElement(prevList, child, i), visit)
+		}
+		return v
+	default:
+		return node
+	}
+}
+
+func rewriteStrings(node interface{}, fn func(string) string) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = rewriteStrings(child, fn)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = rewriteStrings(child, fn)
+		}
+		return v
+	case string:
+		return fn(v)
+	default:
+		return node
+	}
+}
+
+func matchElement(previous []interface{}, element interface{}, index int) interface{} {
+	if obj, ok := element.(map[string]interface{}); ok {
+		if name, ok := obj["name"].(string); ok && name != "" {
+			for _, candidate := range previous {
+				if prevObj, ok := candidate.(map[string]interface{}); ok && prevObj["name"] == name {
+					return candidate
+				}
+			}
+			return nil
+		}
+	}
+	if index < len(previous) {
+		return previous[index]
+	}
+	return nil
+}
+
+// parseConfig decodes a configuration stored either as base64 encoded JSON
+// (Java compatible) or as raw JSON, reporting which form it had.
+func parseConfig(raw string) (interface{}, bool, bool) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, false, false
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+		if doc, ok := decodeJSON(decoded); ok {
+			return doc, true, true
+		}
+	}
+	doc, ok := decodeJSON([]byte(raw))
+	return doc, false, ok
+}
+
+func decodeJSON(data []byte) (interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	switch doc.(type) {
+	case map[string]interface{}, []interface{}:
+		return doc, true
+	}
+	return nil, false
+}
+
+func formatConfig(doc interface{}, encoded bool) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return "", err
+	}
+	text := bytes.TrimRight(buf.Bytes(), "\n")
+	if encoded {
+		return base64.StdEncoding.EncodeToString(text), nil
+	}
+	return string(text), nil
+}
@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"regexp"
+	"strconv"
+)
+
+var placeholderPattern = regexp.MustCompile(`^\{\{secret:([^{}]+)\}\}$`)
+
+// Placeholder is the portable form of a secret in exported configurations.
+// Its key is the value to send back to resolve it on import.
+func Placeholder(key string) string {
+	return "{{secret:" + key + "}}"
+}
+
+// PlaceholderConfig replaces the non-empty secret fields of a configuration
+// with placeholders keyed by prefix and the path of the field, such as
+// "12.password" or "12.apis.0.token". Configurations that are not JSON are
+// returned unchanged.
+func PlaceholderConfig(raw string, prefix string) string {
+	doc, encoded, ok := parseConfig(raw)
+	if !ok {
+		return raw
+	}
+	doc = placeholders(doc, prefix)
+	replaced, err := formatConfig(doc, encoded)
+	if err != nil {
+		return raw
+	}
+	return replaced
+}
+
+// ResolvePlaceholders replaces the placeholders of a configuration with the
+// given secrets. Placeholders without a secret are replaced with Mask, so
+// sealing keeps the stored secret, and their keys are returned.
+func ResolvePlaceholders(raw string, secrets map[string]string) (string, []string) {
+	doc, encoded, ok := parseConfig(raw)
+	if !ok {
+		return raw, nil
+	}
+	var missing []string
+	doc = rewriteStrings(doc, func(text string) string {
+		match := placeholderPattern.FindStringSubmatch(text)
+		if match == nil {
+			return text
+		}
+		if value, found := secrets[match[1]]; found {
+			return value
+		}
+		missing = append(missing, match[1])
+		return Mask
+	})
+	resolved, err := formatConfig(doc, encoded)
+	if err != nil {
+		return raw, missing
+	}
+	return resolved, missing
+}
+
+func placeholders(node interface{}, path string) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if text, isText := child.(string); isText && text != "" && IsSecretField(key) {
+				v[key] = Placeholder(path + "." + key)
+				continue
+			}
+			v[key] = placeholders(child, path+"."+key)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = placeholders(child, path+"."+strconv.Itoa(i))
+		}
+		return v
+	default:
+		return node
+	}
+}
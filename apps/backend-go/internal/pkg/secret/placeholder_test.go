@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholderConfig_RoundTrip(t *testing.T) {
+	raw := `{"host":"db","password":"enc:v1:abc","apis":[{"name":"a","token":"t"}],"sshKey":""}`
+	exported := PlaceholderConfig(raw, "12")
+	for _, want := range []string{`"password":"{{secret:12.password}}"`, `"token":"{{secret:12.apis.0.token}}"`, `"sshKey":""`, `"host":"db"`} {
+		if !strings.Contains(exported, want) {
+			t.Fatalf("expected %s in %s", want, exported)
+		}
+	}
+	if strings.Contains(exported, "enc:v1:abc") {
+		t.Fatalf("expected the secret to be removed: %s", exported)
+	}
+
+	resolved, missing := ResolvePlaceholders(exported, map[string]string{"12.password": "s3cret"})
+	if !strings.Contains(resolved, `"password":"s3cret"`) || !strings.Contains(resolved, `"token":"******"`) {
+		t.Fatalf("unexpected resolved configuration %s", resolved)
+	}
+	if len(missing) != 1 || missing[0] != "12.apis.0.token" {
+		t.Fatalf("expected the token to be missing, got %v", missing)
+	}
+}
+
+func TestPlaceholderConfig_KeepsEncoding(t *testing.T) {
+	raw := base64.StdEncoding.EncodeToString([]byte(`{"password":"x"}`))
+	exported := PlaceholderConfig(raw, "3")
+	decoded, err := base64.StdEncoding.DecodeString(exported)
+	if err != nil || string(decoded) != `{"password":"{{secret:3.password}}"}` {
+		t.Fatalf("unexpected exported configuration %q (%v)", decoded, err)
+	}
+	if PlaceholderConfig("not json", "3") != "not json" {
+		t.Fatal("expected non JSON configurations to be unchanged")
+	}
+}
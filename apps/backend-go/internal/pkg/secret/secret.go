@@ -0,0 +1,131 @@
+// Package secret encrypts the secret fields of datasource configurations at
+// rest. Every value is sealed with its own data key, which is itself sealed
+// with the key encryption key of the Keyring (envelope encryption).
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	prefix     = "enc:v1:"
+	dataKeyLen = 32
+)
+
+// Keyring seals and opens secret values. A nil Keyring leaves values in
+// plain text and can only open values that were never sealed.
+type Keyring struct {
+	kek cipher.AEAD
+}
+
+// NewKeyring derives the key encryption key from key, which is either the
+// aes_key of core_rsa or a key set in the configuration.
+func NewKeyring(key string) (*Keyring, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, fmt.Errorf("secret key is required")
+	}
+	sum := sha256.Sum256([]byte(key))
+	kek, err := newAEAD(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{kek: kek}, nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt seals value under a fresh data key. Empty and already sealed
+// values are returned unchanged.
+func (k *Keyring) Encrypt(value string) (string, error) {
+	if k == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.kek, dataKey)
+	if err != nil {
+		return "", err
+	}
+	payload, err := seal(dek, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return prefix + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt opens a value sealed by Encrypt. Values that are not sealed are
+// returned unchanged so configurations written before encryption keep
+// working.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", fmt.Errorf("secret key is not configured")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed secret value")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed secret value")
+	}
+	payload, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed secret value")
+	}
+	dataKey, err := open(k.kek, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(dek, payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,121 @@
+package secret
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring("0123456789abcdef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sealed, err := k.Encrypt("s3cret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "s3cret") {
+		t.Fatalf("expected sealed value, got %q", sealed)
+	}
+	again, _ := k.Encrypt("s3cret")
+	if again == sealed {
+		t.Fatal("expected a fresh data key for every value")
+	}
+	if plain, err := k.Decrypt(sealed); err != nil || plain != "s3cret" {
+		t.Fatalf("expected round trip, got %q (%v)", plain, err)
+	}
+	if plain, err := k.Decrypt("legacy"); err != nil || plain != "legacy" {
+		t.Fatalf("expected plain values to pass through, got %q (%v)", plain, err)
+	}
+
+	other, _ := NewKeyring("another key")
+	if _, err = other.Decrypt(sealed); err == nil {
+		t.Fatal("expected a different key to fail")
+	}
+	var none *Keyring
+	if _, err = none.Decrypt(sealed); err == nil {
+		t.Fatal("expected a missing key to fail")
+	}
+	if _, err = NewKeyring(" "); err == nil {
+		t.Fatal("expected an empty key to be rejected")
+	}
+}
+
+func TestKeyring_SealConfig(t *testing.T) {
+	k, _ := NewKeyring("0123456789abcdef")
+	raw := base64.StdEncoding.EncodeToString([]byte(`{"host":"db","port":3306,"username":"root","password":"pw"}`))
+	sealed, err := k.SealConfig(raw, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatalf("expected base64 configuration to stay encoded: %v", err)
+	}
+	if strings.Contains(string(decoded), `"pw"`) || !strings.Contains(string(decoded), `"port":3306`) {
+		t.Fatalf("unexpected sealed configuration %s", decoded)
+	}
+
+	for _, update := range []string{
+		`{"host":"db2","port":3306,"username":"root"}`,
+		`{"host":"db2","port":3306,"username":"root","password":"******"}`,
+	} {
+		next, err := k.SealConfig(update, sealed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		opened, err := k.OpenConfig(next)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(opened, `"password":"pw"`) || !strings.Contains(opened, `"host":"db2"`) {
+			t.Fatalf("expected stored password to be kept for %s, got %s", update, opened)
+		}
+	}
+
+	cleared, err := k.SealConfig(`{"host":"db2","password":""}`, sealed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opened, _ := k.OpenConfig(cleared); !strings.Contains(opened, `"password":""`) {
+		t.Fatalf("expected an empty password to clear the stored one, got %s", opened)
+	}
+
+	changed, _ := k.SealConfig(`{"password":"new"}`, sealed)
+	if opened, _ := k.OpenConfig(changed); !strings.Contains(opened, `"password":"new"`) {
+		t.Fatalf("expected new password, got %s", opened)
+	}
+}
+
+func TestKeyring_SealConfigMatchesArraysByName(t *testing.T) {
+	k, _ := NewKeyring("0123456789abcdef")
+	stored, err := k.SealConfig(`[{"name":"a","auth":{"type":"bearer","token":"ta"}},{"name":"b","auth":{"type":"bearer","token":"tb"}}]`, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	next, err := k.SealConfig(`[{"name":"b","auth":{"type":"bearer","token":"******"}},{"name":"c","auth":{"type":"bearer"}}]`, stored)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opened, _ := k.OpenConfig(next)
+	if !strings.Contains(opened, `"token":"tb"`) || strings.Contains(opened, `"ta"`) {
+		t.Fatalf("expected secrets to follow table names, got %s", opened)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	raw := `{"host":"db","password":"pw","ssh":{"privateKey":"key"},"tables":[{"auth":{"keyValue":"k","keyName":"X-Key"}}]}`
+	redacted := RedactConfig(raw)
+	for _, leaked := range []string{`"pw"`, `"key"`, `"k"`} {
+		if strings.Contains(redacted, leaked) {
+			t.Fatalf("expected %s to be redacted, got %s", leaked, redacted)
+		}
+	}
+	if !strings.Contains(redacted, `"keyName":"X-Key"`) || !strings.Contains(redacted, `"password":"******"`) {
+		t.Fatalf("unexpected redaction %s", redacted)
+	}
+	if RedactConfig("not json") != "not json" {
+		t.Fatal("expected non JSON configuration to be returned unchanged")
+	}
+}
@@ -0,0 +1,359 @@
+package sqlparse
+
+// Node is any element of the syntax tree.
+type Node interface {
+	children() []Node
+}
+
+// Query is a SELECT, a set operation or a query with a WITH clause.
+type Query interface {
+	Node
+	query()
+}
+
+// Expr is a scalar expression.
+type Expr interface {
+	Node
+	expr()
+}
+
+// TableExpr is an element of a FROM clause.
+type TableExpr interface {
+	Node
+	tableExpr()
+}
+
+// With is a query preceded by common table expressions.
+type With struct {
+	Recursive bool
+	CTEs      []*CTE
+	Body      Query
+}
+
+type CTE struct {
+	Name    string
+	Columns []string
+	Query   Query
+}
+
+// Select is a single SELECT block. Top is the TOP clause of SQL Server and
+// Limit holds the LIMIT, OFFSET and FETCH counts.
+type Select struct {
+	Distinct   bool
+	DistinctOn []Expr
+	Top        Expr
+	Items      []*SelectItem
+	From       []TableExpr
+	Where      Expr
+	GroupBy    []Expr
+	Having     Expr
+	Windows    []*Window
+	OrderBy    []*OrderItem
+	Limit      []Expr
+}
+
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+}
+
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// SetOp combines two queries with UNION, EXCEPT or INTERSECT.
+type SetOp struct {
+	Op    string
+	All   bool
+	Left  Query
+	Right Query
+}
+
+// Ordered is a parenthesized query or set operation with its own ORDER BY
+// or LIMIT.
+type Ordered struct {
+	Query   Query
+	OrderBy []*OrderItem
+	Limit   []Expr
+}
+
+// TableName is a table reference; Parts holds the qualifiers followed by
+// the name.
+type TableName struct {
+	Parts []string
+	Alias string
+}
+
+// Name returns the unqualified table name.
+func (t *TableName) Name() string {
+	return t.Parts[len(t.Parts)-1]
+}
+
+// DerivedTable is a subquery in a FROM clause.
+type DerivedTable struct {
+	Query Query
+	Alias string
+}
+
+// TableFunc is a function returning rows, such as generate_series.
+type TableFunc struct {
+	Call  *FuncCall
+	Alias string
+}
+
+// Join joins two table expressions; Kind is the join keyword, such as
+// "LEFT JOIN".
+type Join struct {
+	Kind  string
+	Left  TableExpr
+	Right TableExpr
+	On    Expr
+	Using []string
+}
+
+type Literal struct {
+	Value string
+}
+
+type ColumnRef struct {
+	Parts []string
+}
+
+// Star is * or table.*.
+type Star struct {
+	Table []string
+}
+
+// Param is a ? placeholder.
+type Param struct{}
+
+type Variable struct {
+	Name string
+}
+
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+type Binary struct {
+	Op string
+	L  Expr
+	R  Expr
+}
+
+// FuncCall is a function call. Name holds the qualifiers of the function
+// followed by its name.
+type FuncCall struct {
+	Name     []string
+	Distinct bool
+	Args     []Expr
+	OrderBy  []*OrderItem
+	Filter   Expr
+	Over     *Window
+}
+
+// Window is a window specification; Frame holds the offsets of its frame
+// bounds.
+type Window struct {
+	Name        string
+	PartitionBy []Expr
+	OrderBy     []*OrderItem
+	Frame       []Expr
+}
+
+// Subquery is a query used as an expression, or the query of EXISTS.
+type Subquery struct {
+	Query  Query
+	Exists bool
+}
+
+type Case struct {
+	Operand Expr
+	Whens   []*When
+	Else    Expr
+}
+
+type When struct {
+	Cond   Expr
+	Result Expr
+}
+
+// Cast is CAST(x AS type), CONVERT(x, type) or x::type.
+type Cast struct {
+	X    Expr
+	Type string
+}
+
+type Between struct {
+	X   Expr
+	Not bool
+	Lo  Expr
+	Hi  Expr
+}
+
+// In tests X against a list or a subquery.
+type In struct {
+	X     Expr
+	Not   bool
+	List  []Expr
+	Query Query
+}
+
+// Is is X IS [NOT] NULL, TRUE, FALSE or UNKNOWN.
+type Is struct {
+	X    Expr
+	Not  bool
+	What string
+}
+
+type Interval struct {
+	X    Expr
+	Unit string
+}
+
+type Tuple struct {
+	Items []Expr
+}
+
+func (*With) query()    {}
+func (*Select) query()  {}
+func (*SetOp) query()   {}
+func (*Ordered) query() {}
+
+func (*TableName) tableExpr()    {}
+func (*DerivedTable) tableExpr() {}
+func (*TableFunc) tableExpr()    {}
+func (*Join) tableExpr()         {}
+
+func (*Literal) expr()   {}
+func (*ColumnRef) expr() {}
+func (*Star) expr()      {}
+func (*Param) expr()     {}
+func (*Variable) expr()  {}
+func (*Unary) expr()     {}
+func (*Binary) expr()    {}
+func (*FuncCall) expr()  {}
+func (*Subquery) expr()  {}
+func (*Case) expr()      {}
+func (*Cast) expr()      {}
+func (*Between) expr()   {}
+func (*In) expr()        {}
+func (*Is) expr()        {}
+func (*Interval) expr()  {}
+func (*Tuple) expr()     {}
+
+func (n *With) children() []Node {
+	nodes := make([]Node, 0, len(n.CTEs)+1)
+	for _, cte := range n.CTEs {
+		nodes = append(nodes, cte.Query)
+	}
+	return append(nodes, n.Body)
+}
+
+func (n *Select) children() []Node {
+	nodes := appendExprs(nil, n.DistinctOn...)
+	nodes = appendExprs(nodes, n.Top)
+	for _, item := range n.Items {
+		nodes = append(nodes, item.Expr)
+	}
+	for _, table := range n.From {
+		nodes = append(nodes, table)
+	}
+	nodes = appendExprs(nodes, n.Where)
+	nodes = appendExprs(nodes, n.GroupBy...)
+	nodes = appendExprs(nodes, n.Having)
+	for _, window := range n.Windows {
+		nodes = append(nodes, window.children()...)
+	}
+	nodes = appendOrder(nodes, n.OrderBy)
+	return appendExprs(nodes, n.Limit...)
+}
+
+func (n *SetOp) children() []Node { return []Node{n.Left, n.Right} }
+
+func (n *Ordered) children() []Node {
+	nodes := appendOrder([]Node{n.Query}, n.OrderBy)
+	return appendExprs(nodes, n.Limit...)
+}
+
+func (n *TableName) children() []Node    { return nil }
+func (n *DerivedTable) children() []Node { return []Node{n.Query} }
+func (n *TableFunc) children() []Node    { return []Node{n.Call} }
+func (n *Join) children() []Node         { return appendExprs([]Node{n.Left, n.Right}, n.On) }
+
+func (n *Literal) children() []Node   { return nil }
+func (n *ColumnRef) children() []Node { return nil }
+func (n *Star) children() []Node      { return nil }
+func (n *Param) children() []Node     { return nil }
+func (n *Variable) children() []Node  { return nil }
+func (n *Unary) children() []Node     { return []Node{n.X} }
+func (n *Binary) children() []Node    { return []Node{n.L, n.R} }
+
+func (n *FuncCall) children() []Node {
+	nodes := appendExprs(nil, n.Args...)
+	nodes = appendOrder(nodes, n.OrderBy)
+	nodes = appendExprs(nodes, n.Filter)
+	if n.Over != nil {
+		nodes = append(nodes, n.Over.children()...)
+	}
+	return nodes
+}
+
+func (n *Window) children() []Node {
+	nodes := appendOrder(appendExprs(nil, n.PartitionBy...), n.OrderBy)
+	return appendExprs(nodes, n.Frame...)
+}
+
+func (n *Subquery) children() []Node { return []Node{n.Query} }
+
+func (n *Case) children() []Node {
+	nodes := appendExprs(nil, n.Operand)
+	for _, when := range n.Whens {
+		nodes = append(nodes, when.Cond, when.Result)
+	}
+	return appendExprs(nodes, n.Else)
+}
+
+func (n *Cast) children() []Node    { return []Node{n.X} }
+func (n *Between) children() []Node { return []Node{n.X, n.Lo, n.Hi} }
+
+func (n *In) children() []Node {
+	nodes := appendExprs([]Node{n.X}, n.List...)
+	if n.Query != nil {
+		nodes = append(nodes, n.Query)
+	}
+	return nodes
+}
+
+func (n *Is) children() []Node       { return []Node{n.X} }
+func (n *Interval) children() []Node { return []Node{n.X} }
+func (n *Tuple) children() []Node    { return appendExprs(nil, n.Items...) }
+
+func appendExprs(nodes []Node, exprs ...Expr) []Node {
+	for _, expr := range exprs {
+		if expr != nil {
+			nodes = append(nodes, expr)
+		}
+	}
+	return nodes
+}
+
+func appendOrder(nodes []Node, items []*OrderItem) []Node {
+	for _, item := range items {
+		nodes = append(nodes, item.Expr)
+	}
+	return nodes
+}
+
+// Walk visits node and its descendants depth first until fn returns false
+// for a node, which skips the descendants of that node.
+func Walk(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	for _, child := range node.children() {
+		Walk(child, fn)
+	}
+}
@@ -0,0 +1,722 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+// Binding powers of the infix operators, from the loosest to the tightest.
+const (
+	precOr = iota + 1
+	precXor
+	precAnd
+	precCompare
+	precBitOr
+	precBitAnd
+	precShift
+	precAdd
+	precMul
+	precBitXor
+	precUnary
+)
+
+var compareOps = wordSet("=", "<", ">", "<=", ">=", "<>", "!=", "<=>")
+
+// negatable are the predicates that may be written with NOT, as in NOT IN.
+var negatable = wordSet("IN", "LIKE", "ILIKE", "RLIKE", "REGEXP", "BETWEEN", "SIMILAR")
+
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseBinary(precOr)
+}
+
+// infix returns the binding power of the operator at the current token, or
+// zero when the token does not continue an expression.
+func (p *parser) infix() int {
+	tok := p.peek()
+	switch tok.kind {
+	case tokOp:
+		switch {
+		case tok.text == "||":
+			return precOr
+		case tok.text == "&&":
+			return precAnd
+		case compareOps[tok.text]:
+			return precCompare
+		case tok.text == "|":
+			return precBitOr
+		case tok.text == "&":
+			return precBitAnd
+		case tok.text == "<<" || tok.text == ">>":
+			return precShift
+		case tok.text == "+" || tok.text == "-":
+			return precAdd
+		case tok.text == "*" || tok.text == "/" || tok.text == "%":
+			return precMul
+		case tok.text == "^":
+			return precBitXor
+		}
+	case tokWord:
+		switch word := strings.ToUpper(tok.text); word {
+		case "OR":
+			return precOr
+		case "XOR":
+			return precXor
+		case "AND":
+			return precAnd
+		case "NOT":
+			if next := p.peekAt(1); next.kind == tokWord && negatable[strings.ToUpper(next.text)] {
+				return precCompare
+			}
+		case "IN":
+			if next := p.peekAt(1); next.kind == tokOp && next.text == "(" {
+				return precCompare
+			}
+		case "IS", "LIKE", "ILIKE", "RLIKE", "REGEXP", "BETWEEN", "SIMILAR":
+			return precCompare
+		case "SOUNDS":
+			if isWordToken(p.peekAt(1), "LIKE") {
+				return precCompare
+			}
+		case "MEMBER":
+			if isWordToken(p.peekAt(1), "OF") {
+				return precCompare
+			}
+		case "DIV", "MOD":
+			return precMul
+		}
+	}
+	return 0
+}
+
+// parseBinary reads an expression whose operators bind at least as tightly
+// as min.
+func (p *parser) parseBinary(min int) (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.isOp(":=") {
+			return nil, p.errorf("assignments are not allowed")
+		}
+		prec := p.infix()
+		if prec == 0 || prec < min {
+			return left, nil
+		}
+		if prec == precCompare {
+			if left, err = p.parsePredicate(left); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		op := strings.ToUpper(p.next().text)
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: op, L: left, R: right}
+	}
+}
+
+// parsePredicate reads a comparison, IS, IN, BETWEEN or pattern match whose
+// left operand has been read.
+func (p *parser) parsePredicate(left Expr) (Expr, error) {
+	if tok := p.peek(); tok.kind == tokOp {
+		p.next()
+		right, err := p.parseBinary(precCompare + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: tok.text, L: left, R: right}, nil
+	}
+
+	not := p.acceptWord("NOT")
+	word := strings.ToUpper(p.next().text)
+	switch word {
+	case "IS":
+		is := &Is{X: left, Not: p.acceptWord("NOT")}
+		if p.acceptWord("DISTINCT") {
+			if err := p.expectWord("FROM"); err != nil {
+				return nil, err
+			}
+			right, err := p.parseBinary(precCompare + 1)
+			if err != nil {
+				return nil, err
+			}
+			op := "IS DISTINCT FROM"
+			if is.Not {
+				op = "IS NOT DISTINCT FROM"
+			}
+			return &Binary{Op: op, L: left, R: right}, nil
+		}
+		if !p.isWord("NULL", "TRUE", "FALSE", "UNKNOWN") {
+			return nil, p.errorf("expected NULL, TRUE, FALSE or UNKNOWN")
+		}
+		is.What = strings.ToUpper(p.next().text)
+		return is, nil
+	case "IN":
+		in := &In{X: left, Not: not}
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		var err error
+		if p.isWord("SELECT", "WITH") {
+			in.Query, err = p.parseQuery()
+		} else {
+			in.List, err = p.parseExprList()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return in, p.expectOp(")")
+	case "BETWEEN":
+		between := &Between{X: left, Not: not}
+		var err error
+		if between.Lo, err = p.parseBinary(precCompare + 1); err != nil {
+			return nil, err
+		}
+		if err = p.expectWord("AND"); err != nil {
+			return nil, err
+		}
+		if between.Hi, err = p.parseBinary(precCompare + 1); err != nil {
+			return nil, err
+		}
+		return between, nil
+	case "SIMILAR":
+		if err := p.expectWord("TO"); err != nil {
+			return nil, err
+		}
+		word = "SIMILAR TO"
+	case "SOUNDS", "MEMBER":
+		word += " " + strings.ToUpper(p.next().text)
+	}
+	op := word
+	if not {
+		op = "NOT " + op
+	}
+	right, err := p.parseBinary(precCompare + 1)
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptWord("ESCAPE") {
+		escape, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		right = &Binary{Op: "ESCAPE", L: right, R: escape}
+	}
+	return &Binary{Op: op, L: left, R: right}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokOp && (tok.text == "-" || tok.text == "+" || tok.text == "~" || tok.text == "!"):
+		p.next()
+		x, err := p.parseBinary(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: tok.text, X: x}, nil
+	case isWordToken(tok, "NOT"):
+		p.next()
+		x, err := p.parseBinary(precCompare)
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "NOT", X: x}, nil
+	case isWordToken(tok, "BINARY") && !(p.peekAt(1).kind == tokOp && p.peekAt(1).text == "("):
+		p.next()
+		x, err := p.parseBinary(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "BINARY", X: x}, nil
+	}
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return p.parsePostfix(x)
+}
+
+// parsePostfix reads the operators that follow an operand: casts with ::,
+// COLLATE, JSON paths, subscripts and AT TIME ZONE.
+func (p *parser) parsePostfix(x Expr) (Expr, error) {
+	for {
+		switch {
+		case p.acceptOp("::"):
+			typ, err := p.parseTypeName()
+			if err != nil {
+				return nil, err
+			}
+			x = &Cast{X: x, Type: typ}
+		case p.acceptWord("COLLATE"):
+			tok := p.next()
+			if tok.kind != tokWord && tok.kind != tokIdent && tok.kind != tokString {
+				return nil, p.errorf("expected a collation")
+			}
+		case p.isOp("->") || p.isOp("->>"):
+			op := p.next().text
+			path, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			x = &Binary{Op: op, L: x, R: path}
+		case p.acceptOp("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.acceptOp(":") {
+				upper, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				index = &Binary{Op: ":", L: index, R: upper}
+			}
+			if err = p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			x = &Binary{Op: "[]", L: x, R: index}
+		case p.isWord("AT") && isWordToken(p.peekAt(1), "TIME"):
+			p.next()
+			p.next()
+			if err := p.expectWord("ZONE"); err != nil {
+				return nil, err
+			}
+			zone, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			x = &Binary{Op: "AT TIME ZONE", L: x, R: zone}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		return &Literal{Value: tok.text}, nil
+	case tokString:
+		p.next()
+		value := tok.text
+		// Adjacent literals are concatenated.
+		for p.peek().kind == tokString {
+			value += p.next().text
+		}
+		return &Literal{Value: value}, nil
+	case tokParam:
+		p.next()
+		return &Param{}, nil
+	case tokVariable:
+		p.next()
+		return &Variable{Name: tok.text}, nil
+	case tokIdent:
+		return p.parseNameExpr()
+	case tokOp:
+		switch tok.text {
+		case "(":
+			p.next()
+			if p.isWord("SELECT", "WITH") {
+				query, err := p.parseQuery()
+				if err != nil {
+					return nil, err
+				}
+				return &Subquery{Query: query}, p.expectOp(")")
+			}
+			items, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			if len(items) == 1 {
+				return items[0], nil
+			}
+			return &Tuple{Items: items}, nil
+		case "*":
+			p.next()
+			return &Star{}, nil
+		}
+		return nil, p.errorf("expected an expression")
+	case tokWord:
+		return p.parseWordExpr()
+	}
+	return nil, p.errorf("expected an expression")
+}
+
+// parseWordExpr reads an expression starting with an unquoted word: a
+// keyword literal or construct, a column or a function call.
+func (p *parser) parseWordExpr() (Expr, error) {
+	tok := p.peek()
+	word := strings.ToUpper(tok.text)
+	next := p.peekAt(1)
+	call := next.kind == tokOp && next.text == "("
+
+	switch {
+	case word == "NULL" || word == "TRUE" || word == "FALSE":
+		p.next()
+		return &Literal{Value: word}, nil
+	case word == "CASE":
+		p.next()
+		return p.parseCase()
+	case (word == "CAST" || word == "TRY_CAST" || word == "SAFE_CAST") && call:
+		p.next()
+		p.next()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectWord("AS"); err != nil {
+			return nil, err
+		}
+		typ, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		return &Cast{X: x, Type: typ}, p.expectOp(")")
+	case word == "CONVERT" && call:
+		p.next()
+		p.next()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		cast := &Cast{X: x}
+		if p.acceptWord("USING") {
+			if _, err = p.parseName(); err != nil {
+				return nil, err
+			}
+		} else {
+			if err = p.expectOp(","); err != nil {
+				return nil, err
+			}
+			if cast.Type, err = p.parseTypeName(); err != nil {
+				return nil, err
+			}
+			// SQL Server takes the value as the second argument and an
+			// optional style as the third.
+			if p.acceptOp(",") {
+				if _, err = p.parseExpr(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return cast, p.expectOp(")")
+	case word == "EXISTS" && call:
+		p.next()
+		p.next()
+		if !p.isWord("SELECT", "WITH") {
+			return nil, p.errorf("expected SELECT")
+		}
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		return &Subquery{Query: query, Exists: true}, p.expectOp(")")
+	case word == "INTERVAL" && !call:
+		p.next()
+		x, err := p.parseBinary(precAdd)
+		if err != nil {
+			return nil, err
+		}
+		interval := &Interval{X: x}
+		if tok := p.peek(); tok.kind == tokWord && intervalUnits[strings.ToUpper(tok.text)] {
+			interval.Unit = strings.ToUpper(p.next().text)
+		}
+		return interval, nil
+	case next.kind == tokString && (word == "DATE" || word == "TIME" || word == "TIMESTAMP" ||
+		word == "N" || word == "X" || word == "B" || word == "E" || strings.HasPrefix(word, "_")):
+		// Typed literals and charset introducers, as in DATE '2024-01-01'
+		// or _utf8mb4'text'.
+		p.next()
+		return p.parsePrimary()
+	case call && !noFunction[word]:
+		p.next()
+		return p.parseFuncCall([]string{tok.text})
+	case reserved[word]:
+		return nil, p.errorf("expected an expression")
+	}
+	return p.parseNameExpr()
+}
+
+// parseNameExpr reads a possibly qualified column, table.* or a function
+// call with a qualified name.
+func (p *parser) parseNameExpr() (Expr, error) {
+	parts := []string{p.next().text}
+	for p.acceptOp(".") {
+		if p.acceptOp("*") {
+			return &Star{Table: parts}, nil
+		}
+		tok := p.peek()
+		if tok.kind != tokWord && tok.kind != tokIdent {
+			return nil, p.errorf("expected a name")
+		}
+		p.next()
+		parts = append(parts, tok.text)
+	}
+	if p.isOp("(") {
+		return p.parseFuncCall(parts)
+	}
+	return &ColumnRef{Parts: parts}, nil
+}
+
+func (p *parser) parseCase() (Expr, error) {
+	c := &Case{}
+	var err error
+	if !p.isWord("WHEN") {
+		if c.Operand, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptWord("WHEN") {
+		when := &When{}
+		if when.Cond, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if err = p.expectWord("THEN"); err != nil {
+			return nil, err
+		}
+		if when.Result, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		c.Whens = append(c.Whens, when)
+	}
+	if len(c.Whens) == 0 {
+		return nil, p.errorf("expected WHEN")
+	}
+	if p.acceptWord("ELSE") {
+		if c.Else, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return c, p.expectWord("END")
+}
+
+// parseFuncCall reads the arguments of a function whose name has been read,
+// including the keyword separated forms such as TRIM(LEADING x FROM y),
+// EXTRACT(YEAR FROM d) and GROUP_CONCAT(x ORDER BY y SEPARATOR ','), and the
+// WITHIN GROUP, FILTER and OVER clauses that follow it.
+func (p *parser) parseFuncCall(name []string) (*FuncCall, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	call := &FuncCall{Name: name}
+	if !p.acceptOp(")") {
+		if p.acceptWord("DISTINCT") {
+			call.Distinct = true
+		} else {
+			p.acceptWord("ALL")
+		}
+		for {
+			p.acceptWord("LEADING", "TRAILING", "BOTH")
+			var arg Expr
+			var err error
+			switch {
+			case p.isOp(")"):
+				// TRIM(BOTH FROM x) leaves no argument before FROM.
+			case p.isOp("*"):
+				p.next()
+				arg = &Star{}
+			case p.isWord("SELECT", "WITH"):
+				query, err := p.parseQuery()
+				if err != nil {
+					return nil, err
+				}
+				arg = &Subquery{Query: query}
+			default:
+				if arg, err = p.parseExpr(); err != nil {
+					return nil, err
+				}
+			}
+			if arg != nil {
+				call.Args = append(call.Args, arg)
+			}
+			if p.acceptWord("AS") {
+				if _, err = p.parseTypeName(); err != nil {
+					return nil, err
+				}
+			}
+			if p.isWord("ORDER") && isWordToken(p.peekAt(1), "BY") {
+				p.next()
+				p.next()
+				if call.OrderBy, err = p.parseOrderItems(); err != nil {
+					return nil, err
+				}
+			}
+			if !p.acceptOp(",") && !p.acceptWord("FROM", "FOR", "IN", "USING", "SEPARATOR") {
+				break
+			}
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if p.isWord("WITHIN") && isWordToken(p.peekAt(1), "GROUP") {
+		p.next()
+		p.next()
+		if err = p.expectOp("("); err != nil {
+			return nil, err
+		}
+		if err = p.expectWord("ORDER"); err != nil {
+			return nil, err
+		}
+		if err = p.expectWord("BY"); err != nil {
+			return nil, err
+		}
+		if call.OrderBy, err = p.parseOrderItems(); err != nil {
+			return nil, err
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.isWord("FILTER") && p.peekAt(1).kind == tokOp && p.peekAt(1).text == "(" {
+		p.next()
+		p.next()
+		if err = p.expectWord("WHERE"); err != nil {
+			return nil, err
+		}
+		if call.Filter, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	if p.isWord("IGNORE", "RESPECT") && isWordToken(p.peekAt(1), "NULLS") {
+		p.next()
+		p.next()
+	}
+	if p.acceptWord("OVER") {
+		if p.isOp("(") {
+			if call.Over, err = p.parseWindowSpec(); err != nil {
+				return nil, err
+			}
+		} else {
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			call.Over = &Window{Name: name}
+		}
+	}
+	return call, nil
+}
+
+// parseWindowSpec reads a parenthesized window specification.
+func (p *parser) parseWindowSpec() (*Window, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	window := &Window{}
+	var err error
+	if tok := p.peek(); (tok.kind == tokWord || tok.kind == tokIdent) &&
+		!p.isWord("PARTITION", "ORDER", "ROWS", "RANGE", "GROUPS") {
+		if window.Name, err = p.parseName(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isWord("PARTITION") && isWordToken(p.peekAt(1), "BY") {
+		p.next()
+		p.next()
+		if window.PartitionBy, err = p.parseExprList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isWord("ORDER") && isWordToken(p.peekAt(1), "BY") {
+		p.next()
+		p.next()
+		if window.OrderBy, err = p.parseOrderItems(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptWord("ROWS", "RANGE", "GROUPS") {
+		between := p.acceptWord("BETWEEN")
+		if err = p.parseFrameBound(window); err != nil {
+			return nil, err
+		}
+		if between {
+			if err = p.expectWord("AND"); err != nil {
+				return nil, err
+			}
+			if err = p.parseFrameBound(window); err != nil {
+				return nil, err
+			}
+		}
+		if p.acceptWord("EXCLUDE") {
+			switch {
+			case p.acceptWord("CURRENT"):
+				err = p.expectWord("ROW")
+			case p.acceptWord("NO"):
+				err = p.expectWord("OTHERS")
+			case !p.acceptWord("GROUP", "TIES"):
+				err = p.errorf("expected CURRENT ROW, GROUP, TIES or NO OTHERS")
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return window, p.expectOp(")")
+}
+
+func (p *parser) parseFrameBound(window *Window) error {
+	switch {
+	case p.acceptWord("UNBOUNDED"):
+	case p.acceptWord("CURRENT"):
+		return p.expectWord("ROW")
+	default:
+		offset, err := p.parseBinary(precCompare + 1)
+		if err != nil {
+			return err
+		}
+		window.Frame = append(window.Frame, offset)
+	}
+	if !p.acceptWord("PRECEDING", "FOLLOWING") {
+		return p.errorf("expected PRECEDING or FOLLOWING")
+	}
+	return nil
+}
+
+// parseTypeName reads the target type of a cast, such as DECIMAL(10, 2),
+// CHAR(20) CHARACTER SET utf8mb4 or TIMESTAMP WITH TIME ZONE.
+func (p *parser) parseTypeName() (string, error) {
+	tok := p.peek()
+	if tok.kind != tokWord && tok.kind != tokIdent {
+		return "", p.errorf("expected a type")
+	}
+	p.next()
+	words := []string{tok.text}
+	for {
+		switch {
+		case p.acceptOp("("):
+			args := make([]string, 0, 2)
+			for !p.acceptOp(")") {
+				arg := p.next()
+				if arg.kind == tokEOF {
+					return "", p.errorf("expected )")
+				}
+				args = append(args, arg.text)
+			}
+			words[len(words)-1] += "(" + strings.Join(args, "") + ")"
+		case p.isOp("[") && p.peekAt(1).kind == tokOp && p.peekAt(1).text == "]":
+			p.next()
+			p.next()
+			words[len(words)-1] += "[]"
+		case isWordToken(p.tokens[p.pos-1], "CHARSET", "SET") && (p.peek().kind == tokWord || p.peek().kind == tokIdent):
+			words = append(words, p.next().text)
+		case p.peek().kind == tokWord && typeWords[strings.ToUpper(p.peek().text)]:
+			words = append(words, p.next().text)
+		default:
+			return strings.Join(words, " "), nil
+		}
+	}
+}
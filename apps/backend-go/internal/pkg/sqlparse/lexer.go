@@ -0,0 +1,203 @@
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	// tokWord is an unquoted identifier or keyword.
+	tokWord
+	// tokIdent is a quoted identifier: `name` or "name".
+	tokIdent
+	tokString
+	tokNumber
+	// tokParam is a ? placeholder.
+	tokParam
+	// tokVariable is a @user or @@system variable.
+	tokVariable
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of statement"
+	}
+	return fmt.Sprintf("%q at offset %d", t.text, t.pos)
+}
+
+// operators are matched longest first.
+var operators = []string{
+	"<=>", "->>",
+	"<=", ">=", "<>", "!=", "||", "&&", ":=", "::", "->", "<<", ">>",
+	"=", "<", ">", "+", "-", "*", "/", "%", "^", "&", "|", "~", "!",
+	"(", ")", ",", ".", ";", "[", "]", ":",
+}
+
+// lex splits a statement into tokens. Comments are dropped, except MySQL
+// executable comments, which are rejected since the server runs their text.
+func lex(sql string) ([]token, error) {
+	tokens := make([]token, 0, len(sql)/4)
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f':
+			i++
+		case strings.HasPrefix(sql[i:], "--") && (i+2 == len(sql) || sql[i+2] <= ' '):
+			// MySQL only starts a comment when -- is followed by a space;
+			// 1--1 is a subtraction.
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 1
+			}
+		case ch == '#':
+			// # starts a comment in MySQL but is an operator elsewhere.
+			return nil, fmt.Errorf("# comments are not supported, use -- instead")
+		case strings.HasPrefix(sql[i:], "/*"):
+			if strings.HasPrefix(sql[i:], "/*!") {
+				return nil, fmt.Errorf("executable comments are not allowed")
+			}
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			i += end + 4
+		case ch == '\'':
+			text, n, err := lexQuoted(sql, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i += n
+		case ch == '`' || ch == '"':
+			text, n, err := lexQuoted(sql, i, ch)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: text, pos: i})
+			i += n
+		case isDigit(ch) || (ch == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			n := lexNumber(sql[i:])
+			tokens = append(tokens, token{kind: tokNumber, text: sql[i : i+n], pos: i})
+			i += n
+		case isWordStart(ch):
+			n := 1
+			for i+n < len(sql) && isWordPart(sql[i+n]) {
+				n++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: sql[i : i+n], pos: i})
+			i += n
+		case ch == '@':
+			n := 1
+			if i+1 < len(sql) && sql[i+1] == '@' {
+				n++
+			}
+			start := n
+			for i+n < len(sql) && (isWordPart(sql[i+n]) || sql[i+n] == '.') {
+				n++
+			}
+			if n == start {
+				return nil, fmt.Errorf("invalid variable at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: tokVariable, text: sql[i : i+n], pos: i})
+			i += n
+		case ch == '?':
+			tokens = append(tokens, token{kind: tokParam, text: "?", pos: i})
+			i++
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(sql[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", ch, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(sql)}), nil
+}
+
+// lexQuoted reads a literal or quoted identifier starting at sql[start].
+// The quote is escaped by doubling it. A backslash before the quote of a
+// string literal is rejected: MySQL reads it as an escape and PostgreSQL as
+// the end of the literal, so the two would see different statements.
+func lexQuoted(sql string, start int, quote byte) (string, int, error) {
+	var text strings.Builder
+	for i := start + 1; i < len(sql); i++ {
+		ch := sql[i]
+		if ch == '\\' && quote == '\'' && i+1 < len(sql) {
+			if sql[i+1] == quote {
+				return "", 0, fmt.Errorf("backslash escaped quote at offset %d, double the quote instead", i)
+			}
+			text.WriteByte(ch)
+			text.WriteByte(sql[i+1])
+			i++
+			continue
+		}
+		if ch == quote {
+			if i+1 < len(sql) && sql[i+1] == quote {
+				text.WriteByte(quote)
+				i++
+				continue
+			}
+			return text.String(), i - start + 1, nil
+		}
+		text.WriteByte(ch)
+	}
+	return "", 0, fmt.Errorf("unterminated quoted text at offset %d", start)
+}
+
+func lexNumber(s string) int {
+	n := 0
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") || strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B") {
+		n = 2
+		for n < len(s) && isWordPart(s[n]) {
+			n++
+		}
+		return n
+	}
+	for n < len(s) && (isDigit(s[n]) || s[n] == '.') {
+		n++
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if m < len(s) && (s[m] == '+' || s[m] == '-') {
+			m++
+		}
+		if m < len(s) && isDigit(s[m]) {
+			n = m
+			for n < len(s) && isDigit(s[n]) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isWordStart(ch byte) bool {
+	return ch == '_' || ch == '$' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch >= 0x80
+}
+
+func isWordPart(ch byte) bool {
+	return isWordStart(ch) || isDigit(ch)
+}
@@ -0,0 +1,737 @@
+// Package sqlparse parses SELECT queries, MySQL syntax first with the common
+// extensions of the other supported databases, so that user SQL can be
+// checked before it runs on a datasource.
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reserved are the keywords that end an expression and can therefore not be
+// used as an implicit alias.
+var reserved = wordSet(
+	"SELECT", "FROM", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "OFFSET", "FETCH",
+	"UNION", "EXCEPT", "INTERSECT", "MINUS", "INTO", "FOR", "LOCK", "WINDOW", "QUALIFY",
+	"ON", "USING", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "NATURAL", "OUTER",
+	"STRAIGHT_JOIN", "APPLY", "AS", "AND", "OR", "XOR", "NOT", "WITH", "USE", "IGNORE",
+	"FORCE", "PARTITION", "TABLESAMPLE", "LATERAL", "WHEN", "THEN", "ELSE", "END",
+	"IS", "IN", "LIKE", "BETWEEN", "ASC", "DESC",
+)
+
+// noFunction are the keywords that are never function names.
+var noFunction = wordSet("SELECT", "FROM", "WHERE", "AND", "OR", "XOR", "NOT", "IN", "IS",
+	"LIKE", "BETWEEN", "ON", "USING", "AS", "WHEN", "THEN", "ELSE", "END", "JOIN")
+
+var intervalUnits = wordSet(
+	"MICROSECOND", "SECOND", "MINUTE", "HOUR", "DAY", "WEEK", "MONTH", "QUARTER", "YEAR",
+	"SECOND_MICROSECOND", "MINUTE_MICROSECOND", "MINUTE_SECOND", "HOUR_MICROSECOND",
+	"HOUR_SECOND", "HOUR_MINUTE", "DAY_MICROSECOND", "DAY_SECOND", "DAY_MINUTE", "DAY_HOUR",
+	"YEAR_MONTH",
+)
+
+// typeWords may follow the first word of a type name, as in DOUBLE
+// PRECISION or TIMESTAMP WITH TIME ZONE.
+var typeWords = wordSet("UNSIGNED", "SIGNED", "INTEGER", "INT", "PRECISION", "VARYING",
+	"CHARACTER", "CHARSET", "SET", "WITH", "WITHOUT", "TIME", "ZONE", "BINARY", "LOCAL")
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a single SELECT query, optionally followed by a semicolon.
+// Statements of any other kind, several statements, SELECT ... INTO and
+// locking reads are rejected.
+func Parse(sql string) (Query, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, fmt.Errorf("sql is required")
+	}
+	if !p.isWord("SELECT", "WITH") && !p.isOp("(") {
+		return nil, fmt.Errorf("only SELECT queries are supported, got %s", strings.ToUpper(p.peek().text))
+	}
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptOp(";")
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("only a single statement is supported, unexpected %s", p.peek())
+	}
+	return query, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error near %s: %s", p.peek(), fmt.Sprintf(format, args...))
+}
+
+func isWordToken(tok token, words ...string) bool {
+	if tok.kind != tokWord {
+		return false
+	}
+	for _, word := range words {
+		if strings.EqualFold(tok.text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) isWord(words ...string) bool {
+	return isWordToken(p.peek(), words...)
+}
+
+func (p *parser) acceptWord(words ...string) bool {
+	if p.isWord(words...) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectWord(word string) error {
+	if !p.acceptWord(word) {
+		return p.errorf("expected %s", word)
+	}
+	return nil
+}
+
+func (p *parser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokOp && tok.text == op
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf("expected %s", op)
+	}
+	return nil
+}
+
+// queryAhead reports whether a query starts at the current token, possibly
+// after opening parentheses.
+func (p *parser) queryAhead() bool {
+	i := 0
+	for tok := p.peekAt(i); tok.kind == tokOp && tok.text == "("; tok = p.peekAt(i) {
+		i++
+	}
+	return isWordToken(p.peekAt(i), "SELECT", "WITH")
+}
+
+// parseName reads an identifier, quoted or not.
+func (p *parser) parseName() (string, error) {
+	tok := p.peek()
+	if tok.kind == tokIdent || (tok.kind == tokWord && !reserved[strings.ToUpper(tok.text)]) {
+		p.next()
+		return tok.text, nil
+	}
+	return "", p.errorf("expected a name")
+}
+
+func (p *parser) parseNameList() ([]string, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return names, p.expectOp(")")
+}
+
+// parseAlias reads an optional alias introduced by AS or implicit.
+func (p *parser) parseAlias() (string, error) {
+	if p.acceptWord("AS") {
+		if tok := p.peek(); tok.kind == tokString {
+			p.next()
+			return tok.text, nil
+		}
+		return p.parseName()
+	}
+	tok := p.peek()
+	if tok.kind == tokIdent || tok.kind == tokString || (tok.kind == tokWord && !reserved[strings.ToUpper(tok.text)]) {
+		p.next()
+		return tok.text, nil
+	}
+	return "", nil
+}
+
+// rejectTail rejects the clauses that turn a query into a write: INTO and
+// locking reads.
+func (p *parser) rejectTail() error {
+	switch {
+	case p.isWord("INTO"):
+		return fmt.Errorf("SELECT ... INTO is not allowed")
+	case p.isWord("FOR") && isWordToken(p.peekAt(1), "UPDATE", "SHARE", "NO", "KEY"):
+		return fmt.Errorf("locking reads are not allowed")
+	case p.isWord("LOCK") && isWordToken(p.peekAt(1), "IN"):
+		return fmt.Errorf("locking reads are not allowed")
+	}
+	return nil
+}
+
+// parseQuery reads a query with its WITH clause, set operations, ORDER BY
+// and LIMIT.
+func (p *parser) parseQuery() (Query, error) {
+	if p.acceptWord("WITH") {
+		return p.parseWith()
+	}
+
+	query, err := p.parseQueryTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("UNION", "EXCEPT", "INTERSECT", "MINUS") {
+		op := &SetOp{Op: strings.ToUpper(p.next().text), Left: query}
+		op.All = p.acceptWord("ALL")
+		if !op.All {
+			p.acceptWord("DISTINCT")
+		}
+		if op.Right, err = p.parseQueryTerm(); err != nil {
+			return nil, err
+		}
+		query = op
+	}
+
+	orderBy, limit, err := p.parseOrderLimit()
+	if err != nil {
+		return nil, err
+	}
+	if orderBy != nil || limit != nil {
+		if sel, ok := query.(*Select); ok && sel.OrderBy == nil && sel.Limit == nil {
+			sel.OrderBy, sel.Limit = orderBy, limit
+		} else {
+			query = &Ordered{Query: query, OrderBy: orderBy, Limit: limit}
+		}
+	}
+	if err = p.rejectTail(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func (p *parser) parseWith() (Query, error) {
+	with := &With{Recursive: p.acceptWord("RECURSIVE")}
+	for {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		cte := &CTE{Name: name}
+		if p.isOp("(") {
+			if cte.Columns, err = p.parseNameList(); err != nil {
+				return nil, err
+			}
+		}
+		if err = p.expectWord("AS"); err != nil {
+			return nil, err
+		}
+		if p.isWord("NOT") && isWordToken(p.peekAt(1), "MATERIALIZED") {
+			p.next()
+		}
+		p.acceptWord("MATERIALIZED")
+		if err = p.expectOp("("); err != nil {
+			return nil, err
+		}
+		if !p.queryAhead() {
+			return nil, p.errorf("common table expressions must be SELECT queries")
+		}
+		if cte.Query, err = p.parseQuery(); err != nil {
+			return nil, err
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		with.CTEs = append(with.CTEs, cte)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if !p.queryAhead() {
+		return nil, p.errorf("only SELECT queries are supported")
+	}
+	body, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	with.Body = body
+	return with, nil
+}
+
+func (p *parser) parseQueryTerm() (Query, error) {
+	if p.acceptOp("(") {
+		if !p.queryAhead() {
+			return nil, p.errorf("expected SELECT")
+		}
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		return query, p.expectOp(")")
+	}
+	if p.isWord("SELECT") {
+		return p.parseSelect()
+	}
+	return nil, p.errorf("expected SELECT")
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	p.next()
+	sel := &Select{}
+	var err error
+modifiers:
+	for {
+		switch {
+		case p.acceptWord("ALL", "HIGH_PRIORITY", "STRAIGHT_JOIN", "SQL_SMALL_RESULT", "SQL_BIG_RESULT",
+			"SQL_BUFFER_RESULT", "SQL_NO_CACHE", "SQL_CACHE", "SQL_CALC_FOUND_ROWS"):
+		case p.acceptWord("DISTINCT", "DISTINCTROW"):
+			sel.Distinct = true
+			if p.acceptWord("ON") {
+				if err = p.expectOp("("); err != nil {
+					return nil, err
+				}
+				if sel.DistinctOn, err = p.parseExprList(); err != nil {
+					return nil, err
+				}
+				if err = p.expectOp(")"); err != nil {
+					return nil, err
+				}
+			}
+		case p.isWord("TOP"):
+			p.next()
+			if sel.Top, err = p.parsePrimary(); err != nil {
+				return nil, err
+			}
+			p.acceptWord("PERCENT")
+			if p.isWord("WITH") && isWordToken(p.peekAt(1), "TIES") {
+				p.next()
+				p.next()
+			}
+		default:
+			break modifiers
+		}
+	}
+
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		sel.Items = append(sel.Items, item)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if err = p.rejectTail(); err != nil {
+		return nil, err
+	}
+
+	if p.acceptWord("FROM") {
+		for {
+			table, err := p.parseTableRef()
+			if err != nil {
+				return nil, err
+			}
+			sel.From = append(sel.From, table)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptWord("WHERE") {
+		if sel.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isWord("GROUP") && isWordToken(p.peekAt(1), "BY") {
+		p.next()
+		p.next()
+		for {
+			if p.isWord("GROUPING") && isWordToken(p.peekAt(1), "SETS") {
+				p.next()
+				p.next()
+			}
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			p.acceptWord("ASC", "DESC")
+			sel.GroupBy = append(sel.GroupBy, expr)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		if p.isWord("WITH") && isWordToken(p.peekAt(1), "ROLLUP") {
+			p.next()
+			p.next()
+		}
+	}
+	if p.acceptWord("HAVING") {
+		if sel.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptWord("WINDOW") {
+		for {
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectWord("AS"); err != nil {
+				return nil, err
+			}
+			window, err := p.parseWindowSpec()
+			if err != nil {
+				return nil, err
+			}
+			window.Name = name
+			sel.Windows = append(sel.Windows, window)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptWord("QUALIFY") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		sel.Having = joinAnd(sel.Having, expr)
+	}
+	return sel, nil
+}
+
+func joinAnd(left Expr, right Expr) Expr {
+	if left == nil {
+		return right
+	}
+	return &Binary{Op: "AND", L: left, R: right}
+}
+
+func (p *parser) parseSelectItem() (*SelectItem, error) {
+	if p.acceptOp("*") {
+		return &SelectItem{Expr: &Star{}}, nil
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return nil, err
+	}
+	return &SelectItem{Expr: expr, Alias: alias}, nil
+}
+
+func (p *parser) parseOrderLimit() ([]*OrderItem, []Expr, error) {
+	var orderBy []*OrderItem
+	var limit []Expr
+	var err error
+	if p.isWord("ORDER") && isWordToken(p.peekAt(1), "BY") {
+		p.next()
+		p.next()
+		if orderBy, err = p.parseOrderItems(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.acceptWord("LIMIT") {
+		if !p.acceptWord("ALL") {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			limit = append(limit, expr)
+			if p.acceptOp(",") {
+				if expr, err = p.parseExpr(); err != nil {
+					return nil, nil, err
+				}
+				limit = append(limit, expr)
+			}
+		}
+	}
+	if p.acceptWord("OFFSET") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, nil, err
+		}
+		limit = append(limit, expr)
+		p.acceptWord("ROW", "ROWS")
+	}
+	if p.acceptWord("FETCH") {
+		p.acceptWord("FIRST", "NEXT")
+		if !p.isWord("ROW", "ROWS") {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			limit = append(limit, expr)
+			p.acceptWord("PERCENT")
+		}
+		if !p.acceptWord("ROW", "ROWS") {
+			return nil, nil, p.errorf("expected ROWS")
+		}
+		if p.acceptWord("WITH") {
+			if err = p.expectWord("TIES"); err != nil {
+				return nil, nil, err
+			}
+		} else if err = p.expectWord("ONLY"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if limit == nil && orderBy == nil {
+		return nil, nil, nil
+	}
+	return orderBy, limit, nil
+}
+
+func (p *parser) parseOrderItems() ([]*OrderItem, error) {
+	items := make([]*OrderItem, 0)
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		item := &OrderItem{Expr: expr}
+		if p.acceptWord("DESC") {
+			item.Desc = true
+		} else {
+			p.acceptWord("ASC")
+		}
+		if p.acceptWord("NULLS") && !p.acceptWord("FIRST", "LAST") {
+			return nil, p.errorf("expected FIRST or LAST")
+		}
+		items = append(items, item)
+		if !p.acceptOp(",") {
+			return items, nil
+		}
+	}
+}
+
+// parseTableRef reads a table factor followed by its joins.
+func (p *parser) parseTableRef() (TableExpr, error) {
+	left, err := p.parseTableFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		kind, ok := p.parseJoinKeyword()
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseTableFactor()
+		if err != nil {
+			return nil, err
+		}
+		join := &Join{Kind: kind, Left: left, Right: right}
+		if p.acceptWord("ON") {
+			if join.On, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		} else if p.acceptWord("USING") {
+			if join.Using, err = p.parseNameList(); err != nil {
+				return nil, err
+			}
+		}
+		left = join
+	}
+}
+
+func (p *parser) parseJoinKeyword() (string, bool) {
+	start := p.pos
+	words := make([]string, 0, 3)
+	for p.isWord("NATURAL", "INNER", "CROSS", "LEFT", "RIGHT", "FULL", "OUTER") {
+		words = append(words, strings.ToUpper(p.next().text))
+	}
+	if p.isWord("JOIN", "APPLY", "STRAIGHT_JOIN") {
+		words = append(words, strings.ToUpper(p.next().text))
+		return strings.Join(words, " "), true
+	}
+	p.pos = start
+	return "", false
+}
+
+func (p *parser) parseTableFactor() (TableExpr, error) {
+	p.acceptWord("LATERAL")
+	if p.acceptOp("(") {
+		if p.queryAhead() {
+			query, err := p.parseQuery()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			alias, err := p.parseTableAlias()
+			if err != nil {
+				return nil, err
+			}
+			return &DerivedTable{Query: query, Alias: alias}, nil
+		}
+		table, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		for p.acceptOp(",") {
+			right, err := p.parseTableRef()
+			if err != nil {
+				return nil, err
+			}
+			table = &Join{Kind: "CROSS JOIN", Left: table, Right: right}
+		}
+		if err = p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return table, nil
+	}
+
+	parts, err := p.parseQualifiedName()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("(") {
+		call, err := p.parseFuncCall(parts)
+		if err != nil {
+			return nil, err
+		}
+		alias, err := p.parseTableAlias()
+		if err != nil {
+			return nil, err
+		}
+		return &TableFunc{Call: call, Alias: alias}, nil
+	}
+
+	table := &TableName{Parts: parts}
+	if p.acceptWord("PARTITION") {
+		if _, err = p.parseNameList(); err != nil {
+			return nil, err
+		}
+	}
+	if table.Alias, err = p.parseTableAlias(); err != nil {
+		return nil, err
+	}
+	// Index hints of MySQL and table hints of SQL Server.
+	for p.isWord("USE", "IGNORE", "FORCE") {
+		p.next()
+		if !p.acceptWord("INDEX", "KEY") {
+			return nil, p.errorf("expected INDEX")
+		}
+		if p.acceptWord("FOR") {
+			if !p.acceptWord("JOIN") {
+				if !p.acceptWord("ORDER", "GROUP") {
+					return nil, p.errorf("expected JOIN, ORDER BY or GROUP BY")
+				}
+				if err = p.expectWord("BY"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if p.isOp("(") && p.peekAt(1).kind == tokOp && p.peekAt(1).text == ")" {
+			p.next()
+			p.next()
+			continue
+		}
+		if _, err = p.parseNameList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isWord("WITH") && p.peekAt(1).kind == tokOp && p.peekAt(1).text == "(" {
+		p.next()
+		if _, err = p.parseNameList(); err != nil {
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// parseTableAlias reads an optional table alias and its column names.
+func (p *parser) parseTableAlias() (string, error) {
+	alias, err := p.parseAlias()
+	if err != nil || alias == "" {
+		return alias, err
+	}
+	if p.isOp("(") {
+		if _, err = p.parseNameList(); err != nil {
+			return "", err
+		}
+	}
+	return alias, nil
+}
+
+func (p *parser) parseQualifiedName() ([]string, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	parts := []string{name}
+	for p.isOp(".") {
+		p.next()
+		tok := p.peek()
+		if tok.kind != tokWord && tok.kind != tokIdent {
+			return nil, p.errorf("expected a name")
+		}
+		p.next()
+		parts = append(parts, tok.text)
+	}
+	return parts, nil
+}
+
+func (p *parser) parseExprList() ([]Expr, error) {
+	exprs := make([]Expr, 0)
+	for {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.acceptOp(",") {
+			return exprs, nil
+		}
+	}
+}
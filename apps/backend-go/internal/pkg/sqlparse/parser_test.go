@@ -0,0 +1,55 @@
+package sqlparse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_AcceptsQueries(t *testing.T) {
+	queries := []string{
+		"SELECT * FROM core_dataset_group",
+		"select drop_date, update_time, `delete` from orders where created_by = 'x';",
+		"SELECT a.id, COUNT(DISTINCT b.id) AS n FROM a LEFT JOIN b ON a.id = b.a_id GROUP BY a.id HAVING COUNT(*) > 1 ORDER BY n DESC LIMIT 10, 20",
+		"WITH t AS (SELECT 1 AS x) SELECT x FROM t UNION ALL (SELECT 2) ORDER BY 1",
+		"SELECT 1--1",
+		"SELECT 1 -- trailing comment\n FROM dual",
+		"SELECT CASE WHEN a IS NOT NULL THEN 'y' ELSE 'n' END, CAST(b AS DECIMAL(10, 2)) FROM t WHERE c NOT IN (1, 2) AND d BETWEEN 1 AND 2",
+		"SELECT x::timestamp with time zone, y -> '$.a', TRIM(LEADING '0' FROM z), EXTRACT(YEAR FROM d) FROM t",
+		"SELECT GROUP_CONCAT(name ORDER BY name SEPARATOR ',') FROM t WHERE d > NOW() - INTERVAL 1 DAY",
+		"SELECT ROW_NUMBER() OVER (PARTITION BY a ORDER BY b ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) FROM t",
+		"SELECT TOP 10 * FROM dbo.t WITH (NOLOCK)",
+		"SELECT * FROM t OFFSET 10 ROWS FETCH NEXT 5 ROWS ONLY",
+		"SELECT * FROM t WHERE EXISTS (SELECT 1 FROM u WHERE u.id = t.id) AND name LIKE 'a%' ESCAPE '!'",
+		"SELECT _utf8mb4'text', DATE '2024-01-01', 'it''s'",
+	}
+	for _, query := range queries {
+		if _, err := Parse(query); err != nil {
+			t.Errorf("Parse(%q): %v", query, err)
+		}
+	}
+}
+
+func TestParse_RejectsWrites(t *testing.T) {
+	cases := map[string]string{
+		"":                                               "required",
+		"INSERT INTO x VALUES (1)":                       "only SELECT",
+		"SELECT 1; SELECT 2":                             "single statement",
+		"SELECT * FROM t;\nDROP TABLE t":                 "single statement",
+		"SELECT * FROM t\nDROP TABLE t":                  "single statement",
+		"SELECT * INTO OUTFILE '/tmp/x' FROM t":          "INTO",
+		"SELECT * FROM t INTO OUTFILE '/tmp/x'":          "INTO",
+		"SELECT * FROM t FOR UPDATE":                     "locking",
+		"SELECT * FROM t LOCK IN SHARE MODE":             "locking",
+		"SELECT 1 /*!50000 , SLEEP(5) */":                "executable comments",
+		"SELECT 'a\\' OR 1=1 -- '":                       "backslash",
+		"SELECT @a := 1":                                 "assignments",
+		"WITH d AS (DELETE FROM t RETURNING *) SELECT 1": "SELECT",
+		"SELECT 1 # comment":                             "# comments",
+	}
+	for query, want := range cases {
+		_, err := Parse(query)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q): expected error containing %q, got %v", query, want, err)
+		}
+	}
+}
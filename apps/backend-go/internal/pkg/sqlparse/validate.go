@@ -0,0 +1,116 @@
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDeniedFunctions are the functions rejected when no list is
+// configured: functions that block the connection, read or write server
+// files, run commands, reach other servers or change sequences.
+var DefaultDeniedFunctions = []string{
+	"sleep", "benchmark", "load_file", "get_lock", "release_lock", "release_all_locks",
+	"is_free_lock", "is_used_lock", "master_pos_wait", "source_pos_wait",
+	"wait_for_executed_gtid_set", "sys_exec", "sys_eval",
+	"pg_sleep", "pg_sleep_for", "pg_sleep_until", "pg_read_file", "pg_read_binary_file",
+	"pg_ls_dir", "pg_terminate_backend", "pg_cancel_backend", "lo_import", "lo_export",
+	"dblink", "dblink_exec",
+	"xp_cmdshell", "openrowset", "opendatasource", "openquery",
+	"dbms_lock.sleep", "dbms_pipe.receive_message", "utl_http.request",
+	"nextval", "setval",
+}
+
+// TableRef is a table read by a query. Qualifiers holds the database or
+// schema names written before the table name, if any.
+type TableRef struct {
+	Qualifiers []string
+	Name       string
+}
+
+func (t TableRef) String() string {
+	return strings.Join(append(append([]string{}, t.Qualifiers...), t.Name), ".")
+}
+
+// Result is a validated query with the tables and functions it references.
+// Tables excludes common table expressions.
+type Result struct {
+	Query     Query
+	Tables    []TableRef
+	Functions []string
+}
+
+// Validator checks that user SQL is a single read-only query that calls none
+// of the denied functions.
+type Validator struct {
+	denied map[string]bool
+}
+
+// NewValidator returns a validator rejecting the given functions, or
+// DefaultDeniedFunctions when the list is empty. A plain name denies the
+// function under any schema or package; a qualified name such as
+// dbms_lock.sleep only denies that qualified function.
+func NewValidator(denied []string) *Validator {
+	if len(denied) == 0 {
+		denied = DefaultDeniedFunctions
+	}
+	set := make(map[string]bool, len(denied))
+	for _, name := range denied {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			set[name] = true
+		}
+	}
+	return &Validator{denied: set}
+}
+
+// Validate parses sql and checks its functions.
+func (v *Validator) Validate(sql string) (*Result, error) {
+	query, err := Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Query: query}
+	ctes := make(map[string]bool)
+	Walk(query, func(node Node) bool {
+		if with, ok := node.(*With); ok {
+			for _, cte := range with.CTEs {
+				ctes[strings.ToLower(cte.Name)] = true
+			}
+		}
+		return true
+	})
+
+	seenTables := make(map[string]bool)
+	seenFuncs := make(map[string]bool)
+	Walk(query, func(node Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *TableName:
+			ref := TableRef{Qualifiers: n.Parts[:len(n.Parts)-1], Name: n.Name()}
+			if len(ref.Qualifiers) == 0 && (ctes[strings.ToLower(ref.Name)] || strings.EqualFold(ref.Name, "dual")) {
+				return true
+			}
+			if key := strings.ToLower(ref.String()); !seenTables[key] {
+				seenTables[key] = true
+				result.Tables = append(result.Tables, ref)
+			}
+		case *FuncCall:
+			name := strings.ToLower(strings.Join(n.Name, "."))
+			if v.denied[name] || v.denied[strings.ToLower(n.Name[len(n.Name)-1])] {
+				err = fmt.Errorf("function %s is not allowed", strings.Join(n.Name, "."))
+				return false
+			}
+			if !seenFuncs[name] {
+				seenFuncs[name] = true
+				result.Functions = append(result.Functions, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package sqlparse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidator_ListsTables(t *testing.T) {
+	result, err := NewValidator(nil).Validate(`WITH recent AS (SELECT * FROM orders WHERE d > ?)
+		SELECT r.id, c.name FROM recent r JOIN shop.customers c ON c.id = r.customer_id
+		WHERE c.id IN (SELECT customer_id FROM Orders) AND 1 = (SELECT 1 FROM dual)`)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	got := make([]string, 0, len(result.Tables))
+	for _, table := range result.Tables {
+		got = append(got, table.String())
+	}
+	if strings.Join(got, ",") != "orders,shop.customers" {
+		t.Fatalf("unexpected tables: %v", got)
+	}
+}
+
+func TestValidator_DeniedFunctions(t *testing.T) {
+	validator := NewValidator(nil)
+	for _, query := range []string{
+		"SELECT SLEEP(5)",
+		"SELECT * FROM t WHERE id = 1 AND sleep (5) = 0",
+		"SELECT * FROM t ORDER BY (SELECT BENCHMARK(1000000, MD5('x')))",
+		"SELECT dbms_lock.sleep(5) FROM dual",
+		"SELECT * FROM pg_read_file('/etc/passwd')",
+		"SELECT nextval('seq')",
+	} {
+		if _, err := validator.Validate(query); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("Validate(%q): expected denied function, got %v", query, err)
+		}
+	}
+	if _, err := validator.Validate("SELECT sleep_minutes, LOWER(name) FROM t"); err != nil {
+		t.Fatalf("expected column named like a function to pass, got %v", err)
+	}
+
+	// A qualified entry only denies the qualified function.
+	custom := NewValidator([]string{"util.secret", "md5"})
+	if _, err := custom.Validate("SELECT SLEEP(1), secret(1)"); err != nil {
+		t.Fatalf("expected configured list to replace the defaults, got %v", err)
+	}
+	for _, query := range []string{"SELECT util.secret(1)", "SELECT x.md5('a')"} {
+		if _, err := custom.Validate(query); err == nil {
+			t.Errorf("Validate(%q): expected denied function", query)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package tabular reads spreadsheet style uploads (XLSX and CSV) into plain
+// string grids, and writes tables to such files.
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheet is one worksheet of an uploaded file. Header holds the first non
+// empty row, Rows the remaining rows padded to the header width.
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]string
+}
+
+// IsSupported reports whether a file name has a readable extension.
+func IsSupported(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx", ".xlsm", ".csv":
+		return true
+	default:
+		return false
+	}
+}
+
+// Read parses the file according to its extension.
+func Read(fileName string, r io.Reader) ([]Sheet, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx", ".xlsm":
+		return readWorkbook(r)
+	case ".csv":
+		name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+		sheet, err := readCSV(name, r)
+		if err != nil {
+			return nil, err
+		}
+		return []Sheet{*sheet}, nil
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(fileName))
+	}
+}
+
+func readWorkbook(r io.Reader) ([]Sheet, error) {
+	book, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer book.Close()
+
+	result := make([]Sheet, 0)
+	for _, name := range book.GetSheetList() {
+		rows, rowsErr := book.GetRows(name)
+		if rowsErr != nil {
+			return nil, rowsErr
+		}
+		sheet, ok := buildSheet(name, rows)
+		if !ok {
+			continue
+		}
+		result = append(result, sheet)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("file does not contain any data")
+	}
+	return result, nil
+}
+
+func readCSV(name string, r io.Reader) (*Sheet, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(content) {
+		return nil, fmt.Errorf("csv file must be UTF-8 encoded")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	sheet, ok := buildSheet(name, rows)
+	if !ok {
+		return nil, fmt.Errorf("file does not contain any data")
+	}
+	return &sheet, nil
+}
+
+func buildSheet(name string, rows [][]string) (Sheet, bool) {
+	start := 0
+	for start < len(rows) && isBlankRow(rows[start]) {
+		start++
+	}
+	if start == len(rows) {
+		return Sheet{}, false
+	}
+
+	header := make([]string, len(rows[start]))
+	for i, cell := range rows[start] {
+		header[i] = strings.TrimSpace(cell)
+	}
+	width := len(header)
+	body := make([][]string, 0, len(rows)-start-1)
+	for _, row := range rows[start+1:] {
+		if isBlankRow(row) {
+			continue
+		}
+		if len(row) > width {
+			width = len(row)
+		}
+		body = append(body, row)
+	}
+
+	for len(header) < width {
+		header = append(header, "")
+	}
+	for i, row := range body {
+		if len(row) < width {
+			padded := make([]string, width)
+			copy(padded, row)
+			body[i] = padded
+		}
+	}
+	return Sheet{Name: name, Header: header, Rows: body}, true
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
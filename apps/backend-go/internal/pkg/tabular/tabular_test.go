@@ -0,0 +1,104 @@
+package tabular
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestRead_CSV(t *testing.T) {
+	content := "\xef\xbb\xbfname,amount\n\nalice,1\nbob,2,extra\n"
+	sheets, err := Read("orders.csv", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sheets) != 1 || sheets[0].Name != "orders" {
+		t.Fatalf("unexpected sheets: %+v", sheets)
+	}
+	sheet := sheets[0]
+	if len(sheet.Header) != 3 || sheet.Header[0] != "name" || sheet.Header[2] != "" {
+		t.Fatalf("unexpected header: %v", sheet.Header)
+	}
+	if len(sheet.Rows) != 2 || len(sheet.Rows[0]) != 3 || sheet.Rows[1][2] != "extra" {
+		t.Fatalf("unexpected rows: %v", sheet.Rows)
+	}
+}
+
+func TestRead_Workbook(t *testing.T) {
+	book := excelize.NewFile()
+	_ = book.SetSheetRow("Sheet1", "A1", &[]interface{}{"id", "city"})
+	_ = book.SetSheetRow("Sheet1", "A2", &[]interface{}{1, "Paris"})
+	if _, err := book.NewSheet("Empty"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sheets, err := Read("report.xlsx", &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sheets) != 1 || sheets[0].Name != "Sheet1" {
+		t.Fatalf("expected only the non-empty sheet, got %+v", sheets)
+	}
+	if sheets[0].Rows[0][1] != "Paris" {
+		t.Fatalf("unexpected rows: %v", sheets[0].Rows)
+	}
+}
+
+func TestRead_Unsupported(t *testing.T) {
+	if _, err := Read("data.txt", strings.NewReader("x")); err == nil {
+		t.Fatal("expected unsupported file error")
+	}
+	if IsSupported("a.xls") {
+		t.Fatal("expected legacy xls to be unsupported")
+	}
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	when := time.Date(2024, 3, 15, 8, 30, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{"name", "amount", "day"},
+		{"alice, jr", int64(3), when},
+		{[]byte("bob"), 2.5, nil},
+	}
+	for _, ext := range []string{"csv", "xlsx"} {
+		var buf bytes.Buffer
+		w, err := NewWriter(ext, &buf)
+		if err != nil {
+			t.Fatalf("%s: NewWriter: %v", ext, err)
+		}
+		for _, row := range rows {
+			if err = w.WriteRow(row); err != nil {
+				t.Fatalf("%s: WriteRow: %v", ext, err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("%s: Close: %v", ext, err)
+		}
+
+		sheets, err := Read("out."+ext, &buf)
+		if err != nil {
+			t.Fatalf("%s: Read: %v", ext, err)
+		}
+		sheet := sheets[0]
+		if strings.Join(sheet.Header, "|") != "name|amount|day" {
+			t.Fatalf("%s: header = %v", ext, sheet.Header)
+		}
+		if len(sheet.Rows) != 2 || sheet.Rows[0][0] != "alice, jr" || sheet.Rows[0][1] != "3" || sheet.Rows[1][0] != "bob" || sheet.Rows[1][1] != "2.5" {
+			t.Fatalf("%s: rows = %v", ext, sheet.Rows)
+		}
+		if ext == "csv" && sheet.Rows[0][2] != "2024-03-15 08:30:00" {
+			t.Fatalf("csv: time = %q", sheet.Rows[0][2])
+		}
+	}
+
+	if _, err := NewWriter("pdf", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected unsupported type error")
+	}
+}
@@ -0,0 +1,126 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Writer writes a table row by row. Rows are not held in memory: CSV rows
+// go straight to the output and XLSX rows through the stream writer of
+// excelize, which spills to a temporary file once its buffer is full.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	// Close finishes the file; nothing is complete before it returns.
+	Close() error
+}
+
+// NewWriter returns a writer of the format named by ext, "csv" or "xlsx".
+func NewWriter(ext string, w io.Writer) (Writer, error) {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "csv":
+		// The byte order mark makes spreadsheet programs read the file as
+		// UTF-8.
+		if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case "xlsx":
+		book := excelize.NewFile()
+		stream, err := book.NewStreamWriter(book.GetSheetName(0))
+		if err != nil {
+			_ = book.Close()
+			return nil, err
+		}
+		return &xlsxWriter{out: w, book: book, stream: stream}, nil
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", ext)
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	c.record = c.record[:0]
+	for _, v := range values {
+		c.record = append(c.record, formatCell(v))
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type xlsxWriter struct {
+	out    io.Writer
+	book   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+	cells  []interface{}
+}
+
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	if x.rows >= excelize.TotalRows {
+		return fmt.Errorf("xlsx sheets hold at most %d rows", excelize.TotalRows)
+	}
+	x.rows++
+	x.cells = x.cells[:0]
+	for _, v := range values {
+		x.cells = append(x.cells, xlsxCell(v))
+	}
+	cell, err := excelize.CoordinatesToCellName(1, x.rows)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, x.cells)
+}
+
+func (x *xlsxWriter) Close() error {
+	err := x.stream.Flush()
+	if err == nil {
+		err = x.book.Write(x.out)
+	}
+	if closeErr := x.book.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// xlsxCell keeps numbers, booleans and times typed and writes other values
+// as text.
+func xlsxCell(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string, bool, time.Time,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	}
+	return formatCell(v)
+}
+
+func formatCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
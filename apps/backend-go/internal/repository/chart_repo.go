@@ -1,23 +1,27 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"regexp"
 
 	"dataease/backend/internal/domain/chart"
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
 
 	"gorm.io/gorm"
 )
 
-var chartTableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
-
 type ChartRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	conns *dsconn.Manager
 }
 
-func NewChartRepository(db *gorm.DB) *ChartRepository {
-	return &ChartRepository{db: db}
+func NewChartRepository(db *gorm.DB, conns *dsconn.Manager) *ChartRepository {
+	return &ChartRepository{db: db, conns: conns}
 }
 
 func (r *ChartRepository) GetByID(id int64) (*chart.CoreChartView, error) {
@@ -33,7 +37,10 @@ func (r *ChartRepository) Update(view *chart.CoreChartView) error {
 	return r.db.Save(view).Error
 }
 
-func (r *ChartRepository) QueryRows(chartID int64, limit int) ([]map[string]interface{}, int64, error) {
+// QueryRows reads the dataset of a chart with its converted and calculated
+// fields. values bind the variables of a custom SQL table; masks, when set,
+// give the column permissions hiding and masking fields of the dataset.
+func (r *ChartRepository) QueryRows(ctx context.Context, chartID int64, limit int, values *datasetsql.Values, masks permission.MaskSource) ([]map[string]interface{}, int64, error) {
 	if limit < 1 {
 		limit = 100
 	}
@@ -49,35 +56,84 @@ func (r *ChartRepository) QueryRows(chartID int64, limit int) ([]map[string]inte
 		return nil, 0, fmt.Errorf("chart does not bind dataset table")
 	}
 
-	var dsTable struct {
-		TableName string `gorm:"column:table_name"`
+	var dsTable dataset.CoreDatasetTable
+	if err = r.db.Where("id = ?", *view.TableID).First(&dsTable).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// A chart on a dataset with a model reads the compiled model, otherwise
+	// it reads the table it is bound to.
+	var def *dataset.Definition
+	if dsTable.DatasetGroupID > 0 {
+		if def, err = loadDatasetDefinition(r.db, dsTable.DatasetGroupID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, err
+		}
+	}
+	var datasourceID int64
+	if def != nil && def.Model != nil {
+		datasourceID = def.DatasourceID()
+	} else if dsTable.DatasourceID != nil {
+		datasourceID = *dsTable.DatasourceID
 	}
-	err = r.db.Table("core_dataset_table").
-		Select("table_name").
-		Where("id = ?", *view.TableID).
-		First(&dsTable).Error
+
+	var ds datasource.CoreDatasource
+	err = r.db.Model(&datasource.CoreDatasource{}).
+		Where("id = ? AND COALESCE(del_flag, 0) = 0", datasourceID).
+		First(&ds).Error
 	if err != nil {
 		return nil, 0, err
 	}
-	if dsTable.TableName == "" || !chartTableNamePattern.MatchString(dsTable.TableName) {
-		return nil, 0, fmt.Errorf("invalid dataset table name")
+	conn, err := r.conns.Get(&ds)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	rows := make([]map[string]interface{}, 0)
-	querySQL := fmt.Sprintf("SELECT * FROM `%s` LIMIT ?", dsTable.TableName)
-	if err = r.db.Raw(querySQL, limit).Scan(&rows).Error; err != nil {
+	// Calculated fields of the chart are selected with those of the dataset.
+	fields, err := r.ListDatasetFieldsByChart(chartID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if def != nil {
+		fields = append(def.Fields, fields...)
+	}
+	var source *datasetsql.Source
+	if def != nil && def.Model != nil {
+		def.Fields = fields
+		source, err = datasetsql.Compile(conn, def, values)
+	} else if source, err = datasetsql.ForTable(conn, &dsTable, values); err == nil {
+		source = datasetsql.WithFields(conn, source, datasetsql.TableFields(fields, dsTable.ID))
+	}
+	if err != nil {
 		return nil, 0, err
 	}
 
-	var countResult struct {
-		C int64 `gorm:"column:c"`
+	if def != nil && def.Model != nil {
+		ctx = dsconn.WithDatasetTables(ctx, def.TableIDs()...)
+	} else {
+		ctx = dsconn.WithDatasetTables(ctx, dsTable.ID)
 	}
-	countSQL := fmt.Sprintf("SELECT COUNT(1) AS c FROM `%s`", dsTable.TableName)
-	if err = r.db.Raw(countSQL).Scan(&countResult).Error; err != nil {
+	args := append(append([]interface{}{}, source.Args...), limit)
+	rows, err := conn.QueryRowsContext(ctx, conn.Limit("SELECT * FROM "+source.From, false), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := conn.QueryCountContext(ctx, "SELECT COUNT(1) FROM "+source.From, source.Args...)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	return rows, countResult.C, nil
+	if masks != nil && dsTable.DatasetGroupID > 0 {
+		columnMasks, maskErr := masks(dsTable.DatasetGroupID)
+		if maskErr != nil {
+			return nil, 0, maskErr
+		}
+		columns := make(map[int64]string, len(fields))
+		for _, field := range fields {
+			columns[field.ID] = source.Column(field)
+		}
+		columnMasks.Derive(datasetsql.FormulaRefs(fields)).Rows(rows, columns)
+	}
+	return rows, total, nil
 }
 
 func (r *ChartRepository) ListDatasetFieldsByGroup(datasetGroupID int64) ([]*dataset.CoreDatasetTableField, error) {
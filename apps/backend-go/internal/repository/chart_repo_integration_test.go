@@ -15,7 +15,7 @@ func TestChartRepository_GetByID(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_chart_view")
 
 	c := &chart.CoreChartView{
@@ -41,7 +41,7 @@ func TestChartRepository_Update(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_chart_view")
 
 	c := &chart.CoreChartView{
@@ -69,7 +69,7 @@ func TestChartRepository_CreateAndGetDatasetField(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_dataset_table_field")
 
 	field := &dataset.CoreDatasetTableField{
@@ -110,7 +110,7 @@ func TestChartRepository_ListDatasetFieldsByGroup(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_dataset_table_field")
 
 	for i := 1; i <= 3; i++ {
@@ -142,7 +142,7 @@ func TestChartRepository_CountDatasetFieldName(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_dataset_table_field")
 
 	field := &dataset.CoreDatasetTableField{
@@ -171,7 +171,7 @@ func TestChartRepository_UpdateDatasetFieldNames(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_dataset_table_field")
 
 	field := &dataset.CoreDatasetTableField{
@@ -197,7 +197,7 @@ func TestChartRepository_DeleteDatasetField(t *testing.T) {
 		t.Skip("Test database not available")
 	}
 
-	repo := NewChartRepository(testDB)
+	repo := NewChartRepository(testDB, nil)
 	cleanupTables("core_dataset_table_field")
 
 	field := &dataset.CoreDatasetTableField{
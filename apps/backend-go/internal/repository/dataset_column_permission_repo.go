@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"dataease/backend/internal/domain/permission"
+)
+
+// ListColumnPermissions returns the column rules of a dataset by field.
+func (r *DatasetRepository) ListColumnPermissions(datasetGroupID int64) ([]*permission.CoreColumnPermission, error) {
+	list := make([]*permission.CoreColumnPermission, 0)
+	err := r.db.Where("dataset_group_id = ?", datasetGroupID).
+		Order("field_id ASC, id ASC").
+		Find(&list).Error
+	return list, err
+}
+
+// ListColumnPermissionsFor returns the column rules of a dataset applying to
+// a user directly or through one of their roles or organizations.
+func (r *DatasetRepository) ListColumnPermissionsFor(datasetGroupID, userID int64, roleIDs, orgIDs []int64) ([]*permission.CoreColumnPermission, error) {
+	targets := r.db.Where("target_type = ? AND target_id = ?", permission.TargetUser, userID)
+	if len(roleIDs) > 0 {
+		targets = targets.Or("target_type = ? AND target_id IN ?", permission.TargetRole, roleIDs)
+	}
+	if len(orgIDs) > 0 {
+		targets = targets.Or("target_type = ? AND target_id IN ?", permission.TargetOrg, orgIDs)
+	}
+	list := make([]*permission.CoreColumnPermission, 0)
+	err := r.db.Where("dataset_group_id = ?", datasetGroupID).
+		Where(targets).
+		Order("id ASC").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *DatasetRepository) GetColumnPermission(id int64) (*permission.CoreColumnPermission, error) {
+	var rule permission.CoreColumnPermission
+	if err := r.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *DatasetRepository) SaveColumnPermission(rule *permission.CoreColumnPermission) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *DatasetRepository) DeleteColumnPermission(id int64) error {
+	return r.db.Where("id = ?", id).Delete(&permission.CoreColumnPermission{}).Error
+}
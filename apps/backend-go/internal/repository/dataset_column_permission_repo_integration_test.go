@@ -0,0 +1,53 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/permission"
+)
+
+func TestDatasetRepository_ColumnPermissions(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_column_permission")
+
+	rules := []*permission.CoreColumnPermission{
+		{DatasetGroupID: 900, FieldID: 1, TargetType: permission.TargetUser, TargetID: 5, RuleType: permission.ColumnRuleHide},
+		{DatasetGroupID: 900, FieldID: 2, TargetType: permission.TargetRole, TargetID: 7, RuleType: permission.ColumnRuleFull},
+		{DatasetGroupID: 900, FieldID: 3, TargetType: permission.TargetOrg, TargetID: 9, RuleType: permission.ColumnRuleFull},
+		{DatasetGroupID: 900, FieldID: 4, TargetType: permission.TargetUser, TargetID: 6, RuleType: permission.ColumnRuleFull},
+		{DatasetGroupID: 901, FieldID: 5, TargetType: permission.TargetUser, TargetID: 5, RuleType: permission.ColumnRuleFull},
+	}
+	for _, rule := range rules {
+		if err := repo.SaveColumnPermission(rule); err != nil {
+			t.Fatalf("SaveColumnPermission failed: %v", err)
+		}
+	}
+
+	list, err := repo.ListColumnPermissions(900)
+	if err != nil || len(list) != 4 {
+		t.Fatalf("ListColumnPermissions = %d rules, %v", len(list), err)
+	}
+
+	list, err = repo.ListColumnPermissionsFor(900, 5, []int64{7}, []int64{9})
+	if err != nil || len(list) != 3 {
+		t.Fatalf("rules of the user, their role and their org should apply, got %+v, %v", list, err)
+	}
+	list, err = repo.ListColumnPermissionsFor(900, 5, nil, nil)
+	if err != nil || len(list) != 1 || list[0].FieldID != 1 {
+		t.Fatalf("only the rule of the user should apply, got %+v, %v", list, err)
+	}
+
+	if err = repo.DeleteColumnPermission(rules[0].ID); err != nil {
+		t.Fatalf("DeleteColumnPermission failed: %v", err)
+	}
+	if _, err = repo.GetColumnPermission(rules[0].ID); err == nil {
+		t.Fatal("expected deleted rule to be gone")
+	}
+}
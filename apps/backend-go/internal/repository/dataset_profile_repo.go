@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"errors"
+
+	"dataease/backend/internal/domain/dataset"
+
+	"gorm.io/gorm"
+)
+
+// GetProfile returns nil when the dataset has never been profiled.
+func (r *DatasetRepository) GetProfile(datasetGroupID int64) (*dataset.CoreDatasetProfile, error) {
+	var profile dataset.CoreDatasetProfile
+	err := r.db.Where("dataset_group_id = ?", datasetGroupID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *DatasetRepository) SaveProfile(profile *dataset.CoreDatasetProfile) error {
+	return r.db.Save(profile).Error
+}
@@ -0,0 +1,35 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func TestDatasetRepository_Profile(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_profile")
+
+	profile, err := repo.GetProfile(900)
+	if err != nil || profile != nil {
+		t.Fatalf("GetProfile of unprofiled dataset = %+v, %v", profile, err)
+	}
+	if err = repo.SaveProfile(&dataset.CoreDatasetProfile{DatasetGroupID: 900, Status: dataset.ProfileRunning}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+	if err = repo.SaveProfile(&dataset.CoreDatasetProfile{DatasetGroupID: 900, Status: dataset.ProfileSuccess, Result: `{"rowCount":3}`}); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	profile, err = repo.GetProfile(900)
+	if err != nil || profile == nil || profile.Status != dataset.ProfileSuccess || profile.Result != `{"rowCount":3}` {
+		t.Fatalf("GetProfile = %+v, %v", profile, err)
+	}
+}
@@ -1,11 +1,15 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
 
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
 
 	"gorm.io/gorm"
 )
@@ -85,7 +89,64 @@ func (r *DatasetRepository) ListTablesByDatasetGroupID(datasetGroupID int64) ([]
 	return tables, err
 }
 
-func (r *DatasetRepository) PreviewSQL(rawSQL string, limit int) ([]map[string]interface{}, error) {
+// ListGroupIDsByDatasources returns the datasets with a table reading from
+// one of the datasources.
+func (r *DatasetRepository) ListGroupIDsByDatasources(datasourceIDs []int64) ([]int64, error) {
+	var ids []int64
+	if len(datasourceIDs) == 0 {
+		return ids, nil
+	}
+	err := r.db.Model(&dataset.CoreDatasetTable{}).
+		Where("datasource_id IN ? AND dataset_group_id > 0", datasourceIDs).
+		Distinct("dataset_group_id").
+		Order("dataset_group_id ASC").
+		Pluck("dataset_group_id", &ids).Error
+	return ids, err
+}
+
+// ReplaceContent replaces the tables and fields of a dataset. The IDs of the
+// given tables are only used to link the fields to them: every row gets a
+// new ID. Fields owned by charts are kept.
+func (r *DatasetRepository) ReplaceContent(datasetGroupID int64, tables []*dataset.CoreDatasetTable, fields []*dataset.CoreDatasetTableField) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dataset_group_id = ? AND chart_id IS NULL", datasetGroupID).
+			Delete(&dataset.CoreDatasetTableField{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_group_id = ?", datasetGroupID).
+			Delete(&dataset.CoreDatasetTable{}).Error; err != nil {
+			return err
+		}
+
+		tableIDs := make(map[int64]int64, len(tables))
+		for _, table := range tables {
+			sourceID := table.ID
+			table.ID = 0
+			table.DatasetGroupID = datasetGroupID
+			if err := tx.Create(table).Error; err != nil {
+				return err
+			}
+			tableIDs[sourceID] = table.ID
+		}
+		for _, field := range fields {
+			field.ID = 0
+			field.DatasetGroupID = datasetGroupID
+			if field.DatasetTableID != nil {
+				tableID, ok := tableIDs[*field.DatasetTableID]
+				if !ok {
+					return fmt.Errorf("field %d references an unknown table", *field.DatasetTableID)
+				}
+				field.DatasetTableID = &tableID
+			}
+			if err := tx.Create(field).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *DatasetRepository) PreviewSQL(ctx context.Context, conn *dsconn.Conn, rawSQL string, args []interface{}, limit int) ([]map[string]interface{}, error) {
 	if limit < 1 {
 		limit = 100
 	}
@@ -93,12 +154,8 @@ func (r *DatasetRepository) PreviewSQL(rawSQL string, limit int) ([]map[string]i
 		limit = 500
 	}
 
-	rows := make([]map[string]interface{}, 0)
-	query := fmt.Sprintf("SELECT * FROM (%s) AS de_preview LIMIT ?", rawSQL)
-	if err := r.db.Raw(query, limit).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-	return rows, nil
+	query := conn.Limit(fmt.Sprintf("SELECT * FROM (%s) de_preview", rawSQL), false)
+	return conn.QueryRowsContext(ctx, query, append(append([]interface{}{}, args...), limit)...)
 }
 
 func (r *DatasetRepository) CountChartRelations(datasetGroupID int64) (int64, error) {
@@ -110,6 +167,21 @@ func (r *DatasetRepository) CountChartRelations(datasetGroupID int64) (int64, er
 	return count, err
 }
 
+// LastSyncTask returns the sync task of the given datasources that ran
+// last, or nil when none has run.
+func (r *DatasetRepository) LastSyncTask(datasourceIDs []int64) (*datasource.CoreDatasourceTask, error) {
+	if len(datasourceIDs) == 0 {
+		return nil, nil
+	}
+	var tasks []*datasource.CoreDatasourceTask
+	err := r.db.Where("ds_id IN ? AND last_exec_time > 0", datasourceIDs).
+		Order("last_exec_time DESC").Limit(1).Find(&tasks).Error
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+	return tasks[0], nil
+}
+
 func (r *DatasetRepository) ListFields(datasetGroupID int64) ([]*dataset.CoreDatasetTableField, error) {
 	var fields []*dataset.CoreDatasetTableField
 	err := r.db.Model(&dataset.CoreDatasetTableField{}).
@@ -128,6 +200,14 @@ func (r *DatasetRepository) GetFieldByID(id int64) (*dataset.CoreDatasetTableFie
 	return &field, nil
 }
 
+// SaveField creates a field or updates all of its columns.
+func (r *DatasetRepository) SaveField(field *dataset.CoreDatasetTableField) error {
+	if field.ID == 0 {
+		return r.db.Create(field).Error
+	}
+	return r.db.Save(field).Error
+}
+
 func (r *DatasetRepository) GetTableByID(id int64) (*dataset.CoreDatasetTable, error) {
 	var table dataset.CoreDatasetTable
 	err := r.db.Model(&dataset.CoreDatasetTable{}).Where("id = ?", id).First(&table).Error
@@ -137,53 +217,113 @@ func (r *DatasetRepository) GetTableByID(id int64) (*dataset.CoreDatasetTable, e
 	return &table, nil
 }
 
-func (r *DatasetRepository) FindPrimaryTableName(datasetGroupID int64) (string, error) {
-	var table dataset.CoreDatasetTable
-	err := r.db.Model(&dataset.CoreDatasetTable{}).
+// GetDefinition loads the group, model, tables and dataset fields that the
+// queries on a dataset are compiled from.
+func (r *DatasetRepository) GetDefinition(datasetGroupID int64) (*dataset.Definition, error) {
+	return loadDatasetDefinition(r.db, datasetGroupID)
+}
+
+func loadDatasetDefinition(db *gorm.DB, datasetGroupID int64) (*dataset.Definition, error) {
+	var group dataset.CoreDatasetGroup
+	err := db.Model(&dataset.CoreDatasetGroup{}).
+		Where("id = ? AND COALESCE(del_flag, 0) = 0", datasetGroupID).
+		First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	model, err := dataset.ParseModel(group.Info)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &dataset.Definition{Group: &group, Model: model}
+	err = db.Model(&dataset.CoreDatasetTable{}).
 		Where("dataset_group_id = ?", datasetGroupID).
 		Order("id ASC").
-		First(&table).Error
+		Find(&def.Tables).Error
 	if err != nil {
-		return "", err
-	}
-	if table.PhysicalTable == nil || *table.PhysicalTable == "" {
-		return "", fmt.Errorf("dataset table_name is empty")
+		return nil, err
 	}
-	if !tableNamePattern.MatchString(*table.PhysicalTable) {
-		return "", fmt.Errorf("invalid dataset table name")
+	err = db.Model(&dataset.CoreDatasetTableField{}).
+		Where("dataset_group_id = ? AND chart_id IS NULL", datasetGroupID).
+		Order("id ASC").
+		Find(&def.Fields).Error
+	if err != nil {
+		return nil, err
 	}
-	return *table.PhysicalTable, nil
+	return def, nil
 }
 
-func (r *DatasetRepository) PreviewRows(tableName string, limit int) ([]map[string]interface{}, error) {
-	if !tableNamePattern.MatchString(tableName) {
-		return nil, fmt.Errorf("invalid table name")
+// Transaction runs fn with a repository whose writes are committed together
+// once fn returns nil, and rolled back otherwise.
+func (r *DatasetRepository) Transaction(fn func(repo *DatasetRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&DatasetRepository{db: tx})
+	})
+}
+
+// CreateTable adds a table to a dataset together with its fields.
+func (r *DatasetRepository) CreateTable(table *dataset.CoreDatasetTable, fields []*dataset.CoreDatasetTableField) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(table).Error; err != nil {
+			return err
+		}
+		for _, field := range fields {
+			field.DatasetTableID = &table.ID
+			field.DatasetGroupID = table.DatasetGroupID
+			if err := tx.Create(field).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTablesExcept removes the tables of a dataset that are not kept, with
+// their dataset fields.
+func (r *DatasetRepository) DeleteTablesExcept(datasetGroupID int64, keep []int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		q := tx.Model(&dataset.CoreDatasetTable{}).Where("dataset_group_id = ?", datasetGroupID)
+		if len(keep) > 0 {
+			q = q.Where("id NOT IN ?", keep)
+		}
+		if err := q.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("dataset_table_id IN ? AND chart_id IS NULL", ids).
+			Delete(&dataset.CoreDatasetTableField{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&dataset.CoreDatasetTable{}).Error
+	})
+}
+
+func (r *DatasetRepository) PreviewRows(ctx context.Context, conn *dsconn.Conn, tableName string, limit int) ([]map[string]interface{}, error) {
+	source, err := datasetsql.Table(conn, tableName)
+	if err != nil {
+		return nil, err
 	}
+	return r.PreviewSource(ctx, conn, source, limit)
+}
+
+// PreviewSource returns the first rows of a dataset source.
+func (r *DatasetRepository) PreviewSource(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, limit int) ([]map[string]interface{}, error) {
 	if limit < 1 {
 		limit = 100
 	}
 	if limit > 500 {
 		limit = 500
 	}
-
-	rows := make([]map[string]interface{}, 0)
-	sql := fmt.Sprintf("SELECT * FROM `%s` LIMIT ?", tableName)
-	if err := r.db.Raw(sql, limit).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-	return rows, nil
+	args := append(append([]interface{}{}, source.Args...), limit)
+	return conn.QueryRowsContext(ctx, conn.Limit("SELECT * FROM "+source.From, false), args...)
 }
 
-func (r *DatasetRepository) QueryDistinctValues(tableName string, columnName string, filters []dataset.EnumFilterClause, limit int) ([]string, error) {
-	if !tableNamePattern.MatchString(tableName) {
-		return nil, fmt.Errorf("invalid table name")
-	}
-
-	quotedTable, err := quoteIdentifier(tableName)
-	if err != nil {
-		return nil, err
-	}
-	quotedColumn, err := quoteIdentifier(columnName)
+func (r *DatasetRepository) QueryDistinctValues(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, columnName string, filters []dataset.EnumFilterClause, limit int) ([]string, error) {
+	quotedColumn, err := quoteIdentifier(conn, columnName)
 	if err != nil {
 		return nil, err
 	}
@@ -191,16 +331,18 @@ func (r *DatasetRepository) QueryDistinctValues(tableName string, columnName str
 	query := strings.Builder{}
 	query.WriteString("SELECT DISTINCT ")
 	query.WriteString(quotedColumn)
-	query.WriteString(" AS `de_value` FROM ")
-	query.WriteString(quotedTable)
+	query.WriteString(" AS ")
+	query.WriteString(conn.QuoteIdentifier("de_value"))
+	query.WriteString(" FROM ")
+	query.WriteString(source.From)
 
-	args := make([]interface{}, 0)
+	args := append([]interface{}{}, source.Args...)
 	whereParts := make([]string, 0)
 	for _, filter := range filters {
 		if strings.TrimSpace(filter.Column) == "" || len(filter.Values) == 0 {
 			continue
 		}
-		quotedFilterColumn, quoteErr := quoteIdentifier(filter.Column)
+		quotedFilterColumn, quoteErr := quoteIdentifier(conn, filter.Column)
 		if quoteErr != nil {
 			continue
 		}
@@ -219,49 +361,40 @@ func (r *DatasetRepository) QueryDistinctValues(tableName string, columnName str
 	query.WriteString(" ORDER BY ")
 	query.WriteString(quotedColumn)
 	query.WriteString(" ASC")
+	querySQL := query.String()
 	if limit > 0 {
-		query.WriteString(" LIMIT ?")
+		querySQL = conn.Limit(querySQL, true)
 		args = append(args, limit)
 	}
 
-	type row struct {
-		Value *string `gorm:"column:de_value"`
-	}
-	rows := make([]row, 0)
-	if err = r.db.Raw(query.String(), args...).Scan(&rows).Error; err != nil {
+	rows, err := conn.QueryRowsContext(ctx, querySQL, args...)
+	if err != nil {
 		return nil, err
 	}
 
 	result := make([]string, 0, len(rows))
 	for _, item := range rows {
-		if item.Value == nil {
+		value := item["de_value"]
+		if value == nil {
 			continue
 		}
-		result = append(result, *item.Value)
+		result = append(result, fmt.Sprint(value))
 	}
 	return result, nil
 }
 
-func (r *DatasetRepository) QueryDistinctObjectValues(tableName string, columns []dataset.EnumObjectColumn, filters []dataset.EnumFilterClause, searchColumn string, searchText string, sortColumn string, sortDirection string, limit int) ([]map[string]interface{}, error) {
-	if !tableNamePattern.MatchString(tableName) {
-		return nil, fmt.Errorf("invalid table name")
-	}
+func (r *DatasetRepository) QueryDistinctObjectValues(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, columns []dataset.EnumObjectColumn, filters []dataset.EnumFilterClause, searchColumn string, searchText string, sortColumn string, sortDirection string, limit int) ([]map[string]interface{}, error) {
 	if len(columns) == 0 {
 		return []map[string]interface{}{}, nil
 	}
 
-	quotedTable, err := quoteIdentifier(tableName)
-	if err != nil {
-		return nil, err
-	}
-
 	selectParts := make([]string, 0, len(columns))
 	for _, column := range columns {
-		quotedColumn, quoteErr := quoteIdentifier(column.Column)
+		quotedColumn, quoteErr := quoteIdentifier(conn, column.Column)
 		if quoteErr != nil {
 			return nil, quoteErr
 		}
-		quotedAlias, quoteErr := quoteIdentifier(column.Alias)
+		quotedAlias, quoteErr := quoteIdentifier(conn, column.Alias)
 		if quoteErr != nil {
 			return nil, quoteErr
 		}
@@ -272,15 +405,15 @@ func (r *DatasetRepository) QueryDistinctObjectValues(tableName string, columns
 	query.WriteString("SELECT DISTINCT ")
 	query.WriteString(strings.Join(selectParts, ", "))
 	query.WriteString(" FROM ")
-	query.WriteString(quotedTable)
+	query.WriteString(source.From)
 
-	args := make([]interface{}, 0)
+	args := append([]interface{}{}, source.Args...)
 	whereParts := make([]string, 0)
 	for _, filter := range filters {
 		if strings.TrimSpace(filter.Column) == "" || len(filter.Values) == 0 {
 			continue
 		}
-		quotedFilterColumn, quoteErr := quoteIdentifier(filter.Column)
+		quotedFilterColumn, quoteErr := quoteIdentifier(conn, filter.Column)
 		if quoteErr != nil {
 			continue
 		}
@@ -293,7 +426,7 @@ func (r *DatasetRepository) QueryDistinctObjectValues(tableName string, columns
 	}
 
 	if strings.TrimSpace(searchText) != "" && strings.TrimSpace(searchColumn) != "" {
-		quotedSearchColumn, quoteErr := quoteIdentifier(searchColumn)
+		quotedSearchColumn, quoteErr := quoteIdentifier(conn, searchColumn)
 		if quoteErr == nil {
 			whereParts = append(whereParts, fmt.Sprintf("%s LIKE ?", quotedSearchColumn))
 			args = append(args, "%"+strings.TrimSpace(searchText)+"%")
@@ -305,9 +438,11 @@ func (r *DatasetRepository) QueryDistinctObjectValues(tableName string, columns
 		query.WriteString(strings.Join(whereParts, " AND "))
 	}
 
+	ordered := false
 	if strings.TrimSpace(sortColumn) != "" {
-		quotedSortColumn, quoteErr := quoteIdentifier(sortColumn)
+		quotedSortColumn, quoteErr := quoteIdentifier(conn, sortColumn)
 		if quoteErr == nil {
+			ordered = true
 			direction := strings.ToUpper(strings.TrimSpace(sortDirection))
 			if direction != "DESC" {
 				direction = "ASC"
@@ -318,33 +453,66 @@ func (r *DatasetRepository) QueryDistinctObjectValues(tableName string, columns
 			query.WriteString(direction)
 		}
 	}
+	querySQL := query.String()
 	if limit > 0 {
-		query.WriteString(" LIMIT ?")
+		querySQL = conn.Limit(querySQL, ordered)
 		args = append(args, limit)
 	}
 
-	rows := make([]map[string]interface{}, 0)
-	if err = r.db.Raw(query.String(), args...).Scan(&rows).Error; err != nil {
-		return nil, err
+	return conn.QueryRowsContext(ctx, querySQL, args...)
+}
+
+func (r *DatasetRepository) CountRows(ctx context.Context, conn *dsconn.Conn, tableName string) (int64, error) {
+	source, err := datasetsql.Table(conn, tableName)
+	if err != nil {
+		return 0, err
 	}
-	return rows, nil
+	return r.CountSource(ctx, conn, source)
+}
+
+// CountSource counts the rows of a dataset source.
+func (r *DatasetRepository) CountSource(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source) (int64, error) {
+	return conn.QueryCountContext(ctx, "SELECT COUNT(1) FROM "+source.From, source.Args...)
 }
 
-func (r *DatasetRepository) CountRows(tableName string) (int64, error) {
-	if !tableNamePattern.MatchString(tableName) {
-		return 0, fmt.Errorf("invalid table name")
+// CountWhere counts the rows of a dataset source matching a condition
+// compiled by datasetsql.Where.
+func (r *DatasetRepository) CountWhere(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, where string, whereArgs []interface{}) (int64, error) {
+	query := "SELECT COUNT(1) FROM " + source.From
+	if where != "" {
+		query += " WHERE " + where
 	}
-	var result struct {
-		C int64 `gorm:"column:c"`
+	return conn.QueryCountContext(ctx, query, append(append([]interface{}{}, source.Args...), whereArgs...)...)
+}
+
+// EachRow reads columns of a dataset source matching a condition, at most
+// limit rows when limit is positive, and calls fn with every row as it is
+// read.
+func (r *DatasetRepository) EachRow(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, columns []string, where string, whereArgs []interface{}, limit int64, fn func(values []interface{}) error) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns to read")
 	}
-	sql := fmt.Sprintf("SELECT COUNT(1) AS c FROM `%s`", tableName)
-	if err := r.db.Raw(sql).Scan(&result).Error; err != nil {
-		return 0, err
+	selectParts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted, err := quoteIdentifier(conn, column)
+		if err != nil {
+			return err
+		}
+		selectParts = append(selectParts, quoted)
+	}
+	query := "SELECT " + strings.Join(selectParts, ", ") + " FROM " + source.From
+	args := append(append([]interface{}{}, source.Args...), whereArgs...)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	if limit > 0 {
+		query = conn.Limit(query, false)
+		args = append(args, limit)
 	}
-	return result.C, nil
+	return conn.QueryEachContext(ctx, query, fn, args...)
 }
 
-func quoteIdentifier(name string) (string, error) {
+func quoteIdentifier(conn *dsconn.Conn, name string) (string, error) {
 	trimmed := strings.TrimSpace(name)
 	if trimmed == "" {
 		return "", fmt.Errorf("invalid identifier")
@@ -352,5 +520,5 @@ func quoteIdentifier(name string) (string, error) {
 	if strings.ContainsRune(trimmed, 0) {
 		return "", fmt.Errorf("invalid identifier")
 	}
-	return "`" + strings.ReplaceAll(trimmed, "`", "``") + "`", nil
+	return conn.QuoteIdentifier(trimmed), nil
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
 )
 
 func TestDatasetRepository_CreateAndGetGroupByID(t *testing.T) {
@@ -291,3 +292,115 @@ func TestDatasetRepository_GetTableByID(t *testing.T) {
 		t.Errorf("Expected Name 'Test Table', got '%s'", *found.Name)
 	}
 }
+
+func TestDatasetRepository_ReplaceContent(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_group", "core_dataset_table", "core_dataset_table_field")
+
+	group := &dataset.CoreDatasetGroup{Name: "Imported", NodeType: strPtr("dataset")}
+	if err := repo.CreateGroup(group); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	datasourceID := int64(7)
+	sourceTableID := int64(900)
+	tables := []*dataset.CoreDatasetTable{{ID: sourceTableID, DatasourceID: &datasourceID, PhysicalTable: strPtr("orders")}}
+	fields := []*dataset.CoreDatasetTableField{{ID: 901, DatasetTableID: &sourceTableID, OriginName: strPtr("amount")}}
+	if err := repo.ReplaceContent(group.ID, tables, fields); err != nil {
+		t.Fatalf("ReplaceContent failed: %v", err)
+	}
+
+	stored, err := repo.ListTablesByDatasetGroupID(group.ID)
+	if err != nil || len(stored) != 1 || stored[0].ID == sourceTableID {
+		t.Fatalf("expected one table with a new id, got %+v (%v)", stored, err)
+	}
+	storedFields, err := repo.ListFields(group.ID)
+	if err != nil || len(storedFields) != 1 || *storedFields[0].DatasetTableID != stored[0].ID {
+		t.Fatalf("expected the field linked to the new table, got %+v (%v)", storedFields, err)
+	}
+
+	ids, err := repo.ListGroupIDsByDatasources([]int64{datasourceID, 8})
+	if err != nil || len(ids) != 1 || ids[0] != group.ID {
+		t.Fatalf("expected dataset %d, got %v (%v)", group.ID, ids, err)
+	}
+
+	if err = repo.ReplaceContent(group.ID, nil, nil); err != nil {
+		t.Fatalf("ReplaceContent failed: %v", err)
+	}
+	if stored, _ = repo.ListTablesByDatasetGroupID(group.ID); len(stored) != 0 {
+		t.Fatalf("expected the tables to be replaced, got %+v", stored)
+	}
+}
+
+func TestDatasetRepository_ModelTables(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_group", "core_dataset_table", "core_dataset_table_field")
+
+	info := `{"union":[{"tableId":1,"datasourceId":7,"tableName":"orders"}]}`
+	group := &dataset.CoreDatasetGroup{Name: "Joined", NodeType: strPtr("dataset"), Info: &info}
+	if err := repo.CreateGroup(group); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	datasourceID := int64(7)
+	orders := &dataset.CoreDatasetTable{DatasetGroupID: group.ID, DatasourceID: &datasourceID, PhysicalTable: strPtr("orders")}
+	if err := repo.CreateTable(orders, []*dataset.CoreDatasetTableField{{OriginName: strPtr("amount")}}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	customers := &dataset.CoreDatasetTable{DatasetGroupID: group.ID, DatasourceID: &datasourceID, PhysicalTable: strPtr("customers")}
+	if err := repo.CreateTable(customers, []*dataset.CoreDatasetTableField{{OriginName: strPtr("name")}}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+
+	def, err := repo.GetDefinition(group.ID)
+	if err != nil {
+		t.Fatalf("GetDefinition failed: %v", err)
+	}
+	if def.Model == nil || len(def.Tables) != 2 || len(def.Fields) != 2 {
+		t.Fatalf("unexpected definition: %+v", def)
+	}
+
+	if err = repo.DeleteTablesExcept(group.ID, []int64{orders.ID}); err != nil {
+		t.Fatalf("DeleteTablesExcept failed: %v", err)
+	}
+	if def, err = repo.GetDefinition(group.ID); err != nil || len(def.Tables) != 1 || len(def.Fields) != 1 {
+		t.Fatalf("expected only the orders table to remain, got %+v (%v)", def, err)
+	}
+}
+
+func TestDatasetRepository_LastSyncTask(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_datasource_task")
+
+	for _, task := range []*datasource.CoreDatasourceTask{
+		{DsID: 10, Name: "never", LastExecTime: 0},
+		{DsID: 10, Name: "older", LastExecTime: 100, LastExecStatus: datasource.ExecStatusCompleted},
+		{DsID: 11, Name: "newer", LastExecTime: 200, LastExecStatus: datasource.ExecStatusError},
+		{DsID: 12, Name: "other", LastExecTime: 300},
+	} {
+		if err := testDB.Create(task).Error; err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+	}
+
+	task, err := repo.LastSyncTask([]int64{10, 11})
+	if err != nil {
+		t.Fatalf("LastSyncTask failed: %v", err)
+	}
+	if task == nil || task.Name != "newer" {
+		t.Errorf("Expected the newer task, got %+v", task)
+	}
+	if task, err = repo.LastSyncTask([]int64{13}); err != nil || task != nil {
+		t.Errorf("Expected no task, got %+v, %v", task, err)
+	}
+}
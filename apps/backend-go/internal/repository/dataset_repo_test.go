@@ -1,10 +1,13 @@
 package repository
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestPreviewRows_InvalidTableName(t *testing.T) {
 	repo := &DatasetRepository{}
-	_, err := repo.PreviewRows("core_dataset_table;drop table x", 10)
+	_, err := repo.PreviewRows(context.Background(), nil, "core_dataset_table;drop table x", 10)
 	if err == nil {
 		t.Fatal("expected invalid table name error")
 	}
@@ -12,7 +15,7 @@ func TestPreviewRows_InvalidTableName(t *testing.T) {
 
 func TestCountRows_InvalidTableName(t *testing.T) {
 	repo := &DatasetRepository{}
-	_, err := repo.CountRows("x` or 1=1 --")
+	_, err := repo.CountRows(context.Background(), nil, "x` or 1=1 --")
 	if err == nil {
 		t.Fatal("expected invalid table name error")
 	}
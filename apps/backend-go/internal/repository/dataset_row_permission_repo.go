@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"dataease/backend/internal/domain/permission"
+)
+
+// ListRowPermissionsFor returns the enabled row rules of a dataset applying
+// to a user directly or through one of their roles or organizations.
+func (r *DatasetRepository) ListRowPermissionsFor(datasetID, userID int64, roleIDs, orgIDs []int64) ([]*permission.CoreRowPermission, error) {
+	targets := r.db.Where("auth_target_type = ? AND auth_target_id = ?", permission.TargetUser, userID)
+	if len(roleIDs) > 0 {
+		targets = targets.Or("auth_target_type = ? AND auth_target_id IN ?", permission.TargetRole, roleIDs)
+	}
+	if len(orgIDs) > 0 {
+		targets = targets.Or("auth_target_type = ? AND auth_target_id IN ?", permission.TargetOrg, orgIDs)
+	}
+	list := make([]*permission.CoreRowPermission, 0)
+	err := r.db.Where("dataset_id = ? AND enable = ?", datasetID, true).
+		Where(targets).
+		Order("id ASC").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *DatasetRepository) SaveRowPermission(rule *permission.CoreRowPermission) error {
+	return r.db.Save(rule).Error
+}
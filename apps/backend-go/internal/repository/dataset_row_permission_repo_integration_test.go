@@ -0,0 +1,42 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/permission"
+)
+
+func TestDatasetRepository_RowPermissions(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_row_permission")
+
+	rules := []*permission.CoreRowPermission{
+		{DatasetID: 900, AuthTargetType: permission.TargetUser, AuthTargetID: 5, Enable: true},
+		{DatasetID: 900, AuthTargetType: permission.TargetRole, AuthTargetID: 7, Enable: true},
+		{DatasetID: 900, AuthTargetType: permission.TargetOrg, AuthTargetID: 9, Enable: true},
+		{DatasetID: 900, AuthTargetType: permission.TargetUser, AuthTargetID: 5, Enable: false},
+		{DatasetID: 900, AuthTargetType: permission.TargetUser, AuthTargetID: 6, Enable: true},
+		{DatasetID: 901, AuthTargetType: permission.TargetUser, AuthTargetID: 5, Enable: true},
+	}
+	for _, rule := range rules {
+		if err := repo.SaveRowPermission(rule); err != nil {
+			t.Fatalf("SaveRowPermission failed: %v", err)
+		}
+	}
+
+	list, err := repo.ListRowPermissionsFor(900, 5, []int64{7}, []int64{9})
+	if err != nil || len(list) != 3 {
+		t.Fatalf("enabled rules of the user, their role and their org should apply, got %+v, %v", list, err)
+	}
+	list, err = repo.ListRowPermissionsFor(900, 5, nil, nil)
+	if err != nil || len(list) != 1 || list[0].ID != rules[0].ID {
+		t.Fatalf("only the enabled rule of the user should apply, got %+v, %v", list, err)
+	}
+}
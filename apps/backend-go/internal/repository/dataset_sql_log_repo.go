@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"dataease/backend/internal/domain/dataset"
+)
+
+func (r *DatasetRepository) CreateSQLLogs(logs []*dataset.CoreDatasetTableSQLLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return r.db.Create(&logs).Error
+}
+
+// ListSQLLogs pages the queries of a table lasting at least minSpend
+// milliseconds, latest first.
+func (r *DatasetRepository) ListSQLLogs(tableID string, minSpend int64, page, pageSize int) ([]*dataset.CoreDatasetTableSQLLog, int64, error) {
+	query := r.db.Model(&dataset.CoreDatasetTableSQLLog{}).Where("table_id = ?", tableID)
+	if minSpend > 0 {
+		query = query.Where("spend >= ?", minSpend)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	logs := make([]*dataset.CoreDatasetTableSQLLog, 0)
+	if err := query.Order("start_time DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+func (r *DatasetRepository) DeleteSQLLogs(tableID string) error {
+	return r.db.Where("table_id = ?", tableID).Delete(&dataset.CoreDatasetTableSQLLog{}).Error
+}
@@ -0,0 +1,45 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func TestDatasetRepository_SQLLogs(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_table_sql_log")
+
+	logs := []*dataset.CoreDatasetTableSQLLog{
+		{ID: "a", TableID: "7", StartTime: 1000, Spend: 20, SQL: "SELECT 1", Status: dataset.SQLLogCompleted},
+		{ID: "b", TableID: "7", StartTime: 2000, Spend: 5000, SQL: "SELECT 2", Status: dataset.SQLLogCompleted},
+		{ID: "c", TableID: "7", StartTime: 3000, Spend: 9000, SQL: "SELECT 3", Status: dataset.SQLLogError},
+		{ID: "d", TableID: "8", StartTime: 4000, Spend: 9000, SQL: "SELECT 4", Status: dataset.SQLLogCompleted},
+	}
+	if err := repo.CreateSQLLogs(logs); err != nil {
+		t.Fatalf("CreateSQLLogs failed: %v", err)
+	}
+
+	page, total, err := repo.ListSQLLogs("7", 0, 1, 2)
+	if err != nil || total != 3 || len(page) != 2 || page[0].ID != "c" || page[1].ID != "b" {
+		t.Fatalf("ListSQLLogs first page = %+v, %d, %v", page, total, err)
+	}
+	page, total, err = repo.ListSQLLogs("7", 1000, 2, 1)
+	if err != nil || total != 2 || len(page) != 1 || page[0].ID != "b" {
+		t.Fatalf("ListSQLLogs of slow queries = %+v, %d, %v", page, total, err)
+	}
+
+	if err = repo.DeleteSQLLogs("7"); err != nil {
+		t.Fatalf("DeleteSQLLogs failed: %v", err)
+	}
+	if _, total, err = repo.ListSQLLogs("8", 0, 1, 10); err != nil || total != 1 {
+		t.Fatalf("logs of other tables should be kept, got %d, %v", total, err)
+	}
+}
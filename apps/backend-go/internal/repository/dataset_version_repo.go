@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"errors"
+
+	"dataease/backend/internal/domain/chart"
+	"dataease/backend/internal/domain/dataset"
+
+	"gorm.io/gorm"
+)
+
+// LatestVersion returns nil when the dataset has no version yet.
+func (r *DatasetRepository) LatestVersion(datasetGroupID int64) (*dataset.CoreDatasetVersion, error) {
+	var version dataset.CoreDatasetVersion
+	err := r.db.Where("dataset_group_id = ?", datasetGroupID).Order("version DESC").First(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (r *DatasetRepository) CreateVersion(version *dataset.CoreDatasetVersion) error {
+	return r.db.Create(version).Error
+}
+
+// ListVersions returns the versions of a dataset without their snapshots,
+// newest first.
+func (r *DatasetRepository) ListVersions(datasetGroupID int64) ([]dataset.CoreDatasetVersion, error) {
+	list := make([]dataset.CoreDatasetVersion, 0)
+	err := r.db.Select("id", "dataset_group_id", "version", "create_time").
+		Where("dataset_group_id = ?", datasetGroupID).
+		Order("version DESC").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *DatasetRepository) GetVersion(datasetGroupID int64, version int) (*dataset.CoreDatasetVersion, error) {
+	var v dataset.CoreDatasetVersion
+	err := r.db.Where("dataset_group_id = ? AND version = ?", datasetGroupID, version).First(&v).Error
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// RestoreSnapshot puts a dataset back to a snapshot: the model of the group
+// and its tables and fields, which keep the ids they had so that charts
+// referencing them resolve again. Fields owned by charts are kept.
+func (r *DatasetRepository) RestoreSnapshot(datasetGroupID int64, snapshot *dataset.Snapshot) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var info *string
+		if snapshot.Group != nil {
+			info = snapshot.Group.Info
+		}
+		if err := tx.Model(&dataset.CoreDatasetGroup{}).Where("id = ?", datasetGroupID).
+			Update("info", info).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_group_id = ? AND chart_id IS NULL", datasetGroupID).
+			Delete(&dataset.CoreDatasetTableField{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("dataset_group_id = ?", datasetGroupID).
+			Delete(&dataset.CoreDatasetTable{}).Error; err != nil {
+			return err
+		}
+		for _, table := range snapshot.Tables {
+			table.DatasetGroupID = datasetGroupID
+			if err := tx.Create(table).Error; err != nil {
+				return err
+			}
+		}
+		for _, field := range snapshot.Fields {
+			field.DatasetGroupID = datasetGroupID
+			if err := tx.Create(field).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListChartsByDataset returns the charts bound to the tables of a dataset.
+func (r *DatasetRepository) ListChartsByDataset(datasetGroupID int64) ([]*chart.CoreChartView, error) {
+	list := make([]*chart.CoreChartView, 0)
+	err := r.db.Table("core_chart_view AS cv").
+		Select("cv.*").
+		Joins("JOIN core_dataset_table AS dt ON dt.id = cv.table_id").
+		Where("dt.dataset_group_id = ?", datasetGroupID).
+		Order("cv.id ASC").
+		Find(&list).Error
+	return list, err
+}
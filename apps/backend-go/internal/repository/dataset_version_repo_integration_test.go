@@ -0,0 +1,99 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func TestDatasetRepository_Versions(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_version")
+
+	latest, err := repo.LatestVersion(900)
+	if err != nil || latest != nil {
+		t.Fatalf("LatestVersion of empty history = %+v, %v", latest, err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err = repo.CreateVersion(&dataset.CoreDatasetVersion{DatasetGroupID: 900, Version: i, Snapshot: `{}`}); err != nil {
+			t.Fatalf("CreateVersion failed: %v", err)
+		}
+	}
+
+	latest, err = repo.LatestVersion(900)
+	if err != nil || latest == nil || latest.Version != 2 {
+		t.Fatalf("LatestVersion = %+v, %v", latest, err)
+	}
+	list, err := repo.ListVersions(900)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Version != 2 || list[0].Snapshot != "" {
+		t.Fatalf("ListVersions = %+v", list)
+	}
+	found, err := repo.GetVersion(900, 1)
+	if err != nil || found.Snapshot != `{}` {
+		t.Fatalf("GetVersion = %+v, %v", found, err)
+	}
+}
+
+func TestDatasetRepository_RestoreSnapshot(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasetRepository(testDB)
+	cleanupTables("core_dataset_group", "core_dataset_table", "core_dataset_table_field")
+
+	group := &dataset.CoreDatasetGroup{Name: "Versioned", NodeType: strPtr("dataset"), Info: strPtr(`{"v":2}`)}
+	if err := repo.CreateGroup(group); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	chartID := int64(77)
+	for _, field := range []*dataset.CoreDatasetTableField{
+		{DatasetGroupID: group.ID, OriginName: strPtr("new_col")},
+		{DatasetGroupID: group.ID, OriginName: strPtr("[1]"), ChartID: &chartID},
+	} {
+		if err := repo.SaveField(field); err != nil {
+			t.Fatalf("SaveField failed: %v", err)
+		}
+	}
+
+	tableID := int64(5001)
+	snapshot := &dataset.Snapshot{
+		Group:  &dataset.CoreDatasetGroup{Info: strPtr(`{"v":1}`)},
+		Tables: []*dataset.CoreDatasetTable{{ID: 5001, Name: strPtr("orders")}},
+		Fields: []*dataset.CoreDatasetTableField{{ID: 6001, DatasetTableID: &tableID, OriginName: strPtr("old_col")}},
+	}
+	if err := repo.RestoreSnapshot(group.ID, snapshot); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	def, err := repo.GetDefinition(group.ID)
+	if err != nil {
+		t.Fatalf("GetDefinition failed: %v", err)
+	}
+	if def.Group.Info == nil || *def.Group.Info != `{"v":1}` {
+		t.Errorf("expected restored info, got %v", def.Group.Info)
+	}
+	if len(def.Tables) != 1 || def.Tables[0].ID != 5001 {
+		t.Errorf("expected restored table 5001, got %+v", def.Tables)
+	}
+	if len(def.Fields) != 1 || def.Fields[0].ID != 6001 {
+		t.Errorf("expected restored field 6001, got %+v", def.Fields)
+	}
+	fields, err := repo.ListFields(group.ID)
+	if err != nil {
+		t.Fatalf("ListFields failed: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Errorf("expected chart field to be kept, got %d fields", len(fields))
+	}
+}
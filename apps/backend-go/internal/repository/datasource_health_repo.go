@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+
+	"dataease/backend/internal/domain/datasource"
+
+	"gorm.io/gorm"
+)
+
+// ListMonitorable returns every datasource that is not a folder.
+func (r *DatasourceRepository) ListMonitorable() ([]*datasource.CoreDatasource, error) {
+	var list []*datasource.CoreDatasource
+	err := r.db.Model(&datasource.CoreDatasource{}).
+		Where("COALESCE(del_flag, 0) = 0 AND type <> ?", datasource.TypeFolder).
+		Order("id ASC").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *DatasourceRepository) CreateHealthCheck(check *datasource.CoreDatasourceHealth) error {
+	return r.db.Create(check).Error
+}
+
+// ListHealthChecks returns the latest checks of a datasource, newest first.
+func (r *DatasourceRepository) ListHealthChecks(dsID int64, limit int) ([]datasource.CoreDatasourceHealth, error) {
+	var list []datasource.CoreDatasourceHealth
+	err := r.db.Where("ds_id = ?", dsID).
+		Order("check_time DESC, id DESC").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+// LatestHealthCheck returns nil when the datasource was never checked.
+func (r *DatasourceRepository) LatestHealthCheck(dsID int64) (*datasource.CoreDatasourceHealth, error) {
+	var check datasource.CoreDatasourceHealth
+	err := r.db.Where("ds_id = ?", dsID).Order("check_time DESC, id DESC").First(&check).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// PurgeHealthChecks deletes the checks made before the given time.
+func (r *DatasourceRepository) PurgeHealthChecks(before int64) error {
+	return r.db.Where("check_time < ?", before).Delete(&datasource.CoreDatasourceHealth{}).Error
+}
+
+// UpdateTableStatus sets the status of every core_dataset_table row reading
+// from the datasource.
+func (r *DatasourceRepository) UpdateTableStatus(dsID int64, status string, checkTime int64) error {
+	return r.db.Model(&datasourceTable{}).
+		Where("datasource_id = ?", dsID).
+		Updates(map[string]interface{}{"status": status, "last_update": checkTime}).Error
+}
+
+// ListTableStatuses returns the recorded status of the datasource tables
+// keyed by physical table name.
+func (r *DatasourceRepository) ListTableStatuses(dsID int64) (map[string]datasource.TableInfo, error) {
+	var rows []datasourceTable
+	if err := r.db.Model(&datasourceTable{}).
+		Where("datasource_id = ? AND status IS NOT NULL", dsID).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]datasource.TableInfo, len(rows))
+	for _, row := range rows {
+		info := datasource.TableInfo{TableName: row.PhysicalName, Status: *row.Status}
+		if row.LastUpdate != nil {
+			info.LastUpdate = *row.LastUpdate
+		}
+		result[row.PhysicalName] = info
+	}
+	return result, nil
+}
@@ -0,0 +1,88 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/domain/msgcenter"
+)
+
+func TestDatasourceRepository_HealthHistoryAndTableStatus(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasourceRepository(testDB)
+	cleanupTables("core_datasource_health", "core_dataset_table")
+
+	now := time.Now().UnixMilli()
+	for i, status := range []string{datasource.StatusSuccess, datasource.StatusError} {
+		check := &datasource.CoreDatasourceHealth{DsID: 7, Status: status, CheckTime: now + int64(i)}
+		if err := repo.CreateHealthCheck(check); err != nil {
+			t.Fatalf("CreateHealthCheck failed: %v", err)
+		}
+	}
+	latest, err := repo.LatestHealthCheck(7)
+	if err != nil || latest == nil || latest.Status != datasource.StatusError {
+		t.Fatalf("Expected the error check to be latest, got %+v (%v)", latest, err)
+	}
+	if err = repo.PurgeHealthChecks(now + 1); err != nil {
+		t.Fatalf("PurgeHealthChecks failed: %v", err)
+	}
+	history, err := repo.ListHealthChecks(7, 10)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected one check after purge, got %+v (%v)", history, err)
+	}
+
+	physical := "orders"
+	dsID := int64(7)
+	if err = testDB.Create(&dataset.CoreDatasetTable{ID: 70, DatasourceID: &dsID, PhysicalTable: &physical}).Error; err != nil {
+		t.Fatalf("Create table failed: %v", err)
+	}
+	if err = repo.UpdateTableStatus(7, datasource.StatusError, now); err != nil {
+		t.Fatalf("UpdateTableStatus failed: %v", err)
+	}
+	statuses, err := repo.ListTableStatuses(7)
+	if err != nil {
+		t.Fatalf("ListTableStatuses failed: %v", err)
+	}
+	if got := statuses[physical]; got.Status != datasource.StatusError || got.LastUpdate != now {
+		t.Errorf("Unexpected table status %+v", got)
+	}
+}
+
+func TestMsgCenterRepository_Messages(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewMsgCenterRepository(testDB)
+	cleanupTables("core_message", "core_msg_setting")
+
+	for i := 0; i < 2; i++ {
+		msg := &msgcenter.CoreMessage{UserID: 3, Title: "down", Type: msgcenter.TypeDatasource, CreateTime: int64(i)}
+		if err := repo.CreateMessage(msg); err != nil {
+			t.Fatalf("CreateMessage failed: %v", err)
+		}
+		if i == 0 {
+			if err := repo.MarkAsRead(strconv.FormatInt(msg.ID, 10), 3); err != nil {
+				t.Fatalf("MarkAsRead failed: %v", err)
+			}
+		}
+	}
+
+	unread, err := repo.CountUnread(3)
+	if err != nil || unread != 1 {
+		t.Fatalf("Expected one unread message, got %d (%v)", unread, err)
+	}
+	list, total, err := repo.ListMessages(3, msgcenter.TypeDatasource, "", 0, 10)
+	if err != nil || total != 2 || len(list) != 2 || list[0].CreateTime != 1 {
+		t.Errorf("Unexpected messages: total=%d list=%+v (%v)", total, list, err)
+	}
+}
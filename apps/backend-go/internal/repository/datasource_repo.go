@@ -2,8 +2,6 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
-	"regexp"
 	"strings"
 
 	"dataease/backend/internal/domain/datasource"
@@ -11,14 +9,15 @@ import (
 	"gorm.io/gorm"
 )
 
-var datasourceTableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
-
 type datasourceTable struct {
-	ID           int64   `gorm:"column:id"`
-	Name         string  `gorm:"column:name"`
-	PhysicalName string  `gorm:"column:table_name"`
-	DatasourceID int64   `gorm:"column:datasource_id"`
-	Type         *string `gorm:"column:type"`
+	ID             int64   `gorm:"column:id;primaryKey;autoIncrement"`
+	Name           string  `gorm:"column:name"`
+	PhysicalName   string  `gorm:"column:table_name"`
+	DatasourceID   int64   `gorm:"column:datasource_id"`
+	DatasetGroupID int64   `gorm:"column:dataset_group_id"`
+	Type           *string `gorm:"column:type"`
+	Status         *string `gorm:"column:status"`
+	LastUpdate     *int64  `gorm:"column:last_update"`
 }
 
 func (datasourceTable) TableName() string {
@@ -84,6 +83,18 @@ func (r *DatasourceRepository) Update(ds *datasource.CoreDatasource) error {
 	return r.db.Save(ds).Error
 }
 
+func (r *DatasourceRepository) UpdateStatus(id int64, status string) error {
+	return r.db.Model(&datasource.CoreDatasource{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+}
+
+func (r *DatasourceRepository) UpdateTaskStatus(id int64, status string) error {
+	return r.db.Model(&datasource.CoreDatasource{}).
+		Where("id = ?", id).
+		Update("task_status", status).Error
+}
+
 func (r *DatasourceRepository) SoftDelete(id int64) error {
 	return r.db.Model(&datasource.CoreDatasource{}).
 		Where("id = ? AND COALESCE(del_flag, 0) = 0", id).
@@ -136,10 +147,12 @@ func (r *DatasourceRepository) ListByType(dsType string, excludeID *int64) ([]*d
 	return list, nil
 }
 
+// ListTables lists the engine tables registered by a datasource itself, such
+// as the sheets of an Excel upload. They are not bound to a dataset group.
 func (r *DatasourceRepository) ListTables(datasourceID int64) ([]datasource.TableInfo, error) {
 	var rows []datasourceTable
 	if err := r.db.Model(&datasourceTable{}).
-		Where("datasource_id = ?", datasourceID).
+		Where("datasource_id = ? AND dataset_group_id = 0", datasourceID).
 		Order("id DESC").
 		Find(&rows).Error; err != nil {
 		return nil, err
@@ -151,18 +164,56 @@ func (r *DatasourceRepository) ListTables(datasourceID int64) ([]datasource.Tabl
 		if row.Type != nil {
 			typeVal = *row.Type
 		}
-		result = append(result, datasource.TableInfo{
+		info := datasource.TableInfo{
 			ID:           row.ID,
 			DatasourceID: row.DatasourceID,
 			Name:         row.Name,
 			TableName:    row.PhysicalName,
 			Type:         typeVal,
-		})
+		}
+		if row.Status != nil {
+			info.Status = *row.Status
+		}
+		if row.LastUpdate != nil {
+			info.LastUpdate = *row.LastUpdate
+		}
+		result = append(result, info)
 	}
 
 	return result, nil
 }
 
+func (r *DatasourceRepository) CreateTable(datasourceID int64, name string, physicalName string, tableType string) error {
+	return r.db.Create(&datasourceTable{
+		Name:         name,
+		PhysicalName: physicalName,
+		DatasourceID: datasourceID,
+		Type:         &tableType,
+	}).Error
+}
+
+// SaveTable records a physical table of a datasource, replacing the entry
+// of the same table.
+func (r *DatasourceRepository) SaveTable(datasourceID int64, name string, physicalName string, tableType string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("datasource_id = ? AND dataset_group_id = 0 AND table_name = ?", datasourceID, physicalName).
+			Delete(&datasourceTable{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&datasourceTable{
+			Name:         name,
+			PhysicalName: physicalName,
+			DatasourceID: datasourceID,
+			Type:         &tableType,
+		}).Error
+	})
+}
+
+func (r *DatasourceRepository) DeleteTables(datasourceID int64) error {
+	return r.db.Where("datasource_id = ? AND dataset_group_id = 0", datasourceID).
+		Delete(&datasourceTable{}).Error
+}
+
 func (r *DatasourceRepository) ListSchemas() ([]string, error) {
 	rows, err := r.db.Raw("SELECT schema_name FROM information_schema.schemata ORDER BY schema_name ASC").Rows()
 	if err != nil {
@@ -189,70 +240,10 @@ func (r *DatasourceRepository) ListSchemas() ([]string, error) {
 	return result, nil
 }
 
-func (r *DatasourceRepository) ListTableFields(tableName string) ([]datasource.TableField, error) {
-	if !datasourceTableNamePattern.MatchString(tableName) {
-		return nil, fmt.Errorf("invalid table name")
-	}
-
-	type columnRow struct {
-		Field string `gorm:"column:Field"`
-		Type  string `gorm:"column:Type"`
-	}
-	rows := make([]columnRow, 0)
-	sql := fmt.Sprintf("SHOW COLUMNS FROM `%s`", tableName)
-	if err := r.db.Raw(sql).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-
-	result := make([]datasource.TableField, 0, len(rows))
-	for _, row := range rows {
-		result = append(result, datasource.TableField{
-			OriginName: row.Field,
-			Name:       row.Field,
-			Type:       row.Type,
-			DeType:     inferDeType(row.Type),
-		})
-	}
-	return result, nil
-}
-
-func (r *DatasourceRepository) PreviewRows(tableName string, limit int) ([]map[string]interface{}, error) {
-	if !datasourceTableNamePattern.MatchString(tableName) {
-		return nil, fmt.Errorf("invalid table name")
-	}
-	if limit <= 0 {
-		limit = 100
-	}
-	if limit > 500 {
-		limit = 500
-	}
-
-	rows := make([]map[string]interface{}, 0)
-	sql := fmt.Sprintf("SELECT * FROM `%s` LIMIT ?", tableName)
-	if err := r.db.Raw(sql, limit).Scan(&rows).Error; err != nil {
-		return nil, err
-	}
-	return rows, nil
-}
-
-func (r *DatasourceRepository) CountRows(tableName string) (int64, error) {
-	if !datasourceTableNamePattern.MatchString(tableName) {
-		return 0, fmt.Errorf("invalid table name")
-	}
-	var result struct {
-		C int64 `gorm:"column:c"`
-	}
-	sql := fmt.Sprintf("SELECT COUNT(1) AS c FROM `%s`", tableName)
-	if err := r.db.Raw(sql).Scan(&result).Error; err != nil {
-		return 0, err
-	}
-	return result.C, nil
-}
-
 func (r *DatasourceRepository) CountDatasourceRelations(datasourceID int64) (int64, error) {
 	var count int64
 	err := r.db.Table("core_dataset_table").
-		Where("datasource_id = ?", datasourceID).
+		Where("datasource_id = ? AND dataset_group_id <> 0", datasourceID).
 		Count(&count).Error
 	if err != nil {
 		return 0, err
@@ -295,15 +286,3 @@ func (r *DatasourceRepository) ExistsFinishPageRecord(userID int64) (bool, error
 func (r *DatasourceRepository) CreateFinishPageRecord(userID int64) error {
 	return r.db.Exec("INSERT IGNORE INTO core_ds_finish_page (id) VALUES (?)", userID).Error
 }
-
-func inferDeType(t string) int {
-	typeName := strings.ToLower(t)
-	switch {
-	case strings.Contains(typeName, "int"), strings.Contains(typeName, "decimal"), strings.Contains(typeName, "double"), strings.Contains(typeName, "float"):
-		return 2
-	case strings.Contains(typeName, "date"), strings.Contains(typeName, "time"), strings.Contains(typeName, "year"):
-		return 1
-	default:
-		return 0
-	}
-}
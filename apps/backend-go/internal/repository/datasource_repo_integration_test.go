@@ -289,3 +289,34 @@ func int64Ptr(v int64) *int64 {
 func strPtr(v string) *string {
 	return &v
 }
+
+func TestDatasourceRepository_UpdateStatus(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasourceRepository(testDB)
+	cleanupTables("core_datasource")
+
+	ds := &datasource.CoreDatasource{
+		Name:       "Status MySQL",
+		Type:       "mysql",
+		Status:     strPtr(datasource.StatusSuccess),
+		CreateTime: int64Ptr(time.Now().Unix()),
+	}
+	if err := repo.Create(ds); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := repo.UpdateStatus(ds.ID, datasource.StatusError); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+
+	found, err := repo.GetByID(ds.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if found.Status == nil || *found.Status != datasource.StatusError {
+		t.Errorf("Expected status '%s', got %v", datasource.StatusError, found.Status)
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"dataease/backend/internal/domain/datasource"
+
+	"gorm.io/gorm"
+)
+
+type DatasourceTaskRepository struct {
+	db *gorm.DB
+}
+
+func NewDatasourceTaskRepository(db *gorm.DB) *DatasourceTaskRepository {
+	return &DatasourceTaskRepository{db: db}
+}
+
+func (r *DatasourceTaskRepository) Create(task *datasource.CoreDatasourceTask) error {
+	return r.db.Create(task).Error
+}
+
+func (r *DatasourceTaskRepository) Update(task *datasource.CoreDatasourceTask) error {
+	return r.db.Save(task).Error
+}
+
+func (r *DatasourceTaskRepository) GetByID(id int64) (*datasource.CoreDatasourceTask, error) {
+	var task datasource.CoreDatasourceTask
+	if err := r.db.Where("id = ?", id).First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (r *DatasourceTaskRepository) ListByDatasource(dsID int64) ([]datasource.CoreDatasourceTask, error) {
+	var list []datasource.CoreDatasourceTask
+	if err := r.db.Where("ds_id = ?", dsID).Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListSchedulable returns the tasks that must be registered with the
+// scheduler on startup: waiting ones and those interrupted mid run.
+func (r *DatasourceTaskRepository) ListSchedulable() ([]datasource.CoreDatasourceTask, error) {
+	var list []datasource.CoreDatasourceTask
+	if err := r.db.Where("task_status IN ?", []string{datasource.TaskStatusWaiting, datasource.TaskStatusRunning}).
+		Order("id").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *DatasourceTaskRepository) UpdateTaskStatus(id int64, status string) error {
+	return r.db.Model(&datasource.CoreDatasourceTask{}).
+		Where("id = ?", id).
+		Update("task_status", status).Error
+}
+
+func (r *DatasourceTaskRepository) UpdateExecResult(id int64, execTime int64, execStatus string, taskStatus string) error {
+	return r.db.Model(&datasource.CoreDatasourceTask{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_exec_time":   execTime,
+			"last_exec_status": execStatus,
+			"task_status":      taskStatus,
+		}).Error
+}
+
+// UpdateExtraData stores the watermark written back by an incremental run.
+func (r *DatasourceTaskRepository) UpdateExtraData(id int64, extraData string) error {
+	return r.db.Model(&datasource.CoreDatasourceTask{}).
+		Where("id = ?", id).
+		Update("extra_data", extraData).Error
+}
+
+func (r *DatasourceTaskRepository) CreateLog(log *datasource.CoreDatasourceTaskLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *DatasourceTaskRepository) UpdateLog(log *datasource.CoreDatasourceTaskLog) error {
+	return r.db.Save(log).Error
+}
+
+func (r *DatasourceTaskRepository) PageLogs(dsID int64, page int, pageSize int) ([]datasource.CoreDatasourceTaskLog, int64, error) {
+	var list []datasource.CoreDatasourceTaskLog
+	var total int64
+
+	query := r.db.Model(&datasource.CoreDatasourceTaskLog{}).Where("ds_id = ?", dsID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
@@ -0,0 +1,62 @@
+//go:build integration
+// +build integration
+
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+func TestDatasourceTaskRepository_ScheduleAndLogs(t *testing.T) {
+	if testDB == nil {
+		t.Skip("Test database not available")
+	}
+
+	repo := NewDatasourceTaskRepository(testDB)
+	cleanupTables("core_datasource_task", "core_datasource_task_log")
+
+	now := time.Now().UnixMilli()
+	waiting := &datasource.CoreDatasourceTask{DsID: 1, Name: "hourly", SyncRate: datasource.SyncRateCron, Cron: "0 0 * * * ?", TaskStatus: datasource.TaskStatusWaiting, CreateTime: now}
+	paused := &datasource.CoreDatasourceTask{DsID: 1, Name: "paused", SyncRate: datasource.SyncRateRightNow, TaskStatus: datasource.TaskStatusPaused, CreateTime: now}
+	for _, task := range []*datasource.CoreDatasourceTask{waiting, paused} {
+		if err := repo.Create(task); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	schedulable, err := repo.ListSchedulable()
+	if err != nil {
+		t.Fatalf("ListSchedulable failed: %v", err)
+	}
+	if len(schedulable) != 1 || schedulable[0].ID != waiting.ID {
+		t.Errorf("Expected only the waiting task, got %+v", schedulable)
+	}
+
+	if err = repo.UpdateExecResult(waiting.ID, now, datasource.ExecStatusCompleted, datasource.TaskStatusStopped); err != nil {
+		t.Fatalf("UpdateExecResult failed: %v", err)
+	}
+	found, err := repo.GetByID(waiting.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if found.LastExecTime != now || found.LastExecStatus != datasource.ExecStatusCompleted || found.TaskStatus != datasource.TaskStatusStopped {
+		t.Errorf("Unexpected exec result %+v", found)
+	}
+
+	for i := 0; i < 3; i++ {
+		log := &datasource.CoreDatasourceTaskLog{DsID: 1, TaskID: waiting.ID, StartTime: now, TaskStatus: datasource.ExecStatusRunning, TriggerType: datasource.TriggerManual}
+		if err = repo.CreateLog(log); err != nil {
+			t.Fatalf("CreateLog failed: %v", err)
+		}
+	}
+	logs, total, err := repo.PageLogs(1, 1, 2)
+	if err != nil {
+		t.Fatalf("PageLogs failed: %v", err)
+	}
+	if total != 3 || len(logs) != 2 || logs[0].TriggerType != datasource.TriggerManual {
+		t.Errorf("Unexpected page: total=%d logs=%+v", total, logs)
+	}
+}
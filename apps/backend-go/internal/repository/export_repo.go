@@ -19,8 +19,10 @@ type coreExportTask struct {
 	ExportTime        int64  `gorm:"column:export_time"`
 	ExportProgress    string `gorm:"column:export_progress;size:20"`
 	ExportMachineName string `gorm:"column:export_machine_name;size:100"`
-	ExportFromName    string `gorm:"column:export_from_name;size:255"`
-	OrgName           string `gorm:"column:org_name;size:255"`
+	Params            string `gorm:"column:params;type:longtext"`
+	// The export source and organization names are not stored.
+	ExportFromName string `gorm:"-"`
+	OrgName        string `gorm:"-"`
 }
 
 func (coreExportTask) TableName() string {
@@ -75,6 +77,26 @@ func (r *ExportRepository) List(page, pageSize int, status string) ([]export.Exp
 	return tasks, total, nil
 }
 
+// Save writes every column of a task.
+func (r *ExportRepository) Save(task *export.ExportTask) error {
+	return r.db.Save(r.toRecord(task)).Error
+}
+
+func (r *ExportRepository) UpdateProgress(id string, progress string) error {
+	return r.db.Model(&coreExportTask{}).Where("id = ?", id).Update("export_progress", progress).Error
+}
+
+// ListIDsByType returns the ids of the tasks DeleteAllByType deletes.
+func (r *ExportRepository) ListIDsByType(exportFromType string) ([]string, error) {
+	ids := make([]string, 0)
+	query := r.db.Model(&coreExportTask{})
+	if exportFromType != "" && exportFromType != "all" {
+		query = query.Where("export_from_type = ?", exportFromType)
+	}
+	err := query.Pluck("id", &ids).Error
+	return ids, err
+}
+
 func (r *ExportRepository) UpdateStatus(id string, status string) error {
 	return r.db.Model(&coreExportTask{}).Where("id = ?", id).Update("export_status", status).Error
 }
@@ -136,6 +158,7 @@ func (r *ExportRepository) toRecord(task *export.ExportTask) *coreExportTask {
 		ExportMachineName: task.ExportMachineName,
 		ExportFromName:    task.ExportFromName,
 		OrgName:           task.OrgName,
+		Params:            task.Params,
 	}
 }
 
@@ -155,5 +178,6 @@ func (r *ExportRepository) toDomain(record *coreExportTask) *export.ExportTask {
 		ExportMachineName: record.ExportMachineName,
 		ExportFromName:    record.ExportFromName,
 		OrgName:           record.OrgName,
+		Params:            record.Params,
 	}
 }
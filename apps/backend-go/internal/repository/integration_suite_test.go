@@ -15,6 +15,7 @@ import (
 	"dataease/backend/internal/domain/dataset"
 	"dataease/backend/internal/domain/datasource"
 	"dataease/backend/internal/domain/menu"
+	"dataease/backend/internal/domain/msgcenter"
 	"dataease/backend/internal/domain/org"
 	"dataease/backend/internal/domain/permission"
 	"dataease/backend/internal/domain/role"
@@ -61,11 +62,13 @@ func TestMain(m *testing.M) {
 		&role.SysRole{},
 		&org.SysOrg{},
 		&menu.CoreMenu{},
-		&datasource.CoreDatasource{},
+		&datasource.CoreDatasource{}, &datasource.CoreDatasourceTask{}, &datasource.CoreDatasourceTaskLog{},
+		&datasource.CoreDatasourceHealth{}, &msgcenter.CoreMessage{}, &coreMsgSetting{},
 		&chart.CoreChartView{},
 		&dataset.CoreDatasetGroup{}, &dataset.CoreDatasetTable{}, &dataset.CoreDatasetTableField{},
+		&dataset.CoreDatasetVersion{}, &dataset.CoreDatasetProfile{}, &dataset.CoreDatasetTableSQLLog{},
 		&audit.AuditLog{}, &audit.AuditLogDetail{}, &audit.LoginFailure{},
-		&permission.SysPerm{},
+		&permission.SysPerm{}, &permission.CoreColumnPermission{}, &permission.CoreRowPermission{},
 		&visualization.DataVisualizationInfo{},
 		&coreShare{}, &coreShareTicket{},
 		&coreVisualizationTemplate{},
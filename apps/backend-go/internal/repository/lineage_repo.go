@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"fmt"
+
+	"dataease/backend/internal/domain/lineage"
+
+	"gorm.io/gorm"
+)
+
+type lineageRow struct {
+	ID     int64   `gorm:"column:id"`
+	Name   *string `gorm:"column:name"`
+	Owner  *string `gorm:"column:owner"`
+	LinkID int64   `gorm:"column:link_id"`
+}
+
+// nodeSQL selects the resources of a type by id.
+var nodeSQL = map[string]string{
+	lineage.TypeDatasource: "SELECT id, name, create_by AS owner, id AS link_id FROM core_datasource " +
+		"WHERE id IN ? AND COALESCE(del_flag, 0) = 0",
+	lineage.TypeDatasetTable: "SELECT id, COALESCE(name, table_name) AS name, NULL AS owner, id AS link_id FROM core_dataset_table " +
+		"WHERE id IN ?",
+	lineage.TypeDataset: "SELECT id, name, create_by AS owner, id AS link_id FROM core_dataset_group " +
+		"WHERE id IN ?",
+	lineage.TypeChart: "SELECT id, title AS name, create_by AS owner, id AS link_id FROM core_chart_view " +
+		"WHERE id IN ?",
+	lineage.TypeVisualization: "SELECT id, name, create_by AS owner, id AS link_id FROM data_visualization_info " +
+		"WHERE id IN ? AND COALESCE(delete_flag, 0) = 0",
+}
+
+// downstreamSQL selects the resources depending on resources of a type;
+// link_id is the id of the resource they depend on.
+var downstreamSQL = map[string]string{
+	lineage.TypeDatasource: "SELECT t.id, COALESCE(t.name, t.table_name) AS name, NULL AS owner, t.datasource_id AS link_id " +
+		"FROM core_dataset_table t WHERE t.datasource_id IN ? AND t.dataset_group_id <> 0",
+	lineage.TypeDatasetTable: "SELECT g.id, g.name, g.create_by AS owner, t.id AS link_id " +
+		"FROM core_dataset_group g JOIN core_dataset_table t ON t.dataset_group_id = g.id WHERE t.id IN ?",
+	lineage.TypeDataset: "SELECT cv.id, cv.title AS name, cv.create_by AS owner, t.dataset_group_id AS link_id " +
+		"FROM core_chart_view cv JOIN core_dataset_table t ON t.id = cv.table_id WHERE t.dataset_group_id IN ?",
+	lineage.TypeChart: "SELECT v.id, v.name, v.create_by AS owner, cv.id AS link_id " +
+		"FROM data_visualization_info v JOIN core_chart_view cv ON cv.scene_id = v.id " +
+		"WHERE cv.id IN ? AND COALESCE(v.delete_flag, 0) = 0",
+}
+
+// upstreamSQL selects the resources that resources of a type depend on;
+// link_id is the id of the dependent resource.
+var upstreamSQL = map[string]string{
+	lineage.TypeVisualization: "SELECT cv.id, cv.title AS name, cv.create_by AS owner, cv.scene_id AS link_id " +
+		"FROM core_chart_view cv WHERE cv.scene_id IN ?",
+	lineage.TypeChart: "SELECT g.id, g.name, g.create_by AS owner, cv.id AS link_id " +
+		"FROM core_dataset_group g JOIN core_dataset_table t ON t.dataset_group_id = g.id " +
+		"JOIN core_chart_view cv ON cv.table_id = t.id WHERE cv.id IN ?",
+	lineage.TypeDataset: "SELECT t.id, COALESCE(t.name, t.table_name) AS name, NULL AS owner, t.dataset_group_id AS link_id " +
+		"FROM core_dataset_table t WHERE t.dataset_group_id IN ?",
+	lineage.TypeDatasetTable: "SELECT d.id, d.name, d.create_by AS owner, t.id AS link_id " +
+		"FROM core_datasource d JOIN core_dataset_table t ON t.datasource_id = d.id " +
+		"WHERE t.id IN ? AND COALESCE(d.del_flag, 0) = 0",
+}
+
+// LineageRepository reads the links between datasources, dataset tables,
+// datasets, charts and visualizations.
+type LineageRepository struct {
+	db *gorm.DB
+}
+
+func NewLineageRepository(db *gorm.DB) *LineageRepository {
+	return &LineageRepository{db: db}
+}
+
+// Nodes returns the resources of a type with the given ids.
+func (r *LineageRepository) Nodes(nodeType string, ids []int64) ([]lineage.Link, error) {
+	return r.links(nodeSQL, nodeType, ids)
+}
+
+// Downstream returns the resources depending directly on the given ones.
+func (r *LineageRepository) Downstream(nodeType string, ids []int64) ([]lineage.Link, error) {
+	return r.links(downstreamSQL, nodeType, ids)
+}
+
+// Upstream returns the resources the given ones depend on directly.
+func (r *LineageRepository) Upstream(nodeType string, ids []int64) ([]lineage.Link, error) {
+	return r.links(upstreamSQL, nodeType, ids)
+}
+
+func (r *LineageRepository) links(queries map[string]string, nodeType string, ids []int64) ([]lineage.Link, error) {
+	query, ok := queries[nodeType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported lineage node type: %s", nodeType)
+	}
+	if len(ids) == 0 {
+		return []lineage.Link{}, nil
+	}
+	var rows []lineageRow
+	if err := r.db.Raw(query, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make([]lineage.Link, 0, len(rows))
+	for _, row := range rows {
+		link := lineage.Link{ID: row.ID, LinkID: row.LinkID}
+		if row.Name != nil {
+			link.Name = *row.Name
+		}
+		if row.Owner != nil {
+			link.Owner = *row.Owner
+		}
+		result = append(result, link)
+	}
+	return result, nil
+}
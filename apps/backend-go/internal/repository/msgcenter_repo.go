@@ -3,6 +3,8 @@ package repository
 import (
 	"time"
 
+	"dataease/backend/internal/domain/msgcenter"
+
 	"gorm.io/gorm"
 )
 
@@ -126,3 +128,45 @@ func (r *MsgCenterRepository) GetReadStatusMap(msgIDs []string, userID int64) (m
 
 	return result, nil
 }
+
+// CreateMessage stores a message for its user.
+func (r *MsgCenterRepository) CreateMessage(msg *msgcenter.CoreMessage) error {
+	return r.db.Create(msg).Error
+}
+
+// ListMessages returns one page of the messages of a user, newest first,
+// optionally filtered by type and read status ("read" or "unread").
+func (r *MsgCenterRepository) ListMessages(userID int64, msgType string, readStatus string, offset int, limit int) ([]msgcenter.CoreMessage, int64, error) {
+	query := r.messageQuery(userID, msgType, readStatus)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []msgcenter.CoreMessage
+	err := query.Order("create_time DESC, id DESC").Offset(offset).Limit(limit).Find(&list).Error
+	return list, total, err
+}
+
+// CountUnread returns the number of unread messages of a user.
+func (r *MsgCenterRepository) CountUnread(userID int64) (int64, error) {
+	var total int64
+	err := r.messageQuery(userID, "", "unread").Count(&total).Error
+	return total, err
+}
+
+func (r *MsgCenterRepository) messageQuery(userID int64, msgType string, readStatus string) *gorm.DB {
+	query := r.db.Model(&msgcenter.CoreMessage{}).Where("user_id = ?", userID)
+	if msgType != "" {
+		query = query.Where("type = ?", msgType)
+	}
+	read := r.db.Model(&coreMsgSetting{}).
+		Select("msg_id").
+		Where("user_id = ? AND status = ?", userID, "read")
+	switch readStatus {
+	case "read":
+		query = query.Where("CAST(id AS CHAR) IN (?)", read)
+	case "unread":
+		query = query.Where("CAST(id AS CHAR) NOT IN (?)", read)
+	}
+	return query
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type coreRsa struct {
+	ID     int32  `gorm:"column:id;primaryKey"`
+	AesKey string `gorm:"column:aes_key"`
+}
+
+func (coreRsa) TableName() string {
+	return "core_rsa"
+}
+
+type RsaRepository struct {
+	db *gorm.DB
+}
+
+func NewRsaRepository(db *gorm.DB) *RsaRepository {
+	return &RsaRepository{db: db}
+}
+
+// GetAESKey returns the aes_key of the newest core_rsa row, or an empty
+// string when the table holds none.
+func (r *RsaRepository) GetAESKey() (string, error) {
+	var row coreRsa
+	err := r.db.Order("create_time DESC").First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return row.AesKey, nil
+}
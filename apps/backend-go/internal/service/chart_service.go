@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
@@ -10,12 +11,14 @@ import (
 
 	"dataease/backend/internal/domain/chart"
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/datasetsql"
 )
 
 type ChartRepository interface {
 	GetByID(id int64) (*chart.CoreChartView, error)
 	Update(view *chart.CoreChartView) error
-	QueryRows(chartID int64, limit int) ([]map[string]interface{}, int64, error)
+	QueryRows(ctx context.Context, chartID int64, limit int, values *datasetsql.Values, masks permission.MaskSource) ([]map[string]interface{}, int64, error)
 	ListDatasetFieldsByGroup(datasetGroupID int64) ([]*dataset.CoreDatasetTableField, error)
 	ListDatasetFieldsByChart(chartID int64) ([]*dataset.CoreDatasetTableField, error)
 	GetDatasetFieldByID(id int64) (*dataset.CoreDatasetTableField, error)
@@ -27,24 +30,66 @@ type ChartRepository interface {
 }
 
 type ChartService struct {
-	repo ChartRepository
+	repo       ChartRepository
+	tickets    *TicketService
+	columnPerm *ColumnPermissionService
+	checkSQL   func(query string) error
 }
 
 func NewChartService(repo ChartRepository) *ChartService {
 	return &ChartService{repo: repo}
 }
 
+// SetTickets enables the share ticket args as values of SQL variables.
+func (s *ChartService) SetTickets(tickets *TicketService) {
+	s.tickets = tickets
+}
+
+// SetColumnPermissions enables hiding and masking the dataset fields of
+// charts.
+func (s *ChartService) SetColumnPermissions(columnPerm *ColumnPermissionService) {
+	s.columnPerm = columnPerm
+}
+
+// SetSQLCheck validates the SQL of custom SQL tables before charts run it.
+func (s *ChartService) SetSQLCheck(check func(query string) error) {
+	s.checkSQL = check
+}
+
 func (s *ChartService) Query(req *chart.ChartQueryRequest) (*chart.CoreChartView, error) {
 	return s.repo.GetByID(req.ID)
 }
 
-func (s *ChartService) QueryData(req *chart.ChartDataRequest) (*chart.ChartDataResponse, error) {
+func (s *ChartService) QueryData(ctx context.Context, req *chart.ChartDataRequest) (*chart.ChartDataResponse, error) {
 	limit := 100
 	if req.ResultCount != nil && *req.ResultCount > 0 {
 		limit = *req.ResultCount
 	}
 
-	rows, total, err := s.repo.QueryRows(req.ID, limit)
+	// The args of a share ticket are fixed by whoever shared the chart: they
+	// take precedence over the parameters sent along.
+	values := &datasetsql.Values{Check: s.checkSQL}
+	if req.Ticket != "" && s.tickets != nil {
+		view, err := s.repo.GetByID(req.ID)
+		if err != nil {
+			return nil, err
+		}
+		if view.SceneID == nil {
+			return nil, fmt.Errorf("share ticket does not grant chart %d", req.ID)
+		}
+		args, err := s.tickets.Args(req.Ticket, *view.SceneID)
+		if err != nil {
+			return nil, err
+		}
+		values.Layers = append(values.Layers, args)
+	}
+	values.Layers = append(values.Layers, req.Parameters, req.OuterParams)
+
+	var masks permission.MaskSource
+	if s.columnPerm != nil {
+		masks = s.columnPerm.MaskSource(ctx)
+	}
+	rows, total, err := s.repo.QueryRows(ctx, req.ID, limit, values, masks)
 	if err != nil {
 		return nil, err
 	}
@@ -125,10 +170,20 @@ func (s *ChartService) SaveFromMap(body map[string]interface{}) (*chart.CoreChar
 	return view, nil
 }
 
-func (s *ChartService) ListByDQ(datasetGroupID int64, chartID int64) (*chart.ChartFieldListResponse, error) {
+// ListByDQ lists the fields of a dataset and of a chart as dimensions and
+// quotas, leaving out those hidden from the user of ctx and flagging those
+// masked.
+func (s *ChartService) ListByDQ(ctx context.Context, datasetGroupID int64, chartID int64) (*chart.ChartFieldListResponse, error) {
 	if datasetGroupID <= 0 {
 		return &chart.ChartFieldListResponse{DimensionList: []chart.ChartField{}, QuotaList: []chart.ChartField{}}, nil
 	}
+	var masks permission.ColumnMasks
+	if s.columnPerm != nil {
+		var err error
+		if masks, err = s.columnPerm.Masks(ctx, datasetGroupID); err != nil {
+			return nil, err
+		}
+	}
 
 	baseFields, err := s.repo.ListDatasetFieldsByGroup(datasetGroupID)
 	if err != nil {
@@ -149,6 +204,7 @@ func (s *ChartService) ListByDQ(datasetGroupID int64, chartID int64) (*chart.Cha
 		if chartErr != nil {
 			return nil, chartErr
 		}
+		masks = masks.Derive(datasetsql.FormulaRefs(append(baseFields, chartFields...)))
 		for _, field := range chartFields {
 			if field == nil {
 				continue
@@ -160,6 +216,10 @@ func (s *ChartService) ListByDQ(datasetGroupID int64, chartID int64) (*chart.Cha
 	dimensionList := make([]chart.ChartField, 0)
 	quotaList := make([]chart.ChartField, 0)
 	for _, field := range all {
+		if masks.Hidden(field.ID) {
+			continue
+		}
+		field.Desensitized = masks.Masked(field.ID)
 		if strings.EqualFold(field.GroupType, "d") {
 			dimensionList = append(dimensionList, field)
 		} else {
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
@@ -11,6 +12,8 @@ import (
 
 	"dataease/backend/internal/domain/chart"
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/datasetsql"
 )
 
 type chartRegressionSample struct {
@@ -43,7 +46,7 @@ func (r *fakeChartRepo) GetByID(id int64) (*chart.CoreChartView, error) {
 	return v, nil
 }
 
-func (r *fakeChartRepo) QueryRows(chartID int64, limit int) ([]map[string]interface{}, int64, error) {
+func (r *fakeChartRepo) QueryRows(_ context.Context, chartID int64, limit int, _ *datasetsql.Values, _ permission.MaskSource) ([]map[string]interface{}, int64, error) {
 	s, ok := r.data[chartID]
 	if !ok {
 		return nil, 0, errors.New("not found")
@@ -235,7 +238,7 @@ func TestChartQueryData_RegressionSamples(t *testing.T) {
 		sample := sample
 		t.Run(sample.Name, func(t *testing.T) {
 			resultCount := sample.ResultCount
-			resp, err := svc.QueryData(&chart.ChartDataRequest{ID: sample.ChartID, ResultCount: &resultCount})
+			resp, err := svc.QueryData(context.Background(), &chart.ChartDataRequest{ID: sample.ChartID, ResultCount: &resultCount})
 			if err != nil {
 				t.Fatalf("query data failed: %v", err)
 			}
@@ -264,6 +267,17 @@ func TestChartQueryData_RegressionSamples(t *testing.T) {
 	}
 }
 
+func TestChartQueryData_TicketNeedsSharedChart(t *testing.T) {
+	repo := &fakeChartRepo{byID: map[int64]*chart.CoreChartView{7: {ID: 7}}}
+	svc := NewChartService(repo)
+	// The chart belongs to no dashboard: the ticket is refused before it is
+	// looked up.
+	svc.SetTickets(NewTicketService(nil))
+	if _, err := svc.QueryData(context.Background(), &chart.ChartDataRequest{ID: 7, Ticket: "abc"}); err == nil {
+		t.Fatal("expected a ticket on an unshared chart to be refused")
+	}
+}
+
 func TestChartListByDQ_SplitsAndCount(t *testing.T) {
 	nameD := "region"
 	originD := "region"
@@ -293,7 +307,7 @@ func TestChartListByDQ_SplitsAndCount(t *testing.T) {
 	}
 
 	svc := NewChartService(repo)
-	result, err := svc.ListByDQ(11, 99)
+	result, err := svc.ListByDQ(context.Background(), 11, 99)
 	if err != nil {
 		t.Fatalf("ListByDQ failed: %v", err)
 	}
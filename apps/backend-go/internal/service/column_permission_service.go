@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type viewerKey struct{}
+
+type viewer struct {
+	id         int64
+	admin      bool
+	background bool
+}
+
+// errNoViewer fails the queries whose reader is unknown rather than showing
+// them the fields in clear.
+var errNoViewer = errors.New("column permissions: the reader of the data is unknown")
+
+// WithViewer records the user reading the data of ctx, whose column
+// permissions hide and mask the fields of the datasets read.
+func WithViewer(ctx context.Context, userID int64, admin bool) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer{id: userID, admin: admin})
+}
+
+// WithBackground marks ctx as that of a background job, whose results no
+// user reads: its queries are not masked.
+func WithBackground(ctx context.Context) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer{background: true})
+}
+
+// ColumnPermissionService keeps the rules hiding or masking dataset fields
+// for users, roles and organizations, and resolves those of a reader.
+type ColumnPermissionService struct {
+	repo         *repository.DatasetRepository
+	userRoles    *repository.UserRoleRepository
+	exemptAdmins bool
+}
+
+func NewColumnPermissionService(repo *repository.DatasetRepository, userRoles *repository.UserRoleRepository) *ColumnPermissionService {
+	return &ColumnPermissionService{repo: repo, userRoles: userRoles}
+}
+
+// SetExemptAdmins lets admins read every field in clear. Admins are masked
+// like any user otherwise.
+func (s *ColumnPermissionService) SetExemptAdmins(exempt bool) {
+	s.exemptAdmins = exempt
+}
+
+func (s *ColumnPermissionService) List(datasetGroupID int64) ([]*permission.CoreColumnPermission, error) {
+	if datasetGroupID <= 0 {
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	return s.repo.ListColumnPermissions(datasetGroupID)
+}
+
+// Save creates a rule, or updates it when its id is set.
+func (s *ColumnPermissionService) Save(rule *permission.CoreColumnPermission) (*permission.CoreColumnPermission, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	field, err := s.repo.GetFieldByID(rule.FieldID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("dataset field not found")
+		}
+		return nil, err
+	}
+	if field.DatasetGroupID != rule.DatasetGroupID {
+		return nil, fmt.Errorf("field %d does not belong to dataset %d", rule.FieldID, rule.DatasetGroupID)
+	}
+	if rule.ID > 0 {
+		if _, err = s.repo.GetColumnPermission(rule.ID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("column permission not found")
+			}
+			return nil, err
+		}
+	}
+	rule.UpdateTime = time.Now().UnixMilli()
+	if err = s.repo.SaveColumnPermission(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *ColumnPermissionService) Delete(id int64) error {
+	if id <= 0 {
+		return fmt.Errorf("column permission id is required")
+	}
+	return s.repo.DeleteColumnPermission(id)
+}
+
+// Masks returns the column permissions of the reader of ctx on a dataset.
+// Background jobs are not masked; queries without a reader fail.
+func (s *ColumnPermissionService) Masks(ctx context.Context, datasetGroupID int64) (permission.ColumnMasks, error) {
+	v, ok := ctx.Value(viewerKey{}).(viewer)
+	if !ok {
+		return nil, errNoViewer
+	}
+	if v.background || (v.admin && s.exemptAdmins) {
+		return nil, nil
+	}
+	roleIDs, orgIDs, err := userTargets(s.userRoles, v.id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ListColumnPermissionsFor(datasetGroupID, v.id, roleIDs, orgIDs)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	// Calculated fields would read controlled fields in clear otherwise.
+	fields, err := s.repo.ListFields(datasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	return permission.NewColumnMasks(rules).Derive(datasetsql.FormulaRefs(fields)), nil
+}
+
+// userTargets returns the roles and organizations of a user, whose rules
+// apply to them as well.
+func userTargets(userRoles *repository.UserRoleRepository, userID int64) (roleIDs, orgIDs []int64, err error) {
+	if userRoles == nil {
+		return nil, nil, nil
+	}
+	roles, err := userRoles.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[int64]bool, len(roles))
+	for _, r := range roles {
+		roleIDs = append(roleIDs, r.RoleID)
+		if !seen[r.OrgID] {
+			seen[r.OrgID] = true
+			orgIDs = append(orgIDs, r.OrgID)
+		}
+	}
+	return roleIDs, orgIDs, nil
+}
+
+// MaskSource resolves the column permissions of the reader of ctx.
+func (s *ColumnPermissionService) MaskSource(ctx context.Context) permission.MaskSource {
+	return func(datasetGroupID int64) (permission.ColumnMasks, error) {
+		return s.Masks(ctx, datasetGroupID)
+	}
+}
+
+// SetColumnPermissions enables hiding and masking dataset fields.
+func (s *DatasetService) SetColumnPermissions(columnPerm *ColumnPermissionService) {
+	s.columnPerm = columnPerm
+}
+
+func (s *DatasetService) columnMasks(ctx context.Context, datasetGroupID int64) (permission.ColumnMasks, error) {
+	if s.columnPerm == nil {
+		return nil, nil
+	}
+	return s.columnPerm.Masks(ctx, datasetGroupID)
+}
+
+// sourceColumns maps the fields of a dataset to the columns of its source.
+func sourceColumns(fields []*dataset.CoreDatasetTableField, source *datasetsql.Source) map[int64]string {
+	columns := make(map[int64]string, len(fields))
+	for _, field := range fields {
+		columns[field.ID] = source.Column(field)
+	}
+	return columns
+}
+
+// presenceTerms compare no value: they tell nothing of masked values.
+var presenceTerms = map[string]bool{"null": true, "not_null": true, "empty": true, "not_empty": true}
+
+// guardFilter drops the conditions of a filter on fields hidden from the
+// reader and refuses those comparing masked fields with values, as the rows
+// matched would tell their clear values apart.
+func guardFilter(tree *dataset.FilterTree, masks permission.ColumnMasks) (*dataset.FilterTree, error) {
+	if tree == nil || len(masks) == 0 {
+		return tree, nil
+	}
+	guarded := &dataset.FilterTree{Logic: tree.Logic, Items: make([]dataset.FilterItem, 0, len(tree.Items))}
+	for _, item := range tree.Items {
+		if strings.EqualFold(item.Type, "tree") {
+			sub, err := guardFilter(item.SubTree, masks)
+			if err != nil {
+				return nil, err
+			}
+			item.SubTree = sub
+			guarded.Items = append(guarded.Items, item)
+			continue
+		}
+		id, err := item.FieldID.Int64()
+		if err != nil {
+			guarded.Items = append(guarded.Items, item)
+			continue
+		}
+		if masks.Hidden(id) {
+			continue
+		}
+		term := strings.ToLower(strings.TrimSpace(item.Term))
+		if masks.Masked(id) && (strings.EqualFold(item.FilterType, "enum") || !presenceTerms[term]) {
+			return nil, fmt.Errorf("field %d is masked and cannot be filtered by value", id)
+		}
+		guarded.Items = append(guarded.Items, item)
+	}
+	return guarded, nil
+}
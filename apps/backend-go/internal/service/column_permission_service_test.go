@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/permission"
+)
+
+func TestColumnPermissionMasks_Exemptions(t *testing.T) {
+	// Without a repository, any lookup of rules would panic.
+	svc := NewColumnPermissionService(nil, nil)
+
+	if _, err := svc.Masks(context.Background(), 1); err == nil {
+		t.Fatal("queries without a reader should fail")
+	}
+
+	masks, err := svc.Masks(WithBackground(context.Background()), 1)
+	if err != nil || masks != nil {
+		t.Fatalf("background jobs should not be masked, got %v, %v", masks, err)
+	}
+
+	svc.SetExemptAdmins(true)
+	masks, err = svc.Masks(WithViewer(context.Background(), 1, true), 1)
+	if err != nil || masks != nil {
+		t.Fatalf("exempt admins should not be masked, got %v, %v", masks, err)
+	}
+}
+
+func TestGuardFilter(t *testing.T) {
+	masks := permission.NewColumnMasks([]*permission.CoreColumnPermission{
+		{FieldID: 1, RuleType: permission.ColumnRuleHide},
+		{FieldID: 2, RuleType: permission.ColumnRuleFull},
+	})
+	tree := &dataset.FilterTree{Logic: "and", Items: []dataset.FilterItem{
+		{FieldID: "1", FilterType: "logic", Term: "eq", Value: "42"},
+		{FieldID: "2", FilterType: "logic", Term: "not_null"},
+		{Type: "tree", SubTree: &dataset.FilterTree{Logic: "or", Items: []dataset.FilterItem{
+			{FieldID: "1", FilterType: "enum", EnumValue: []string{"a"}},
+			{FieldID: "3", FilterType: "logic", Term: "like", Value: "x"},
+		}}},
+	}}
+	guarded, err := guardFilter(tree, masks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(guarded.Items) != 2 || guarded.Items[0].FieldID != "2" || len(guarded.Items[1].SubTree.Items) != 1 {
+		t.Errorf("conditions on hidden fields should be dropped, got %+v", guarded)
+	}
+	if len(tree.Items) != 3 {
+		t.Errorf("the filter of the request should be left as is, got %+v", tree)
+	}
+
+	for _, item := range []dataset.FilterItem{
+		{FieldID: "2", FilterType: "logic", Term: "like", Value: "13"},
+		{FieldID: "2", FilterType: "enum", EnumValue: []string{"alice"}},
+	} {
+		if _, err = guardFilter(&dataset.FilterTree{Items: []dataset.FilterItem{item}}, masks); err == nil {
+			t.Errorf("expected %+v on a masked field to be refused", item)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/tabular"
+)
+
+// ExportRows writes the rows of a dataset to w: a header with the names of
+// its checked fields the user may see, then their values, masked by the
+// column permissions, matching the filter of the request and the row
+// permissions of the user, at most limit rows. Rows are streamed
+// from the datasource; progress is called after every row with the rows
+// written and the rows expected.
+func (s *DatasetService) ExportRows(ctx context.Context, userID int64, req *dataset.ExportRequest, limit int64, w tabular.Writer, progress func(written, total int64)) error {
+	filter, err := dataset.ParseFilterTree(req.ExpressionTree)
+	if err != nil {
+		return err
+	}
+	def, err := s.repo.GetDefinition(req.ID)
+	if err != nil {
+		return err
+	}
+	conn, source, err := s.datasetSource(def, nil)
+	if err != nil {
+		return err
+	}
+	ctx = dsconn.WithDatasetTables(ctx, def.TableIDs()...)
+	masks, err := s.columnMasks(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	fields := make([]*dataset.CoreDatasetTableField, 0, len(def.Fields))
+	for _, field := range exportFields(def.Fields, source) {
+		if !masks.Hidden(field.ID) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("dataset has no field to export")
+	}
+	if filter, err = guardFilter(filter, masks); err != nil {
+		return err
+	}
+
+	trees := []*dataset.FilterTree{filter}
+	if s.rowPerm != nil {
+		rowFilter, rowErr := s.rowPerm.DatasetFilter(req.ID, userID)
+		if rowErr != nil {
+			return rowErr
+		}
+		trees = append(trees, rowFilter)
+	}
+	where, whereArgs, err := datasetsql.Where(conn, source, def.Fields, trees...)
+	if err != nil {
+		return err
+	}
+
+	total, err := s.repo.CountWhere(ctx, conn, source, where, whereArgs)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && total > limit {
+		total = limit
+	}
+
+	header := make([]interface{}, len(fields))
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.DisplayName()
+		columns[i] = source.Column(field)
+	}
+	if err = w.WriteRow(header); err != nil {
+		return err
+	}
+	var written int64
+	return s.repo.EachRow(ctx, conn, source, columns, where, whereArgs, limit, func(values []interface{}) error {
+		for i, field := range fields {
+			values[i] = masks.Value(field.ID, values[i])
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+		written++
+		if progress != nil {
+			progress(written, total)
+		}
+		return nil
+	})
+}
+
+// exportFields are the checked fields of a dataset its source selects.
+func exportFields(fields []*dataset.CoreDatasetTableField, source *datasetsql.Source) []*dataset.CoreDatasetTableField {
+	result := make([]*dataset.CoreDatasetTableField, 0, len(fields))
+	for _, field := range fields {
+		if field.Checked != nil && !*field.Checked {
+			continue
+		}
+		if source.Column(field) == "" || !source.Exposes(field) {
+			continue
+		}
+		result = append(result, field)
+	}
+	return result
+}
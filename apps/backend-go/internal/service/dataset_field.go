@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/calcfield"
+	"dataease/backend/internal/pkg/datasetsql"
+)
+
+// SaveField creates or updates a calculated field of a dataset, or of a
+// chart when ChartID is set, or retypes a column of a dataset. The formula
+// of a calculated field is checked against the fields it may reference and
+// the deType of the field is derived from it.
+func (s *DatasetService) SaveField(field *dataset.CoreDatasetTableField) (*dataset.CoreDatasetTableField, error) {
+	if field == nil {
+		return nil, fmt.Errorf("dataset field is required")
+	}
+	if field.DatasetGroupID <= 0 {
+		return nil, fmt.Errorf("dataset group id is required")
+	}
+	if field.ExtField == nil || *field.ExtField != datasetsql.ExtFieldCalculated {
+		return s.retypeField(field)
+	}
+	if field.Name == nil || strings.TrimSpace(*field.Name) == "" {
+		return nil, fmt.Errorf("field name is required")
+	}
+	if field.ID > 0 {
+		existing, err := s.repo.GetFieldByID(field.ID)
+		if err != nil {
+			return nil, err
+		}
+		if existing.DatasetGroupID != field.DatasetGroupID {
+			return nil, fmt.Errorf("field does not belong to the dataset")
+		}
+		field.DataeaseName, field.FieldShortName = existing.DataeaseName, existing.FieldShortName
+	}
+
+	fields, err := s.repo.ListFields(field.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCalcField(field, fields); err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.SaveField(field); err != nil {
+		return nil, err
+	}
+	if field.DataeaseName == nil || strings.TrimSpace(*field.DataeaseName) == "" {
+		alias := fieldNameShort(fmt.Sprintf("%d_%s", field.ID, *field.OriginName))
+		field.DataeaseName, field.FieldShortName = &alias, &alias
+		if err = s.repo.SaveField(field); err != nil {
+			return nil, err
+		}
+	}
+	if field.ChartID == nil {
+		s.recordVersion(field.DatasetGroupID)
+	}
+	return field, nil
+}
+
+// checkCalcField checks the formula of a calculated field against the
+// fields of its dataset and sets the types of the field from the result.
+// Formulas of a chart may reference the fields of the dataset and of the
+// chart; formulas of the dataset only its own.
+func checkCalcField(field *dataset.CoreDatasetTableField, fields []*dataset.CoreDatasetTableField) error {
+	if field.OriginName == nil || strings.TrimSpace(*field.OriginName) == "" {
+		return fmt.Errorf("formula is required")
+	}
+	scope := make([]*dataset.CoreDatasetTableField, 0, len(fields)+1)
+	for _, other := range fields {
+		if other.ID == field.ID {
+			continue
+		}
+		if other.ChartID == nil || (field.ChartID != nil && *other.ChartID == *field.ChartID) {
+			scope = append(scope, other)
+		}
+	}
+	scope = append(scope, field)
+
+	info, err := calcfield.NewSet(datasetsql.CalcFields(scope)).Check(field.ID)
+	if err != nil {
+		return fmt.Errorf("invalid formula: %w", err)
+	}
+	deType := info.Type
+	field.DeType = &deType
+	field.DeExtractType = &deType
+	groupType := "d"
+	if info.Aggregate || deType == calcfield.TypeInt || deType == calcfield.TypeFloat {
+		groupType = "q"
+	}
+	field.GroupType = &groupType
+	return nil
+}
+
+// retypeField updates how a column of a dataset is read: its name, its
+// deType with the format dates are parsed with, and its group type.
+func (s *DatasetService) retypeField(req *dataset.CoreDatasetTableField) (*dataset.CoreDatasetTableField, error) {
+	if req.ID <= 0 {
+		return nil, fmt.Errorf("field id is required")
+	}
+	field, err := s.repo.GetFieldByID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if field.DatasetGroupID != req.DatasetGroupID {
+		return nil, fmt.Errorf("field does not belong to the dataset")
+	}
+	if err = retype(field, req); err != nil {
+		return nil, err
+	}
+	if err = s.repo.SaveField(field); err != nil {
+		return nil, err
+	}
+	s.recordVersion(field.DatasetGroupID)
+	return field, nil
+}
+
+// retype applies the name, types and date format of req to a column field.
+// The type the column was first read as is kept as its extract type, which
+// queries convert from.
+func retype(field *dataset.CoreDatasetTableField, req *dataset.CoreDatasetTableField) error {
+	if field.ExtField != nil && *field.ExtField == datasetsql.ExtFieldCalculated {
+		return fmt.Errorf("field is a calculated field")
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		name := strings.TrimSpace(*req.Name)
+		field.Name = &name
+	}
+	if req.DeType != nil {
+		if field.DeExtractType == nil {
+			field.DeExtractType = field.DeType
+		}
+		deType := *req.DeType
+		field.DeType = &deType
+	}
+	field.DateFormat, field.DateFormatType = req.DateFormat, req.DateFormatType
+	if req.GroupType != nil {
+		groupType := strings.TrimSpace(*req.GroupType)
+		if groupType != "d" && groupType != "q" {
+			return fmt.Errorf("group type must be d or q")
+		}
+		field.GroupType = &groupType
+	}
+	if err := datasetsql.CheckConversion(field); err != nil {
+		return fmt.Errorf("invalid field type: %w", err)
+	}
+	return nil
+}
+
+// CalcFunctions lists the functions formulas of calculated fields may call.
+func (s *DatasetService) CalcFunctions() []calcfield.FunctionDoc {
+	return calcfield.Functions()
+}
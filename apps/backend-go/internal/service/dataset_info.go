@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/domain/lineage"
+	"dataease/backend/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// SetUsers enables the names of the creator and last editor of datasets.
+func (s *DatasetService) SetUsers(users *repository.UserRepository) {
+	s.users = users
+}
+
+// BarInfo summarises a dataset for its info panel.
+func (s *DatasetService) BarInfo(id int64) (*dataset.BarInfo, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	def, err := s.repo.GetDefinition(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("dataset not found")
+		}
+		return nil, err
+	}
+	group := def.Group
+	info := &dataset.BarInfo{
+		ID:             group.ID,
+		Name:           group.Name,
+		NodeType:       stringValue(group.NodeType),
+		CreateBy:       stringValue(group.CreateBy),
+		CreateTime:     group.CreateTime,
+		UpdateBy:       stringValue(group.UpdateBy),
+		LastUpdateTime: group.LastUpdateTime,
+		Datasources:    make([]dataset.BarDatasource, 0),
+		FieldCount:     len(def.Fields),
+	}
+
+	datasourceIDs := make([]int64, 0, len(def.Tables))
+	seen := make(map[int64]bool, len(def.Tables))
+	for _, table := range def.Tables {
+		if table.DatasourceID == nil || seen[*table.DatasourceID] {
+			continue
+		}
+		seen[*table.DatasourceID] = true
+		datasourceIDs = append(datasourceIDs, *table.DatasourceID)
+	}
+	sources := make([]*datasource.CoreDatasource, 0, len(datasourceIDs))
+	for _, dsID := range datasourceIDs {
+		ds, dsErr := s.dsRepo.GetByID(dsID)
+		if dsErr != nil {
+			if errors.Is(dsErr, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, dsErr
+		}
+		sources = append(sources, ds)
+		info.Datasources = append(info.Datasources, dataset.BarDatasource{
+			ID: ds.ID, Name: ds.Name, Type: ds.Type, Status: stringValue(ds.Status),
+		})
+	}
+	info.IsCross = len(datasourceIDs) > 1
+	info.DatasetType = datasetKind(def.Tables, sources)
+
+	task, err := s.repo.LastSyncTask(datasourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	if task != nil {
+		info.LastSyncTime, info.SyncStatus = &task.LastExecTime, task.LastExecStatus
+	} else {
+		info.LastSyncTime, info.SyncStatus = tableSync(def.Tables)
+	}
+
+	info.Creator, info.Updater = s.userName(info.CreateBy), s.userName(info.UpdateBy)
+	if info.ChartCount, info.DashboardCount, err = s.dependents(id); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// datasetKind tells a dataset joining tables from one selecting a single
+// table, a custom SQL or an Excel sheet.
+func datasetKind(tables []*dataset.CoreDatasetTable, sources []*datasource.CoreDatasource) string {
+	switch {
+	case len(tables) == 0:
+		return ""
+	case len(tables) > 1:
+		return dataset.KindJoin
+	case strings.EqualFold(stringValue(tables[0].Type), dataset.TableTypeSQL):
+		return dataset.KindSQL
+	case len(sources) == 1 && sources[0].Type == datasource.TypeExcel:
+		return dataset.KindExcel
+	}
+	return dataset.KindTable
+}
+
+// tableSync is the latest check of the tables of a dataset. A failing table
+// fails the dataset.
+func tableSync(tables []*dataset.CoreDatasetTable) (*int64, string) {
+	var last *int64
+	status := ""
+	for _, table := range tables {
+		if table.LastUpdate != nil && (last == nil || *table.LastUpdate > *last) {
+			last = table.LastUpdate
+		}
+		if table.Status != nil && *table.Status != "" && status != datasource.StatusError {
+			status = *table.Status
+		}
+	}
+	return last, status
+}
+
+// userName is the nick name, else the username, of the user whose id is
+// text; ids of unknown users stay as they are.
+func (s *DatasetService) userName(text string) string {
+	id, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || s.users == nil {
+		return text
+	}
+	u, err := s.users.GetByID(id)
+	if err != nil || u == nil {
+		return text
+	}
+	if u.NickName != "" {
+		return u.NickName
+	}
+	return u.Username
+}
+
+// dependents counts the charts and the dashboards using a dataset.
+func (s *DatasetService) dependents(id int64) (int, int, error) {
+	if s.lineage == nil {
+		count, err := s.repo.CountChartRelations(id)
+		return int(count), 0, err
+	}
+	impact, err := s.lineage.Impact(lineage.TypeDataset, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	charts, dashboards := 0, 0
+	for _, node := range impact.Impact {
+		switch node.Type {
+		case lineage.TypeChart:
+			charts++
+		case lineage.TypeVisualization:
+			dashboards++
+		}
+	}
+	return charts, dashboards, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/repository"
+)
+
+// saveModel stores the relational model of a dataset. Tables already bound
+// to the dataset are reused by datasource, name and alias, new ones are
+// created with a field per column, and tables the model no longer references
+// are removed. The model is compiled against the tables it would have before
+// anything is written, so that a model the datasource cannot run is
+// rejected, and is then stored in one transaction.
+func (s *DatasetService) saveModel(group *dataset.CoreDatasetGroup, model *dataset.Model) error {
+	if err := model.Validate(); err != nil {
+		return err
+	}
+	def, err := s.repo.GetDefinition(group.ID)
+	if err != nil {
+		return err
+	}
+	conn, err := s.datasourceConnection(model.Union[0].DatasourceID)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*dataset.CoreDatasetTable, len(def.Tables))
+	for _, table := range def.Tables {
+		if table.DatasourceID != nil && table.PhysicalTable != nil {
+			existing[existingTableKey(table)] = table
+		}
+	}
+
+	// New tables and their fields get negative ids until they are created.
+	candidate := &dataset.Definition{Group: def.Group, Model: model}
+	listed := make(map[int64]bool)
+	created := make([]*modelTable, 0)
+	keep := make([]int64, 0)
+	nextFieldID := int64(0)
+	model.Walk(func(node *dataset.ModelNode, _ *dataset.ModelNode) bool {
+		key := modelTableKey(node.DatasourceID, node.TableName, node.Alias)
+		table, ok := existing[key]
+		if !ok {
+			var t *modelTable
+			if t, err = s.newModelTable(conn, group.ID, node, int64(-len(created)-1)); err != nil {
+				return false
+			}
+			for _, field := range t.fields {
+				nextFieldID--
+				field.ID = nextFieldID
+			}
+			created = append(created, t)
+			candidate.Fields = append(candidate.Fields, t.fields...)
+			table = t.table
+			existing[key] = table
+		}
+		if !listed[table.ID] {
+			listed[table.ID] = true
+			candidate.Tables = append(candidate.Tables, table)
+			if ok {
+				keep = append(keep, table.ID)
+			}
+		}
+		node.TableID = table.ID
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, field := range def.Fields {
+		if field.DatasetTableID != nil && *field.DatasetTableID > 0 && listed[*field.DatasetTableID] {
+			candidate.Fields = append(candidate.Fields, field)
+		}
+	}
+	if _, err = datasetsql.Compile(conn, candidate, &datasetsql.Values{Check: s.CheckSQL}); err != nil {
+		return err
+	}
+
+	return s.repo.Transaction(func(repo *repository.DatasetRepository) error {
+		ids := make(map[int64]int64, len(created))
+		for _, t := range created {
+			provisional := t.table.ID
+			t.table.ID = 0
+			for _, field := range t.fields {
+				field.ID = 0
+			}
+			if err := repo.CreateTable(t.table, t.fields); err != nil {
+				return err
+			}
+			ids[provisional] = t.table.ID
+			keep = append(keep, t.table.ID)
+		}
+		model.Walk(func(node *dataset.ModelNode, _ *dataset.ModelNode) bool {
+			if id, ok := ids[node.TableID]; ok {
+				node.TableID = id
+			}
+			return true
+		})
+		if err := repo.DeleteTablesExcept(group.ID, keep); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(model)
+		if err != nil {
+			return err
+		}
+		info := string(data)
+		group.Info = &info
+		return repo.UpdateGroup(group)
+	})
+}
+
+// modelTable is a table of a model not saved yet, with its fields.
+type modelTable struct {
+	table  *dataset.CoreDatasetTable
+	fields []*dataset.CoreDatasetTableField
+}
+
+// newModelTable reads the columns of the table of a node into a table with
+// a field per column. The table is identified by id until it is created.
+func (s *DatasetService) newModelTable(conn *dsconn.Conn, datasetGroupID int64, node *dataset.ModelNode, id int64) (*modelTable, error) {
+	columns, err := conn.ListColumns(node.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s has no column", node.TableName)
+	}
+
+	name := node.Name()
+	physical := node.TableName
+	tableType := "db"
+	datasourceID := node.DatasourceID
+	t := &modelTable{table: &dataset.CoreDatasetTable{
+		ID:             id,
+		Name:           &name,
+		DatasourceID:   &datasourceID,
+		DatasetGroupID: datasetGroupID,
+		PhysicalTable:  &physical,
+		Type:           &tableType,
+	}}
+	for _, column := range columns {
+		originName := column.Name
+		columnType := column.Type
+		deType := conn.DeType(column.Type)
+		dataeaseName := fieldNameShort(fmt.Sprintf("%d_%s_%s", datasourceID, name, originName))
+		checked := true
+		extField := 0
+		t.fields = append(t.fields, &dataset.CoreDatasetTableField{
+			DatasourceID:   &datasourceID,
+			DatasetTableID: &t.table.ID,
+			DatasetGroupID: datasetGroupID,
+			OriginName:     &originName,
+			Name:           &originName,
+			DataeaseName:   &dataeaseName,
+			FieldShortName: &dataeaseName,
+			Type:           &columnType,
+			DeType:         &deType,
+			DeExtractType:  &deType,
+			ExtField:       &extField,
+			Checked:        &checked,
+		})
+	}
+	return t, nil
+}
+
+// existingTableKey keys a saved table like the model node it was created
+// for: its name is the alias of the node when it differs from the table.
+func existingTableKey(table *dataset.CoreDatasetTable) string {
+	alias := ""
+	if table.Name != nil && !strings.EqualFold(strings.TrimSpace(*table.Name), strings.TrimSpace(*table.PhysicalTable)) {
+		alias = *table.Name
+	}
+	return modelTableKey(*table.DatasourceID, *table.PhysicalTable, alias)
+}
+
+func modelTableKey(datasourceID int64, tableName, alias string) string {
+	return fmt.Sprintf("%d:%s:%s", datasourceID, strings.ToLower(strings.TrimSpace(tableName)), strings.ToLower(strings.TrimSpace(alias)))
+}
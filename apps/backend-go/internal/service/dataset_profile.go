@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	profileTopValues = 10
+	profileBuckets   = 10
+)
+
+// Profile returns the cached profile of a dataset as the user of ctx may
+// see it; its status is empty when the dataset has never been profiled.
+func (s *DatasetService) Profile(ctx context.Context, id int64) (*dataset.ProfileResponse, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	record, err := s.repo.GetProfile(id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return &dataset.ProfileResponse{DatasetGroupID: id}, nil
+	}
+	return s.maskedProfile(ctx, record)
+}
+
+// RefreshProfile starts profiling a dataset in the background and returns
+// the cached profile marked running. A dataset is profiled by one job at a
+// time; ctx only carries the user the queries run as.
+func (s *DatasetService) RefreshProfile(ctx context.Context, id int64) (*dataset.ProfileResponse, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	group, err := s.repo.GetGroupByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("dataset not found")
+		}
+		return nil, err
+	}
+	if group.NodeType == nil || *group.NodeType != dataset.NodeTypeDataset {
+		return nil, fmt.Errorf("only datasets can be profiled")
+	}
+	if !s.acquireProfile(id) {
+		return s.Profile(ctx, id)
+	}
+
+	record, err := s.repo.GetProfile(id)
+	if err == nil && record == nil {
+		record = &dataset.CoreDatasetProfile{DatasetGroupID: id}
+	}
+	if err == nil {
+		record.Status, record.Msg, record.UpdateTime = dataset.ProfileRunning, "", time.Now().UnixMilli()
+		err = s.repo.SaveProfile(record)
+	}
+	if err != nil {
+		s.releaseProfile(id)
+		return nil, err
+	}
+
+	go func() {
+		defer s.releaseProfile(id)
+		s.runProfile(context.WithoutCancel(ctx), record)
+	}()
+	return s.maskedProfile(ctx, record)
+}
+
+// RefreshProfilesOf refreshes the profiles of the datasets reading a
+// datasource, once its data has been synced. Datasets never profiled are
+// left alone.
+func (s *DatasetService) RefreshProfilesOf(datasourceID int64) {
+	ids, err := s.repo.ListGroupIDsByDatasources([]int64{datasourceID})
+	if err != nil {
+		logger.Warn("Failed to list datasets to profile", zap.Int64("datasourceId", datasourceID), zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		record, getErr := s.repo.GetProfile(id)
+		if getErr == nil && record == nil {
+			continue
+		}
+		if getErr == nil {
+			_, getErr = s.RefreshProfile(WithBackground(dsconn.WithUser(context.Background(), 0, "")), id)
+		}
+		if getErr != nil {
+			logger.Warn("Failed to refresh dataset profile", zap.Int64("datasetGroupId", id), zap.Error(getErr))
+		}
+	}
+}
+
+func (s *DatasetService) runProfile(ctx context.Context, record *dataset.CoreDatasetProfile) {
+	profile, err := s.computeProfile(ctx, record.DatasetGroupID)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(profile); err == nil {
+			record.Result, record.ProfiledAt = string(data), time.Now().UnixMilli()
+		}
+	}
+	record.Status = dataset.ProfileSuccess
+	if err != nil {
+		logger.Warn("Dataset profiling failed", zap.Int64("datasetGroupId", record.DatasetGroupID), zap.Error(err))
+		record.Status, record.Msg = dataset.ProfileFailed, err.Error()
+	}
+	record.UpdateTime = time.Now().UnixMilli()
+	if err = s.repo.SaveProfile(record); err != nil {
+		logger.Warn("Failed to save dataset profile", zap.Int64("datasetGroupId", record.DatasetGroupID), zap.Error(err))
+	}
+}
+
+// computeProfile reads the statistics of all fields in one query, then the
+// most frequent values and the histogram of each field. A field failing
+// the latter two keeps its statistics and reports the error.
+func (s *DatasetService) computeProfile(ctx context.Context, id int64) (*dataset.Profile, error) {
+	def, err := s.repo.GetDefinition(id)
+	if err != nil {
+		return nil, err
+	}
+	conn, source, err := s.datasetSource(def, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx = dsconn.WithDatasetTables(ctx, def.TableIDs()...)
+	fields := exportFields(def.Fields, source)
+
+	query, err := datasetsql.ProfileStats(conn, source, fields)
+	if err != nil {
+		return nil, err
+	}
+	_, rows, err := conn.QueryGridContext(ctx, query, source.Args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) != 1 || len(rows[0]) != 1+4*len(fields) {
+		return nil, fmt.Errorf("unexpected profile statistics")
+	}
+	stats := rows[0]
+	profile := &dataset.Profile{RowCount: profileInt(stats[0]), Fields: make([]dataset.FieldProfile, 0, len(fields))}
+
+	for i, field := range fields {
+		col := 1 + 4*i
+		fp := dataset.FieldProfile{
+			FieldID:       field.ID,
+			Name:          field.DisplayName(),
+			DeType:        field.DeType,
+			NullCount:     profile.RowCount - profileInt(stats[col]),
+			DistinctCount: profileInt(stats[col+1]),
+			Min:           normalizePreviewValue(stats[col+2]),
+			Max:           normalizePreviewValue(stats[col+3]),
+			TopValues:     make([]dataset.ValueCount, 0),
+		}
+		if profile.RowCount > 0 {
+			fp.NullRatio = float64(fp.NullCount) / float64(profile.RowCount)
+		}
+		if fp.TopValues, err = s.profileTopValues(ctx, conn, source, field); err == nil && fp.NullCount < profile.RowCount && datasetsql.Ranged(field) {
+			fp.Histogram, err = s.profileHistogram(ctx, conn, source, field)
+		}
+		if err != nil {
+			fp.Error = err.Error()
+		}
+		profile.Fields = append(profile.Fields, fp)
+	}
+	return profile, nil
+}
+
+func (s *DatasetService) profileTopValues(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, field *dataset.CoreDatasetTableField) ([]dataset.ValueCount, error) {
+	query, err := datasetsql.ProfileTopValues(conn, source, field)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]interface{}{}, source.Args...), profileTopValues)
+	_, rows, err := conn.QueryGridContext(ctx, conn.Limit(query, true), args...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]dataset.ValueCount, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, dataset.ValueCount{Value: normalizePreviewValue(row[0]), Count: profileInt(row[1])})
+	}
+	return values, nil
+}
+
+// profileHistogram lists every bucket, empty ones included.
+func (s *DatasetService) profileHistogram(ctx context.Context, conn *dsconn.Conn, source *datasetsql.Source, field *dataset.CoreDatasetTableField) ([]dataset.HistogramBucket, error) {
+	query, args, err := datasetsql.ProfileHistogram(conn, source, field, profileBuckets)
+	if err != nil {
+		return nil, err
+	}
+	_, rows, err := conn.QueryGridContext(ctx, query, args...)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	lo, _ := profileFloat(rows[0][2])
+	hi, _ := profileFloat(rows[0][3])
+	if hi <= lo {
+		return []dataset.HistogramBucket{{Lower: lo, Upper: hi, Count: profileInt(rows[0][1])}}, nil
+	}
+	width := (hi - lo) / profileBuckets
+	buckets := make([]dataset.HistogramBucket, profileBuckets)
+	for i := range buckets {
+		buckets[i].Lower, buckets[i].Upper = lo+float64(i)*width, lo+float64(i+1)*width
+	}
+	buckets[profileBuckets-1].Upper = hi
+	for _, row := range rows {
+		if b := profileInt(row[0]); b >= 0 && b < profileBuckets {
+			buckets[b].Count += profileInt(row[1])
+		}
+	}
+	return buckets, nil
+}
+
+func (s *DatasetService) acquireProfile(id int64) bool {
+	s.profileMu.Lock()
+	defer s.profileMu.Unlock()
+	if s.profiling == nil {
+		s.profiling = make(map[int64]struct{})
+	}
+	if _, busy := s.profiling[id]; busy {
+		return false
+	}
+	s.profiling[id] = struct{}{}
+	return true
+}
+
+func (s *DatasetService) releaseProfile(id int64) {
+	s.profileMu.Lock()
+	delete(s.profiling, id)
+	s.profileMu.Unlock()
+}
+
+// maskedProfile leaves out the fields hidden from the user of ctx and masks
+// the values of the masked ones, whose histogram is left out.
+func (s *DatasetService) maskedProfile(ctx context.Context, record *dataset.CoreDatasetProfile) (*dataset.ProfileResponse, error) {
+	resp, err := profileResponse(record)
+	if err != nil || resp.Profile == nil {
+		return resp, err
+	}
+	masks, err := s.columnMasks(ctx, record.DatasetGroupID)
+	if err != nil || len(masks) == 0 {
+		return resp, err
+	}
+	fields := make([]dataset.FieldProfile, 0, len(resp.Profile.Fields))
+	for _, fp := range resp.Profile.Fields {
+		if masks.Hidden(fp.FieldID) {
+			continue
+		}
+		if masks.Masked(fp.FieldID) {
+			fp.Min, fp.Max, fp.Histogram = masks.Value(fp.FieldID, fp.Min), masks.Value(fp.FieldID, fp.Max), nil
+			for i := range fp.TopValues {
+				fp.TopValues[i].Value = masks.Value(fp.FieldID, fp.TopValues[i].Value)
+			}
+		}
+		fields = append(fields, fp)
+	}
+	resp.Profile.Fields = fields
+	return resp, nil
+}
+
+func profileResponse(record *dataset.CoreDatasetProfile) (*dataset.ProfileResponse, error) {
+	profile, err := dataset.ParseProfile(record.Result)
+	if err != nil {
+		return nil, err
+	}
+	return &dataset.ProfileResponse{
+		DatasetGroupID: record.DatasetGroupID,
+		Status:         record.Status,
+		Msg:            record.Msg,
+		ProfiledAt:     record.ProfiledAt,
+		UpdateTime:     record.UpdateTime,
+		Profile:        profile,
+	}, nil
+}
+
+// profileFloat reads a number as the drivers return it.
+func profileFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case []byte:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func profileInt(v interface{}) int64 {
+	f, _ := profileFloat(v)
+	return int64(f)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
+)
+
+func TestProfileTopValuesAndHistogram(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/profile.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec(`CREATE TABLE sales (city TEXT, amount INTEGER);
+		INSERT INTO sales VALUES ('Paris', 0), ('Paris', 5), ('Lyon', 100), ('Nice', NULL)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn, err := dsconn.NewConn(db, "sqlite", &datasource.ConnectionConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	source, err := datasetsql.Table(conn, "sales")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	intType := 2
+	city := &dataset.CoreDatasetTableField{ID: 1, OriginName: strPtr("city")}
+	amount := &dataset.CoreDatasetTableField{ID: 2, OriginName: strPtr("amount"), DeType: &intType}
+	svc := NewDatasetService(nil, nil, nil)
+
+	top, err := svc.profileTopValues(context.Background(), conn, source, city)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(top) != 3 || top[0].Value != "Paris" || top[0].Count != 2 {
+		t.Fatalf("unexpected top values: %+v", top)
+	}
+
+	buckets, err := svc.profileHistogram(context.Background(), conn, source, amount)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(buckets) != profileBuckets {
+		t.Fatalf("expected every bucket, got %+v", buckets)
+	}
+	if buckets[0].Lower != 0 || buckets[0].Upper != 10 || buckets[0].Count != 2 || buckets[5].Count != 0 ||
+		buckets[9].Upper != 100 || buckets[9].Count != 1 {
+		t.Fatalf("unexpected buckets: %+v", buckets)
+	}
+}
+
+func TestProfileFloat(t *testing.T) {
+	for _, v := range []interface{}{int64(3), uint64(3), float32(3), "3", []byte("3.0")} {
+		if f, ok := profileFloat(v); !ok || f != 3 {
+			t.Errorf("profileFloat(%#v) = %v, %v", v, f, ok)
+		}
+	}
+	if _, ok := profileFloat(nil); ok {
+		t.Error("nil is not a number")
+	}
+}
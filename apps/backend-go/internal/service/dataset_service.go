@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -9,26 +10,59 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/lineage"
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/sqlparse"
 	"dataease/backend/internal/repository"
 
 	"gorm.io/gorm"
 )
 
 type DatasetService struct {
-	repo *repository.DatasetRepository
+	repo    *repository.DatasetRepository
+	dsRepo  *repository.DatasourceRepository
+	conns   *dsconn.Manager
+	lineage *LineageService
+	sql     *sqlparse.Validator
+	rowPerm *RowPermissionService
+	users   *repository.UserRepository
+
+	columnPerm *ColumnPermissionService
+
+	profileMu sync.Mutex
+	profiling map[int64]struct{}
 }
 
-type sqlVariableDetailRaw struct {
-	VariableName string        `json:"variableName"`
-	Type         []string      `json:"type"`
-	Params       []interface{} `json:"params"`
+// SetLineage enables the impact list of delete confirmations.
+func (s *DatasetService) SetLineage(lineage *LineageService) {
+	s.lineage = lineage
 }
 
-func NewDatasetService(repo *repository.DatasetRepository) *DatasetService {
-	return &DatasetService{repo: repo}
+func NewDatasetService(repo *repository.DatasetRepository, dsRepo *repository.DatasourceRepository, conns *dsconn.Manager) *DatasetService {
+	return &DatasetService{repo: repo, dsRepo: dsRepo, conns: conns, sql: sqlparse.NewValidator(nil)}
+}
+
+// SetRowPermissions applies the row permissions of users to the rows they
+// export.
+func (s *DatasetService) SetRowPermissions(rowPerm *RowPermissionService) {
+	s.rowPerm = rowPerm
+}
+
+// SetDeniedSQLFunctions replaces the functions custom SQL may not call.
+func (s *DatasetService) SetDeniedSQLFunctions(names []string) {
+	s.sql = sqlparse.NewValidator(names)
+}
+
+// CheckSQL validates the bound query of a custom SQL table before it runs.
+func (s *DatasetService) CheckSQL(query string) error {
+	_, err := s.sql.Validate(query)
+	return err
 }
 
 func (s *DatasetService) Tree(req *dataset.TreeRequest) ([]dataset.TreeNode, error) {
@@ -87,25 +121,35 @@ func (s *DatasetService) Fields(req *dataset.FieldsRequest) ([]*dataset.CoreData
 	return s.repo.ListFields(req.DatasetGroupID)
 }
 
-func (s *DatasetService) Preview(req *dataset.PreviewRequest) (*dataset.PreviewResponse, error) {
+func (s *DatasetService) Preview(ctx context.Context, req *dataset.PreviewRequest) (*dataset.PreviewResponse, error) {
 	limit := req.Limit
 	if limit < 1 {
 		limit = 100
 	}
 
-	tableName, err := s.repo.FindPrimaryTableName(req.DatasetGroupID)
+	def, err := s.repo.GetDefinition(req.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	conn, source, err := s.datasetSource(def, &datasetsql.Values{Editing: true})
 	if err != nil {
 		return nil, err
 	}
+	ctx = dsconn.WithDatasetTables(ctx, def.TableIDs()...)
 
-	rows, err := s.repo.PreviewRows(tableName, limit)
+	rows, err := s.repo.PreviewSource(ctx, conn, source, limit)
 	if err != nil {
 		return nil, err
 	}
-	total, err := s.repo.CountRows(tableName)
+	total, err := s.repo.CountSource(ctx, conn, source)
 	if err != nil {
 		return nil, err
 	}
+	masks, err := s.columnMasks(ctx, req.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	masks.Rows(rows, sourceColumns(def.Fields, source))
 
 	columns := make([]string, 0)
 	if len(rows) > 0 {
@@ -122,7 +166,7 @@ func (s *DatasetService) Preview(req *dataset.PreviewRequest) (*dataset.PreviewR
 	}, nil
 }
 
-func (s *DatasetService) PreviewSQL(req *dataset.SQLPreviewRequest) (map[string]interface{}, error) {
+func (s *DatasetService) PreviewSQL(ctx context.Context, req *dataset.SQLPreviewRequest) (map[string]interface{}, error) {
 	empty := map[string]interface{}{
 		"data": dataset.SQLPreviewData{
 			Fields: []dataset.SQLPreviewField{},
@@ -147,11 +191,37 @@ func (s *DatasetService) PreviewSQL(req *dataset.SQLPreviewRequest) (map[string]
 		return empty, nil
 	}
 
-	if err := validatePreviewSQL(rawSQL); err != nil {
+	// Variables are bound, with the values of the editor, before the SQL is
+	// parsed: ${name} is no SQL.
+	variables, err := dataset.ParseSQLVariables(&req.SQLVariableDetails)
+	if err != nil {
+		return nil, err
+	}
+	tableID, _ := strconv.ParseInt(strings.TrimSpace(req.TableID), 10, 64)
+	boundSQL, args, err := datasetsql.Bind(tableID, rawSQL, variables, &datasetsql.Values{Editing: true})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := s.sql.Validate(boundSQL)
+	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.repo.PreviewSQL(rawSQL, 100)
+	conn, err := s.datasourceConnection(req.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := s.sqlTables(ctx, req.DatasourceID, conn)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSQLTables(conn, tables, parsed.Tables); err != nil {
+		return nil, err
+	}
+	if tableID > 0 {
+		ctx = dsconn.WithDatasetTables(ctx, tableID)
+	}
+	rows, err := s.repo.PreviewSQL(ctx, conn, boundSQL, args, 100)
 	if err != nil {
 		return nil, err
 	}
@@ -198,8 +268,8 @@ func (s *DatasetService) GetSQLParams(ids []int64) ([]dataset.SQLVariableDetails
 				continue
 			}
 
-			rawList := make([]sqlVariableDetailRaw, 0)
-			if err = json.Unmarshal([]byte(*table.SQLVariables), &rawList); err != nil {
+			rawList, parseErr := dataset.ParseSQLVariables(table.SQLVariables)
+			if parseErr != nil {
 				continue
 			}
 
@@ -217,7 +287,7 @@ func (s *DatasetService) GetSQLParams(ids []int64) ([]dataset.SQLVariableDetails
 					DatasetGroupID:  datasetGroupID,
 					DatasetTableID:  table.ID,
 					DatasetFullName: fullName,
-					DeType:          inferSQLVariableDeType(raw.Type),
+					DeType:          raw.DeType(),
 				}
 				result = append(result, item)
 			}
@@ -227,7 +297,7 @@ func (s *DatasetService) GetSQLParams(ids []int64) ([]dataset.SQLVariableDetails
 	return result, nil
 }
 
-func (s *DatasetService) GetFieldEnum(req *dataset.MultFieldValuesRequest) ([]string, error) {
+func (s *DatasetService) GetFieldEnum(ctx context.Context, req *dataset.MultFieldValuesRequest) ([]string, error) {
 	if req == nil || len(req.FieldIDs) == 0 {
 		return []string{}, nil
 	}
@@ -249,7 +319,7 @@ func (s *DatasetService) GetFieldEnum(req *dataset.MultFieldValuesRequest) ([]st
 		}
 		uniqField[fieldID] = struct{}{}
 
-		field, tableName, columnName, err := s.resolveEnumFieldTarget(fieldID)
+		target, err := s.resolveEnumFieldTarget(fieldID)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				continue
@@ -257,17 +327,28 @@ func (s *DatasetService) GetFieldEnum(req *dataset.MultFieldValuesRequest) ([]st
 			return nil, err
 		}
 
-		filters, err := s.buildEnumFilterClauses(req.Filter, tableName)
+		masks, err := s.columnMasks(ctx, target.field.DatasetGroupID)
+		if err != nil {
+			return nil, err
+		}
+		if masks.Hidden(fieldID) {
+			continue
+		}
+
+		filters, err := s.buildEnumFilterClauses(req.Filter, target.key, masks)
 		if err != nil {
 			return nil, err
 		}
-		values, err := s.repo.QueryDistinctValues(tableName, columnName, filters, limit)
+		values, err := s.repo.QueryDistinctValues(dsconn.WithDatasetTables(ctx, target.tables...), target.conn, target.source, target.column, filters, limit)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, value := range values {
-			normalized := normalizeEnumValue(value, field.DeType)
+			if masks.Masked(fieldID) {
+				value = fmt.Sprint(masks.Value(fieldID, value))
+			}
+			normalized := normalizeEnumValue(value, target.field.DeType)
 			if normalized == "" {
 				continue
 			}
@@ -281,47 +362,47 @@ func (s *DatasetService) GetFieldEnum(req *dataset.MultFieldValuesRequest) ([]st
 	return result, nil
 }
 
-func (s *DatasetService) GetFieldEnumObj(req *dataset.EnumValueRequest) ([]map[string]interface{}, error) {
+func (s *DatasetService) GetFieldEnumObj(ctx context.Context, req *dataset.EnumValueRequest) ([]map[string]interface{}, error) {
 	if req == nil || req.QueryID <= 0 {
 		return []map[string]interface{}{}, nil
 	}
 
-	queryField, tableName, queryColumn, err := s.resolveEnumFieldTarget(req.QueryID)
+	query, err := s.resolveEnumFieldTarget(req.QueryID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return []map[string]interface{}{}, nil
 		}
 		return nil, err
 	}
+	queryField, queryColumn := query.field, query.column
+	masks, err := s.columnMasks(ctx, queryField.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	if masks.Hidden(req.QueryID) {
+		return []map[string]interface{}{}, nil
+	}
 
 	displayID := req.DisplayID
 	if displayID <= 0 {
 		displayID = req.QueryID
 	}
-	displayField, _, displayColumn, err := s.resolveEnumFieldTarget(displayID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			displayID = req.QueryID
-			displayField = queryField
-			displayColumn = queryColumn
-		} else {
-			return nil, err
-		}
+	displayField, displayColumn := queryField, queryColumn
+	display, err := s.resolveEnumFieldTarget(displayID)
+	switch {
+	case err == nil && display.key == query.key && !masks.Hidden(displayID):
+		displayField, displayColumn = display.field, display.column
+	case err == nil || errors.Is(err, gorm.ErrRecordNotFound):
+		displayID = req.QueryID
+	default:
+		return nil, err
 	}
 
+	sortColumn := ""
 	if req.SortID > 0 {
-		_, sortTableName, _, sortErr := s.resolveEnumFieldTarget(req.SortID)
-		if sortErr == nil && sortTableName != tableName {
-			req.SortID = 0
-		}
-	}
-
-	if displayID != req.QueryID {
-		_, displayTableName, _, displayErr := s.resolveEnumFieldTarget(displayID)
-		if displayErr == nil && displayTableName != tableName {
-			displayID = req.QueryID
-			displayField = queryField
-			displayColumn = queryColumn
+		sort, sortErr := s.resolveEnumFieldTarget(req.SortID)
+		if sortErr == nil && sort.key == query.key && !masks.Hidden(req.SortID) {
+			sortColumn = sort.column
 		}
 	}
 
@@ -330,7 +411,7 @@ func (s *DatasetService) GetFieldEnumObj(req *dataset.EnumValueRequest) ([]map[s
 		columns = append(columns, dataset.EnumObjectColumn{Column: displayColumn, Alias: enumAlias(displayID)})
 	}
 
-	filters, err := s.buildEnumFilterClauses(req.Filter, tableName)
+	filters, err := s.buildEnumFilterClauses(req.Filter, query.key, masks)
 	if err != nil {
 		return nil, err
 	}
@@ -344,24 +425,24 @@ func (s *DatasetService) GetFieldEnumObj(req *dataset.EnumValueRequest) ([]map[s
 	if displayID == req.QueryID {
 		searchColumn = queryColumn
 	}
-
-	sortColumn := ""
-	if req.SortID > 0 {
-		_, _, resolvedSortColumn, sortErr := s.resolveEnumFieldTarget(req.SortID)
-		if sortErr == nil {
-			sortColumn = resolvedSortColumn
-		}
+	// Searching masked values would tell the clear values apart.
+	searchText := req.SearchText
+	if masks.Masked(displayID) {
+		searchText = ""
 	}
+
 	if sortColumn == "" {
 		sortColumn = searchColumn
 	}
 
 	rows, err := s.repo.QueryDistinctObjectValues(
-		tableName,
+		dsconn.WithDatasetTables(ctx, query.tables...),
+		query.conn,
+		query.source,
 		columns,
 		filters,
 		searchColumn,
-		req.SearchText,
+		searchText,
 		sortColumn,
 		req.Sort,
 		limit,
@@ -386,7 +467,7 @@ func (s *DatasetService) GetFieldEnumObj(req *dataset.EnumValueRequest) ([]map[s
 			if fieldID == displayID {
 				deType = displayField.DeType
 			}
-			normalized := normalizeEnumValue(fmt.Sprintf("%v", normalizePreviewValue(rawValue)), deType)
+			normalized := normalizeEnumValue(fmt.Sprintf("%v", masks.Value(fieldID, normalizePreviewValue(rawValue))), deType)
 			if normalized == "" {
 				hasEmpty = true
 				break
@@ -411,62 +492,143 @@ func (s *DatasetService) GetFieldEnumObj(req *dataset.EnumValueRequest) ([]map[s
 	return result, nil
 }
 
-func (s *DatasetService) GetFieldEnumDs(fieldID int64) ([]string, error) {
+func (s *DatasetService) GetFieldEnumDs(ctx context.Context, fieldID int64) ([]string, error) {
 	if fieldID <= 0 {
 		return []string{}, nil
 	}
-	return s.GetFieldEnum(&dataset.MultFieldValuesRequest{FieldIDs: []int64{fieldID}, ResultMode: 0})
+	return s.GetFieldEnum(ctx, &dataset.MultFieldValuesRequest{FieldIDs: []int64{fieldID}, ResultMode: 0})
 }
 
-func (s *DatasetService) PerDelete(id int64) (bool, error) {
+// PerDelete reports the charts and visualizations that break when the
+// dataset is deleted.
+func (s *DatasetService) PerDelete(id int64) (*lineage.DeleteImpact, error) {
 	if id <= 0 {
-		return false, fmt.Errorf("dataset id is required")
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	if s.lineage != nil {
+		return s.lineage.Impact(lineage.TypeDataset, id)
 	}
 	count, err := s.repo.CountChartRelations(id)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return count > 0, nil
+	return &lineage.DeleteImpact{InUse: count > 0, Impact: []lineage.Node{}}, nil
+}
+
+// enumTarget is where the values of a dataset field are read from. Filters
+// and related fields only apply within one key.
+type enumTarget struct {
+	field  *dataset.CoreDatasetTableField
+	key    string
+	conn   *dsconn.Conn
+	source *datasetsql.Source
+	column string
+	// tables are the dataset tables the values are read from.
+	tables []int64
 }
 
-func (s *DatasetService) resolveEnumFieldTarget(fieldID int64) (*dataset.CoreDatasetTableField, string, string, error) {
+// resolveEnumFieldTarget reads fields of datasets with a model from the
+// compiled model and the other fields from their own table.
+func (s *DatasetService) resolveEnumFieldTarget(fieldID int64) (*enumTarget, error) {
 	field, err := s.repo.GetFieldByID(fieldID)
 	if err != nil {
-		return nil, "", "", err
-	}
-
-	columnName := ""
-	if field.OriginName != nil {
-		columnName = strings.TrimSpace(*field.OriginName)
-	}
-	if columnName == "" && field.DataeaseName != nil {
-		columnName = strings.TrimSpace(*field.DataeaseName)
+		return nil, err
 	}
-	if columnName == "" && field.Name != nil {
-		columnName = strings.TrimSpace(*field.Name)
+	def, err := s.repo.GetDefinition(field.DatasetGroupID)
+	if err != nil {
+		return nil, err
 	}
-	if columnName == "" {
-		return nil, "", "", fmt.Errorf("dataset field origin name is required")
+
+	target := &enumTarget{field: field}
+	if def.Model != nil {
+		if target.conn, target.source, err = s.datasetSource(def, nil); err != nil {
+			return nil, err
+		}
+		if !target.source.Exposes(field) {
+			return nil, fmt.Errorf("dataset field %d is not selected by the dataset model", field.ID)
+		}
+		target.key = fmt.Sprintf("dataset:%d", def.Group.ID)
+		target.column = target.source.Column(field)
+		target.tables = def.TableIDs()
+		return target, nil
 	}
 
-	tableName := ""
+	var table *dataset.CoreDatasetTable
 	if field.DatasetTableID != nil && *field.DatasetTableID > 0 {
-		table, tableErr := s.repo.GetTableByID(*field.DatasetTableID)
-		if tableErr == nil && table.PhysicalTable != nil {
-			tableName = strings.TrimSpace(*table.PhysicalTable)
+		table, err = s.repo.GetTableByID(*field.DatasetTableID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
 		}
 	}
-	if tableName == "" {
-		tableName, err = s.repo.FindPrimaryTableName(field.DatasetGroupID)
-		if err != nil {
-			return nil, "", "", err
+	if table == nil {
+		if len(def.Tables) == 0 {
+			return nil, fmt.Errorf("dataset has no table")
 		}
+		table = def.Tables[0]
+	}
+	if table.PhysicalTable == nil || strings.TrimSpace(*table.PhysicalTable) == "" {
+		return nil, fmt.Errorf("dataset table_name is empty")
+	}
+	if target.conn, err = s.tableConnection(table); err != nil {
+		return nil, err
+	}
+	if target.source, err = datasetsql.ForTable(target.conn, table, nil); err != nil {
+		return nil, err
+	}
+	target.source = datasetsql.WithFields(target.conn, target.source, datasetsql.TableFields(def.Fields, table.ID))
+	target.key = "table:" + strings.TrimSpace(*table.PhysicalTable)
+	target.tables = []int64{table.ID}
+	if target.column = target.source.Column(field); target.column == "" {
+		return nil, fmt.Errorf("dataset field origin name is required")
+	}
+	return target, nil
+}
+
+// datasetSource connects to the datasource of a dataset and compiles what
+// its queries read from, binding its SQL variables from values.
+// datasetSource compiles the source of a dataset, checking the SQL of its
+// custom SQL tables.
+func (s *DatasetService) datasetSource(def *dataset.Definition, values *datasetsql.Values) (*dsconn.Conn, *datasetsql.Source, error) {
+	conn, err := s.datasourceConnection(def.DatasourceID())
+	if err != nil {
+		return nil, nil, err
+	}
+	checked := datasetsql.Values{Check: s.CheckSQL}
+	if values != nil {
+		checked.Layers, checked.Editing = values.Layers, values.Editing
 	}
+	source, err := datasetsql.Compile(conn, def, &checked)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, source, nil
+}
 
-	return field, tableName, columnName, nil
+func (s *DatasetService) tableConnection(table *dataset.CoreDatasetTable) (*dsconn.Conn, error) {
+	if table.DatasourceID == nil || *table.DatasourceID <= 0 {
+		return nil, fmt.Errorf("dataset table does not bind datasource")
+	}
+	return s.datasourceConnection(*table.DatasourceID)
 }
 
-func (s *DatasetService) buildEnumFilterClauses(filters []dataset.EnumFilter, targetTableName string) ([]dataset.EnumFilterClause, error) {
+func (s *DatasetService) datasourceConnection(datasourceID int64) (*dsconn.Conn, error) {
+	if datasourceID <= 0 {
+		return nil, fmt.Errorf("datasource id is required")
+	}
+	ds, err := s.dsRepo.GetByID(datasourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("datasource not found")
+		}
+		return nil, err
+	}
+	return s.conns.Get(ds)
+}
+
+// buildEnumFilterClauses keeps the filters on the source of targetKey. Those
+// on fields hidden from the reader are dropped; masked fields cannot be
+// filtered by value.
+func (s *DatasetService) buildEnumFilterClauses(filters []dataset.EnumFilter, targetKey string, masks permission.ColumnMasks) ([]dataset.EnumFilterClause, error) {
 	clauses := make([]dataset.EnumFilterClause, 0)
 	for _, filter := range filters {
 		if strings.TrimSpace(filter.Operator) != "" && !strings.EqualFold(strings.TrimSpace(filter.Operator), "in") {
@@ -482,17 +644,20 @@ func (s *DatasetService) buildEnumFilterClauses(filters []dataset.EnumFilter, ta
 		}
 
 		for _, id := range ids {
-			_, tableName, columnName, err := s.resolveEnumFieldTarget(id)
+			target, err := s.resolveEnumFieldTarget(id)
 			if err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
 					continue
 				}
 				return nil, err
 			}
-			if tableName != targetTableName {
+			if target.key != targetKey || masks.Hidden(id) {
 				continue
 			}
-			clauses = append(clauses, dataset.EnumFilterClause{Column: columnName, Values: values})
+			if masks.Masked(id) {
+				return nil, fmt.Errorf("field %d is masked and cannot be filtered by value", id)
+			}
+			clauses = append(clauses, dataset.EnumFilterClause{Column: target.column, Values: values})
 		}
 	}
 	return clauses, nil
@@ -613,10 +778,17 @@ func (s *DatasetService) Save(req *dataset.WriteRequest) (*dataset.CoreDatasetGr
 	if req.Type != nil {
 		existing.Type = req.Type
 	}
+	existing.UpdateBy, existing.LastUpdateTime = editStamp(req.UserID)
 
 	if err = s.repo.UpdateGroup(existing); err != nil {
 		return nil, err
 	}
+	if req.Model != nil {
+		if err = s.saveModel(existing, req.Model); err != nil {
+			return nil, err
+		}
+	}
+	s.recordVersion(existing.ID)
 	return existing, nil
 }
 
@@ -660,18 +832,29 @@ func (s *DatasetService) Create(req *dataset.WriteRequest) (*dataset.CoreDataset
 		nodeType = dataset.NodeTypeFolder
 	}
 	delFlag := 0
+	by, now := editStamp(req.UserID)
 	group := &dataset.CoreDatasetGroup{
-		Name:     name,
-		PID:      &pid,
-		Level:    &level,
-		NodeType: &nodeType,
-		Type:     req.Type,
-		DelFlag:  &delFlag,
+		Name:           name,
+		PID:            &pid,
+		Level:          &level,
+		NodeType:       &nodeType,
+		Type:           req.Type,
+		DelFlag:        &delFlag,
+		CreateBy:       by,
+		CreateTime:     now,
+		UpdateBy:       by,
+		LastUpdateTime: now,
 	}
 
 	if err = s.repo.CreateGroup(group); err != nil {
 		return nil, err
 	}
+	if req.Model != nil {
+		if err = s.saveModel(group, req.Model); err != nil {
+			return nil, err
+		}
+	}
+	s.recordVersion(group.ID)
 
 	return group, nil
 }
@@ -800,6 +983,16 @@ func (s *DatasetService) isDescendant(rootID int64, targetID int64) (bool, error
 	return false, nil
 }
 
+// editStamp is who saves a dataset, when known, and the time of the save.
+func editStamp(userID int64) (*string, *int64) {
+	now := time.Now().UnixMilli()
+	if userID <= 0 {
+		return nil, &now
+	}
+	by := strconv.FormatInt(userID, 10)
+	return &by, &now
+}
+
 func normalizedDatasetPID(pid *int64) int64 {
 	if pid == nil {
 		return 0
@@ -860,23 +1053,48 @@ func (s *DatasetService) datasetFullName(datasetGroupID int64) (string, error) {
 	return strings.Join(names, "/"), nil
 }
 
-func validatePreviewSQL(rawSQL string) error {
-	text := strings.TrimSpace(strings.TrimSuffix(rawSQL, ";"))
-	lower := strings.ToLower(text)
-	if text == "" {
-		return fmt.Errorf("sql is required")
+// sqlTables lists the tables custom SQL on a datasource may read. Excel and
+// API datasources share the engine with the application tables, so only the
+// tables registered for them are listed.
+func (s *DatasetService) sqlTables(ctx context.Context, datasourceID int64, conn *dsconn.Conn) ([]string, error) {
+	ds, err := s.dsRepo.GetByID(datasourceID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if dsconn.IsEngineType(ds.Type) {
+		tables, err := s.dsRepo.ListTables(datasourceID)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			names = append(names, table.TableName)
+		}
+		return names, nil
 	}
-	if !(strings.HasPrefix(lower, "select") || strings.HasPrefix(lower, "with")) {
-		return fmt.Errorf("only select query is supported")
+	tables, err := conn.ListTablesContext(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if strings.Contains(text, ";") {
-		return fmt.Errorf("only single select statement is supported")
+	for _, table := range tables {
+		names = append(names, table.Name)
 	}
-	blocked := []string{" insert ", " update ", " delete ", " drop ", " alter ", " truncate ", " create "}
-	padded := " " + lower + " "
-	for _, token := range blocked {
-		if strings.Contains(padded, token) {
-			return fmt.Errorf("unsupported sql statement")
+	return names, nil
+}
+
+// checkSQLTables rejects custom SQL reading tables outside the datasource:
+// every table must be one of tables and may only be qualified by its own
+// database or schema.
+func checkSQLTables(conn *dsconn.Conn, tables []string, refs []sqlparse.TableRef) error {
+	known := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		known[strings.ToLower(table)] = true
+	}
+	namespace := conn.Namespace()
+	for _, ref := range refs {
+		foreign := len(ref.Qualifiers) > 1 || (len(ref.Qualifiers) == 1 && !strings.EqualFold(ref.Qualifiers[0], namespace))
+		if foreign || !known[strings.ToLower(ref.Name)] {
+			return fmt.Errorf("table %s is not in the datasource", ref)
 		}
 	}
 	return nil
@@ -940,7 +1158,7 @@ func inferPreviewDeType(v interface{}) int {
 		if text == "" {
 			return 0
 		}
-		if isDateTimeText(text) {
+		if _, ok := parseDateTimeText(text); ok {
 			return 1
 		}
 		if _, err := strconv.ParseInt(text, 10, 64); err == nil {
@@ -955,30 +1173,7 @@ func inferPreviewDeType(v interface{}) int {
 	}
 }
 
-func inferSQLVariableDeType(typeList []string) int {
-	if len(typeList) == 0 {
-		return 0
-	}
-	typeText := strings.ToUpper(strings.TrimSpace(typeList[0]))
-	if typeText == "" {
-		return 0
-	}
-	if strings.Contains(typeText, "DATETIME") || strings.Contains(typeText, "TIMESTAMP") || strings.Contains(typeText, "DATE") || strings.Contains(typeText, "TIME") || strings.Contains(typeText, "YEAR") {
-		return 1
-	}
-	if strings.Contains(typeText, "DOUBLE") || strings.Contains(typeText, "FLOAT") || strings.Contains(typeText, "DECIMAL") || strings.Contains(typeText, "NUMERIC") || strings.Contains(typeText, "REAL") {
-		return 3
-	}
-	if strings.Contains(typeText, "INT") || strings.Contains(typeText, "LONG") || strings.Contains(typeText, "SHORT") || strings.Contains(typeText, "BIGINT") || strings.Contains(typeText, "SMALLINT") || strings.Contains(typeText, "TINYINT") {
-		return 2
-	}
-	if strings.Contains(typeText, "BOOL") {
-		return 4
-	}
-	return 0
-}
-
-func isDateTimeText(text string) bool {
+func parseDateTimeText(text string) (time.Time, bool) {
 	layouts := []string{
 		time.RFC3339,
 		"2006-01-02 15:04:05",
@@ -987,9 +1182,9 @@ func isDateTimeText(text string) bool {
 		"2006/01/02",
 	}
 	for _, layout := range layouts {
-		if _, err := time.Parse(layout, text); err == nil {
-			return true
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
 		}
 	}
-	return false
+	return time.Time{}, false
 }
@@ -1,40 +1,92 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"strings"
 	"testing"
+
+	"dataease/backend/internal/domain/chart"
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/sqlparse"
 )
 
-func TestInferSQLVariableDeType(t *testing.T) {
-	cases := []struct {
-		name     string
-		types    []string
-		expected int
-	}{
-		{name: "datetime", types: []string{"DATETIME"}, expected: 1},
-		{name: "double", types: []string{"DOUBLE"}, expected: 3},
-		{name: "bigint", types: []string{"BIGINT"}, expected: 2},
-		{name: "text", types: []string{"TEXT"}, expected: 0},
+func TestPreviewSQL_Validates(t *testing.T) {
+	svc := NewDatasetService(nil, nil, nil)
+	for _, sql := range []string{
+		"INSERT INTO x VALUES (1)",
+		"SELECT 1; SELECT 2",
+		"SELECT * FROM core_dataset_group\nDROP TABLE core_dataset_group",
+		"SELECT SLEEP(5)",
+	} {
+		if _, err := svc.PreviewSQL(context.Background(), &dataset.SQLPreviewRequest{DatasourceID: 1, SQL: sql}); err == nil {
+			t.Fatalf("expected %q to be rejected", sql)
+		}
 	}
 
-	for _, tc := range cases {
-		if actual := inferSQLVariableDeType(tc.types); actual != tc.expected {
-			t.Fatalf("%s expected %d, got %d", tc.name, tc.expected, actual)
-		}
+	svc.SetDeniedSQLFunctions([]string{"md5"})
+	if _, err := svc.PreviewSQL(context.Background(), &dataset.SQLPreviewRequest{DatasourceID: 1, SQL: "SELECT MD5('x')"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected configured function to be rejected, got %v", err)
+	}
+
+	// Variables are bound before the SQL is parsed.
+	req := &dataset.SQLPreviewRequest{
+		DatasourceID:       1,
+		SQL:                "SELECT MD5(${name}) FROM t",
+		SQLVariableDetails: `[{"variableName":"name","type":["VARCHAR"],"defaultValue":"x","defaultValueScope":"EDIT"}]`,
+	}
+	if _, err := svc.PreviewSQL(context.Background(), req); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected the bound SQL to be validated, got %v", err)
 	}
 }
 
-func TestValidatePreviewSQL(t *testing.T) {
-	if err := validatePreviewSQL("SELECT * FROM core_dataset_group"); err != nil {
-		t.Fatalf("expected select sql valid, got error: %v", err)
+func TestCheckSQLTables(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/ds.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("CREATE TABLE orders (id INTEGER)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn, err := dsconn.NewConn(db, "sqlite", &datasource.ConnectionConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if err := validatePreviewSQL("INSERT INTO x VALUES (1)"); err == nil {
-		t.Fatal("expected insert sql to be rejected")
+	validator := sqlparse.NewValidator(nil)
+	cases := map[string]bool{
+		"SELECT * FROM orders":                                            true,
+		"SELECT * FROM main.Orders":                                       true,
+		"WITH o AS (SELECT 1) SELECT * FROM o":                            true,
+		"SELECT * FROM customers":                                         false,
+		"SELECT * FROM other.orders":                                      false,
+		"SELECT * FROM orders WHERE id IN (SELECT id FROM x.main.orders)": false,
+	}
+	for query, allowed := range cases {
+		parsed, err := validator.Validate(query)
+		if err != nil {
+			t.Fatalf("Validate(%q): %v", query, err)
+		}
+		if err = checkSQLTables(conn, []string{"orders"}, parsed.Tables); (err == nil) != allowed {
+			t.Errorf("checkSQLTables(%q): allowed=%v, got %v", query, allowed, err)
+		}
 	}
 
-	if err := validatePreviewSQL("SELECT 1; SELECT 2"); err == nil {
-		t.Fatal("expected multi statement sql to be rejected")
+	// Engine backed datasources only list their registered tables, not the
+	// application tables stored next to them.
+	if _, err = db.Exec("CREATE TABLE core_user (id INTEGER)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parsed, err := validator.Validate("SELECT * FROM core_user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = checkSQLTables(conn, []string{"excel_0123456789abcdef"}, parsed.Tables); err == nil {
+		t.Error("expected a core_ table to be rejected")
 	}
 }
 
@@ -68,3 +120,149 @@ func TestNormalizeEnumValueScientific(t *testing.T) {
 		t.Fatalf("expected non scientific notation, got %s", normalized)
 	}
 }
+
+func TestCheckCalcField(t *testing.T) {
+	text, number := 0, 2
+	extField := 2
+	chartA, chartB := int64(7), int64(8)
+	column := func(id int64, deType *int, chartID *int64) *dataset.CoreDatasetTableField {
+		name := "c"
+		return &dataset.CoreDatasetTableField{ID: id, OriginName: &name, DeType: deType, ChartID: chartID}
+	}
+	formula := func(id int64, text string, chartID *int64) *dataset.CoreDatasetTableField {
+		name := "calc"
+		return &dataset.CoreDatasetTableField{ID: id, Name: &name, OriginName: &text, ExtField: &extField, ChartID: chartID}
+	}
+	fields := []*dataset.CoreDatasetTableField{
+		column(1, &number, nil),
+		column(2, &text, nil),
+		formula(3, "[1] * 2", &chartA),
+	}
+
+	field := formula(0, "SUM([1]) + 1", nil)
+	if err := checkCalcField(field, fields); err != nil {
+		t.Fatalf("checkCalcField: %v", err)
+	}
+	if *field.DeType != number || *field.GroupType != "q" {
+		t.Fatalf("unexpected types: %d %s", *field.DeType, *field.GroupType)
+	}
+
+	if err := checkCalcField(formula(0, "[3] + 1", &chartA), fields); err != nil {
+		t.Fatalf("chart formula: %v", err)
+	}
+	cases := map[*dataset.CoreDatasetTableField]string{
+		formula(0, "[3] + 1", &chartB): "position 1: unknown field [3]",
+		formula(0, "[3] + 1", nil):     "position 1: unknown field [3]",
+		formula(0, "UPPER([1])", nil):  "position 7: argument 1 of UPPER must be text",
+		formula(0, "[1] + ", nil):      "position 7",
+		formula(9, "[9] + 1", nil):     "position 1: circular reference",
+		formula(0, "  ", nil):          "formula is required",
+	}
+	for field, want := range cases {
+		err := checkCalcField(field, fields)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("checkCalcField(%q): expected error containing %q, got %v", *field.OriginName, want, err)
+		}
+	}
+}
+
+func TestRetype(t *testing.T) {
+	text, date, number := 0, 1, 2
+	name := "day"
+	field := &dataset.CoreDatasetTableField{ID: 1, OriginName: &name, DeType: &text}
+
+	format, dimension := "dd/MM/yyyy", "d"
+	if err := retype(field, &dataset.CoreDatasetTableField{DeType: &date, DateFormat: &format, GroupType: &dimension}); err != nil {
+		t.Fatalf("retype: %v", err)
+	}
+	if *field.DeExtractType != text || *field.DeType != date || *field.DateFormat != format || *field.GroupType != "d" {
+		t.Fatalf("unexpected field: %+v", field)
+	}
+	if err := retype(field, &dataset.CoreDatasetTableField{DeType: &number}); err != nil {
+		t.Fatalf("retype again: %v", err)
+	}
+	if *field.DeExtractType != text || *field.DeType != number || field.DateFormat != nil {
+		t.Fatalf("extract type should be kept: %+v", field)
+	}
+
+	bad := "dd.MM.yy"
+	err := retype(field, &dataset.CoreDatasetTableField{DeType: &date, DateFormat: &bad})
+	if err == nil || !strings.Contains(err.Error(), "invalid field type") {
+		t.Fatalf("expected invalid date format, got %v", err)
+	}
+	group := "x"
+	if err = retype(field, &dataset.CoreDatasetTableField{GroupType: &group}); err == nil {
+		t.Fatal("expected group type error")
+	}
+}
+
+func TestChartImpacts(t *testing.T) {
+	title := "Sales"
+	xAxis := `[{"id":"101","name":"region"}]`
+	yAxis := `[{"id":102,"summary":"sum"}]`
+	filter := `{"items":[{"fieldId":"103","filterType":"logic"}]}`
+	other := `[{"id":"104"}]`
+	charts := []*chart.CoreChartView{
+		{ID: 1, Title: &title, XAxis: &xAxis, YAxis: &yAxis, CustomFilter: &filter},
+		{ID: 2, XAxis: &other},
+		{ID: 3},
+	}
+	chartID, extField := int64(3), datasetsql.ExtFieldCalculated
+	formula := "[105] / 2"
+	fields := []*dataset.CoreDatasetTableField{{ID: 200, ChartID: &chartID, ExtField: &extField, OriginName: &formula}}
+	removed := []dataset.FieldChange{{ID: 101}, {ID: 103}, {ID: 105}}
+
+	impacts := chartImpacts(charts, fields, removed)
+	if len(impacts) != 2 {
+		t.Fatalf("impacts = %+v", impacts)
+	}
+	if impacts[0].ID != 1 || impacts[0].Title != "Sales" || len(impacts[0].FieldIDs) != 2 ||
+		impacts[0].FieldIDs[0] != 101 || impacts[0].FieldIDs[1] != 103 {
+		t.Fatalf("chart 1 impact = %+v", impacts[0])
+	}
+	if impacts[1].ID != 3 || len(impacts[1].FieldIDs) != 1 || impacts[1].FieldIDs[0] != 105 {
+		t.Fatalf("chart 3 impact = %+v", impacts[1])
+	}
+	if got := chartImpacts(charts, fields, nil); len(got) != 0 {
+		t.Fatalf("expected no impacts, got %+v", got)
+	}
+}
+
+func TestDatasetKind(t *testing.T) {
+	excel := &datasource.CoreDatasource{ID: 1, Type: datasource.TypeExcel}
+	mysql := &datasource.CoreDatasource{ID: 2, Type: "mysql"}
+	db := &dataset.CoreDatasetTable{Type: strPtr(dataset.TableTypeDB)}
+	custom := &dataset.CoreDatasetTable{Type: strPtr(dataset.TableTypeSQL)}
+
+	cases := []struct {
+		tables  []*dataset.CoreDatasetTable
+		sources []*datasource.CoreDatasource
+		want    string
+	}{
+		{nil, nil, ""},
+		{[]*dataset.CoreDatasetTable{db}, []*datasource.CoreDatasource{mysql}, dataset.KindTable},
+		{[]*dataset.CoreDatasetTable{custom}, []*datasource.CoreDatasource{mysql}, dataset.KindSQL},
+		{[]*dataset.CoreDatasetTable{db}, []*datasource.CoreDatasource{excel}, dataset.KindExcel},
+		{[]*dataset.CoreDatasetTable{db, db}, []*datasource.CoreDatasource{excel, mysql}, dataset.KindJoin},
+	}
+	for i, tc := range cases {
+		if got := datasetKind(tc.tables, tc.sources); got != tc.want {
+			t.Errorf("case %d: got %q, want %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestTableSync(t *testing.T) {
+	older, newer := int64(100), int64(200)
+	last, status := tableSync([]*dataset.CoreDatasetTable{
+		{Status: strPtr(datasource.StatusError), LastUpdate: &older},
+		{Status: strPtr(datasource.StatusSuccess), LastUpdate: &newer},
+		{},
+	})
+	if last == nil || *last != newer || status != datasource.StatusError {
+		t.Fatalf("got %v %q, want the latest check and the failure", last, status)
+	}
+	if last, status = tableSync(nil); last != nil || status != "" {
+		t.Fatalf("tables never checked should report nothing, got %v %q", last, status)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const maxSQLLogPageSize = 100
+
+// LogQuery records a query run for dataset tables in the history of each
+// table. It is the query logger of the datasource connections.
+func (s *DatasetService) LogQuery(q dsconn.QueryLog) {
+	status := dataset.SQLLogCompleted
+	if q.Err != nil {
+		status = dataset.SQLLogError
+	}
+	logs := make([]*dataset.CoreDatasetTableSQLLog, 0, len(q.TableIDs))
+	for _, tableID := range q.TableIDs {
+		id, err := generateUUID()
+		if err != nil {
+			logger.Warn("Failed to log dataset query", zap.Error(err))
+			return
+		}
+		logs = append(logs, &dataset.CoreDatasetTableSQLLog{
+			ID:        id,
+			TableID:   strconv.FormatInt(tableID, 10),
+			StartTime: q.Start.UnixMilli(),
+			EndTime:   q.End.UnixMilli(),
+			Spend:     q.End.Sub(q.Start).Milliseconds(),
+			SQL:       q.SQL,
+			Status:    status,
+		})
+	}
+	if err := s.repo.CreateSQLLogs(logs); err != nil {
+		logger.Warn("Failed to log dataset query", zap.Int64s("tableIds", q.TableIDs), zap.Error(err))
+	}
+}
+
+// SQLLogs pages the query history of a dataset table.
+func (s *DatasetService) SQLLogs(req *dataset.SQLLogRequest) (*dataset.SQLLogPage, error) {
+	tableID := strings.TrimSpace(req.TableID)
+	if tableID == "" {
+		return nil, fmt.Errorf("table id is required")
+	}
+	page := req.GoPage
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > maxSQLLogPageSize {
+		pageSize = maxSQLLogPageSize
+	}
+	logs, total, err := s.repo.ListSQLLogs(tableID, req.MinSpend, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &dataset.SQLLogPage{List: logs, Total: total, PageNum: page, PageSize: pageSize}, nil
+}
+
+// DeleteSQLLogs clears the query history of a dataset table.
+func (s *DatasetService) DeleteSQLLogs(tableID string) error {
+	if strings.TrimSpace(tableID) == "" {
+		return fmt.Errorf("table id is required")
+	}
+	return s.repo.DeleteSQLLogs(tableID)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"dataease/backend/internal/domain/chart"
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/pkg/calcfield"
+	"dataease/backend/internal/pkg/datasetsql"
+	"dataease/backend/internal/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// recordVersion snapshots the definition of a dataset as its next version,
+// unless it is unchanged since the latest one. Folders have no versions.
+// A failure is logged rather than failing the save that triggered it.
+func (s *DatasetService) recordVersion(datasetGroupID int64) {
+	if _, err := s.snapshotVersion(datasetGroupID); err != nil {
+		logger.Warn("Failed to record dataset version", zap.Int64("datasetGroupId", datasetGroupID), zap.Error(err))
+	}
+}
+
+func (s *DatasetService) snapshotVersion(datasetGroupID int64) (int, error) {
+	def, err := s.repo.GetDefinition(datasetGroupID)
+	if err != nil {
+		return 0, err
+	}
+	if def.Group.NodeType == nil || *def.Group.NodeType != dataset.NodeTypeDataset {
+		return 0, nil
+	}
+	// Edit stamps change on every save and are not part of a version.
+	group := *def.Group
+	group.UpdateBy, group.LastUpdateTime = nil, nil
+	data, err := json.Marshal(&dataset.Snapshot{Group: &group, Tables: def.Tables, Fields: def.Fields})
+	if err != nil {
+		return 0, err
+	}
+	latest, err := s.repo.LatestVersion(datasetGroupID)
+	if err != nil {
+		return 0, err
+	}
+	number := 1
+	if latest != nil {
+		if latest.Snapshot == string(data) {
+			return latest.Version, nil
+		}
+		number = latest.Version + 1
+	}
+	version := &dataset.CoreDatasetVersion{
+		DatasetGroupID: datasetGroupID,
+		Version:        number,
+		Snapshot:       string(data),
+		CreateTime:     time.Now().UnixMilli(),
+	}
+	if err = s.repo.CreateVersion(version); err != nil {
+		return 0, err
+	}
+	return number, nil
+}
+
+// ListVersions returns the versions of a dataset, newest first.
+func (s *DatasetService) ListVersions(datasetGroupID int64) ([]dataset.CoreDatasetVersion, error) {
+	if datasetGroupID <= 0 {
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	return s.repo.ListVersions(datasetGroupID)
+}
+
+// DiffVersions compares two versions of a dataset.
+func (s *DatasetService) DiffVersions(req *dataset.VersionDiffRequest) (*dataset.VersionDiff, error) {
+	from, err := s.versionSnapshot(req.DatasetGroupID, req.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.versionSnapshot(req.DatasetGroupID, req.To)
+	if err != nil {
+		return nil, err
+	}
+	diff := dataset.DiffSnapshots(from, to)
+	diff.From, diff.To = req.From, req.To
+	return diff, nil
+}
+
+// Rollback puts a dataset back to one of its versions and records the
+// result as a new version. It reports the fields the rollback removes and
+// the charts that reference them; a dry run only reports.
+func (s *DatasetService) Rollback(req *dataset.VersionRollbackRequest) (*dataset.VersionRollbackResponse, error) {
+	target, err := s.versionSnapshot(req.DatasetGroupID, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	def, err := s.repo.GetDefinition(req.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	current := &dataset.Snapshot{Group: def.Group, Tables: def.Tables, Fields: def.Fields}
+	diff := dataset.DiffSnapshots(current, target)
+
+	fields, err := s.repo.ListFields(req.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	charts, err := s.repo.ListChartsByDataset(req.DatasetGroupID)
+	if err != nil {
+		return nil, err
+	}
+	result := &dataset.VersionRollbackResponse{
+		RemovedFields:  diff.RemovedFields,
+		AffectedCharts: chartImpacts(charts, fields, diff.RemovedFields),
+	}
+	if req.DryRun {
+		return result, nil
+	}
+
+	if err = s.repo.RestoreSnapshot(req.DatasetGroupID, target); err != nil {
+		return nil, err
+	}
+	if result.Version, err = s.snapshotVersion(req.DatasetGroupID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *DatasetService) versionSnapshot(datasetGroupID int64, number int) (*dataset.Snapshot, error) {
+	if datasetGroupID <= 0 || number <= 0 {
+		return nil, fmt.Errorf("dataset id and version are required")
+	}
+	version, err := s.repo.GetVersion(datasetGroupID, number)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("dataset version %d not found", number)
+		}
+		return nil, err
+	}
+	snapshot, err := dataset.ParseSnapshot(version.Snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot of dataset version %d: %w", number, err)
+	}
+	return snapshot, nil
+}
+
+// chartImpacts lists the charts that reference removed fields, on their
+// axes and filters or in the formulas of their calculated fields.
+func chartImpacts(charts []*chart.CoreChartView, fields []*dataset.CoreDatasetTableField, removed []dataset.FieldChange) []dataset.ChartImpact {
+	impacts := make([]dataset.ChartImpact, 0)
+	if len(removed) == 0 {
+		return impacts
+	}
+	gone := make(map[int64]bool, len(removed))
+	for _, field := range removed {
+		gone[field.ID] = true
+	}
+	formulas := make(map[int64][]int64)
+	for _, field := range fields {
+		if field.ChartID == nil || field.OriginName == nil || field.ExtField == nil || *field.ExtField != datasetsql.ExtFieldCalculated {
+			continue
+		}
+		if formula, err := calcfield.Parse(*field.OriginName); err == nil {
+			formulas[*field.ChartID] = append(formulas[*field.ChartID], formula.Refs()...)
+		}
+	}
+
+	for _, view := range charts {
+		refs := make(map[int64]bool)
+		for _, raw := range []*string{view.XAxis, view.YAxis, view.CustomFilter} {
+			if raw != nil {
+				collectFieldIDs(*raw, refs)
+			}
+		}
+		for _, id := range formulas[view.ID] {
+			refs[id] = true
+		}
+		ids := make([]int64, 0)
+		for id := range refs {
+			if gone[id] {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		impacts = append(impacts, dataset.ChartImpact{ID: view.ID, Title: stringValue(view.Title), FieldIDs: ids})
+	}
+	return impacts
+}
+
+// collectFieldIDs adds the field ids found in the JSON of a chart setting:
+// the id and fieldId of every object it holds.
+func collectFieldIDs(raw string, ids map[int64]bool) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			for key, item := range v {
+				if key == "id" || key == "fieldId" {
+					if id, ok := jsonID(item); ok {
+						ids[id] = true
+						continue
+					}
+				}
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+}
+
+// jsonID reads an id written as a number or, as the front end does for
+// large ids, as a string.
+func jsonID(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int64(v), v > 0
+	case string:
+		id, err := strconv.ParseInt(v, 10, 64)
+		return id, err == nil && id > 0
+	}
+	return 0, false
+}
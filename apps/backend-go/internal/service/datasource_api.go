@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/apisource"
+	"dataease/backend/internal/pkg/dsconn"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiPreviewRows  = 100
+	apiCheckTimeout = 30 * time.Second
+	apiSyncTimeout  = 10 * time.Minute
+)
+
+// CheckAPIDatasource fetches the first records of an API definition. Fields
+// are inferred from the first record when the definition has none.
+func (s *DatasourceService) CheckAPIDatasource(def *datasource.APIDefinition) (*datasource.APICheckResponse, error) {
+	if def == nil {
+		return nil, fmt.Errorf("api definition is required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiCheckTimeout)
+	defer cancel()
+	records, err := apisource.Fetch(ctx, s.apiClient, def, apiPreviewRows)
+	if err != nil {
+		return nil, err
+	}
+
+	checked := *def
+	checked.Fields = append([]datasource.APIField(nil), def.Fields...)
+	columns, rows, err := extractAPIRows(&checked, records)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		item := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			item[column.Name] = row[i]
+		}
+		data = append(data, item)
+	}
+	return &datasource.APICheckResponse{Definition: checked, Data: data, Total: len(records)}, nil
+}
+
+// SyncAPITable refreshes the engine tables of an API datasource. An empty
+// TableName refreshes every table of the datasource.
+func (s *DatasourceService) SyncAPITable(ctx context.Context, req *datasource.APISyncRequest) error {
+	if req == nil || req.DatasourceID <= 0 {
+		return fmt.Errorf("datasource id is required")
+	}
+	ds, err := s.repo.GetByID(req.DatasourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("datasource not found")
+		}
+		return err
+	}
+	if !isAPIType(ds.Type) {
+		return fmt.Errorf("datasource is not an API datasource")
+	}
+	raw := ""
+	if ds.Configuration != nil {
+		raw = *ds.Configuration
+	}
+	if raw, err = s.conns.OpenConfiguration(raw); err != nil {
+		return err
+	}
+	defs, err := datasource.DecodeAPIDefinitions(raw)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	only := strings.TrimSpace(req.TableName)
+	synced, syncErr := s.syncAPIDefinitions(ctx, defs, defs, only)
+	status := datasource.StatusSuccess
+	if syncErr != nil {
+		status = datasource.StatusError
+	}
+	if err = s.repo.UpdateStatus(ds.ID, status); err != nil {
+		return err
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+
+	configuration, err := s.marshalAPIDefinitions(synced)
+	if err != nil {
+		return err
+	}
+	ds.Configuration = &configuration
+	now := time.Now().UnixMilli()
+	ds.UpdateTime = &now
+	if err = s.repo.Update(ds); err != nil {
+		return err
+	}
+	return s.registerAPITables(ds.ID, synced)
+}
+
+// SyncAPIDatasource refreshes every table of an API datasource.
+func (s *DatasourceService) SyncAPIDatasource(ctx context.Context, id int64) error {
+	return s.SyncAPITable(ctx, &datasource.APISyncRequest{DatasourceID: id})
+}
+
+// syncAPIDefinitions materializes the definitions into engine tables, reusing
+// the physical table of definitions already present in previous, matched by
+// name; the others get a new table. Physical names in defs are ignored, as
+// the engine also holds the application tables. When only is set, other
+// definitions are left untouched. Tables of removed definitions are dropped.
+func (s *DatasourceService) syncAPIDefinitions(ctx context.Context, defs []datasource.APIDefinition, previous []datasource.APIDefinition, only string) ([]datasource.APIDefinition, error) {
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("api datasource requires at least one table")
+	}
+	conn, err := s.conns.Engine()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]string, len(previous))
+	for _, def := range previous {
+		if def.DeTableName != "" {
+			existing[def.Name] = def.DeTableName
+		}
+	}
+	seen := make(map[string]struct{}, len(defs))
+	result := make([]datasource.APIDefinition, len(defs))
+	created := make([]string, 0)
+	matched := only == ""
+	for i, def := range defs {
+		name := strings.TrimSpace(def.Name)
+		if name == "" {
+			return nil, fmt.Errorf("api table name is required")
+		}
+		if _, dup := seen[name]; dup {
+			return nil, fmt.Errorf("duplicate api table name: %s", name)
+		}
+		seen[name] = struct{}{}
+		def.Name = name
+		def.DeTableName = existing[name]
+		result[i] = def
+		if only != "" && only != name && only != def.DeTableName {
+			continue
+		}
+		matched = true
+
+		if result[i].DeTableName == "" {
+			tableName, idErr := newEngineTableName("api")
+			if idErr != nil {
+				s.dropEngineTables(created...)
+				return nil, idErr
+			}
+			result[i].DeTableName = tableName
+			created = append(created, tableName)
+		}
+		if err = s.materializeAPITable(ctx, conn, &result[i]); err != nil {
+			s.dropEngineTables(created...)
+			return nil, fmt.Errorf("api table %s: %w", name, err)
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("api table %s not found", only)
+	}
+
+	if only == "" {
+		stale := make([]string, 0)
+		for name, tableName := range existing {
+			if _, ok := seen[name]; !ok {
+				stale = append(stale, tableName)
+			}
+		}
+		s.dropEngineTables(stale...)
+	}
+	return result, nil
+}
+
+// materializeAPITable fetches every page and reloads the engine table
+// through a staging table, so a failing API keeps the previously synced
+// data.
+func (s *DatasourceService) materializeAPITable(ctx context.Context, conn *dsconn.Conn, def *datasource.APIDefinition) error {
+	ctx, cancel := context.WithTimeout(ctx, apiSyncTimeout)
+	defer cancel()
+	records, err := apisource.Fetch(ctx, s.apiClient, def, 0)
+	if err != nil {
+		return err
+	}
+	columns, rows, err := extractAPIRows(def, records)
+	if err != nil {
+		return err
+	}
+	return replaceEngineTable(ctx, conn, def.DeTableName, func(staging string) error {
+		if err := createEngineTable(ctx, conn, staging, columns); err != nil {
+			return err
+		}
+		return insertEngineRows(ctx, conn, staging, columns, rows)
+	})
+}
+
+// extractAPIRows maps records onto the definition fields, inferring them
+// first when the definition has none, and converts every cell to its deType.
+func extractAPIRows(def *datasource.APIDefinition, records []interface{}) ([]engineColumn, [][]interface{}, error) {
+	inferTypes := false
+	if len(def.Fields) == 0 {
+		if len(records) == 0 {
+			return nil, nil, fmt.Errorf("api returned no records to infer fields from")
+		}
+		def.Fields = apisource.InferFields(records[0])
+		inferTypes = true
+	}
+
+	columns := make([]engineColumn, 0, len(def.Fields))
+	seen := make(map[string]struct{}, len(def.Fields))
+	for i, field := range def.Fields {
+		name := truncateRunes(strings.TrimSpace(field.Name), excelColumnMaxLength)
+		if name == "" || strings.TrimSpace(field.JSONPath) == "" {
+			return nil, nil, fmt.Errorf("api field name and jsonPath are required")
+		}
+		key := strings.ToLower(name)
+		if _, dup := seen[key]; dup {
+			return nil, nil, fmt.Errorf("duplicate api field name: %s", name)
+		}
+		seen[key] = struct{}{}
+		def.Fields[i].Name = name
+		columns = append(columns, engineColumn{Name: name, DeType: field.DeType})
+	}
+
+	texts, err := apisource.Extract(records, def.Fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inferTypes {
+		for i := range columns {
+			values := make([]string, 0, len(texts))
+			for _, row := range texts {
+				if text, ok := row[i].(string); ok {
+					values = append(values, text)
+				}
+			}
+			columns[i].DeType = inferColumnDeType(values)
+			def.Fields[i].DeType = columns[i].DeType
+		}
+	}
+
+	rows := make([][]interface{}, 0, len(texts))
+	for _, row := range texts {
+		values := make([]interface{}, len(columns))
+		for i, cell := range row {
+			if text, ok := cell.(string); ok {
+				values[i] = convertCellValue(text, columns[i].DeType)
+			}
+		}
+		rows = append(rows, values)
+	}
+	return columns, rows, nil
+}
+
+// validateAPI fetches one record of every definition.
+func (s *DatasourceService) validateAPI(cfgRaw string) *datasource.ValidateResponse {
+	defs, err := datasource.DecodeAPIDefinitions(cfgRaw)
+	if err != nil {
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "invalid configuration: " + err.Error(), ErrorType: datasource.ErrorTypeConfig}
+	}
+	if len(defs) == 0 {
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "api datasource requires at least one table", ErrorType: datasource.ErrorTypeConfig}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	for i := range defs {
+		if _, err = apisource.Fetch(ctx, s.apiClient, &defs[i], 1); err != nil {
+			errorType := dsconn.Classify(ctx, err)
+			var statusErr *apisource.StatusError
+			if errors.As(err, &statusErr) {
+				errorType = datasource.ErrorTypeUnknown
+				switch statusErr.Code {
+				case http.StatusUnauthorized:
+					errorType = datasource.ErrorTypeAuth
+				case http.StatusForbidden:
+					errorType = datasource.ErrorTypePermission
+				}
+			}
+			return &datasource.ValidateResponse{Status: datasource.StatusError, Message: defs[i].Name + ": " + err.Error(), ErrorType: errorType}
+		}
+	}
+	return &datasource.ValidateResponse{Status: datasource.StatusSuccess, Message: "api check passed"}
+}
+
+// prepareAPIDefinitions decodes a submitted API configuration and syncs all
+// of its tables, keeping the physical tables of the previous configuration.
+func (s *DatasourceService) prepareAPIDefinitions(ctx context.Context, raw *string, previousRaw *string) ([]datasource.APIDefinition, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("datasource configuration is required")
+	}
+	defs, err := datasource.DecodeAPIDefinitions(*raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	var previous []datasource.APIDefinition
+	if previousRaw != nil {
+		previous, _ = datasource.DecodeAPIDefinitions(*previousRaw)
+	}
+	return s.syncAPIDefinitions(ctx, defs, previous, "")
+}
+
+func (s *DatasourceService) registerAPITables(datasourceID int64, defs []datasource.APIDefinition) error {
+	tables := make([]datasource.TableInfo, 0, len(defs))
+	for _, def := range defs {
+		if def.DeTableName != "" {
+			tables = append(tables, datasource.TableInfo{Name: def.Name, TableName: def.DeTableName})
+		}
+	}
+	return s.registerEngineTables(datasourceID, "api", tables)
+}
+
+func (s *DatasourceService) dropAPITables(defs []datasource.APIDefinition) {
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.DeTableName)
+	}
+	s.dropEngineTables(names...)
+}
+
+// marshalAPIDefinitions encodes the definitions for storage with their
+// credentials sealed.
+func (s *DatasourceService) marshalAPIDefinitions(defs []datasource.APIDefinition) (string, error) {
+	raw, err := json.Marshal(defs)
+	if err != nil {
+		return "", err
+	}
+	return s.conns.SealConfiguration(string(raw), "")
+}
+
+func isAPIType(dsType string) bool {
+	return strings.EqualFold(strings.TrimSpace(dsType), datasource.TypeAPI)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/dsconn"
+)
+
+func TestDatasourceService_SyncAPIDefinitions(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "u" || pass != "p" || failing.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[{"id":1,"price":"2.5","meta":{"city":"Paris"}},{"id":2,"price":"3","meta":{"city":null}}]}`))
+	}))
+	defer server.Close()
+
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/engine.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	engine, err := dsconn.NewConn(db, "sqlite", &datasource.ConnectionConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conns := dsconn.NewManager(dsconn.DefaultOptions())
+	conns.SetEngine(engine)
+	svc := NewDatasourceService(nil, conns)
+
+	def := datasource.APIDefinition{
+		Name:     "orders",
+		URL:      server.URL,
+		Auth:     datasource.APIAuth{Type: datasource.APIAuthBasic, Username: "u", Password: "p"},
+		RootPath: "$.items[*]",
+	}
+	check, err := svc.CheckAPIDatasource(&def)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fields := check.Definition.Fields
+	if len(fields) != 3 || fields[0].Name != "id" || fields[0].DeType != 2 || fields[2].Name != "price" || fields[2].DeType != 3 {
+		t.Fatalf("unexpected inferred fields %+v", fields)
+	}
+	if check.Total != 2 || check.Data[1]["meta_city"] != nil {
+		t.Fatalf("unexpected preview %+v", check.Data)
+	}
+	if len(def.Fields) != 0 {
+		t.Fatal("expected check to leave the submitted definition untouched")
+	}
+
+	synced, err := svc.syncAPIDefinitions(context.Background(), []datasource.APIDefinition{check.Definition}, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	table := synced[0].DeTableName
+	count, err := engine.CountRows(table)
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 rows, got %d (%v)", count, err)
+	}
+
+	if _, err = engine.Exec(`CREATE TABLE core_user (id INTEGER)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	forged := synced[0]
+	forged.DeTableName = "core_user"
+	resynced, err := svc.syncAPIDefinitions(context.Background(), []datasource.APIDefinition{forged}, synced, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resynced[0].DeTableName != table {
+		t.Fatalf("expected the stored table to be reused, got %s", resynced[0].DeTableName)
+	}
+	if count, err = engine.CountRows("core_user"); err != nil || count != 0 {
+		t.Fatalf("expected core_user to be left alone, got %d (%v)", count, err)
+	}
+
+	failing.Store(true)
+	if _, err = svc.syncAPIDefinitions(context.Background(), synced, synced, "orders"); err == nil {
+		t.Fatal("expected failing api to return an error")
+	}
+	if count, err = engine.CountRows(table); err != nil || count != 2 {
+		t.Fatalf("expected failed sync to keep previous rows, got %d (%v)", count, err)
+	}
+
+	result := svc.validateAPI(`[{"name":"orders","url":"` + server.URL + `"}]`)
+	if result.Status != datasource.StatusError || result.ErrorType != datasource.ErrorTypeAuth {
+		t.Fatalf("expected auth failure, got %+v", result)
+	}
+}
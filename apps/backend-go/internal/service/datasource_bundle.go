@@ -0,0 +1,448 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/secret"
+
+	"gorm.io/gorm"
+)
+
+const (
+	bundleKindDatasource = "datasource"
+	bundleKindDataset    = "dataset"
+	maxRenameAttempts    = 100
+)
+
+// DatasourceBundleService exports datasource subtrees, optionally with their
+// datasets, to portable bundles and imports them into another environment.
+type DatasourceBundleService struct {
+	datasources *DatasourceService
+	datasets    *DatasetService
+}
+
+func NewDatasourceBundleService(datasources *DatasourceService, datasets *DatasetService) *DatasourceBundleService {
+	return &DatasourceBundleService{datasources: datasources, datasets: datasets}
+}
+
+// Export bundles a datasource or a folder with everything below it. Secrets
+// are replaced with placeholders keyed by the datasource ID.
+func (s *DatasourceBundleService) Export(req *datasource.ExportRequest) (*datasource.Bundle, error) {
+	if req == nil || req.ID <= 0 {
+		return nil, fmt.Errorf("datasource id is required")
+	}
+	root, err := s.datasources.repo.GetByID(req.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("datasource not found")
+		}
+		return nil, err
+	}
+
+	bundle := &datasource.Bundle{
+		Version:     datasource.BundleVersion,
+		ExportTime:  time.Now().UnixMilli(),
+		Datasources: make([]datasource.BundleDatasource, 0),
+	}
+	exported := make(map[int64]struct{})
+	queue := []*datasource.CoreDatasource{root}
+	for len(queue) > 0 {
+		ds := queue[0]
+		queue = queue[1:]
+		pid := int64(0)
+		if ds.ID != root.ID {
+			pid = normalizedPID(ds.PID)
+		}
+		bundle.Datasources = append(bundle.Datasources, bundleDatasource(ds, pid))
+		exported[ds.ID] = struct{}{}
+		if ds.Type != datasource.TypeFolder {
+			continue
+		}
+		children, listErr := s.datasources.repo.ListChildren(ds.ID)
+		if listErr != nil {
+			return nil, listErr
+		}
+		queue = append(queue, children...)
+	}
+
+	if req.WithDatasets {
+		if bundle.Datasets, err = s.exportDatasets(exported); err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+func bundleDatasource(ds *datasource.CoreDatasource, pid int64) datasource.BundleDatasource {
+	entry := datasource.BundleDatasource{
+		ID:             ds.ID,
+		PID:            pid,
+		Name:           ds.Name,
+		Type:           ds.Type,
+		Description:    ds.Description,
+		EditType:       ds.EditType,
+		EnableDataFill: ds.EnableDataFill,
+	}
+	if ds.Configuration != nil {
+		configuration := secret.PlaceholderConfig(*ds.Configuration, strconv.FormatInt(ds.ID, 10))
+		entry.Configuration = &configuration
+	}
+	return entry
+}
+
+// exportDatasets returns the datasets whose tables all read from exported
+// datasources, without the fields owned by charts.
+func (s *DatasourceBundleService) exportDatasets(exported map[int64]struct{}) ([]datasource.BundleDataset, error) {
+	ids := make([]int64, 0, len(exported))
+	for id := range exported {
+		ids = append(ids, id)
+	}
+	groupIDs, err := s.datasets.repo.ListGroupIDsByDatasources(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]datasource.BundleDataset, 0, len(groupIDs))
+	for _, groupID := range groupIDs {
+		group, getErr := s.datasets.repo.GetGroupByID(groupID)
+		if getErr != nil {
+			if errors.Is(getErr, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, getErr
+		}
+		tables, listErr := s.datasets.repo.ListTablesByDatasetGroupID(groupID)
+		if listErr != nil {
+			return nil, listErr
+		}
+		if !tablesWithin(tables, exported) {
+			continue
+		}
+		fields, listErr := s.datasets.repo.ListFields(groupID)
+		if listErr != nil {
+			return nil, listErr
+		}
+		datasetFields := make([]*dataset.CoreDatasetTableField, 0, len(fields))
+		for _, field := range fields {
+			if field.ChartID == nil {
+				datasetFields = append(datasetFields, field)
+			}
+		}
+		result = append(result, datasource.BundleDataset{
+			ID:     group.ID,
+			Name:   group.Name,
+			Type:   group.Type,
+			Tables: tables,
+			Fields: datasetFields,
+		})
+	}
+	return result, nil
+}
+
+func tablesWithin(tables []*dataset.CoreDatasetTable, exported map[int64]struct{}) bool {
+	if len(tables) == 0 {
+		return false
+	}
+	for _, table := range tables {
+		if table.DatasourceID == nil {
+			return false
+		}
+		if _, ok := exported[*table.DatasourceID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Import recreates the datasources of a bundle under req.PID, then its
+// datasets under req.DatasetPID, and reports the outcome of every entry. A
+// name taken under the same parent, as counted by CountByNameAndPID, is
+// resolved by the conflict strategy; existing folders are merged into.
+func (s *DatasourceBundleService) Import(ctx context.Context, req *datasource.ImportRequest) (*datasource.ImportResult, error) {
+	if req == nil || req.Bundle == nil {
+		return nil, fmt.Errorf("bundle is required")
+	}
+	if req.Bundle.Version <= 0 || req.Bundle.Version > datasource.BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version: %d", req.Bundle.Version)
+	}
+	conflict := strings.TrimSpace(req.Conflict)
+	if conflict == "" {
+		conflict = datasource.ConflictSkip
+	}
+	if conflict != datasource.ConflictSkip && conflict != datasource.ConflictOverwrite && conflict != datasource.ConflictRename {
+		return nil, fmt.Errorf("unsupported conflict strategy: %s", req.Conflict)
+	}
+
+	result := &datasource.ImportResult{Items: make([]datasource.ImportItem, 0)}
+	ids := make(map[int64]int64)
+	for _, entry := range orderBundle(req.Bundle.Datasources) {
+		pid := req.PID
+		if entry.PID > 0 {
+			mapped, ok := ids[entry.PID]
+			if !ok {
+				result.Items = append(result.Items, datasource.ImportItem{
+					Kind: bundleKindDatasource, SourceID: entry.ID, Name: entry.Name,
+					Action: datasource.ImportFailed, Message: "parent folder was not imported",
+				})
+				continue
+			}
+			pid = mapped
+		}
+		item := s.importDatasource(ctx, entry, pid, conflict, req)
+		if item.ID > 0 {
+			ids[entry.ID] = item.ID
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	for _, entry := range req.Bundle.Datasets {
+		result.Items = append(result.Items, s.importDataset(entry, ids, conflict, req.DatasetPID))
+	}
+	return result, nil
+}
+
+// orderBundle sorts the entries so that folders come before their content.
+// Entries whose parent is not in the bundle are treated as roots.
+func orderBundle(entries []datasource.BundleDatasource) []datasource.BundleDatasource {
+	byID := make(map[int64]datasource.BundleDatasource, len(entries))
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+	depth := func(entry datasource.BundleDatasource) int {
+		d := 0
+		for seen := map[int64]bool{entry.ID: true}; entry.PID > 0; d++ {
+			parent, ok := byID[entry.PID]
+			if !ok || seen[parent.ID] {
+				break
+			}
+			seen[parent.ID] = true
+			entry = parent
+		}
+		return d
+	}
+	ordered := append([]datasource.BundleDatasource(nil), entries...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return depth(ordered[i]) < depth(ordered[j])
+	})
+	return ordered
+}
+
+func (s *DatasourceBundleService) importDatasource(ctx context.Context, entry datasource.BundleDatasource, pid int64, conflict string, req *datasource.ImportRequest) datasource.ImportItem {
+	item := datasource.ImportItem{Kind: bundleKindDatasource, SourceID: entry.ID, Name: entry.Name}
+	if isExcelType(entry.Type) {
+		item.Action = datasource.ImportSkipped
+		item.Message = "file datasources cannot be imported"
+		return item
+	}
+
+	var missing []string
+	write := &datasource.WriteRequest{
+		Name:           entry.Name,
+		PID:            &pid,
+		Description:    entry.Description,
+		Type:           entry.Type,
+		NodeType:       entry.Type,
+		EditType:       entry.EditType,
+		EnableDataFill: entry.EnableDataFill,
+		CreateBy:       req.CreateBy,
+	}
+	if entry.Configuration != nil {
+		var configuration string
+		configuration, missing = secret.ResolvePlaceholders(*entry.Configuration, req.Secrets)
+		write.Configuration = &configuration
+	}
+
+	count, err := s.datasources.repo.CountByNameAndPID(entry.Name, pid, nil)
+	if err != nil {
+		return failedItem(item, err)
+	}
+	action := datasource.ImportCreated
+	if count > 0 {
+		existing, findErr := s.findDatasource(entry.Name, pid)
+		if findErr != nil {
+			return failedItem(item, findErr)
+		}
+		folder := entry.Type == datasource.TypeFolder
+		switch {
+		case folder && existing.Type == datasource.TypeFolder:
+			item.ID = existing.ID
+			item.Action = datasource.ImportSkipped
+			item.Message = "folder already exists, content merged into it"
+			return item
+		case conflict == datasource.ConflictRename:
+			if write.Name, err = s.freeDatasourceName(entry.Name, pid); err != nil {
+				return failedItem(item, err)
+			}
+			item.Name = write.Name
+		case conflict == datasource.ConflictOverwrite && !folder && existing.Type != datasource.TypeFolder:
+			write.ID = existing.ID
+			action = datasource.ImportOverwritten
+		default:
+			item.ID = existing.ID
+			item.Action = datasource.ImportSkipped
+			item.Message = "datasource name already exists"
+			return item
+		}
+	}
+
+	var ds *datasource.CoreDatasource
+	if write.ID > 0 {
+		ds, err = s.datasources.Update(ctx, write)
+	} else {
+		ds, err = s.datasources.Save(ctx, write)
+	}
+	if err != nil {
+		return failedItem(item, err)
+	}
+	item.ID = ds.ID
+	item.Action = action
+	if len(missing) > 0 {
+		item.Message = "missing secrets: " + strings.Join(missing, ", ")
+	}
+	return item
+}
+
+func (s *DatasourceBundleService) findDatasource(name string, pid int64) (*datasource.CoreDatasource, error) {
+	children, err := s.datasources.repo.ListChildren(pid)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if child.Name == name {
+			return child, nil
+		}
+	}
+	return nil, fmt.Errorf("datasource %s not found", name)
+}
+
+func (s *DatasourceBundleService) freeDatasourceName(name string, pid int64) (string, error) {
+	return freeName(name, func(candidate string) (int64, error) {
+		return s.datasources.repo.CountByNameAndPID(candidate, pid, nil)
+	})
+}
+
+func (s *DatasourceBundleService) importDataset(entry datasource.BundleDataset, ids map[int64]int64, conflict string, pid int64) datasource.ImportItem {
+	item := datasource.ImportItem{Kind: bundleKindDataset, SourceID: entry.ID, Name: entry.Name}
+	tables := make([]*dataset.CoreDatasetTable, 0, len(entry.Tables))
+	for _, source := range entry.Tables {
+		if source == nil || source.DatasourceID == nil {
+			continue
+		}
+		datasourceID, ok := ids[*source.DatasourceID]
+		if !ok {
+			item.Action = datasource.ImportFailed
+			item.Message = fmt.Sprintf("datasource %d was not imported", *source.DatasourceID)
+			return item
+		}
+		table := *source
+		table.DatasourceID = &datasourceID
+		tables = append(tables, &table)
+	}
+	fields := make([]*dataset.CoreDatasetTableField, 0, len(entry.Fields))
+	for _, source := range entry.Fields {
+		if source == nil {
+			continue
+		}
+		field := *source
+		field.ChartID = nil
+		if field.DatasourceID != nil {
+			if datasourceID, ok := ids[*field.DatasourceID]; ok {
+				field.DatasourceID = &datasourceID
+			}
+		}
+		fields = append(fields, &field)
+	}
+
+	name := entry.Name
+	count, err := s.datasets.repo.CountGroupByNameAndPID(name, pid, nil)
+	if err != nil {
+		return failedItem(item, err)
+	}
+	var group *dataset.CoreDatasetGroup
+	action := datasource.ImportCreated
+	if count > 0 {
+		switch conflict {
+		case datasource.ConflictRename:
+			if name, err = freeName(entry.Name, func(candidate string) (int64, error) {
+				return s.datasets.repo.CountGroupByNameAndPID(candidate, pid, nil)
+			}); err != nil {
+				return failedItem(item, err)
+			}
+			item.Name = name
+		case datasource.ConflictOverwrite:
+			if group, err = s.findDataset(name, pid); err != nil {
+				return failedItem(item, err)
+			}
+			action = datasource.ImportOverwritten
+		default:
+			if existing, findErr := s.findDataset(name, pid); findErr == nil {
+				item.ID = existing.ID
+			}
+			item.Action = datasource.ImportSkipped
+			item.Message = "dataset name already exists"
+			return item
+		}
+	}
+
+	if group == nil {
+		group, err = s.datasets.Create(&dataset.WriteRequest{
+			PID:      &pid,
+			Name:     name,
+			NodeType: dataset.NodeTypeDataset,
+			Type:     entry.Type,
+		})
+		if err != nil {
+			return failedItem(item, err)
+		}
+	}
+	item.ID = group.ID
+	if err = s.datasets.repo.ReplaceContent(group.ID, tables, fields); err != nil {
+		return failedItem(item, err)
+	}
+	s.datasets.recordVersion(group.ID)
+	item.Action = action
+	return item
+}
+
+// findDataset returns the dataset named name under pid; folders do not match.
+func (s *DatasourceBundleService) findDataset(name string, pid int64) (*dataset.CoreDatasetGroup, error) {
+	children, err := s.datasets.repo.ListGroupChildren(pid)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if child.Name == name && child.NodeType != nil && *child.NodeType == dataset.NodeTypeDataset {
+			return child, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a dataset", name)
+}
+
+// freeName appends the first counter that makes name unused.
+func freeName(name string, count func(candidate string) (int64, error)) (string, error) {
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		n, err := count(candidate)
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free name for %s", name)
+}
+
+func failedItem(item datasource.ImportItem, err error) datasource.ImportItem {
+	item.Action = datasource.ImportFailed
+	item.Message = err.Error()
+	return item
+}
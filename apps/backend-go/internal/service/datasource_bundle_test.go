@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/datasource"
+)
+
+func TestOrderBundle_ParentsFirst(t *testing.T) {
+	entries := []datasource.BundleDatasource{
+		{ID: 3, PID: 2, Name: "orders"},
+		{ID: 2, PID: 1, Name: "sales"},
+		{ID: 1, Name: "root"},
+		{ID: 4, PID: 99, Name: "orphan"},
+	}
+	ordered := orderBundle(entries)
+	names := make([]string, 0, len(ordered))
+	for _, entry := range ordered {
+		names = append(names, entry.Name)
+	}
+	if got := strings.Join(names, ","); got != "root,orphan,sales,orders" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestBundleDatasource_ReplacesSecrets(t *testing.T) {
+	configuration := `{"host":"db","password":"enc:v1:x"}`
+	pid := int64(5)
+	entry := bundleDatasource(&datasource.CoreDatasource{ID: 12, PID: &pid, Name: "mysql", Type: "mysql", Configuration: &configuration}, 0)
+	if entry.PID != 0 || entry.Configuration == nil || !strings.Contains(*entry.Configuration, "{{secret:12.password}}") {
+		t.Fatalf("unexpected bundle entry %+v", entry)
+	}
+}
+
+func TestTablesWithin(t *testing.T) {
+	one, two := int64(1), int64(2)
+	exported := map[int64]struct{}{1: {}}
+	if !tablesWithin([]*dataset.CoreDatasetTable{{DatasourceID: &one}}, exported) {
+		t.Fatal("expected tables of exported datasources to be within")
+	}
+	if tablesWithin([]*dataset.CoreDatasetTable{{DatasourceID: &one}, {DatasourceID: &two}}, exported) {
+		t.Fatal("expected a table of another datasource to be outside")
+	}
+	if tablesWithin(nil, exported) {
+		t.Fatal("expected a dataset without tables to be outside")
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	taken := map[string]bool{"mysql (1)": true, "mysql (2)": true}
+	name, err := freeName("mysql", func(candidate string) (int64, error) {
+		if taken[candidate] {
+			return 1, nil
+		}
+		return 0, nil
+	})
+	if err != nil || name != "mysql (3)" {
+		t.Fatalf("expected mysql (3), got %q (%v)", name, err)
+	}
+	if _, err = freeName("x", func(string) (int64, error) { return 0, fmt.Errorf("boom") }); err == nil {
+		t.Fatal("expected the count error")
+	}
+}
+
+func TestDatasourceBundleService_ImportRejectsBadRequests(t *testing.T) {
+	svc := NewDatasourceBundleService(nil, nil)
+	cases := []*datasource.ImportRequest{
+		nil,
+		{},
+		{Bundle: &datasource.Bundle{Version: datasource.BundleVersion + 1}},
+		{Bundle: &datasource.Bundle{Version: datasource.BundleVersion}, Conflict: "merge"},
+	}
+	for _, req := range cases {
+		if _, err := svc.Import(context.Background(), req); err == nil {
+			t.Errorf("expected error for %+v", req)
+		}
+	}
+}
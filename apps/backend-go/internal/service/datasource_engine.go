@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/dsconn"
+)
+
+const engineInsertBatch = 500
+
+// engineColumn is a column of a table materialized in the engine database.
+type engineColumn struct {
+	Name   string
+	DeType int
+}
+
+// engineExecer runs statements on the engine database, directly or in a
+// transaction.
+type engineExecer interface {
+	QuoteIdentifier(name string) string
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// inEngineTx runs fn in a transaction of the engine database, committed when
+// fn succeeds.
+func inEngineTx(ctx context.Context, conn *dsconn.Conn, fn func(tx *dsconn.Tx) error) error {
+	tx, err := conn.BeginContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// newEngineTableName returns a random physical table name for an import.
+func newEngineTableName(prefix string) (string, error) {
+	id, err := generateUUID()
+	if err != nil {
+		return "", err
+	}
+	return prefix + "_" + id[:16], nil
+}
+
+// createEngineTable (re)creates a table using the provider column types of
+// the engine database. Tables holding data are reloaded through
+// replaceEngineTable instead.
+func createEngineTable(ctx context.Context, conn *dsconn.Conn, tableName string, columns []engineColumn) error {
+	defs := make([]string, 0, len(columns))
+	for _, column := range columns {
+		defs = append(defs, conn.QuoteIdentifier(column.Name)+" "+conn.Provider().ColumnType(column.DeType))
+	}
+
+	quoted := conn.QuoteIdentifier(tableName)
+	if _, err := conn.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoted); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", quoted, strings.Join(defs, ", ")))
+	return err
+}
+
+// replaceEngineTable reloads a table: load creates and fills a staging
+// table, which then takes the name of tableName. A failing load drops the
+// staging table and keeps the previous data.
+func replaceEngineTable(ctx context.Context, conn *dsconn.Conn, tableName string, load func(staging string) error) error {
+	staging, err := newEngineTableName("stage")
+	if err != nil {
+		return err
+	}
+	if err = load(staging); err != nil {
+		_, _ = conn.Exec("DROP TABLE IF EXISTS " + conn.QuoteIdentifier(staging))
+		return err
+	}
+	if err = swapEngineTable(ctx, conn, staging, tableName); err != nil {
+		_, _ = conn.Exec("DROP TABLE IF EXISTS " + conn.QuoteIdentifier(staging))
+		return err
+	}
+	return nil
+}
+
+// swapEngineTable renames staging to tableName. The previous table is moved
+// aside first, and restored if the rename fails; it is missing on a first
+// load, when moving it fails. The restore runs even once ctx has ended.
+func swapEngineTable(ctx context.Context, conn *dsconn.Conn, staging string, tableName string) error {
+	backup, err := newEngineTableName("old")
+	if err != nil {
+		return err
+	}
+	rename := func(ctx context.Context, from, to string) error {
+		_, renameErr := conn.ExecContext(ctx, "ALTER TABLE "+conn.QuoteIdentifier(from)+" RENAME TO "+conn.QuoteIdentifier(to))
+		return renameErr
+	}
+	movedAside := rename(ctx, tableName, backup) == nil
+	if err = rename(ctx, staging, tableName); err != nil {
+		if movedAside {
+			_ = rename(context.Background(), backup, tableName)
+		}
+		return err
+	}
+	if movedAside {
+		_, _ = conn.Exec("DROP TABLE IF EXISTS " + conn.QuoteIdentifier(backup))
+	}
+	return nil
+}
+
+// insertEngineRows writes already converted rows in batches; each row holds
+// one value per column.
+func insertEngineRows(ctx context.Context, conn engineExecer, tableName string, columns []engineColumn, rows [][]interface{}) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, conn.QuoteIdentifier(column.Name))
+	}
+
+	rowPlaceholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", conn.QuoteIdentifier(tableName), strings.Join(names, ", "))
+	for start := 0; start < len(rows); start += engineInsertBatch {
+		end := start + engineInsertBatch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		placeholders := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			placeholders = append(placeholders, rowPlaceholder)
+			args = append(args, row...)
+		}
+		if _, err := conn.ExecContext(ctx, prefix+strings.Join(placeholders, ", "), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropEngineTables removes engine tables, ignoring failures since it only
+// runs while cleaning up.
+func (s *DatasourceService) dropEngineTables(names ...string) {
+	conn, err := s.conns.Engine()
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		if name != "" {
+			_, _ = conn.Exec("DROP TABLE IF EXISTS " + conn.QuoteIdentifier(name))
+		}
+	}
+}
+
+// registerEngineTables replaces the core_dataset_table entries of a
+// datasource with its engine tables. Name is the display name, TableName the
+// physical table.
+func (s *DatasourceService) registerEngineTables(datasourceID int64, tableType string, tables []datasource.TableInfo) error {
+	if err := s.repo.DeleteTables(datasourceID); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := s.repo.CreateTable(datasourceID, table.Name, table.TableName, tableType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
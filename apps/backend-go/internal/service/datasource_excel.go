@@ -0,0 +1,609 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/tabular"
+)
+
+const (
+	excelPreviewRows     = 100
+	excelInferRows       = 1000
+	excelColumnMaxLength = 64
+	defaultUploadMaxSize = 100 << 20
+	remoteFileTimeout    = 60 * time.Second
+	remoteFileRedirects  = 5
+)
+
+const uploadNameSuffix = ".name"
+
+var uploadFileIDPattern = regexp.MustCompile(`^[a-f0-9]{32}\.(xlsx|xlsm|csv)$`)
+
+// SetUploadOptions configures where uploaded files are kept until they are
+// imported, and the largest accepted upload in bytes.
+func (s *DatasourceService) SetUploadOptions(dir string, maxSize int64) {
+	s.uploadDir = dir
+	s.uploadMaxSize = maxSize
+}
+
+// SetRemoteFileHosts restricts the hosts remote files are loaded from. With
+// no host listed, any host with a public address is accepted; private and
+// loopback addresses are only reached through a listed host.
+func (s *DatasourceService) SetRemoteFileHosts(hosts []string) {
+	s.remoteHosts = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			s.remoteHosts[host] = true
+		}
+	}
+}
+
+// UploadFile stores an XLSX/CSV upload and returns its sheets with inferred
+// fields and a preview of the first rows. The data is only imported once the
+// datasource is saved with the returned file ID.
+func (s *DatasourceService) UploadFile(fileName string, r io.Reader) (*datasource.ExcelFileData, error) {
+	if !tabular.IsSupported(fileName) {
+		return nil, fmt.Errorf("only xlsx and csv files are supported")
+	}
+	id, err := generateUUID()
+	if err != nil {
+		return nil, err
+	}
+	fileID := id + strings.ToLower(filepath.Ext(fileName))
+
+	dir := s.resolveUploadDir()
+	if err = os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	target := filepath.Join(dir, fileID)
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	maxSize := s.resolveUploadMaxSize()
+	written, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written > maxSize {
+		err = fmt.Errorf("file exceeds the %d bytes upload limit", maxSize)
+	}
+	if err == nil {
+		err = os.WriteFile(target+uploadNameSuffix, []byte(filepath.Base(fileName)), 0o640)
+	}
+	if err != nil {
+		removeUpload(target)
+		return nil, err
+	}
+
+	_, sheets, err := s.readUpload(fileID)
+	if err != nil {
+		removeUpload(target)
+		return nil, err
+	}
+
+	result := &datasource.ExcelFileData{
+		FileID:   fileID,
+		FileName: filepath.Base(fileName),
+		Sheets:   make([]datasource.ExcelSheet, 0, len(sheets)),
+	}
+	for _, sheet := range sheets {
+		fields := inferExcelFields(sheet)
+		preview := sheet.Rows
+		if len(preview) > excelPreviewRows {
+			preview = preview[:excelPreviewRows]
+		}
+		data := make([]map[string]interface{}, 0, len(preview))
+		for _, row := range preview {
+			item := make(map[string]interface{}, len(fields))
+			for i, field := range fields {
+				item[field.Name] = convertCellValue(row[i], field.DeType)
+			}
+			data = append(data, item)
+		}
+		result.Sheets = append(result.Sheets, datasource.ExcelSheet{
+			TableName: sheet.Name,
+			Fields:    fields,
+			Data:      data,
+			Total:     len(sheet.Rows),
+		})
+	}
+	return result, nil
+}
+
+// LoadRemoteFile downloads an XLSX/CSV file over HTTP(S) and handles it like
+// an upload.
+func (s *DatasourceService) LoadRemoteFile(req *datasource.RemoteFileRequest) (*datasource.ExcelFileData, error) {
+	if req == nil || strings.TrimSpace(req.URL) == "" {
+		return nil, fmt.Errorf("file url is required")
+	}
+	fileURL, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (fileURL.Scheme != "http" && fileURL.Scheme != "https") || fileURL.Host == "" {
+		return nil, fmt.Errorf("file url must be an http(s) address")
+	}
+	fileName := path.Base(fileURL.Path)
+	if !tabular.IsSupported(fileName) {
+		return nil, fmt.Errorf("only xlsx and csv files are supported")
+	}
+
+	if err = s.checkRemoteHost(fileURL.Hostname()); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodGet, fileURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserName != "" {
+		httpReq.SetBasicAuth(req.UserName, req.Passwd)
+	}
+	resp, err := s.remoteFileClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: unexpected status %d", resp.StatusCode)
+	}
+	if maxSize := s.resolveUploadMaxSize(); resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("file exceeds the %d bytes upload limit", maxSize)
+	}
+	// UploadFile stops reading past the upload limit.
+	return s.UploadFile(fileName, resp.Body)
+}
+
+func (s *DatasourceService) checkRemoteHost(host string) error {
+	if len(s.remoteHosts) > 0 && !s.remoteHosts[strings.ToLower(host)] {
+		return fmt.Errorf("host %s is not allowed", host)
+	}
+	return nil
+}
+
+// remoteFileClient downloads remote files without a proxy, refusing hosts
+// off the allowlist and, unless listed, hosts resolving to a private,
+// loopback or link-local address, redirects included. The resolved address
+// is the one dialed, so a host cannot resolve differently once checked.
+func (s *DatasourceService) remoteFileClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("host %s has no address", host)
+			}
+			if !s.remoteHosts[strings.ToLower(host)] {
+				for _, ip := range ips {
+					if !publicIP(ip.IP) {
+						return nil, fmt.Errorf("host %s resolves to a non-public address", host)
+					}
+				}
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+		},
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   remoteFileTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= remoteFileRedirects {
+				return fmt.Errorf("too many redirects")
+			}
+			return s.checkRemoteHost(req.URL.Hostname())
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast()
+}
+
+// importExcel materializes the sheets of an uploaded file into engine tables.
+// Replace mode reloads the tables through staging tables (keeping the
+// physical name of sheets that already exist); append mode inserts into the
+// tables of the previous import.
+func (s *DatasourceService) importExcel(ctx context.Context, req *datasource.WriteRequest, previous *datasource.ExcelConfig, editType string) (*datasource.ExcelConfig, error) {
+	fileName, sheets, err := s.readUpload(req.FileID)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.conns.Engine()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]datasource.ExcelSheet, len(req.Sheets))
+	for _, sheet := range req.Sheets {
+		selected[sheet.TableName] = sheet
+	}
+	existing := make(map[string]datasource.ExcelSheet)
+	if previous != nil {
+		for _, sheet := range previous.Sheets {
+			existing[sheet.TableName] = sheet
+		}
+	}
+
+	if editType == datasource.EditTypeAppend {
+		if previous == nil {
+			return nil, fmt.Errorf("no previous import to append to")
+		}
+		for _, sheet := range sheets {
+			if _, ok := selected[sheet.Name]; len(selected) > 0 && !ok {
+				continue
+			}
+			target, ok := existing[sheet.Name]
+			if !ok {
+				return nil, fmt.Errorf("sheet %s does not exist in datasource", sheet.Name)
+			}
+			fields := inferExcelFields(sheet)
+			if err = matchAppendFields(fields, target.Fields); err != nil {
+				return nil, fmt.Errorf("sheet %s: %w", sheet.Name, err)
+			}
+			if err = insertExcelRows(ctx, conn, target.DeTableName, target.Fields, fields, sheet.Rows); err != nil {
+				return nil, err
+			}
+			for i := range previous.Sheets {
+				if previous.Sheets[i].TableName == sheet.Name {
+					previous.Sheets[i].Total += len(sheet.Rows)
+				}
+			}
+		}
+		return previous, nil
+	}
+
+	// Every sheet is loaded into a staging table first, and the tables are
+	// only swapped in once all loaded, so a failing import keeps the data.
+	result := &datasource.ExcelConfig{FileName: fileName, Sheets: make([]datasource.ExcelSheet, 0, len(sheets))}
+	staged := make([]string, 0, len(sheets))
+	dropStaged := func() { s.dropEngineTables(staged...) }
+	for _, sheet := range sheets {
+		requested, ok := selected[sheet.Name]
+		if len(selected) > 0 && !ok {
+			continue
+		}
+		fields := inferExcelFields(sheet)
+		if ok && len(requested.Fields) > 0 {
+			fields = mergeRequestedFields(fields, requested.Fields)
+		}
+
+		tableName := existing[sheet.Name].DeTableName
+		if tableName == "" {
+			if tableName, err = newEngineTableName("excel"); err != nil {
+				dropStaged()
+				return nil, err
+			}
+		}
+		staging, idErr := newEngineTableName("stage")
+		if idErr != nil {
+			dropStaged()
+			return nil, idErr
+		}
+		staged = append(staged, staging)
+		if err = createExcelTable(ctx, conn, staging, fields); err == nil {
+			err = insertExcelRows(ctx, conn, staging, fields, fields, sheet.Rows)
+		}
+		if err != nil {
+			dropStaged()
+			return nil, err
+		}
+		result.Sheets = append(result.Sheets, datasource.ExcelSheet{
+			TableName:   sheet.Name,
+			DeTableName: tableName,
+			Fields:      fields,
+			Total:       len(sheet.Rows),
+		})
+		delete(existing, sheet.Name)
+	}
+	if len(result.Sheets) == 0 {
+		return nil, fmt.Errorf("no sheet selected")
+	}
+	for i, sheet := range result.Sheets {
+		if err = swapEngineTable(ctx, conn, staged[i], sheet.DeTableName); err != nil {
+			s.dropEngineTables(staged[i:]...)
+			return nil, err
+		}
+	}
+
+	stale := &datasource.ExcelConfig{}
+	for _, sheet := range existing {
+		stale.Sheets = append(stale.Sheets, sheet)
+	}
+	s.dropExcelTables(stale)
+	return result, nil
+}
+
+func (s *DatasourceService) dropExcelTables(cfg *datasource.ExcelConfig) {
+	if cfg == nil {
+		return
+	}
+	names := make([]string, 0, len(cfg.Sheets))
+	for _, sheet := range cfg.Sheets {
+		names = append(names, sheet.DeTableName)
+	}
+	s.dropEngineTables(names...)
+}
+
+func (s *DatasourceService) registerExcelTables(datasourceID int64, cfg *datasource.ExcelConfig) error {
+	tables := make([]datasource.TableInfo, 0, len(cfg.Sheets))
+	for _, sheet := range cfg.Sheets {
+		tables = append(tables, datasource.TableInfo{Name: sheet.TableName, TableName: sheet.DeTableName})
+	}
+	return s.registerEngineTables(datasourceID, "excel", tables)
+}
+
+// readUpload parses a stored upload. The original file name is kept next to
+// the file so CSV sheets are named after it rather than after the file ID.
+func (s *DatasourceService) readUpload(fileID string) (string, []tabular.Sheet, error) {
+	if !uploadFileIDPattern.MatchString(fileID) {
+		return "", nil, fmt.Errorf("invalid file id")
+	}
+	target := filepath.Join(s.resolveUploadDir(), fileID)
+	f, err := os.Open(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, fmt.Errorf("uploaded file not found")
+		}
+		return "", nil, err
+	}
+	defer f.Close()
+
+	fileName := fileID
+	if raw, nameErr := os.ReadFile(target + uploadNameSuffix); nameErr == nil && tabular.IsSupported(string(raw)) {
+		fileName = string(raw)
+	}
+	sheets, err := tabular.Read(fileName, f)
+	if err != nil {
+		return "", nil, err
+	}
+	return fileName, sheets, nil
+}
+
+func removeUpload(target string) {
+	_ = os.Remove(target)
+	_ = os.Remove(target + uploadNameSuffix)
+}
+
+func (s *DatasourceService) resolveUploadDir() string {
+	if strings.TrimSpace(s.uploadDir) != "" {
+		return s.uploadDir
+	}
+	return filepath.Join(os.TempDir(), "dataease", "excel")
+}
+
+func (s *DatasourceService) resolveUploadMaxSize() int64 {
+	if s.uploadMaxSize > 0 {
+		return s.uploadMaxSize
+	}
+	return defaultUploadMaxSize
+}
+
+func decodeExcelConfig(raw *string) *datasource.ExcelConfig {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return nil
+	}
+	var cfg datasource.ExcelConfig
+	if err := json.Unmarshal([]byte(*raw), &cfg); err != nil {
+		return nil
+	}
+	return &cfg
+}
+
+func isExcelType(dsType string) bool {
+	return strings.EqualFold(strings.TrimSpace(dsType), datasource.TypeExcel)
+}
+
+// inferExcelFields names the columns of a sheet and infers their deType from
+// the leading rows using the same rules as SQL previews.
+func inferExcelFields(sheet tabular.Sheet) []datasource.ExcelField {
+	fields := make([]datasource.ExcelField, 0, len(sheet.Header))
+	seen := make(map[string]int, len(sheet.Header))
+	for i, header := range sheet.Header {
+		name := header
+		if name == "" {
+			name = "column_" + strconv.Itoa(i+1)
+		}
+		name = truncateRunes(name, excelColumnMaxLength-4)
+		key := strings.ToLower(name)
+		if count, ok := seen[key]; ok {
+			seen[key] = count + 1
+			name = name + "_" + strconv.Itoa(count+1)
+			key = strings.ToLower(name)
+		}
+		seen[key] = 1
+
+		values := make([]string, 0, excelInferRows)
+		for rowIdx, row := range sheet.Rows {
+			if rowIdx >= excelInferRows {
+				break
+			}
+			values = append(values, row[i])
+		}
+		origin := header
+		if origin == "" {
+			origin = name
+		}
+		fields = append(fields, datasource.ExcelField{
+			OriginName: origin,
+			Name:       name,
+			DeType:     inferColumnDeType(values),
+			Checked:    true,
+		})
+	}
+	return fields
+}
+
+func inferColumnDeType(values []string) int {
+	result := -1
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		deType := inferPreviewDeType(value)
+		switch {
+		case result < 0:
+			result = deType
+		case result == deType:
+		case (result == 2 && deType == 3) || (result == 3 && deType == 2):
+			result = 3
+		default:
+			return 0
+		}
+	}
+	if result < 0 {
+		return 0
+	}
+	return result
+}
+
+// mergeRequestedFields applies the deType and checked flags chosen on the
+// preview to the inferred fields.
+func mergeRequestedFields(inferred []datasource.ExcelField, requested []datasource.ExcelField) []datasource.ExcelField {
+	byName := make(map[string]datasource.ExcelField, len(requested))
+	for _, field := range requested {
+		byName[field.Name] = field
+	}
+	result := make([]datasource.ExcelField, len(inferred))
+	for i, field := range inferred {
+		if chosen, ok := byName[field.Name]; ok {
+			field.Checked = chosen.Checked
+			if chosen.DeType >= 0 && chosen.DeType <= 4 {
+				field.DeType = chosen.DeType
+			}
+		}
+		result[i] = field
+	}
+	return result
+}
+
+func matchAppendFields(uploaded []datasource.ExcelField, target []datasource.ExcelField) error {
+	known := make(map[string]struct{}, len(target))
+	for _, field := range target {
+		known[field.Name] = struct{}{}
+	}
+	for _, field := range uploaded {
+		if _, ok := known[field.Name]; !ok {
+			return fmt.Errorf("field %s does not exist in the imported table", field.OriginName)
+		}
+	}
+	return nil
+}
+
+func createExcelTable(ctx context.Context, conn *dsconn.Conn, tableName string, fields []datasource.ExcelField) error {
+	columns := make([]engineColumn, 0, len(fields))
+	for _, field := range fields {
+		if field.Checked {
+			columns = append(columns, engineColumn{Name: field.Name, DeType: field.DeType})
+		}
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("no field selected")
+	}
+	return createEngineTable(ctx, conn, tableName, columns)
+}
+
+// insertExcelRows writes rows into the checked target columns. Values are
+// parsed with the deType of the target column; row cells are located through
+// the uploaded fields.
+func insertExcelRows(ctx context.Context, conn *dsconn.Conn, tableName string, target []datasource.ExcelField, uploaded []datasource.ExcelField, rows [][]string) error {
+	positions := make(map[string]int, len(uploaded))
+	for i, field := range uploaded {
+		positions[field.Name] = i
+	}
+
+	columns := make([]engineColumn, 0, len(target))
+	indexes := make([]int, 0, len(target))
+	for _, field := range target {
+		if !field.Checked {
+			continue
+		}
+		pos, ok := positions[field.Name]
+		if !ok {
+			pos = -1
+		}
+		columns = append(columns, engineColumn{Name: field.Name, DeType: field.DeType})
+		indexes = append(indexes, pos)
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	values := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		item := make([]interface{}, len(columns))
+		for i, pos := range indexes {
+			if pos >= 0 && pos < len(row) {
+				item[i] = convertCellValue(row[pos], columns[i].DeType)
+			}
+		}
+		values = append(values, item)
+	}
+	return insertEngineRows(ctx, conn, tableName, columns, values)
+}
+
+// convertCellValue parses a cell for its deType. Cells that cannot be parsed
+// are stored as NULL rather than failing the import.
+func convertCellValue(text string, deType int) interface{} {
+	value := strings.TrimSpace(text)
+	if value == "" {
+		return nil
+	}
+	switch deType {
+	case 1:
+		if t, ok := parseDateTimeText(value); ok {
+			return t
+		}
+		return nil
+	case 2:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f == float64(int64(f)) {
+			return int64(f)
+		}
+		return nil
+	case 3:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		return nil
+	case 4:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		return nil
+	default:
+		return text
+	}
+}
+
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit])
+}
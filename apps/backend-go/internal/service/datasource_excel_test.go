@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/tabular"
+)
+
+func TestInferExcelFields(t *testing.T) {
+	sheet := tabular.Sheet{
+		Name:   "orders",
+		Header: []string{"id", "", "ID", "amount", "day", "note"},
+		Rows: [][]string{
+			{"1", "x", "a", "10", "2024-01-02", "ok"},
+			{"2", "y", "b", "10.5", "2024-01-03", "12"},
+		},
+	}
+	fields := inferExcelFields(sheet)
+	names := []string{"id", "column_2", "ID_2", "amount", "day", "note"}
+	deTypes := []int{2, 0, 0, 3, 1, 0}
+	for i, field := range fields {
+		if field.Name != names[i] || field.DeType != deTypes[i] || !field.Checked {
+			t.Fatalf("field %d: unexpected %+v", i, field)
+		}
+	}
+}
+
+func TestConvertExcelValue(t *testing.T) {
+	if v := convertCellValue("3.0", 2); v != int64(3) {
+		t.Fatalf("expected integral float to convert, got %v", v)
+	}
+	if v := convertCellValue("abc", 3); v != nil {
+		t.Fatalf("expected unparseable value to be nil, got %v", v)
+	}
+	if v := convertCellValue(" ", 0); v != nil {
+		t.Fatalf("expected blank cell to be nil, got %v", v)
+	}
+	if v := convertCellValue("true", 4); v != true {
+		t.Fatalf("expected bool, got %v", v)
+	}
+}
+
+func TestDatasourceService_ImportExcel(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/engine.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Close()
+	engine, err := dsconn.NewConn(db, "sqlite", &datasource.ConnectionConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conns := dsconn.NewManager(dsconn.DefaultOptions())
+	conns.SetEngine(engine)
+	svc := NewDatasourceService(nil, conns)
+	svc.SetUploadOptions(t.TempDir(), 1<<20)
+
+	upload, err := svc.UploadFile("sales.csv", strings.NewReader("city,amount\nParis,1\nLyon,2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(upload.Sheets) != 1 || upload.Sheets[0].TableName != "sales" || upload.Sheets[0].Total != 2 || upload.Sheets[0].Data[1]["amount"] != int64(2) {
+		t.Fatalf("unexpected upload preview: %+v", upload)
+	}
+
+	cfg, err := svc.importExcel(context.Background(), &datasource.WriteRequest{FileID: upload.FileID}, nil, datasource.EditTypeReplace)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	table := cfg.Sheets[0].DeTableName
+	if !strings.HasPrefix(table, "excel_") {
+		t.Fatalf("unexpected table name %q", table)
+	}
+
+	appended, err := svc.UploadFile("sales.csv", strings.NewReader("city,amount\nNice,3\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = svc.importExcel(context.Background(), &datasource.WriteRequest{FileID: appended.FileID}, cfg, datasource.EditTypeAppend); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	count, err := engine.CountRows(table)
+	if err != nil || count != 3 {
+		t.Fatalf("expected 3 rows after append, got %d (%v)", count, err)
+	}
+
+	replaced, err := svc.UploadFile("sales.csv", strings.NewReader("city,amount\nBrest,4\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg, err = svc.importExcel(context.Background(), &datasource.WriteRequest{FileID: replaced.FileID}, cfg, datasource.EditTypeReplace); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	count, err = engine.CountRows(table)
+	if err != nil || count != 1 || cfg.Sheets[0].DeTableName != table {
+		t.Fatalf("expected the replaced sheet to keep %s with 1 row, got %d in %+v (%v)", table, count, cfg, err)
+	}
+	if tables, listErr := engine.ListTables(); listErr != nil || len(tables) != 1 {
+		t.Fatalf("expected no staging table left, got %+v (%v)", tables, listErr)
+	}
+
+	mismatched, err := svc.UploadFile("sales.csv", strings.NewReader("town,amount\nNice,3\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = svc.importExcel(context.Background(), &datasource.WriteRequest{FileID: mismatched.FileID}, cfg, datasource.EditTypeAppend); err == nil {
+		t.Fatal("expected append with unknown field to fail")
+	}
+
+	if _, err = svc.UploadFile("../evil.txt", strings.NewReader("x")); err == nil {
+		t.Fatal("expected unsupported file to be rejected")
+	}
+	if _, _, err = svc.readUpload("../" + upload.FileID); err == nil {
+		t.Fatal("expected invalid file id to be rejected")
+	}
+}
+
+func TestDatasourceService_LoadRemoteFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved.csv" {
+			http.Redirect(w, r, "http://localhost:"+r.Host[strings.LastIndex(r.Host, ":")+1:]+"/sales.csv", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("city,amount\nParis,1\n"))
+	}))
+	defer server.Close()
+	address, _ := url.Parse(server.URL)
+
+	svc := NewDatasourceService(nil, nil)
+	svc.SetUploadOptions(t.TempDir(), 1<<20)
+	if _, err := svc.LoadRemoteFile(&datasource.RemoteFileRequest{URL: server.URL + "/sales.csv"}); err == nil {
+		t.Fatal("expected a loopback address to be refused")
+	}
+
+	svc.SetRemoteFileHosts([]string{address.Hostname()})
+	file, err := svc.LoadRemoteFile(&datasource.RemoteFileRequest{URL: server.URL + "/sales.csv"})
+	if err != nil || file.Sheets[0].Total != 1 {
+		t.Fatalf("expected a listed host to be loaded, got %+v (%v)", file, err)
+	}
+	if _, err = svc.LoadRemoteFile(&datasource.RemoteFileRequest{URL: server.URL + "/moved.csv"}); err == nil {
+		t.Fatal("expected a redirect to an unlisted host to be refused")
+	}
+
+	svc.SetUploadOptions(t.TempDir(), 8)
+	if _, err = svc.LoadRemoteFile(&datasource.RemoteFileRequest{URL: server.URL + "/sales.csv"}); err == nil {
+		t.Fatal("expected a file over the upload limit to be refused")
+	}
+}
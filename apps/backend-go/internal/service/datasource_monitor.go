@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/domain/msgcenter"
+	scheduler "dataease/backend/internal/job"
+	"dataease/backend/internal/pkg/logger"
+	"dataease/backend/internal/repository"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHealthInterval  = 5 * time.Minute
+	defaultHealthRetention = 7 * 24 * time.Hour
+	healthCheckWorkers     = 4
+	defaultHealthHistory   = 50
+)
+
+// DatasourceMonitorService periodically revalidates every datasource, keeps
+// a history of the checks and notifies the owner of a datasource when it
+// starts failing.
+type DatasourceMonitorService struct {
+	repo        *repository.DatasourceRepository
+	datasources *DatasourceService
+	users       *repository.UserRepository
+	messages    *MsgCenterService
+	scheduler   *scheduler.Scheduler
+	interval    time.Duration
+	retention   time.Duration
+
+	mu       sync.Mutex
+	checking bool
+}
+
+func NewDatasourceMonitorService(repo *repository.DatasourceRepository, datasources *DatasourceService, users *repository.UserRepository, messages *MsgCenterService, sched *scheduler.Scheduler) *DatasourceMonitorService {
+	return &DatasourceMonitorService{
+		repo:        repo,
+		datasources: datasources,
+		users:       users,
+		messages:    messages,
+		scheduler:   sched,
+		interval:    defaultHealthInterval,
+		retention:   defaultHealthRetention,
+	}
+}
+
+// SetPeriods overrides the check interval and the history retention. Zero
+// values keep the defaults.
+func (s *DatasourceMonitorService) SetPeriods(interval time.Duration, retention time.Duration) {
+	if interval > 0 {
+		s.interval = interval
+	}
+	if retention > 0 {
+		s.retention = retention
+	}
+}
+
+func (s *DatasourceMonitorService) Start() {
+	s.scheduler.Schedule(scheduler.Every(time.Now(), s.interval), s.CheckAll)
+	s.scheduler.Start()
+}
+
+func (s *DatasourceMonitorService) Stop() {
+	s.scheduler.Stop()
+}
+
+// CheckAll checks every datasource and purges the expired history. A round
+// still running when the next one is due skips the new one.
+func (s *DatasourceMonitorService) CheckAll() {
+	s.mu.Lock()
+	if s.checking {
+		s.mu.Unlock()
+		return
+	}
+	s.checking = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.checking = false
+		s.mu.Unlock()
+	}()
+
+	list, err := s.repo.ListMonitorable()
+	if err != nil {
+		logger.Warn("Failed to list datasources for health check", zap.Error(err))
+		return
+	}
+	queue := make(chan *datasource.CoreDatasource)
+	var wg sync.WaitGroup
+	for i := 0; i < healthCheckWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ds := range queue {
+				if _, checkErr := s.check(ds); checkErr != nil {
+					logger.Warn("Datasource health check failed", zap.Int64("dsId", ds.ID), zap.Error(checkErr))
+				}
+			}
+		}()
+	}
+	for _, ds := range list {
+		queue <- ds
+	}
+	close(queue)
+	wg.Wait()
+
+	if err = s.repo.PurgeHealthChecks(time.Now().Add(-s.retention).UnixMilli()); err != nil {
+		logger.Warn("Failed to purge datasource health history", zap.Error(err))
+	}
+}
+
+// Check revalidates one datasource immediately.
+func (s *DatasourceMonitorService) Check(dsID int64) (*datasource.CoreDatasourceHealth, error) {
+	ds, err := s.repo.GetByID(dsID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("datasource not found")
+		}
+		return nil, err
+	}
+	if ds.Type == datasource.TypeFolder {
+		return nil, fmt.Errorf("folder has no health status")
+	}
+	return s.check(ds)
+}
+
+// History returns the latest checks of a datasource, newest first.
+func (s *DatasourceMonitorService) History(dsID int64, limit int) ([]datasource.CoreDatasourceHealth, error) {
+	if limit <= 0 {
+		limit = defaultHealthHistory
+	}
+	return s.repo.ListHealthChecks(dsID, limit)
+}
+
+func (s *DatasourceMonitorService) check(ds *datasource.CoreDatasource) (*datasource.CoreDatasourceHealth, error) {
+	start := time.Now()
+	result := s.datasources.checkStored(ds)
+	now := time.Now()
+
+	health := &datasource.CoreDatasourceHealth{
+		DsID:      ds.ID,
+		Status:    result.Status,
+		ErrorType: result.ErrorType,
+		Message:   result.Message,
+		Latency:   now.Sub(start).Milliseconds(),
+		CheckTime: now.UnixMilli(),
+	}
+	if err := s.repo.CreateHealthCheck(health); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateStatus(ds.ID, result.Status); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateTableStatus(ds.ID, result.Status, health.CheckTime); err != nil {
+		return nil, err
+	}
+
+	previous := ""
+	if ds.Status != nil {
+		previous = *ds.Status
+	}
+	if becameUnhealthy(previous, result.Status) {
+		s.notifyOwner(ds, health)
+	}
+	return health, nil
+}
+
+// becameUnhealthy reports whether a check flipped the datasource to error.
+func becameUnhealthy(previous string, current string) bool {
+	return current == datasource.StatusError && previous != datasource.StatusError
+}
+
+func (s *DatasourceMonitorService) notifyOwner(ds *datasource.CoreDatasource, health *datasource.CoreDatasourceHealth) {
+	if s.messages == nil {
+		return
+	}
+	userID := s.ownerID(ds)
+	if userID <= 0 {
+		return
+	}
+	msg := &msgcenter.CoreMessage{
+		Title:   fmt.Sprintf("Datasource %s is unavailable", ds.Name),
+		Content: healthMessage(health),
+		Type:    msgcenter.TypeDatasource,
+		Level:   msgcenter.LevelError,
+	}
+	if err := s.messages.Send(userID, msg); err != nil {
+		logger.Warn("Failed to notify datasource owner", zap.Int64("dsId", ds.ID), zap.Error(err))
+	}
+}
+
+// ownerID resolves the create_by of a datasource, which holds either a user
+// id or a username.
+func (s *DatasourceMonitorService) ownerID(ds *datasource.CoreDatasource) int64 {
+	if ds.CreateBy == nil {
+		return 0
+	}
+	owner := strings.TrimSpace(*ds.CreateBy)
+	if owner == "" {
+		return 0
+	}
+	if id, err := strconv.ParseInt(owner, 10, 64); err == nil {
+		return id
+	}
+	if s.users == nil {
+		return 0
+	}
+	u, err := s.users.GetByUsername(owner)
+	if err != nil || u == nil {
+		return 0
+	}
+	return u.UserID
+}
+
+func healthMessage(health *datasource.CoreDatasourceHealth) string {
+	if health.ErrorType == "" {
+		return health.Message
+	}
+	return fmt.Sprintf("[%s] %s", health.ErrorType, health.Message)
+}
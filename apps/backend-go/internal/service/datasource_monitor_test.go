@@ -0,0 +1,57 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/dsconn"
+)
+
+func TestBecameUnhealthy(t *testing.T) {
+	cases := []struct {
+		previous string
+		current  string
+		want     bool
+	}{
+		{datasource.StatusSuccess, datasource.StatusError, true},
+		{"", datasource.StatusError, true},
+		{datasource.StatusError, datasource.StatusError, false},
+		{datasource.StatusError, datasource.StatusSuccess, false},
+	}
+	for _, tc := range cases {
+		if got := becameUnhealthy(tc.previous, tc.current); got != tc.want {
+			t.Errorf("becameUnhealthy(%q, %q) = %v, want %v", tc.previous, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestDatasourceMonitor_OwnerIDFromNumericCreateBy(t *testing.T) {
+	monitor := &DatasourceMonitorService{}
+	owner := " 42 "
+	if got := monitor.ownerID(&datasource.CoreDatasource{CreateBy: &owner}); got != 42 {
+		t.Errorf("expected owner 42, got %d", got)
+	}
+	name := "alice"
+	if got := monitor.ownerID(&datasource.CoreDatasource{CreateBy: &name}); got != 0 {
+		t.Errorf("expected unresolved owner without a user repository, got %d", got)
+	}
+}
+
+func TestDatasourceService_CheckStoredSqlite(t *testing.T) {
+	path := t.TempDir() + "/source.db"
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	configuration := `{"dataBase": "` + path + `"}`
+	svc := NewDatasourceService(nil, dsconn.NewManager(dsconn.DefaultOptions()))
+	result := svc.checkStored(&datasource.CoreDatasource{ID: 1, Type: "sqlite", Configuration: &configuration})
+	if result.Status != datasource.StatusSuccess {
+		t.Fatalf("expected success, got %+v", result)
+	}
+
+	empty := &datasource.CoreDatasource{ID: 2, Type: "sqlite"}
+	if result = svc.checkStored(empty); result.Status != datasource.StatusError || result.ErrorType != datasource.ErrorTypeConfig {
+		t.Fatalf("expected config error for an empty configuration, got %+v", result)
+	}
+}
@@ -1,38 +1,43 @@
 package service
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
-	"regexp"
-	"strconv"
+	"net/http"
 	"strings"
 	"time"
 
 	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/domain/lineage"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/secret"
 	"dataease/backend/internal/repository"
 
 	"gorm.io/gorm"
 )
 
-var jdbcHostPortPattern = regexp.MustCompile(`(?i)://([^:/?#]+):(\d+)`)
+const validateTimeout = 15 * time.Second
 
-var repeatCheckSchemaTypes = map[string]struct{}{
-	"sqlserver": {},
-	"db2":       {},
-	"oracle":    {},
-	"pg":        {},
-	"redshift":  {},
+type DatasourceService struct {
+	repo          *repository.DatasourceRepository
+	conns         *dsconn.Manager
+	uploadDir     string
+	uploadMaxSize int64
+	remoteHosts   map[string]bool
+	apiClient     *http.Client
+	lineage       *LineageService
 }
 
-type DatasourceService struct {
-	repo *repository.DatasourceRepository
+func NewDatasourceService(repo *repository.DatasourceRepository, conns *dsconn.Manager) *DatasourceService {
+	return &DatasourceService{repo: repo, conns: conns}
 }
 
-func NewDatasourceService(repo *repository.DatasourceRepository) *DatasourceService {
-	return &DatasourceService{repo: repo}
+// SetLineage enables the impact list of delete confirmations.
+func (s *DatasourceService) SetLineage(lineage *LineageService) {
+	s.lineage = lineage
 }
 
 func (s *DatasourceService) List(req *datasource.ListRequest) (*datasource.ListResponse, error) {
@@ -50,6 +55,9 @@ func (s *DatasourceService) List(req *datasource.ListRequest) (*datasource.ListR
 		size = 10
 	}
 
+	for i := range list {
+		list[i] = redactDatasource(list[i])
+	}
 	return &datasource.ListResponse{
 		List:    list,
 		Total:   total,
@@ -61,50 +69,185 @@ func (s *DatasourceService) List(req *datasource.ListRequest) (*datasource.ListR
 func (s *DatasourceService) Validate(req *datasource.ValidateRequest) (*datasource.ValidateResponse, error) {
 	dsType, cfgRaw, err := s.resolveConfig(req)
 	if err != nil {
-		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: err.Error()}, nil
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: err.Error(), ErrorType: datasource.ErrorTypeConfig}, nil
 	}
 
 	if dsType == datasource.TypeFolder || dsType == datasource.TypeExcel {
 		return &datasource.ValidateResponse{Status: datasource.StatusSuccess, Message: "skip validation for folder/excel datasource"}, nil
 	}
 
-	cfg, err := decodeConfig(cfgRaw)
-	if err != nil {
-		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "invalid configuration: " + err.Error()}, nil
+	result := s.check(dsType, cfgRaw)
+	if req.DatasourceID != nil {
+		if err = s.repo.UpdateStatus(*req.DatasourceID, result.Status); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// check validates a decrypted configuration of the given type. It is shared
+// by Validate and the health monitor.
+func (s *DatasourceService) check(dsType string, cfgRaw string) *datasource.ValidateResponse {
+	if dsType == datasource.TypeFolder || dsType == datasource.TypeExcel {
+		return &datasource.ValidateResponse{Status: datasource.StatusSuccess, Message: "skip validation for folder/excel datasource"}
+	}
+	if isAPIType(dsType) {
+		return s.validateAPI(cfgRaw)
 	}
+	return s.validateConfig(dsType, cfgRaw)
+}
 
-	host, port := parseHostPort(cfg)
-	if host == "" || port <= 0 {
-		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "missing host/port in datasource configuration"}, nil
+// checkStored validates a stored datasource without updating its status.
+func (s *DatasourceService) checkStored(ds *datasource.CoreDatasource) *datasource.ValidateResponse {
+	if ds.Type == datasource.TypeFolder || isExcelType(ds.Type) {
+		return s.check(ds.Type, "")
+	}
+	if ds.Configuration == nil || *ds.Configuration == "" {
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "datasource configuration is empty", ErrorType: datasource.ErrorTypeConfig}
+	}
+	configuration, err := s.conns.OpenConfiguration(*ds.Configuration)
+	if err != nil {
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: err.Error(), ErrorType: datasource.ErrorTypeConfig}
 	}
+	return s.check(ds.Type, configuration)
+}
 
-	err = pingTCP(host, port, 3*time.Second)
+func (s *DatasourceService) validateConfig(dsType string, cfgRaw string) *datasource.ValidateResponse {
+	cfg, err := datasource.DecodeConfig(cfgRaw)
 	if err != nil {
-		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: err.Error()}, nil
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "invalid configuration: " + err.Error(), ErrorType: datasource.ErrorTypeConfig}
+	}
+
+	if _, ok := dsconn.Lookup(dsType); !ok {
+		host, port := cfg.HostPort()
+		if host == "" || port <= 0 {
+			return &datasource.ValidateResponse{Status: datasource.StatusError, Message: "missing host/port in datasource configuration", ErrorType: datasource.ErrorTypeConfig}
+		}
+		if err = pingTCP(host, port, 3*time.Second); err != nil {
+			return &datasource.ValidateResponse{Status: datasource.StatusError, Message: err.Error(), ErrorType: datasource.ErrorTypeNetwork}
+		}
+		return &datasource.ValidateResponse{Status: datasource.StatusSuccess, Message: "connection check passed"}
 	}
 
-	return &datasource.ValidateResponse{Status: datasource.StatusSuccess, Message: "connection check passed"}, nil
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+	if err = dsconn.Probe(ctx, dsType, cfg); err != nil {
+		errorType := datasource.ErrorTypeUnknown
+		var probeErr *dsconn.ProbeError
+		if errors.As(err, &probeErr) {
+			errorType = probeErr.Category
+		}
+		return &datasource.ValidateResponse{Status: datasource.StatusError, Message: err.Error(), ErrorType: errorType}
+	}
+	return &datasource.ValidateResponse{Status: datasource.StatusSuccess, Message: "connection check passed"}
+}
+
+// Types lists the datasource types backed by a registered provider, followed
+// by the file based types handled by the local engine.
+func (s *DatasourceService) Types() []datasource.TypeInfo {
+	providers := dsconn.Providers()
+	result := make([]datasource.TypeInfo, 0, len(providers)+2)
+	for _, provider := range providers {
+		result = append(result, datasource.TypeInfo{
+			Type:         provider.Type(),
+			Name:         provider.Name(),
+			ConfigSchema: provider.ConfigSchema(),
+		})
+	}
+	return append(result,
+		datasource.TypeInfo{Type: datasource.TypeExcel, Name: "Excel", ConfigSchema: []datasource.ConfigField{}},
+		datasource.TypeInfo{Type: datasource.TypeAPI, Name: "API", ConfigSchema: []datasource.ConfigField{}},
+	)
 }
 
 func (s *DatasourceService) Tree(req *datasource.ListRequest) ([]*datasource.CoreDatasource, error) {
-	return s.repo.ListAll(req.Keyword)
+	list, err := s.repo.ListAll(req.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i] = redactDatasource(list[i])
+	}
+	return list, nil
 }
 
-func (s *DatasourceService) GetTables(req *datasource.TableRequest) ([]datasource.TableInfo, error) {
+func (s *DatasourceService) GetTables(ctx context.Context, req *datasource.TableRequest) ([]datasource.TableInfo, error) {
 	if req.DatasourceID <= 0 {
 		return []datasource.TableInfo{}, nil
 	}
-	return s.repo.ListTables(req.DatasourceID)
+	ds, err := s.repo.GetByID(req.DatasourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("datasource not found")
+		}
+		return nil, err
+	}
+	if dsconn.IsEngineType(ds.Type) {
+		return s.repo.ListTables(req.DatasourceID)
+	}
+	conn, err := s.conns.Get(ds)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := conn.ListTablesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]datasource.TableInfo, 0, len(tables))
+	for _, table := range tables {
+		name := table.Remark
+		if name == "" {
+			name = table.Name
+		}
+		result = append(result, datasource.TableInfo{
+			DatasourceID: req.DatasourceID,
+			Name:         name,
+			TableName:    table.Name,
+			Type:         "db",
+		})
+	}
+	return result, nil
 }
 
-func (s *DatasourceService) GetTableStatus(req *datasource.TableRequest) ([]datasource.TableInfo, error) {
-	list, err := s.GetTables(req)
+func (s *DatasourceService) GetTableStatus(ctx context.Context, req *datasource.TableRequest) ([]datasource.TableInfo, error) {
+	list, err := s.GetTables(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := s.repo.GetByID(req.DatasourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("datasource not found")
+		}
+		return nil, err
+	}
+	statuses, err := s.repo.ListTableStatuses(req.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.repo.LatestHealthCheck(req.DatasourceID)
 	if err != nil {
 		return nil, err
 	}
+
+	// Tables without a recorded status inherit the datasource status.
+	status := datasource.StatusSuccess
+	if ds.Status != nil && *ds.Status != "" {
+		status = *ds.Status
+	}
+	var lastUpdate int64
+	if latest != nil {
+		lastUpdate = latest.CheckTime
+	}
 	for i := range list {
-		list[i].Status = datasource.StatusSuccess
-		list[i].LastUpdate = 0
+		if recorded, ok := statuses[list[i].TableName]; ok {
+			list[i].Status = recorded.Status
+			list[i].LastUpdate = recorded.LastUpdate
+			continue
+		}
+		list[i].Status = status
+		list[i].LastUpdate = lastUpdate
 	}
 	return list, nil
 }
@@ -113,28 +256,44 @@ func (s *DatasourceService) GetSchema() ([]string, error) {
 	return s.repo.ListSchemas()
 }
 
-func (s *DatasourceService) GetTableField(req *datasource.TableRequest) ([]datasource.TableField, error) {
-	if strings.TrimSpace(req.TableName) == "" {
+func (s *DatasourceService) GetTableField(ctx context.Context, req *datasource.TableRequest) ([]datasource.TableField, error) {
+	tableName := strings.TrimSpace(req.TableName)
+	if tableName == "" {
 		return []datasource.TableField{}, nil
 	}
-	return s.repo.ListTableFields(strings.TrimSpace(req.TableName))
+	conn, err := s.connection(req.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	return listTableFields(ctx, conn, tableName)
 }
 
-func (s *DatasourceService) PreviewData(req *datasource.TableRequest) (*datasource.PreviewDataResponse, error) {
+func (s *DatasourceService) PreviewData(ctx context.Context, req *datasource.TableRequest) (*datasource.PreviewDataResponse, error) {
 	tableName := strings.TrimSpace(req.TableName)
 	if tableName == "" {
 		return &datasource.PreviewDataResponse{Fields: []datasource.TableField{}, Data: []map[string]interface{}{}, Total: 0}, nil
 	}
 
-	fields, err := s.repo.ListTableFields(tableName)
+	conn, err := s.connection(req.DatasourceID)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := s.repo.PreviewRows(tableName, req.Limit)
+	fields, err := listTableFields(ctx, conn, tableName)
 	if err != nil {
 		return nil, err
 	}
-	total, err := s.repo.CountRows(tableName)
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	rows, err := conn.PreviewRowsContext(ctx, tableName, limit)
+	if err != nil {
+		return nil, err
+	}
+	total, err := conn.CountRowsContext(ctx, tableName)
 	if err != nil {
 		return nil, err
 	}
@@ -142,12 +301,61 @@ func (s *DatasourceService) PreviewData(req *datasource.TableRequest) (*datasour
 	return &datasource.PreviewDataResponse{Fields: fields, Data: rows, Total: total}, nil
 }
 
+// connection resolves the pooled connection of an external datasource.
+func (s *DatasourceService) connection(datasourceID int64) (*dsconn.Conn, error) {
+	if datasourceID <= 0 {
+		return nil, fmt.Errorf("datasource id is required")
+	}
+	ds, err := s.repo.GetByID(datasourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("datasource not found")
+		}
+		return nil, err
+	}
+	return s.conns.Get(ds)
+}
+
+func listTableFields(ctx context.Context, conn *dsconn.Conn, tableName string) ([]datasource.TableField, error) {
+	columns, err := conn.ListColumnsContext(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]datasource.TableField, 0, len(columns))
+	for _, column := range columns {
+		result = append(result, datasource.TableField{
+			OriginName: column.Name,
+			Name:       column.Name,
+			Type:       column.Type,
+			DeType:     conn.DeType(column.Type),
+		})
+	}
+	return result, nil
+}
+
+// RunningQueries lists the queries running on every datasource.
+func (s *DatasourceService) RunningQueries() []datasource.RunningQuery {
+	return s.conns.RunningQueries()
+}
+
+// KillQuery cancels a running query; the provider stops it server side.
+func (s *DatasourceService) KillQuery(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("query id is required")
+	}
+	return s.conns.KillQuery(id)
+}
+
 func (s *DatasourceService) ValidateByID(id int64) (*datasource.ValidateResponse, error) {
 	return s.Validate(&datasource.ValidateRequest{DatasourceID: &id})
 }
 
 func (s *DatasourceService) GetByID(id int64) (*datasource.CoreDatasource, error) {
-	return s.repo.GetByID(id)
+	ds, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return redactDatasource(ds), nil
 }
 
 func (s *DatasourceService) CheckRepeat(req *datasource.WriteRequest) (bool, error) {
@@ -166,7 +374,7 @@ func (s *DatasourceService) CheckRepeat(req *datasource.WriteRequest) (bool, err
 	if req.Configuration == nil || strings.TrimSpace(*req.Configuration) == "" {
 		return false, nil
 	}
-	currentCfg, err := decodeConfig(*req.Configuration)
+	currentCfg, err := datasource.DecodeConfig(*req.Configuration)
 	if err != nil {
 		return false, nil
 	}
@@ -188,7 +396,7 @@ func (s *DatasourceService) CheckRepeat(req *datasource.WriteRequest) (bool, err
 		if item.Configuration == nil || strings.TrimSpace(*item.Configuration) == "" {
 			continue
 		}
-		compareCfg, cfgErr := decodeConfig(*item.Configuration)
+		compareCfg, cfgErr := datasource.DecodeConfig(*item.Configuration)
 		if cfgErr != nil {
 			continue
 		}
@@ -200,7 +408,7 @@ func (s *DatasourceService) CheckRepeat(req *datasource.WriteRequest) (bool, err
 	return false, nil
 }
 
-func (s *DatasourceService) Save(req *datasource.WriteRequest) (*datasource.CoreDatasource, error) {
+func (s *DatasourceService) Save(ctx context.Context, req *datasource.WriteRequest) (*datasource.CoreDatasource, error) {
 	name := strings.TrimSpace(req.Name)
 	if name == "" {
 		return nil, fmt.Errorf("datasource name is required")
@@ -215,6 +423,10 @@ func (s *DatasourceService) Save(req *datasource.WriteRequest) (*datasource.Core
 		dsType = datasource.TypeFolder
 	}
 
+	if err := validateConfiguration(dsType, req.Configuration); err != nil {
+		return nil, err
+	}
+
 	count, err := s.repo.CountByNameAndPID(name, pid, nil)
 	if err != nil {
 		return nil, err
@@ -223,6 +435,11 @@ func (s *DatasourceService) Save(req *datasource.WriteRequest) (*datasource.Core
 		return nil, fmt.Errorf("datasource name already exists")
 	}
 
+	configuration, err := s.sealConfiguration(req.Configuration, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UnixMilli()
 	status := datasource.StatusSuccess
 	ds := &datasource.CoreDatasource{
@@ -231,20 +448,64 @@ func (s *DatasourceService) Save(req *datasource.WriteRequest) (*datasource.Core
 		Description:    req.Description,
 		Type:           dsType,
 		EditType:       req.EditType,
-		Configuration:  req.Configuration,
+		Configuration:  configuration,
 		Status:         &status,
 		EnableDataFill: req.EnableDataFill,
 		CreateTime:     &now,
 		UpdateTime:     &now,
 	}
+	if createBy := strings.TrimSpace(req.CreateBy); createBy != "" {
+		ds.CreateBy = &createBy
+	}
+
+	var excelCfg *datasource.ExcelConfig
+	if isExcelType(dsType) {
+		if strings.TrimSpace(req.FileID) == "" {
+			return nil, fmt.Errorf("file id is required")
+		}
+		excelCfg, err = s.importExcel(ctx, req, nil, datasource.EditTypeReplace)
+		if err != nil {
+			return nil, err
+		}
+		raw, marshalErr := json.Marshal(excelCfg)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		configuration := string(raw)
+		ds.Configuration = &configuration
+	}
+	var apiDefs []datasource.APIDefinition
+	if isAPIType(dsType) {
+		if apiDefs, err = s.prepareAPIDefinitions(ctx, req.Configuration, nil); err != nil {
+			return nil, err
+		}
+		configuration, marshalErr := s.marshalAPIDefinitions(apiDefs)
+		if marshalErr != nil {
+			s.dropAPITables(apiDefs)
+			return nil, marshalErr
+		}
+		ds.Configuration = &configuration
+	}
 
 	if err = s.repo.Create(ds); err != nil {
+		s.dropExcelTables(excelCfg)
+		s.dropAPITables(apiDefs)
 		return nil, err
 	}
-	return ds, nil
+	if excelCfg != nil {
+		if err = s.registerExcelTables(ds.ID, excelCfg); err != nil {
+			return nil, err
+		}
+	}
+	if apiDefs != nil {
+		if err = s.registerAPITables(ds.ID, apiDefs); err != nil {
+			return nil, err
+		}
+	}
+	return redactDatasource(ds), nil
 }
 
-func (s *DatasourceService) Update(req *datasource.WriteRequest) (*datasource.CoreDatasource, error) {
+func (s *DatasourceService) Update(ctx context.Context, req *datasource.WriteRequest) (*datasource.CoreDatasource, error) {
 	if req.ID <= 0 {
 		return nil, fmt.Errorf("datasource id is required")
 	}
@@ -274,6 +535,14 @@ func (s *DatasourceService) Update(req *datasource.WriteRequest) (*datasource.Co
 		return nil, fmt.Errorf("datasource name already exists")
 	}
 
+	// The sheets of an Excel datasource name its engine tables: they are only
+	// read from the stored row, never from the request.
+	previousConfiguration := existing.Configuration
+	var previousSheets *datasource.ExcelConfig
+	if isExcelType(existing.Type) {
+		previousSheets = decodeExcelConfig(previousConfiguration)
+	}
+
 	existing.Name = name
 	existing.PID = &pid
 	if req.Description != nil {
@@ -285,8 +554,45 @@ func (s *DatasourceService) Update(req *datasource.WriteRequest) (*datasource.Co
 	if req.EditType != nil {
 		existing.EditType = req.EditType
 	}
-	if req.Configuration != nil {
-		existing.Configuration = req.Configuration
+	if req.Configuration != nil && !isExcelType(existing.Type) {
+		if err = validateConfiguration(existing.Type, req.Configuration); err != nil {
+			return nil, err
+		}
+		if existing.Configuration, err = s.sealConfiguration(req.Configuration, previousConfiguration); err != nil {
+			return nil, err
+		}
+	}
+	var excelCfg *datasource.ExcelConfig
+	if isExcelType(existing.Type) && strings.TrimSpace(req.FileID) != "" {
+		editType := datasource.EditTypeReplace
+		if req.EditType != nil && *req.EditType == datasource.EditTypeAppend {
+			editType = datasource.EditTypeAppend
+		}
+		excelCfg, err = s.importExcel(ctx, req, previousSheets, editType)
+		if err != nil {
+			return nil, err
+		}
+		raw, marshalErr := json.Marshal(excelCfg)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		configuration := string(raw)
+		existing.Configuration = &configuration
+	}
+	var apiDefs []datasource.APIDefinition
+	if isAPIType(existing.Type) && req.Configuration != nil {
+		opened, openErr := s.conns.OpenConfiguration(*existing.Configuration)
+		if openErr != nil {
+			return nil, openErr
+		}
+		if apiDefs, err = s.prepareAPIDefinitions(ctx, &opened, previousConfiguration); err != nil {
+			return nil, err
+		}
+		configuration, marshalErr := s.marshalAPIDefinitions(apiDefs)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		existing.Configuration = &configuration
 	}
 	if req.EnableDataFill != nil {
 		existing.EnableDataFill = req.EnableDataFill
@@ -297,11 +603,22 @@ func (s *DatasourceService) Update(req *datasource.WriteRequest) (*datasource.Co
 	if err = s.repo.Update(existing); err != nil {
 		return nil, err
 	}
-	return existing, nil
+	if excelCfg != nil {
+		if err = s.registerExcelTables(existing.ID, excelCfg); err != nil {
+			return nil, err
+		}
+	}
+	if apiDefs != nil {
+		if err = s.registerAPITables(existing.ID, apiDefs); err != nil {
+			return nil, err
+		}
+	}
+	s.conns.Invalidate(existing.ID)
+	return redactDatasource(existing), nil
 }
 
 func (s *DatasourceService) CreateFolder(name string, pid int64) (*datasource.CoreDatasource, error) {
-	return s.Save(&datasource.WriteRequest{
+	return s.Save(context.Background(), &datasource.WriteRequest{
 		Name:     name,
 		PID:      &pid,
 		Type:     datasource.TypeFolder,
@@ -339,7 +656,7 @@ func (s *DatasourceService) Rename(id int64, name string) (*datasource.CoreDatas
 	if err = s.repo.Update(existing); err != nil {
 		return nil, err
 	}
-	return existing, nil
+	return redactDatasource(existing), nil
 }
 
 func (s *DatasourceService) Move(id int64, pid int64) (*datasource.CoreDatasource, error) {
@@ -381,7 +698,7 @@ func (s *DatasourceService) Move(id int64, pid int64) (*datasource.CoreDatasourc
 	if err = s.repo.Update(existing); err != nil {
 		return nil, err
 	}
-	return existing, nil
+	return redactDatasource(existing), nil
 }
 
 func (s *DatasourceService) Delete(id int64) error {
@@ -391,15 +708,20 @@ func (s *DatasourceService) Delete(id int64) error {
 	return s.deleteRecursive(id)
 }
 
-func (s *DatasourceService) PerDelete(id int64) (bool, error) {
+// PerDelete reports the dataset tables, datasets, charts and
+// visualizations that break when the datasource is deleted.
+func (s *DatasourceService) PerDelete(id int64) (*lineage.DeleteImpact, error) {
 	if id <= 0 {
-		return false, fmt.Errorf("datasource id is required")
+		return nil, fmt.Errorf("datasource id is required")
+	}
+	if s.lineage != nil {
+		return s.lineage.Impact(lineage.TypeDatasource, id)
 	}
 	count, err := s.repo.CountDatasourceRelations(id)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return count > 0, nil
+	return &lineage.DeleteImpact{InUse: count > 0, Impact: []lineage.Node{}}, nil
 }
 
 func (s *DatasourceService) LatestTypes(createBy string) ([]string, error) {
@@ -437,7 +759,11 @@ func (s *DatasourceService) deleteRecursive(id int64) error {
 			return err
 		}
 	}
-	return s.repo.SoftDelete(id)
+	if err = s.repo.SoftDelete(id); err != nil {
+		return err
+	}
+	s.conns.Invalidate(id)
+	return nil
 }
 
 func (s *DatasourceService) isDescendant(rootID int64, targetID int64) (bool, error) {
@@ -482,8 +808,8 @@ func shouldSkipRepeatCheck(dsType string) bool {
 }
 
 func requiresSchemaMatch(dsType string) bool {
-	_, ok := repeatCheckSchemaTypes[strings.ToLower(strings.TrimSpace(dsType))]
-	return ok
+	provider, ok := dsconn.Lookup(dsType)
+	return ok && provider.SchemaScoped()
 }
 
 func isSameDatasourceConnection(dsType string, current *datasource.ConnectionConfig, compare *datasource.ConnectionConfig) bool {
@@ -491,8 +817,8 @@ func isSameDatasourceConnection(dsType string, current *datasource.ConnectionCon
 		return false
 	}
 
-	currentHost, currentPort := parseHostPort(current)
-	compareHost, comparePort := parseHostPort(compare)
+	currentHost, currentPort := current.HostPort()
+	compareHost, comparePort := compare.HostPort()
 	if currentPort <= 0 || comparePort <= 0 {
 		return false
 	}
@@ -528,7 +854,11 @@ func (s *DatasourceService) resolveConfig(req *datasource.ValidateRequest) (stri
 		if ds.Configuration == nil {
 			return ds.Type, "", fmt.Errorf("datasource configuration is empty")
 		}
-		return ds.Type, *ds.Configuration, nil
+		configuration, err := s.conns.OpenConfiguration(*ds.Configuration)
+		if err != nil {
+			return ds.Type, "", err
+		}
+		return ds.Type, configuration, nil
 	}
 
 	if req.Type == nil || *req.Type == "" {
@@ -541,38 +871,47 @@ func (s *DatasourceService) resolveConfig(req *datasource.ValidateRequest) (stri
 	return *req.Type, *req.Configuration, nil
 }
 
-func decodeConfig(raw string) (*datasource.ConnectionConfig, error) {
-	decoded, err := base64.StdEncoding.DecodeString(raw)
-	if err != nil {
-		decoded = []byte(raw)
+// validateConfiguration checks a submitted configuration against the schema
+// of its provider. Folders and the types without a provider have no schema.
+func validateConfiguration(dsType string, raw *string) error {
+	provider, ok := dsconn.Lookup(dsType)
+	if !ok {
+		return nil
 	}
-
-	var cfg datasource.ConnectionConfig
-	if err = json.Unmarshal(decoded, &cfg); err != nil {
-		return nil, err
+	configuration := ""
+	if raw != nil {
+		configuration = *raw
 	}
-	return &cfg, nil
+	return datasource.ValidateConfig(provider.ConfigSchema(), configuration)
 }
 
-func parseHostPort(cfg *datasource.ConnectionConfig) (string, int) {
-	if cfg.Host != "" && cfg.Port > 0 {
-		return cfg.Host, cfg.Port
+// sealConfiguration encrypts the secrets of a submitted configuration and
+// keeps the stored secrets that it omits.
+func (s *DatasourceService) sealConfiguration(raw *string, previous *string) (*string, error) {
+	if raw == nil {
+		return nil, nil
 	}
-
-	jdbc := strings.TrimSpace(cfg.JDBCUrl)
-	if jdbc == "" {
-		return "", 0
-	}
-
-	matches := jdbcHostPortPattern.FindStringSubmatch(jdbc)
-	if len(matches) != 3 {
-		return "", 0
+	stored := ""
+	if previous != nil {
+		stored = *previous
 	}
-	port, err := strconv.Atoi(matches[2])
+	sealed, err := s.conns.SealConfiguration(*raw, stored)
 	if err != nil {
-		return "", 0
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// redactDatasource returns a copy of ds whose configuration secrets are
+// masked, for every response that carries a datasource.
+func redactDatasource(ds *datasource.CoreDatasource) *datasource.CoreDatasource {
+	if ds == nil || ds.Configuration == nil {
+		return ds
 	}
-	return matches[1], port
+	redacted := *ds
+	configuration := secret.RedactConfig(*ds.Configuration)
+	redacted.Configuration = &configuration
+	return &redacted
 }
 
 func pingTCP(host string, port int, timeout time.Duration) error {
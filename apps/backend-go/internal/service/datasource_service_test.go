@@ -1,41 +1,34 @@
 package service
 
 import (
-	"encoding/base64"
+	"context"
+	"errors"
 	"testing"
 
 	"dataease/backend/internal/domain/datasource"
 )
 
-func TestDecodeConfig_Base64(t *testing.T) {
-	raw := `{"host":"127.0.0.1","port":3306}`
-	encoded := base64.StdEncoding.EncodeToString([]byte(raw))
+func TestIsSameDatasourceConnection(t *testing.T) {
+	current := &datasource.ConnectionConfig{Host: "db.local", Port: 5432, Database: "dw", Schema: "public"}
+	compare := &datasource.ConnectionConfig{JDBCUrl: "jdbc:postgresql://DB.LOCAL:5432/dw", Database: "DW", Schema: "sales"}
 
-	cfg, err := decodeConfig(encoded)
-	if err != nil {
-		t.Fatalf("decodeConfig failed: %v", err)
+	if !isSameDatasourceConnection("mysql", current, compare) {
+		t.Fatal("expected mysql connections without schema match to be equal")
 	}
-	if cfg.Host != "127.0.0.1" || cfg.Port != 3306 {
-		t.Fatalf("unexpected config: %+v", cfg)
+	if isSameDatasourceConnection("pg", current, compare) {
+		t.Fatal("expected pg connections with different schema to differ")
 	}
 }
 
-func TestDecodeConfig_RawJSON(t *testing.T) {
-	cfg, err := decodeConfig(`{"host":"db.local","port":5432}`)
-	if err != nil {
-		t.Fatalf("decodeConfig failed: %v", err)
+func TestDatasourceService_SaveRejectsInvalidConfiguration(t *testing.T) {
+	svc := NewDatasourceService(nil, nil)
+	configuration := `{"host": "db", "port": "abc"}`
+	_, err := svc.Save(context.Background(), &datasource.WriteRequest{Name: "orders", Type: "mysql", Configuration: &configuration})
+	var cfgErr *datasource.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
 	}
-	if cfg.Host != "db.local" || cfg.Port != 5432 {
-		t.Fatalf("unexpected config: %+v", cfg)
-	}
-}
-
-func TestParseHostPort_FromJDBCUrl(t *testing.T) {
-	host, port := parseHostPort(&datasource.ConnectionConfig{
-		JDBCUrl: "jdbc:mysql://10.0.0.8:3306/dataease",
-	})
-
-	if host != "10.0.0.8" || port != 3306 {
-		t.Fatalf("unexpected host/port: %s:%d", host, port)
+	if len(cfgErr.Fields) != 2 {
+		t.Fatalf("expected port and database errors, got %+v", cfgErr.Fields)
 	}
 }
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/apisource"
+	"dataease/backend/internal/pkg/dsconn"
+)
+
+const watermarkLayout = "2006-01-02 15:04:05.999999"
+
+// extractTablePattern matches the engine tables named by newEngineTableName
+// for extracts.
+var extractTablePattern = regexp.MustCompile(`^extract_[0-9a-f]{16}$`)
+
+// SyncDatasource runs a sync task against its datasource. API datasources
+// refresh their tables; SQL datasources extract the selected table into the
+// engine. Incremental runs store the new watermark in task.ExtraData.
+func (s *DatasourceService) SyncDatasource(ctx context.Context, task *datasource.CoreDatasourceTask) error {
+	ds, err := s.repo.GetByID(task.DsID)
+	if err != nil {
+		return fmt.Errorf("datasource not found")
+	}
+	if !supportsSync(ds.Type) {
+		return fmt.Errorf("datasource type %s does not support sync tasks", ds.Type)
+	}
+	extra, err := datasource.DecodeSyncTaskExtra(task.ExtraData)
+	if err != nil {
+		return fmt.Errorf("invalid task extra data: %w", err)
+	}
+	incremental := task.UpdateType == datasource.UpdateTypeIncremental
+	if err = validateSyncTaskExtra(ds.Type, extra, incremental); err != nil {
+		return err
+	}
+
+	switch {
+	case isAPIType(ds.Type) && !incremental:
+		return s.SyncAPITable(ctx, &datasource.APISyncRequest{DatasourceID: ds.ID, TableName: extra.TableName})
+	case isAPIType(ds.Type):
+		err = s.syncAPIIncremental(ctx, ds, extra)
+	default:
+		if err = s.extractTable(ctx, ds, extra, incremental); err == nil {
+			err = s.registerExtractTable(ds.ID, task.Name, extra)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(extra)
+	if err != nil {
+		return err
+	}
+	task.ExtraData = string(raw)
+	return nil
+}
+
+// supportsSync reports whether sync tasks can be created for a type.
+func supportsSync(dsType string) bool {
+	if isAPIType(dsType) {
+		return true
+	}
+	_, ok := dsconn.Lookup(dsType)
+	return ok
+}
+
+func validateSyncTaskExtra(dsType string, extra *datasource.SyncTaskExtra, incremental bool) error {
+	if !isAPIType(dsType) && strings.TrimSpace(extra.TableName) == "" && strings.TrimSpace(extra.IncrementalSQL) == "" {
+		return fmt.Errorf("source table is required")
+	}
+	if !incremental {
+		return nil
+	}
+	if strings.TrimSpace(extra.IncrementalField) == "" {
+		return fmt.Errorf("incremental field is required")
+	}
+	if isAPIType(dsType) {
+		if strings.TrimSpace(extra.TableName) == "" {
+			return fmt.Errorf("api table is required for incremental sync")
+		}
+		if strings.TrimSpace(extra.IncrementalSQL) != "" {
+			return fmt.Errorf("custom incremental SQL is only supported for SQL datasources")
+		}
+	}
+	return nil
+}
+
+// syncAPIIncremental fetches an API table with ${last_value} substituted in
+// its request and writes the records above the watermark.
+func (s *DatasourceService) syncAPIIncremental(ctx context.Context, ds *datasource.CoreDatasource, extra *datasource.SyncTaskExtra) error {
+	raw := ""
+	if ds.Configuration != nil {
+		raw = *ds.Configuration
+	}
+	raw, err := s.conns.OpenConfiguration(raw)
+	if err != nil {
+		return err
+	}
+	defs, err := datasource.DecodeAPIDefinitions(raw)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	var def *datasource.APIDefinition
+	for i := range defs {
+		if defs[i].Name == extra.TableName || defs[i].DeTableName == extra.TableName {
+			def = &defs[i]
+			break
+		}
+	}
+	if def == nil || def.DeTableName == "" || len(def.Fields) == 0 {
+		return fmt.Errorf("api table %s has not been synced yet", extra.TableName)
+	}
+
+	request := *def
+	request.URL = strings.ReplaceAll(def.URL, datasource.LastValuePlaceholder, url.QueryEscape(extra.LastValue))
+	request.Body = strings.ReplaceAll(def.Body, datasource.LastValuePlaceholder, extra.LastValue)
+	request.Params = make([]datasource.APIKeyValue, len(def.Params))
+	for i, param := range def.Params {
+		param.Value = strings.ReplaceAll(param.Value, datasource.LastValuePlaceholder, extra.LastValue)
+		request.Params[i] = param
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiSyncTimeout)
+	defer cancel()
+	records, err := apisource.Fetch(ctx, s.apiClient, &request, 0)
+	if err != nil {
+		return err
+	}
+	columns, rows, err := extractAPIRows(&request, records)
+	if err != nil {
+		return err
+	}
+	conn, err := s.conns.Engine()
+	if err != nil {
+		return err
+	}
+	return writeIncrementalRows(ctx, conn, def.DeTableName, columns, rows, extra)
+}
+
+// extractTable copies a table, or the result of the custom incremental SQL,
+// from a SQL datasource into an engine table, streaming the rows in batches.
+// The first incremental run without a watermark loads the whole table; the
+// next ones read the rows at or above the watermark.
+func (s *DatasourceService) extractTable(ctx context.Context, ds *datasource.CoreDatasource, extra *datasource.SyncTaskExtra, incremental bool) error {
+	source, err := s.conns.Get(ds)
+	if err != nil {
+		return err
+	}
+	engine, err := s.conns.Engine()
+	if err != nil {
+		return err
+	}
+
+	// Only names generated here are written: the engine also holds the
+	// application tables.
+	if extra.TargetTable != "" && !extractTablePattern.MatchString(extra.TargetTable) {
+		return fmt.Errorf("invalid extract table %s", extra.TargetTable)
+	}
+
+	var query string
+	var args []interface{}
+	firstRun := extra.TargetTable == "" || (extra.LastValue == "" && extra.TableName != "")
+	switch {
+	case !incremental || (firstRun && extra.TableName != ""):
+		query = "SELECT * FROM " + source.QualifiedTable(extra.TableName)
+	case strings.TrimSpace(extra.IncrementalSQL) != "":
+		query = extra.IncrementalSQL
+		for strings.Contains(query, datasource.LastValuePlaceholder) {
+			query = strings.Replace(query, datasource.LastValuePlaceholder, "?", 1)
+			args = append(args, extra.LastValue)
+		}
+	default:
+		query = fmt.Sprintf("SELECT * FROM %s WHERE %s >= ?", source.QualifiedTable(extra.TableName), source.QuoteIdentifier(extra.IncrementalField))
+		args = append(args, extra.LastValue)
+	}
+
+	if incremental && !firstRun {
+		var w *incrementalWriter
+		return inEngineTx(ctx, engine, func(tx *dsconn.Tx) error {
+			return source.QueryBatchesContext(ctx, query, engineInsertBatch, func(sourceColumns []dsconn.Column, rows [][]interface{}) error {
+				if w == nil {
+					var err error
+					if w, err = newIncrementalWriter(tx, extra.TargetTable, sourceEngineColumns(source, sourceColumns), extra); err != nil {
+						return err
+					}
+				}
+				return w.write(ctx, rows)
+			}, args...)
+		})
+	}
+
+	if extra.TargetTable == "" {
+		if extra.TargetTable, err = newEngineTableName("extract"); err != nil {
+			return err
+		}
+	}
+	return replaceEngineTable(ctx, engine, extra.TargetTable, func(staging string) error {
+		var columns []engineColumn
+		return source.QueryBatchesContext(ctx, query, engineInsertBatch, func(sourceColumns []dsconn.Column, rows [][]interface{}) error {
+			if columns == nil {
+				columns = sourceEngineColumns(source, sourceColumns)
+				if err := createEngineTable(ctx, engine, staging, columns); err != nil {
+					return err
+				}
+			}
+			if err := insertEngineRows(ctx, engine, staging, columns, rows); err != nil {
+				return err
+			}
+			if incremental {
+				return advanceWatermark(columns, rows, extra)
+			}
+			return nil
+		}, args...)
+	})
+}
+
+// registerExtractTable records the engine table a task extracts into as a
+// table of its datasource, named after the source table or the task.
+func (s *DatasourceService) registerExtractTable(datasourceID int64, taskName string, extra *datasource.SyncTaskExtra) error {
+	name := strings.TrimSpace(extra.TableName)
+	if name == "" {
+		name = taskName
+	}
+	return s.repo.SaveTable(datasourceID, name, extra.TargetTable, "extract")
+}
+
+func sourceEngineColumns(source *dsconn.Conn, sourceColumns []dsconn.Column) []engineColumn {
+	columns := make([]engineColumn, len(sourceColumns))
+	for i, column := range sourceColumns {
+		columns[i] = engineColumn{Name: column.Name, DeType: source.DeType(column.Type)}
+	}
+	return columns
+}
+
+// writeIncrementalRows writes the rows of an incremental run in one
+// transaction and advances the watermark.
+func writeIncrementalRows(ctx context.Context, conn *dsconn.Conn, tableName string, columns []engineColumn, rows [][]interface{}, extra *datasource.SyncTaskExtra) error {
+	return inEngineTx(ctx, conn, func(tx *dsconn.Tx) error {
+		w, err := newIncrementalWriter(tx, tableName, columns, extra)
+		if err != nil {
+			return err
+		}
+		return w.write(ctx, rows)
+	})
+}
+
+// incrementalWriter keeps the rows of an incremental run at or above the
+// watermark it started from, upserts them on the key fields (or appends
+// them) and advances the watermark. Rows at the watermark are read again,
+// as more may have been committed with the same value after the last run;
+// when appending, they replace the rows stored with that value.
+type incrementalWriter struct {
+	tx        *dsconn.Tx
+	tableName string
+	columns   []engineColumn
+	extra     *datasource.SyncTaskExtra
+	mark      int
+	since     string
+	cleared   bool
+}
+
+func newIncrementalWriter(tx *dsconn.Tx, tableName string, columns []engineColumn, extra *datasource.SyncTaskExtra) (*incrementalWriter, error) {
+	mark, err := columnIndex(columns, extra.IncrementalField)
+	if err != nil {
+		return nil, err
+	}
+	return &incrementalWriter{tx: tx, tableName: tableName, columns: columns, extra: extra, mark: mark, since: extra.LastValue}, nil
+}
+
+func (w *incrementalWriter) write(ctx context.Context, rows [][]interface{}) error {
+	appending := len(w.extra.KeyFields) == 0
+	fresh := make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		text, ok := watermarkText(row[w.mark])
+		if !ok {
+			continue
+		}
+		order := 1
+		if w.since != "" {
+			order = compareWatermark(text, w.since)
+		}
+		if order < 0 {
+			continue
+		}
+		if order == 0 && appending && !w.cleared {
+			column := w.tx.QuoteIdentifier(w.columns[w.mark].Name)
+			if _, err := w.tx.ExecContext(ctx, "DELETE FROM "+w.tx.QuoteIdentifier(w.tableName)+" WHERE "+column+" = ?", row[w.mark]); err != nil {
+				return err
+			}
+			w.cleared = true
+		}
+		fresh = append(fresh, row)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	if !appending {
+		if err := deleteByKeys(ctx, w.tx, w.tableName, w.columns, fresh, w.extra.KeyFields); err != nil {
+			return err
+		}
+	}
+	if err := insertEngineRows(ctx, w.tx, w.tableName, w.columns, fresh); err != nil {
+		return err
+	}
+	return advanceWatermark(w.columns, fresh, w.extra)
+}
+
+func deleteByKeys(ctx context.Context, conn engineExecer, tableName string, columns []engineColumn, rows [][]interface{}, keyFields []string) error {
+	indexes := make([]int, len(keyFields))
+	conditions := make([]string, len(keyFields))
+	for i, key := range keyFields {
+		idx, err := columnIndex(columns, key)
+		if err != nil {
+			return err
+		}
+		indexes[i] = idx
+		conditions[i] = conn.QuoteIdentifier(columns[idx].Name) + " = ?"
+	}
+	match := "(" + strings.Join(conditions, " AND ") + ")"
+
+	prefix := "DELETE FROM " + conn.QuoteIdentifier(tableName) + " WHERE "
+	for start := 0; start < len(rows); start += engineInsertBatch {
+		end := start + engineInsertBatch
+		if end > len(rows) {
+			end = len(rows)
+		}
+		matches := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(indexes))
+		for _, row := range rows[start:end] {
+			matches = append(matches, match)
+			for _, idx := range indexes {
+				args = append(args, row[idx])
+			}
+		}
+		if _, err := conn.ExecContext(ctx, prefix+strings.Join(matches, " OR "), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func advanceWatermark(columns []engineColumn, rows [][]interface{}, extra *datasource.SyncTaskExtra) error {
+	mark, err := columnIndex(columns, extra.IncrementalField)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		text, ok := watermarkText(row[mark])
+		if ok && (extra.LastValue == "" || compareWatermark(text, extra.LastValue) > 0) {
+			extra.LastValue = text
+		}
+	}
+	return nil
+}
+
+func columnIndex(columns []engineColumn, name string) (int, error) {
+	for i, column := range columns {
+		if strings.EqualFold(column.Name, strings.TrimSpace(name)) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("field %s not found", name)
+}
+
+func watermarkText(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case nil:
+		return "", false
+	case time.Time:
+		return value.Format(watermarkLayout), true
+	case string:
+		return value, value != ""
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// compareWatermark orders watermark values numerically or chronologically
+// when both sides parse as such, and lexically otherwise.
+func compareWatermark(a string, b string) int {
+	if x, errA := strconv.ParseFloat(a, 64); errA == nil {
+		if y, errB := strconv.ParseFloat(b, 64); errB == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, okA := parseDateTimeText(a); okA {
+		if y, okB := parseDateTimeText(b); okB {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(a, b)
+}
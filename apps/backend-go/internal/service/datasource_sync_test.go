@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/dsconn"
+)
+
+// newSyncTest opens a sqlite source holding an orders table and an engine
+// database, and a service extracting from one into the other.
+func newSyncTest(t *testing.T) (*sql.DB, *dsconn.Conn, *DatasourceService, *datasource.CoreDatasource) {
+	t.Helper()
+	dir := t.TempDir()
+	source, err := sql.Open("sqlite", "file:"+dir+"/source.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { source.Close() })
+	if _, err = source.Exec(`CREATE TABLE orders (id INTEGER, amount REAL, updated_at INTEGER)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = source.Exec(`INSERT INTO orders VALUES (1, 10, 100), (2, 20, 200)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+dir+"/engine.db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	engine, err := dsconn.NewConn(db, "sqlite", &datasource.ConnectionConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conns := dsconn.NewManager(dsconn.DefaultOptions())
+	t.Cleanup(conns.Close)
+	conns.SetEngine(engine)
+
+	configuration := `{"dataBase":"` + dir + `/source.db"}`
+	ds := &datasource.CoreDatasource{ID: 1, Type: "sqlite", Configuration: &configuration}
+	return source, engine, NewDatasourceService(nil, conns), ds
+}
+
+func TestDatasourceService_ExtractTableIncremental(t *testing.T) {
+	source, engine, svc, ds := newSyncTest(t)
+	var err error
+	extra := &datasource.SyncTaskExtra{TableName: "orders", IncrementalField: "updated_at", KeyFields: []string{"id"}}
+
+	if err = svc.extractTable(context.Background(), ds, extra, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if extra.TargetTable == "" || extra.LastValue != "200" {
+		t.Fatalf("unexpected first run state %+v", extra)
+	}
+	if count, countErr := engine.CountRows(extra.TargetTable); countErr != nil || count != 2 {
+		t.Fatalf("expected 2 rows, got %d (%v)", count, countErr)
+	}
+
+	if _, err = source.Exec(`UPDATE orders SET amount = 25, updated_at = 300 WHERE id = 2`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = source.Exec(`INSERT INTO orders VALUES (3, 30, 250)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = svc.extractTable(context.Background(), ds, extra, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if extra.LastValue != "300" {
+		t.Fatalf("expected watermark 300, got %s", extra.LastValue)
+	}
+	_, rows, err := engine.QueryGrid(`SELECT id, amount FROM "` + extra.TargetTable + `" ORDER BY id`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 3 || rows[1][1] != 25.0 {
+		t.Fatalf("expected upserted rows, got %v", rows)
+	}
+
+	if err = svc.extractTable(context.Background(), ds, extra, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count, countErr := engine.CountRows(extra.TargetTable); countErr != nil || count != 3 {
+		t.Fatalf("expected an idle run to keep 3 rows, got %d (%v)", count, countErr)
+	}
+}
+
+// TestDatasourceService_ExtractTableAppendBoundary checks that rows committed
+// with the watermark value after a run are appended once, without copying
+// the rows already stored with it.
+func TestDatasourceService_ExtractTableAppendBoundary(t *testing.T) {
+	source, engine, svc, ds := newSyncTest(t)
+	extra := &datasource.SyncTaskExtra{TableName: "orders", IncrementalField: "updated_at"}
+	if err := svc.extractTable(context.Background(), ds, extra, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := source.Exec(`INSERT INTO orders VALUES (3, 30, 200), (4, 40, 400)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for run := 0; run < 2; run++ {
+		if err := svc.extractTable(context.Background(), ds, extra, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	_, rows, err := engine.QueryGrid(`SELECT id FROM "` + extra.TargetTable + `" ORDER BY id`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 4 || extra.LastValue != "400" {
+		t.Fatalf("expected rows 1 to 4 once with watermark 400, got %v (%s)", rows, extra.LastValue)
+	}
+}
+
+func TestDatasourceService_ExtractTableRejectsForeignTarget(t *testing.T) {
+	_, engine, svc, ds := newSyncTest(t)
+	if _, err := engine.Exec(`CREATE TABLE core_user (id INTEGER)`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	extra := &datasource.SyncTaskExtra{TableName: "orders", TargetTable: "core_user"}
+	if err := svc.extractTable(context.Background(), ds, extra, false); err == nil {
+		t.Fatal("expected a target table not named by the server to be refused")
+	}
+	if _, err := engine.CountRows("core_user"); err != nil {
+		t.Fatalf("expected core_user to be left in place: %v", err)
+	}
+}
+
+func TestReplaceEngineTable(t *testing.T) {
+	_, engine, _, _ := newSyncTest(t)
+	columns := []engineColumn{{Name: "id", DeType: 2}}
+	load := func(ids ...interface{}) func(staging string) error {
+		return func(staging string) error {
+			if err := createEngineTable(context.Background(), engine, staging, columns); err != nil {
+				return err
+			}
+			rows := make([][]interface{}, len(ids))
+			for i, id := range ids {
+				rows[i] = []interface{}{id}
+			}
+			return insertEngineRows(context.Background(), engine, staging, columns, rows)
+		}
+	}
+
+	if err := replaceEngineTable(context.Background(), engine, "extract_t", load(1, 2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failing := func(staging string) error {
+		if err := load(3)(staging); err != nil {
+			return err
+		}
+		return errors.New("source went away")
+	}
+	if err := replaceEngineTable(context.Background(), engine, "extract_t", failing); err == nil {
+		t.Fatal("expected the failing load to be reported")
+	}
+	if count, err := engine.CountRows("extract_t"); err != nil || count != 2 {
+		t.Fatalf("expected a failing reload to keep 2 rows, got %d (%v)", count, err)
+	}
+	if err := replaceEngineTable(context.Background(), engine, "extract_t", load(4)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tables, err := engine.ListTables()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count, countErr := engine.CountRows("extract_t"); countErr != nil || count != 1 || len(tables) != 1 {
+		t.Fatalf("expected the reload alone to be left, got %d rows in %+v (%v)", count, tables, countErr)
+	}
+}
+
+func TestValidateSyncTaskExtra(t *testing.T) {
+	if err := validateSyncTaskExtra("mysql", &datasource.SyncTaskExtra{}, false); err == nil {
+		t.Fatal("expected missing source table to fail")
+	}
+	if err := validateSyncTaskExtra("mysql", &datasource.SyncTaskExtra{TableName: "t"}, true); err == nil {
+		t.Fatal("expected missing incremental field to fail")
+	}
+	if err := validateSyncTaskExtra(datasource.TypeAPI, &datasource.SyncTaskExtra{TableName: "t", IncrementalField: "id", IncrementalSQL: "SELECT 1"}, true); err == nil {
+		t.Fatal("expected custom SQL on an API datasource to fail")
+	}
+	if err := validateSyncTaskExtra(datasource.TypeAPI, &datasource.SyncTaskExtra{}, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCompareWatermark(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"9", "10", -1},
+		{"2024-01-02 00:00:00", "2024-01-01 23:59:59", 1},
+		{"b", "a", 1},
+		{"1.5", "1.50", 0},
+	}
+	for _, tc := range cases {
+		if got := compareWatermark(tc.a, tc.b); got != tc.want {
+			t.Fatalf("compareWatermark(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+	scheduler "dataease/backend/internal/job"
+	"dataease/backend/internal/pkg/logger"
+	"dataease/backend/internal/repository"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DatasourceTaskService runs datasource sync tasks on the scheduler and
+// records every run in core_datasource_task_log.
+type DatasourceTaskService struct {
+	repo        *repository.DatasourceTaskRepository
+	dsRepo      *repository.DatasourceRepository
+	datasources *DatasourceService
+	datasets    *DatasetService
+	scheduler   *scheduler.Scheduler
+
+	mu      sync.Mutex
+	entries map[int64]cron.EntryID
+	running map[int64]struct{}
+}
+
+func NewDatasourceTaskService(repo *repository.DatasourceTaskRepository, dsRepo *repository.DatasourceRepository, datasources *DatasourceService, sched *scheduler.Scheduler) *DatasourceTaskService {
+	return &DatasourceTaskService{
+		repo:        repo,
+		dsRepo:      dsRepo,
+		datasources: datasources,
+		scheduler:   sched,
+		entries:     make(map[int64]cron.EntryID),
+		running:     make(map[int64]struct{}),
+	}
+}
+
+// SetDatasets refreshes the profiles of the datasets reading a datasource
+// after each successful sync.
+func (s *DatasourceTaskService) SetDatasets(datasets *DatasetService) {
+	s.datasets = datasets
+}
+
+// Start registers the persisted tasks and starts the scheduler. Tasks left
+// running by a previous process are rescheduled as waiting.
+func (s *DatasourceTaskService) Start() error {
+	tasks, err := s.repo.ListSchedulable()
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.TaskStatus == datasource.TaskStatusRunning {
+			task.TaskStatus = datasource.TaskStatusWaiting
+			if err = s.repo.UpdateTaskStatus(task.ID, task.TaskStatus); err != nil {
+				return err
+			}
+		}
+		if err = s.register(task); err != nil {
+			logger.Warn("Failed to schedule datasource task", zap.Int64("taskId", task.ID), zap.Error(err))
+		}
+	}
+	s.scheduler.Start()
+	return nil
+}
+
+func (s *DatasourceTaskService) Stop() {
+	s.scheduler.Stop()
+}
+
+// Save creates a task, or updates the schedule of an existing one, and
+// (re)registers it. A paused task stays paused.
+func (s *DatasourceTaskService) Save(req *datasource.TaskWriteRequest) (*datasource.CoreDatasourceTask, error) {
+	if req == nil {
+		return nil, fmt.Errorf("task is required")
+	}
+
+	task := &datasource.CoreDatasourceTask{TaskStatus: datasource.TaskStatusWaiting, CreateTime: time.Now().UnixMilli()}
+	previous := ""
+	if req.ID > 0 {
+		existing, err := s.getTask(req.ID)
+		if err != nil {
+			return nil, err
+		}
+		task = existing
+		previous = existing.ExtraData
+	} else {
+		task.DsID = req.DsID
+	}
+	dsType, err := s.checkDatasource(task.DsID)
+	if err != nil {
+		return nil, err
+	}
+
+	task.Name = strings.TrimSpace(req.Name)
+	if task.Name == "" {
+		task.Name = fmt.Sprintf("sync-%d", task.DsID)
+	}
+	task.UpdateType = req.UpdateType
+	if task.UpdateType == "" {
+		task.UpdateType = datasource.UpdateTypeFull
+	}
+	task.SyncRate = req.SyncRate
+	task.StartTime = req.StartTime
+	task.Cron = strings.TrimSpace(req.Cron)
+	task.SimpleCronValue = req.SimpleCronValue
+	task.SimpleCronType = req.SimpleCronType
+	task.EndLimit = req.EndLimit
+	if task.EndLimit == "" {
+		task.EndLimit = datasource.EndLimitNone
+	}
+	task.EndTime = req.EndTime
+	extra, err := datasource.DecodeSyncTaskExtra(req.ExtraData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task extra data: %w", err)
+	}
+	if err = validateSyncTaskExtra(dsType, extra, task.UpdateType == datasource.UpdateTypeIncremental); err != nil {
+		return nil, err
+	}
+	keepSyncState(extra, previous)
+	raw, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	task.ExtraData = string(raw)
+	if _, err = buildTaskSchedule(task); err != nil {
+		return nil, err
+	}
+	if task.TaskStatus != datasource.TaskStatusPaused {
+		task.TaskStatus = datasource.TaskStatusWaiting
+	}
+
+	if task.ID > 0 {
+		err = s.repo.Update(task)
+	} else {
+		err = s.repo.Create(task)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.unregister(task.ID)
+	if task.TaskStatus == datasource.TaskStatusWaiting {
+		if err = s.register(task); err != nil {
+			return nil, err
+		}
+	}
+	return task, nil
+}
+
+func (s *DatasourceTaskService) List(dsID int64) ([]datasource.CoreDatasourceTask, error) {
+	if dsID <= 0 {
+		return nil, fmt.Errorf("datasource id is required")
+	}
+	return s.repo.ListByDatasource(dsID)
+}
+
+// Pause removes a task from the scheduler until it is resumed.
+func (s *DatasourceTaskService) Pause(id int64) error {
+	task, err := s.getTask(id)
+	if err != nil {
+		return err
+	}
+	if task.TaskStatus == datasource.TaskStatusStopped {
+		return fmt.Errorf("task has already finished")
+	}
+	s.unregister(id)
+	return s.repo.UpdateTaskStatus(id, datasource.TaskStatusPaused)
+}
+
+func (s *DatasourceTaskService) Resume(id int64) error {
+	task, err := s.getTask(id)
+	if err != nil {
+		return err
+	}
+	if task.TaskStatus != datasource.TaskStatusPaused {
+		return fmt.Errorf("task is not paused")
+	}
+	task.TaskStatus = datasource.TaskStatusWaiting
+	if err = s.repo.UpdateTaskStatus(id, task.TaskStatus); err != nil {
+		return err
+	}
+	s.unregister(id)
+	return s.register(task)
+}
+
+// RunNow starts a manual run in the background.
+func (s *DatasourceTaskService) RunNow(id int64) error {
+	if _, err := s.getTask(id); err != nil {
+		return err
+	}
+	if !s.acquire(id) {
+		return fmt.Errorf("task is already running")
+	}
+	go func() {
+		defer s.release(id)
+		if err := s.execute(id, datasource.TriggerManual); err != nil {
+			logger.Warn("Datasource task run failed", zap.Int64("taskId", id), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (s *DatasourceTaskService) ListLogs(dsID int64, page int, limit int) (*datasource.TaskLogListResponse, error) {
+	if dsID <= 0 {
+		return nil, fmt.Errorf("datasource id is required")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	list, total, err := s.repo.PageLogs(dsID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &datasource.TaskLogListResponse{List: list, Total: total, Current: page, Size: limit}, nil
+}
+
+func (s *DatasourceTaskService) register(task *datasource.CoreDatasourceTask) error {
+	schedule, err := buildTaskSchedule(task)
+	if err != nil {
+		return err
+	}
+	id := task.ID
+	entryID := s.scheduler.Schedule(schedule, func() {
+		if !s.acquire(id) {
+			return
+		}
+		defer s.release(id)
+		if runErr := s.execute(id, datasource.TriggerCron); runErr != nil {
+			logger.Warn("Datasource task run failed", zap.Int64("taskId", id), zap.Error(runErr))
+		}
+	})
+
+	s.mu.Lock()
+	s.entries[id] = entryID
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *DatasourceTaskService) unregister(id int64) {
+	s.mu.Lock()
+	entryID, ok := s.entries[id]
+	delete(s.entries, id)
+	s.mu.Unlock()
+	if ok {
+		s.scheduler.Remove(entryID)
+	}
+}
+
+func (s *DatasourceTaskService) acquire(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.running[id]; busy {
+		return false
+	}
+	s.running[id] = struct{}{}
+	return true
+}
+
+func (s *DatasourceTaskService) release(id int64) {
+	s.mu.Lock()
+	delete(s.running, id)
+	s.mu.Unlock()
+}
+
+// execute runs one sync and records it. The returned error only reports
+// bookkeeping failures; a failed sync is recorded in the log row.
+func (s *DatasourceTaskService) execute(id int64, trigger string) error {
+	task, err := s.getTask(id)
+	if err != nil {
+		return err
+	}
+	start := time.Now().UnixMilli()
+	log := &datasource.CoreDatasourceTaskLog{
+		DsID:        task.DsID,
+		TaskID:      task.ID,
+		StartTime:   start,
+		TaskStatus:  datasource.ExecStatusRunning,
+		CreateTime:  start,
+		TriggerType: trigger,
+	}
+	if extra, decodeErr := datasource.DecodeSyncTaskExtra(task.ExtraData); decodeErr == nil {
+		log.PhysicalTableName = extra.TableName
+	}
+	if err = s.repo.CreateLog(log); err != nil {
+		return err
+	}
+	if task.TaskStatus == datasource.TaskStatusWaiting {
+		if err = s.repo.UpdateTaskStatus(task.ID, datasource.TaskStatusRunning); err != nil {
+			return err
+		}
+	}
+	if err = s.dsRepo.UpdateTaskStatus(task.DsID, datasource.ExecStatusRunning); err != nil {
+		return err
+	}
+
+	syncErr := s.datasources.SyncDatasource(context.Background(), task)
+	if syncErr == nil {
+		syncErr = s.repo.UpdateExtraData(task.ID, task.ExtraData)
+	}
+
+	execStatus := datasource.ExecStatusCompleted
+	if syncErr != nil {
+		execStatus = datasource.ExecStatusError
+		log.Info = syncErr.Error()
+	}
+	log.TaskStatus = execStatus
+	log.EndTime = time.Now().UnixMilli()
+	if err = s.repo.UpdateLog(log); err != nil {
+		return err
+	}
+
+	// The task may have been paused or rescheduled while it was running.
+	current, err := s.getTask(id)
+	if err != nil {
+		return err
+	}
+	taskStatus := current.TaskStatus
+	if taskStatus == datasource.TaskStatusRunning || taskStatus == datasource.TaskStatusWaiting {
+		taskStatus = datasource.TaskStatusWaiting
+		if trigger == datasource.TriggerCron && taskExhausted(current, time.Now()) {
+			taskStatus = datasource.TaskStatusStopped
+			s.unregister(id)
+		}
+	}
+	if err = s.repo.UpdateExecResult(id, start, execStatus, taskStatus); err != nil {
+		return err
+	}
+	if err = s.dsRepo.UpdateTaskStatus(task.DsID, execStatus); err != nil {
+		return err
+	}
+	if syncErr == nil && s.datasets != nil {
+		s.datasets.RefreshProfilesOf(task.DsID)
+	}
+	return nil
+}
+
+func (s *DatasourceTaskService) getTask(id int64) (*datasource.CoreDatasourceTask, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("task id is required")
+	}
+	task, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("task not found")
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// checkDatasource returns the type of a datasource that sync tasks can run on.
+func (s *DatasourceTaskService) checkDatasource(dsID int64) (string, error) {
+	if dsID <= 0 {
+		return "", fmt.Errorf("datasource id is required")
+	}
+	ds, err := s.dsRepo.GetByID(dsID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("datasource not found")
+		}
+		return "", err
+	}
+	if !supportsSync(ds.Type) {
+		return "", fmt.Errorf("datasource type %s does not support sync tasks", ds.Type)
+	}
+	return ds.Type, nil
+}
+
+// keepSyncState replaces the target table and watermark of submitted extra
+// data, which only runs write, with those stored in previous. The watermark
+// is dropped when the task reads another source.
+func keepSyncState(extra *datasource.SyncTaskExtra, previous string) {
+	extra.TargetTable, extra.LastValue = "", ""
+	stored, err := datasource.DecodeSyncTaskExtra(previous)
+	if err != nil {
+		return
+	}
+	extra.TargetTable = stored.TargetTable
+	if extra.TableName == stored.TableName && extra.IncrementalField == stored.IncrementalField &&
+		extra.IncrementalSQL == stored.IncrementalSQL {
+		extra.LastValue = stored.LastValue
+	}
+}
+
+// buildTaskSchedule translates the sync_rate of a task into a schedule
+// bounded by its start time and, with an end limit, its end time.
+func buildTaskSchedule(task *datasource.CoreDatasourceTask) (cron.Schedule, error) {
+	var start, end time.Time
+	if task.StartTime > 0 {
+		start = time.UnixMilli(task.StartTime)
+	}
+	if task.EndLimit == datasource.EndLimitTime {
+		if task.EndTime <= 0 || task.EndTime <= task.StartTime {
+			return nil, fmt.Errorf("end time must be after start time")
+		}
+		end = time.UnixMilli(task.EndTime)
+	}
+
+	switch task.SyncRate {
+	case datasource.SyncRateRightNow:
+		return scheduler.Once(start), nil
+	case datasource.SyncRateCron:
+		if task.Cron == "" {
+			return nil, fmt.Errorf("cron expression is required")
+		}
+		schedule, err := scheduler.ParseCron(task.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		return scheduler.Bounded(schedule, start, end), nil
+	case datasource.SyncRateSimpleCron:
+		var unit time.Duration
+		switch task.SimpleCronType {
+		case datasource.SimpleCronMinute:
+			unit = time.Minute
+		case datasource.SimpleCronHour:
+			unit = time.Hour
+		case datasource.SimpleCronDay:
+			unit = 24 * time.Hour
+		default:
+			return nil, fmt.Errorf("unsupported simple cron type: %s", task.SimpleCronType)
+		}
+		if task.SimpleCronValue <= 0 {
+			return nil, fmt.Errorf("simple cron value must be positive")
+		}
+		anchor := start
+		if anchor.IsZero() {
+			anchor = time.UnixMilli(task.CreateTime)
+		}
+		return scheduler.Bounded(scheduler.Every(anchor, time.Duration(task.SimpleCronValue)*unit), start, end), nil
+	default:
+		return nil, fmt.Errorf("unsupported sync rate: %s", task.SyncRate)
+	}
+}
+
+// taskExhausted reports whether a task has no activation left after now.
+func taskExhausted(task *datasource.CoreDatasourceTask, now time.Time) bool {
+	if task.SyncRate == datasource.SyncRateRightNow {
+		return true
+	}
+	schedule, err := buildTaskSchedule(task)
+	if err != nil {
+		return true
+	}
+	return schedule.Next(now).IsZero()
+}
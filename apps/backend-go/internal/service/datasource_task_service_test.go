@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"dataease/backend/internal/domain/datasource"
+)
+
+func TestBuildTaskSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		task    datasource.CoreDatasourceTask
+		wantErr bool
+	}{
+		{"cron", datasource.CoreDatasourceTask{SyncRate: datasource.SyncRateCron, Cron: "0 0 * * * ?"}, false},
+		{"invalid cron", datasource.CoreDatasourceTask{SyncRate: datasource.SyncRateCron, Cron: "nope"}, true},
+		{"simple", datasource.CoreDatasourceTask{SyncRate: datasource.SyncRateSimpleCron, SimpleCronValue: 5, SimpleCronType: datasource.SimpleCronMinute}, false},
+		{"simple without value", datasource.CoreDatasourceTask{SyncRate: datasource.SyncRateSimpleCron, SimpleCronType: datasource.SimpleCronHour}, true},
+		{"end before start", datasource.CoreDatasourceTask{SyncRate: datasource.SyncRateRightNow, StartTime: start.UnixMilli(), EndLimit: datasource.EndLimitTime, EndTime: start.UnixMilli()}, true},
+		{"unknown rate", datasource.CoreDatasourceTask{SyncRate: "weekly"}, true},
+	}
+	for _, tc := range cases {
+		_, err := buildTaskSchedule(&tc.task)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: unexpected error state: %v", tc.name, err)
+		}
+	}
+}
+
+func TestTaskExhausted(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	task := &datasource.CoreDatasourceTask{
+		SyncRate:        datasource.SyncRateSimpleCron,
+		SimpleCronValue: 1,
+		SimpleCronType:  datasource.SimpleCronHour,
+		StartTime:       start.UnixMilli(),
+		EndLimit:        datasource.EndLimitTime,
+		EndTime:         start.Add(3 * time.Hour).UnixMilli(),
+	}
+	if taskExhausted(task, start.Add(90*time.Minute)) {
+		t.Fatal("expected activations left before the end time")
+	}
+	if !taskExhausted(task, start.Add(3*time.Hour)) {
+		t.Fatal("expected task to be exhausted after the end time")
+	}
+	if !taskExhausted(&datasource.CoreDatasourceTask{SyncRate: datasource.SyncRateRightNow}, start) {
+		t.Fatal("expected one-off task to be exhausted after its run")
+	}
+}
+
+func TestKeepSyncState(t *testing.T) {
+	previous := `{"tableName":"orders","targetTable":"extract_0123456789abcdef","incrementalField":"updated_at","lastValue":"200"}`
+
+	extra := &datasource.SyncTaskExtra{TableName: "orders", IncrementalField: "updated_at", TargetTable: "core_user", LastValue: "0"}
+	keepSyncState(extra, previous)
+	if extra.TargetTable != "extract_0123456789abcdef" || extra.LastValue != "200" {
+		t.Fatalf("expected the stored target and watermark, got %+v", extra)
+	}
+
+	extra = &datasource.SyncTaskExtra{TableName: "customers", IncrementalField: "updated_at", LastValue: "999"}
+	keepSyncState(extra, previous)
+	if extra.TargetTable != "extract_0123456789abcdef" || extra.LastValue != "" {
+		t.Fatalf("expected the watermark to be dropped for another source, got %+v", extra)
+	}
+
+	extra = &datasource.SyncTaskExtra{TableName: "orders", TargetTable: "core_user", LastValue: "1"}
+	keepSyncState(extra, "")
+	if extra.TargetTable != "" || extra.LastValue != "" {
+		t.Fatalf("expected a new task to start without state, got %+v", extra)
+	}
+}
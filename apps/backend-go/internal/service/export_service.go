@@ -1,14 +1,28 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"dataease/backend/internal/domain/dataset"
 	"dataease/backend/internal/domain/export"
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/logger"
+	"dataease/backend/internal/pkg/tabular"
 	"dataease/backend/internal/repository"
+
+	"go.uber.org/zap"
 )
 
 // Export status transitions:
-// PENDING -> RUNNING -> SUCCESS
+// PENDING -> IN_PROGRESS -> SUCCESS
 //
 //	\-> FAILED
 //
@@ -18,14 +32,31 @@ var (
 	ErrNotFound     = errors.New("导出任务不存在")
 )
 
+const defaultExportLimit = 10000
+
 type ExportService struct {
-	repo *repository.ExportRepository
+	repo     *repository.ExportRepository
+	datasets *DatasetService
+	dir      string
+	limit    int64
 }
 
 func NewExportService(repo *repository.ExportRepository) *ExportService {
 	return &ExportService{repo: repo}
 }
 
+// SetDatasets enables the export of datasets.
+func (s *ExportService) SetDatasets(datasets *DatasetService) {
+	s.datasets = datasets
+}
+
+// SetOptions configures where export files are kept and the most rows a
+// dataset export writes.
+func (s *ExportService) SetOptions(dir string, limit int64) {
+	s.dir = dir
+	s.limit = limit
+}
+
 func (s *ExportService) ExportTasks() export.ExportTasksResponse {
 	counts, err := s.repo.CountByStatus()
 	if err != nil {
@@ -63,15 +94,31 @@ func (s *ExportService) Pager(req *export.PagerRequest) *export.PagerResponse {
 }
 
 func (s *ExportService) Delete(id string) error {
-	return s.repo.Delete(id)
+	return s.DeleteBatch([]string{id})
 }
 
 func (s *ExportService) DeleteBatch(ids []string) error {
-	return s.repo.DeleteBatch(ids)
+	tasks := make([]*export.ExportTask, 0, len(ids))
+	for _, id := range ids {
+		if task, err := s.repo.GetByID(id); err == nil {
+			tasks = append(tasks, task)
+		}
+	}
+	if err := s.repo.DeleteBatch(ids); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		s.removeFile(task)
+	}
+	return nil
 }
 
 func (s *ExportService) DeleteAll(exportFromType string) error {
-	return s.repo.DeleteAllByType(exportFromType)
+	ids, err := s.repo.ListIDsByType(exportFromType)
+	if err != nil {
+		return err
+	}
+	return s.DeleteBatch(ids)
 }
 
 func (s *ExportService) GetByID(id string) (*export.ExportTask, error) {
@@ -88,10 +135,222 @@ func (s *ExportService) CheckAccess(task *export.ExportTask, userID int64, isAdm
 	return nil
 }
 
+// exportParams are the params saved with a dataset export: its request and
+// whether its user read as an admin, so a retry is masked the same way.
+type exportParams struct {
+	dataset.ExportRequest
+	Admin bool `json:"admin,omitempty"`
+}
+
+// Retry runs a dataset export again from its saved request, read as its
+// user with the role they exported with. Other tasks are only marked pending.
 func (s *ExportService) Retry(id string) error {
-	return s.repo.UpdateStatus(id, "PENDING")
+	task, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if s.datasets == nil || task.ExportFromType != export.FromTypeDataset || task.Params == "" {
+		return s.repo.UpdateStatus(id, export.StatusPending)
+	}
+	if task.ExportStatus == export.StatusPending || task.ExportStatus == export.StatusInProgress {
+		return fmt.Errorf("export task is still running")
+	}
+	var params exportParams
+	if err = json.Unmarshal([]byte(task.Params), &params); err != nil {
+		return fmt.Errorf("invalid export task params: %w", err)
+	}
+	s.removeFile(task)
+	task.ExportStatus, task.ExportProgress, task.Msg = export.StatusPending, "0", ""
+	task.FileSize, task.FileSizeUnit = 0, ""
+	task.ExportTime = time.Now().UnixMilli()
+	if err = s.repo.Save(task); err != nil {
+		return err
+	}
+	s.start(WithViewer(dsconn.WithUser(context.Background(), task.UserID, ""), task.UserID, params.Admin), task, &params.ExportRequest)
+	return nil
 }
 
 func (s *ExportService) ExportLimit() *export.ExportLimitResponse {
-	return &export.ExportLimitResponse{Limit: "10000"}
+	return &export.ExportLimitResponse{Limit: strconv.FormatInt(s.resolveLimit(), 10)}
+}
+
+// ExportDataset records a task exporting the rows of a dataset and writes
+// its file in the background. The task outlives the request: ctx only
+// carries the user the queries run as.
+func (s *ExportService) ExportDataset(ctx context.Context, userID int64, req *dataset.ExportRequest) (*export.ExportTask, error) {
+	if s.datasets == nil {
+		return nil, fmt.Errorf("dataset export is not available")
+	}
+	if req == nil || req.ID <= 0 {
+		return nil, fmt.Errorf("dataset id is required")
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	switch format {
+	case "":
+		format = "xlsx"
+	case "xlsx", "csv":
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", req.Format)
+	}
+	req.Format = format
+	if _, err := dataset.ParseFilterTree(req.ExpressionTree); err != nil {
+		return nil, err
+	}
+	group, err := s.datasets.repo.GetGroupByID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if group.NodeType == nil || *group.NodeType != dataset.NodeTypeDataset {
+		return nil, fmt.Errorf("only datasets can be exported")
+	}
+	name := strings.TrimSpace(req.Filename)
+	if name == "" {
+		name = group.Name
+	}
+	v, _ := ctx.Value(viewerKey{}).(viewer)
+	params, err := json.Marshal(exportParams{ExportRequest: *req, Admin: v.admin})
+	if err != nil {
+		return nil, err
+	}
+	id, err := generateUUID()
+	if err != nil {
+		return nil, err
+	}
+	machine, _ := os.Hostname()
+
+	task := &export.ExportTask{
+		ID:                id,
+		UserID:            userID,
+		FileName:          name + "." + format,
+		ExportFrom:        req.ID,
+		ExportStatus:      export.StatusPending,
+		ExportFromType:    export.FromTypeDataset,
+		ExportTime:        time.Now().UnixMilli(),
+		ExportProgress:    "0",
+		ExportMachineName: machine,
+		ExportFromName:    group.Name,
+		Params:            string(params),
+	}
+	if err = s.repo.Create(task); err != nil {
+		return nil, err
+	}
+	s.start(context.WithoutCancel(ctx), task, req)
+	return task, nil
+}
+
+// FilePath returns the file of a finished task.
+func (s *ExportService) FilePath(task *export.ExportTask) (string, error) {
+	if task.ExportStatus != export.StatusSuccess {
+		return "", fmt.Errorf("export task is not finished")
+	}
+	path := s.filePath(task)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("export file is missing")
+	}
+	return path, nil
+}
+
+func (s *ExportService) start(ctx context.Context, task *export.ExportTask, req *dataset.ExportRequest) {
+	go func() {
+		if err := s.run(ctx, task, req); err != nil {
+			logger.Warn("Dataset export failed", zap.String("taskId", task.ID), zap.Int64("datasetId", req.ID), zap.Error(err))
+			s.removeFile(task)
+			task.ExportStatus, task.Msg = export.StatusFailed, err.Error()
+		} else {
+			task.ExportStatus, task.ExportProgress = export.StatusSuccess, "100"
+		}
+		if err := s.repo.Save(task); err != nil {
+			logger.Warn("Failed to save export task", zap.String("taskId", task.ID), zap.Error(err))
+		}
+	}()
+}
+
+// run writes the file of a dataset export, recording its progress in whole
+// percents and its size once written.
+func (s *ExportService) run(ctx context.Context, task *export.ExportTask, req *dataset.ExportRequest) error {
+	task.ExportStatus = export.StatusInProgress
+	if err := s.repo.Save(task); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.resolveDir(), 0o750); err != nil {
+		return err
+	}
+	path := s.filePath(task)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	w, err := tabular.NewWriter(req.Format, file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	percent := 0
+	err = s.datasets.ExportRows(ctx, task.UserID, req, s.resolveLimit(), w, func(written, total int64) {
+		if total <= 0 {
+			return
+		}
+		// 100 is only reported once the file is complete.
+		if next := int(written * 99 / total); next > percent {
+			percent = next
+			if updateErr := s.repo.UpdateProgress(task.ID, strconv.Itoa(percent)); updateErr != nil {
+				logger.Warn("Failed to update export progress", zap.String("taskId", task.ID), zap.Error(updateErr))
+			}
+		}
+	})
+	task.ExportProgress = strconv.Itoa(percent)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	task.FileSize, task.FileSizeUnit = fileSize(info.Size())
+	return nil
+}
+
+// fileSize reports a size in Kb below a megabyte, in Mb below a gigabyte
+// and in Gb above, rounded to two decimals.
+func fileSize(bytes int64) (float64, string) {
+	size, unit := float64(bytes)/1024, "Kb"
+	if size > 1024 {
+		size, unit = size/1024, "Mb"
+	}
+	if size > 1024 {
+		size, unit = size/1024, "Gb"
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(size, 'f', 2, 64), 64)
+	return rounded, unit
+}
+
+func (s *ExportService) filePath(task *export.ExportTask) string {
+	return filepath.Join(s.resolveDir(), task.ID+filepath.Ext(task.FileName))
+}
+
+func (s *ExportService) removeFile(task *export.ExportTask) {
+	if err := os.Remove(s.filePath(task)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Failed to remove export file", zap.String("taskId", task.ID), zap.Error(err))
+	}
+}
+
+func (s *ExportService) resolveDir() string {
+	if strings.TrimSpace(s.dir) != "" {
+		return s.dir
+	}
+	return filepath.Join(os.TempDir(), "dataease", "export")
+}
+
+func (s *ExportService) resolveLimit() int64 {
+	if s.limit > 0 {
+		return s.limit
+	}
+	return defaultExportLimit
 }
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"dataease/backend/internal/domain/dataset"
+)
+
+func TestFileSize(t *testing.T) {
+	cases := []struct {
+		bytes int64
+		size  float64
+		unit  string
+	}{
+		{512, 0.5, "Kb"},
+		{1536 * 1024, 1.5, "Mb"},
+		{1234567, 1.18, "Mb"},
+		{3 << 30, 3, "Gb"},
+	}
+	for _, tc := range cases {
+		size, unit := fileSize(tc.bytes)
+		if size != tc.size || unit != tc.unit {
+			t.Errorf("fileSize(%d) = %v %s, want %v %s", tc.bytes, size, unit, tc.size, tc.unit)
+		}
+	}
+}
+
+func TestExportParams(t *testing.T) {
+	saved, err := json.Marshal(exportParams{ExportRequest: dataset.ExportRequest{ID: 3, Format: "csv"}, Admin: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var params exportParams
+	if err = json.Unmarshal(saved, &params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.ID != 3 || params.Format != "csv" || !params.Admin {
+		t.Errorf("expected the request and role to be kept, got %+v", params)
+	}
+
+	// Tasks saved before the role was recorded retry as non-admins.
+	params = exportParams{}
+	if err = json.Unmarshal([]byte(`{"id":3,"format":"csv"}`), &params); err != nil || params.Admin {
+		t.Errorf("expected a non-admin retry, got %+v, %v", params, err)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"dataease/backend/internal/domain/lineage"
+)
+
+type LineageRepository interface {
+	Nodes(nodeType string, ids []int64) ([]lineage.Link, error)
+	Downstream(nodeType string, ids []int64) ([]lineage.Link, error)
+	Upstream(nodeType string, ids []int64) ([]lineage.Link, error)
+}
+
+// LineageService walks the dependencies between datasources, dataset
+// tables, datasets, charts and visualizations.
+type LineageService struct {
+	repo LineageRepository
+}
+
+func NewLineageService(repo LineageRepository) *LineageService {
+	return &LineageService{repo: repo}
+}
+
+// Graph returns the resources upstream and/or downstream of one resource.
+func (s *LineageService) Graph(req *lineage.Request) (*lineage.Graph, error) {
+	if req == nil || req.ID <= 0 {
+		return nil, fmt.Errorf("resource id is required")
+	}
+	nodeType := strings.TrimSpace(req.Type)
+	if lineage.Downstream(nodeType) == "" && lineage.Upstream(nodeType) == "" {
+		return nil, fmt.Errorf("unsupported lineage node type: %s", req.Type)
+	}
+	direction := strings.TrimSpace(req.Direction)
+	if direction == "" {
+		direction = lineage.DirectionBoth
+	}
+	if direction != lineage.DirectionBoth && direction != lineage.DirectionUpstream && direction != lineage.DirectionDownstream {
+		return nil, fmt.Errorf("unsupported lineage direction: %s", req.Direction)
+	}
+
+	roots, err := s.repo.Nodes(nodeType, []int64{req.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("%s not found", strings.ReplaceAll(nodeType, "_", " "))
+	}
+
+	g := newLineageGraph(nodeType, roots[0])
+	if direction != lineage.DirectionUpstream {
+		if err = s.walk(g, nodeType, req.ID, lineage.DirectionDownstream); err != nil {
+			return nil, err
+		}
+	}
+	if direction != lineage.DirectionDownstream {
+		if err = s.walk(g, nodeType, req.ID, lineage.DirectionUpstream); err != nil {
+			return nil, err
+		}
+	}
+	return &g.Graph, nil
+}
+
+// Impact lists the resources downstream of a resource, which break when it
+// is deleted.
+func (s *LineageService) Impact(nodeType string, id int64) (*lineage.DeleteImpact, error) {
+	g, err := s.Graph(&lineage.Request{Type: nodeType, ID: id, Direction: lineage.DirectionDownstream})
+	if err != nil {
+		return nil, err
+	}
+	impact := make([]lineage.Node, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if node.Direction == lineage.DirectionDownstream {
+			impact = append(impact, node)
+		}
+	}
+	return &lineage.DeleteImpact{InUse: len(impact) > 0, Impact: impact}, nil
+}
+
+// walk follows the links of one direction level by level. Every resource
+// is expanded once, so shared resources do not multiply the queries.
+func (s *LineageService) walk(g *lineageGraph, nodeType string, id int64, direction string) error {
+	ids := []int64{id}
+	for len(ids) > 0 {
+		var next string
+		var links []lineage.Link
+		var err error
+		if direction == lineage.DirectionDownstream {
+			next = lineage.Downstream(nodeType)
+			if next == "" {
+				return nil
+			}
+			links, err = s.repo.Downstream(nodeType, ids)
+		} else {
+			next = lineage.Upstream(nodeType)
+			if next == "" {
+				return nil
+			}
+			links, err = s.repo.Upstream(nodeType, ids)
+		}
+		if err != nil {
+			return err
+		}
+
+		ids = ids[:0:0]
+		for _, link := range links {
+			key := lineage.Key(next, link.ID)
+			linked := lineage.Key(nodeType, link.LinkID)
+			if direction == lineage.DirectionDownstream {
+				g.addEdge(linked, key)
+			} else {
+				g.addEdge(key, linked)
+			}
+			if g.addNode(next, link, direction) {
+				ids = append(ids, link.ID)
+			}
+		}
+		nodeType = next
+	}
+	return nil
+}
+
+type lineageGraph struct {
+	lineage.Graph
+	nodes map[string]struct{}
+	edges map[lineage.Edge]struct{}
+}
+
+func newLineageGraph(nodeType string, root lineage.Link) *lineageGraph {
+	g := &lineageGraph{
+		Graph: lineage.Graph{
+			Root:  lineage.Key(nodeType, root.ID),
+			Nodes: make([]lineage.Node, 0),
+			Edges: make([]lineage.Edge, 0),
+		},
+		nodes: make(map[string]struct{}),
+		edges: make(map[lineage.Edge]struct{}),
+	}
+	g.addNode(nodeType, root, lineage.DirectionRoot)
+	return g
+}
+
+func (g *lineageGraph) addNode(nodeType string, link lineage.Link, direction string) bool {
+	key := lineage.Key(nodeType, link.ID)
+	if _, ok := g.nodes[key]; ok {
+		return false
+	}
+	g.nodes[key] = struct{}{}
+	g.Nodes = append(g.Nodes, lineage.Node{
+		Key:       key,
+		ID:        link.ID,
+		Type:      nodeType,
+		Name:      link.Name,
+		Owner:     link.Owner,
+		Direction: direction,
+	})
+	return true
+}
+
+func (g *lineageGraph) addEdge(from string, to string) {
+	edge := lineage.Edge{From: from, To: to}
+	if _, ok := g.edges[edge]; ok {
+		return
+	}
+	g.edges[edge] = struct{}{}
+	g.Edges = append(g.Edges, edge)
+}
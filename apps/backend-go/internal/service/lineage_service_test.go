@@ -0,0 +1,158 @@
+package service
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/lineage"
+)
+
+// fakeLineageRepository keeps links as child -> parent per type, where the
+// parent is the upstream resource.
+type fakeLineageRepository struct {
+	nodes   map[string]lineage.Link
+	parents map[string][]int64
+}
+
+func (f *fakeLineageRepository) Nodes(nodeType string, ids []int64) ([]lineage.Link, error) {
+	var out []lineage.Link
+	for _, id := range ids {
+		if node, ok := f.nodes[lineage.Key(nodeType, id)]; ok {
+			out = append(out, node)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeLineageRepository) Downstream(nodeType string, ids []int64) ([]lineage.Link, error) {
+	next := lineage.Downstream(nodeType)
+	var out []lineage.Link
+	for key, node := range f.nodes {
+		if key != lineage.Key(next, node.ID) {
+			continue
+		}
+		for _, parent := range f.parents[key] {
+			for _, id := range ids {
+				if parent == id {
+					link := node
+					link.LinkID = id
+					out = append(out, link)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeLineageRepository) Upstream(nodeType string, ids []int64) ([]lineage.Link, error) {
+	prev := lineage.Upstream(nodeType)
+	var out []lineage.Link
+	for _, id := range ids {
+		for _, parent := range f.parents[lineage.Key(nodeType, id)] {
+			link := f.nodes[lineage.Key(prev, parent)]
+			link.LinkID = id
+			out = append(out, link)
+		}
+	}
+	return out, nil
+}
+
+func newFakeLineage() *fakeLineageRepository {
+	f := &fakeLineageRepository{nodes: map[string]lineage.Link{}, parents: map[string][]int64{}}
+	add := func(nodeType string, id int64, name string, parents ...int64) {
+		f.nodes[lineage.Key(nodeType, id)] = lineage.Link{ID: id, Name: name, Owner: "admin"}
+		f.parents[lineage.Key(nodeType, id)] = parents
+	}
+	add(lineage.TypeDatasource, 1, "mysql")
+	add(lineage.TypeDatasetTable, 10, "orders", 1)
+	add(lineage.TypeDatasetTable, 11, "users", 1)
+	add(lineage.TypeDataset, 20, "sales", 10, 11)
+	add(lineage.TypeChart, 30, "revenue", 20)
+	add(lineage.TypeChart, 31, "top users", 20)
+	add(lineage.TypeVisualization, 40, "dashboard", 30, 31)
+	return f
+}
+
+func TestLineageService_GraphDownstreamDedupesSharedNodes(t *testing.T) {
+	svc := NewLineageService(newFakeLineage())
+	g, err := svc.Graph(&lineage.Request{Type: lineage.TypeDatasource, ID: 1, Direction: lineage.DirectionDownstream})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.Root != "datasource:1" {
+		t.Fatalf("unexpected root %s", g.Root)
+	}
+	if len(g.Nodes) != 7 {
+		t.Fatalf("expected 7 nodes, got %+v", g.Nodes)
+	}
+	if len(g.Edges) != 8 {
+		t.Fatalf("expected 8 edges, got %+v", g.Edges)
+	}
+	for _, node := range g.Nodes {
+		if node.Key != g.Root && node.Direction != lineage.DirectionDownstream {
+			t.Fatalf("unexpected direction for %+v", node)
+		}
+	}
+}
+
+func TestLineageService_GraphBothDirections(t *testing.T) {
+	svc := NewLineageService(newFakeLineage())
+	g, err := svc.Graph(&lineage.Request{Type: lineage.TypeChart, ID: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	directions := map[string]string{}
+	for _, node := range g.Nodes {
+		directions[node.Key] = node.Direction
+	}
+	want := map[string]string{
+		"chart:30":         lineage.DirectionRoot,
+		"visualization:40": lineage.DirectionDownstream,
+		"dataset:20":       lineage.DirectionUpstream,
+		"dataset_table:10": lineage.DirectionUpstream,
+		"dataset_table:11": lineage.DirectionUpstream,
+		"datasource:1":     lineage.DirectionUpstream,
+	}
+	if len(directions) != len(want) {
+		t.Fatalf("expected %d nodes, got %+v", len(want), g.Nodes)
+	}
+	for key, direction := range want {
+		if directions[key] != direction {
+			t.Errorf("%s: expected %s, got %q", key, direction, directions[key])
+		}
+	}
+}
+
+func TestLineageService_Impact(t *testing.T) {
+	svc := NewLineageService(newFakeLineage())
+	impact, err := svc.Impact(lineage.TypeDataset, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !impact.InUse || len(impact.Impact) != 3 {
+		t.Fatalf("expected charts and dashboard in the impact, got %+v", impact)
+	}
+
+	impact, err = svc.Impact(lineage.TypeVisualization, 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if impact.InUse || len(impact.Impact) != 0 {
+		t.Fatalf("expected no impact for a dashboard, got %+v", impact)
+	}
+}
+
+func TestLineageService_RejectsInvalidRequests(t *testing.T) {
+	svc := NewLineageService(newFakeLineage())
+	cases := []*lineage.Request{
+		nil,
+		{Type: lineage.TypeDatasource},
+		{Type: "report", ID: 1},
+		{Type: lineage.TypeDatasource, ID: 1, Direction: "sideways"},
+		{Type: lineage.TypeDatasource, ID: 99},
+	}
+	for _, req := range cases {
+		if _, err := svc.Graph(req); err == nil {
+			t.Errorf("expected error for %+v", req)
+		}
+	}
+}
@@ -1,23 +1,62 @@
 package service
 
 import (
+	"encoding/json"
+	"strconv"
+	"time"
+
 	"dataease/backend/internal/domain/msgcenter"
 	"dataease/backend/internal/repository"
+	"dataease/backend/internal/transport/ws"
 )
 
 type MsgCenterService struct {
 	repo *repository.MsgCenterRepository
+	hub  *ws.Hub
 }
 
 func NewMsgCenterService(repo *repository.MsgCenterRepository) *MsgCenterService {
 	return &MsgCenterService{repo: repo}
 }
 
-func (s *MsgCenterService) Count(_ *msgcenter.CountRequest) int64 {
-	return 0
+// SetHub enables websocket pushes of the messages sent.
+func (s *MsgCenterService) SetHub(hub *ws.Hub) {
+	s.hub = hub
+}
+
+// Send stores a message for the user and pushes it to their open
+// websocket connections.
+func (s *MsgCenterService) Send(userID int64, msg *msgcenter.CoreMessage) error {
+	msg.UserID = userID
+	if msg.CreateTime == 0 {
+		msg.CreateTime = time.Now().UnixMilli()
+	}
+	if err := s.repo.CreateMessage(msg); err != nil {
+		return err
+	}
+	if s.hub == nil || userID <= 0 {
+		return nil
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"type": "msg-center",
+		"data": toMessage(msg, false),
+	})
+	if err != nil {
+		return err
+	}
+	s.hub.SendToUser(uint64(userID), payload)
+	return nil
+}
+
+func (s *MsgCenterService) Count(_ *msgcenter.CountRequest, userID int64) int64 {
+	count, err := s.repo.CountUnread(userID)
+	if err != nil {
+		return 0
+	}
+	return count
 }
 
-func (s *MsgCenterService) List(req *msgcenter.ListRequest) *msgcenter.ListResponse {
+func (s *MsgCenterService) List(req *msgcenter.ListRequest, userID int64) *msgcenter.ListResponse {
 	current := req.Current
 	if current < 1 {
 		current = 1
@@ -27,12 +66,42 @@ func (s *MsgCenterService) List(req *msgcenter.ListRequest) *msgcenter.ListRespo
 		size = 10
 	}
 
-	return &msgcenter.ListResponse{
+	result := &msgcenter.ListResponse{
 		List:    make([]msgcenter.Message, 0),
 		Total:   0,
 		Current: current,
 		Size:    size,
 	}
+	rows, total, err := s.repo.ListMessages(userID, req.Type, req.ReadStatus, (current-1)*size, size)
+	if err != nil || len(rows) == 0 {
+		result.Total = total
+		return result
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, strconv.FormatInt(row.ID, 10))
+	}
+	read, err := s.repo.GetReadStatusMap(ids, userID)
+	if err != nil {
+		read = map[string]bool{}
+	}
+	for i := range rows {
+		result.List = append(result.List, toMessage(&rows[i], read[ids[i]]))
+	}
+	result.Total = total
+	return result
+}
+
+func toMessage(row *msgcenter.CoreMessage, read bool) msgcenter.Message {
+	return msgcenter.Message{
+		ID:         strconv.FormatInt(row.ID, 10),
+		Title:      row.Title,
+		Content:    row.Content,
+		Type:       row.Type,
+		Level:      row.Level,
+		Read:       read,
+		CreateTime: row.CreateTime,
+	}
 }
 
 func (s *MsgCenterService) Read(req *msgcenter.ReadRequest, userID int64) *msgcenter.ReadResponse {
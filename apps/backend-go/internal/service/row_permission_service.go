@@ -1,25 +1,61 @@
 package service
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dataease/backend/internal/domain/dataset"
 	"dataease/backend/internal/domain/permission"
 	"dataease/backend/internal/pkg/logger"
+	"dataease/backend/internal/repository"
 
 	"go.uber.org/zap"
 )
 
-type RowPermissionService struct{}
+// RowPermissionService resolves the rules restricting the dataset rows a
+// user reads.
+type RowPermissionService struct {
+	repo      *repository.DatasetRepository
+	userRoles *repository.UserRoleRepository
+}
 
-func NewRowPermissionService() *RowPermissionService {
-	return &RowPermissionService{}
+func NewRowPermissionService(repo *repository.DatasetRepository, userRoles *repository.UserRoleRepository) *RowPermissionService {
+	return &RowPermissionService{repo: repo, userRoles: userRoles}
 }
 
+// GetRowPermissionsTree returns the enabled rules of a dataset applying to a
+// user, but those whose white list holds them. Rules that cannot be read
+// fail the lookup rather than being skipped.
 func (s *RowPermissionService) GetRowPermissionsTree(datasetID, userID int64) (*permission.RowPermissionFilter, error) {
-	logger.Info("GetRowPermissionsTree called",
-		zap.Int64("datasetId", datasetID),
-		zap.Int64("userId", userID),
-	)
-
-	return nil, nil
+	if s.repo == nil {
+		return nil, fmt.Errorf("row permissions are not available")
+	}
+	roleIDs, orgIDs, err := userTargets(s.userRoles, userID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ListRowPermissionsFor(datasetID, userID, roleIDs, orgIDs)
+	if err != nil {
+		return nil, err
+	}
+	filter := &permission.RowPermissionFilter{DatasetID: datasetID, UserID: userID}
+	for _, rule := range rules {
+		exempt, err := rule.Exempts(userID, roleIDs, orgIDs)
+		if err != nil {
+			return nil, err
+		}
+		if exempt {
+			continue
+		}
+		tree, err := rule.Tree()
+		if err != nil {
+			return nil, err
+		}
+		filter.Rules = append(filter.Rules, *tree)
+	}
+	return filter, nil
 }
 
 func (s *RowPermissionService) BuildWhereClause(filter *permission.RowPermissionFilter) (string, []interface{}, error) {
@@ -66,3 +102,71 @@ func (s *RowPermissionService) GetUserRoleIDs(userID int64) []int64 {
 	logger.Debug("GetUserRoleIDs called", zap.Int64("userId", userID))
 	return nil
 }
+
+// DatasetFilter returns the row permissions of a user on a dataset as a
+// filter on its rows, or nil when none apply. Every rule must hold.
+func (s *RowPermissionService) DatasetFilter(datasetID, userID int64) (*dataset.FilterTree, error) {
+	filter, err := s.GetRowPermissionsTree(datasetID, userID)
+	if err != nil || filter == nil || len(filter.Rules) == 0 {
+		return nil, err
+	}
+	return rowPermissionFilter(filter.Rules), nil
+}
+
+func rowPermissionFilter(rules []permission.RowPermissionTree) *dataset.FilterTree {
+	tree := &dataset.FilterTree{Logic: permission.LogicAnd, Items: make([]dataset.FilterItem, 0, len(rules))}
+	for _, rule := range rules {
+		tree.Items = append(tree.Items, rowPermissionItem(rule))
+	}
+	return tree
+}
+
+func rowPermissionItem(rule permission.RowPermissionTree) dataset.FilterItem {
+	if rule.Type == permission.NodeTypeTree {
+		children := rule.Children
+		if rule.SubTree != nil {
+			children = append(append([]permission.RowPermissionTree{}, children...), *rule.SubTree)
+		}
+		sub := rowPermissionFilter(children)
+		if rule.Logic == permission.LogicOr {
+			sub.Logic = permission.LogicOr
+		}
+		return dataset.FilterItem{Type: "tree", SubTree: sub}
+	}
+
+	item := dataset.FilterItem{
+		Type:       "item",
+		FieldID:    json.Number(strconv.FormatInt(rule.FieldID, 10)),
+		FilterType: "logic",
+		Term:       rule.Operator,
+	}
+	switch rule.Operator {
+	case permission.OperatorNotIn:
+		item.Term = "not in"
+	case permission.OperatorNotLike:
+		item.Term = "not like"
+	}
+	if values, ok := rule.Value.([]interface{}); ok {
+		texts := make([]string, len(values))
+		for i, v := range values {
+			texts[i] = ruleValue(v)
+		}
+		if rule.Operator == permission.OperatorIn {
+			item.FilterType, item.EnumValue = "enum", texts
+		} else {
+			item.Value = strings.Join(texts, ",")
+		}
+	} else if rule.Value != nil {
+		item.Value = ruleValue(rule.Value)
+	}
+	return item
+}
+
+// ruleValue writes a JSON value of a rule as filter text, keeping numbers
+// out of exponent notation.
+func ruleValue(v interface{}) string {
+	if n, ok := v.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"dataease/backend/internal/domain/permission"
+)
+
+func TestRowPermissionFilter(t *testing.T) {
+	tree := rowPermissionFilter([]permission.RowPermissionTree{
+		{Type: permission.NodeTypeItem, FieldID: 10, Operator: permission.OperatorIn, Value: []interface{}{"north", "east"}},
+		{Type: permission.NodeTypeTree, Logic: permission.LogicOr, Children: []permission.RowPermissionTree{
+			{Type: permission.NodeTypeItem, FieldID: 11, Operator: permission.OperatorGe, Value: float64(1e7)},
+			{Type: permission.NodeTypeItem, FieldID: 12, Operator: permission.OperatorNotIn, Value: []interface{}{float64(1), float64(2)}},
+			{Type: permission.NodeTypeItem, FieldID: 13, Operator: permission.OperatorNull},
+		}},
+	})
+
+	if tree.Logic != permission.LogicAnd || len(tree.Items) != 2 {
+		t.Fatalf("unexpected tree: %+v", tree)
+	}
+	enum := tree.Items[0]
+	if enum.FilterType != "enum" || enum.FieldID != "10" || len(enum.EnumValue) != 2 || enum.EnumValue[1] != "east" {
+		t.Errorf("in rule should become an enum item, got %+v", enum)
+	}
+	sub := tree.Items[1].SubTree
+	if tree.Items[1].Type != "tree" || sub == nil || sub.Logic != permission.LogicOr || len(sub.Items) != 3 {
+		t.Fatalf("unexpected sub tree: %+v", tree.Items[1])
+	}
+	if got := sub.Items[0]; got.Term != "ge" || got.Value != "10000000" {
+		t.Errorf("numbers should keep their digits, got %+v", got)
+	}
+	if got := sub.Items[1]; got.Term != "not in" || got.Value != "1,2" {
+		t.Errorf("not_in rule should list its values, got %+v", got)
+	}
+	if got := sub.Items[2]; got.Term != "null" || got.Value != "" {
+		t.Errorf("null rule should have no value, got %+v", got)
+	}
+}
@@ -3,20 +3,32 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"dataease/backend/internal/domain/dataset"
 	"dataease/backend/internal/domain/ticket"
 	"dataease/backend/internal/repository"
 )
 
 type TicketService struct {
-	repo *repository.TicketRepository
+	repo   *repository.TicketRepository
+	shares *repository.ShareRepository
 }
 
 func NewTicketService(repo *repository.TicketRepository) *TicketService {
 	return &TicketService{repo: repo}
 }
 
+// SetShares enables checking the resource a ticket was issued for. Ticket
+// args are refused without it.
+func (s *TicketService) SetShares(shares *repository.ShareRepository) {
+	s.shares = shares
+}
+
 func (s *TicketService) CreateTicket(req *ticket.TicketCreateRequest) (*ticket.TicketCreateResponse, error) {
 	ticketStr := req.Ticket
 	if ticketStr == "" || req.GenerateNew {
@@ -65,6 +77,57 @@ func (s *TicketService) ValidateTicket(ticketStr string) *ticket.TicketValidateR
 	}
 }
 
+// Args returns the args of a valid ticket as values of SQL variables, once
+// checked that the ticket was issued for the share of resourceID. The args
+// are a JSON object keyed by variable name.
+func (s *TicketService) Args(ticketStr string, resourceID int64) ([]dataset.SQLParameter, error) {
+	t, err := s.repo.FindByTicket(ticketStr)
+	if err != nil || t == nil {
+		return nil, fmt.Errorf("share ticket is invalid")
+	}
+	if t.Exp > 0 && time.Now().Unix() > t.Exp {
+		return nil, fmt.Errorf("share ticket has expired")
+	}
+	if s.shares == nil {
+		return nil, fmt.Errorf("share ticket cannot be verified")
+	}
+	shared, err := s.shares.GetByUUID(t.UUID)
+	if err != nil || shared.ResourceID != resourceID {
+		return nil, fmt.Errorf("share ticket does not grant resource %d", resourceID)
+	}
+	return ticketParameters(t.Args)
+}
+
+func ticketParameters(args string) ([]dataset.SQLParameter, error) {
+	if strings.TrimSpace(args) == "" {
+		return nil, nil
+	}
+	values := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(args), &values); err != nil {
+		return nil, fmt.Errorf("invalid share ticket args: %w", err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]dataset.SQLParameter, 0, len(values))
+	for _, name := range names {
+		param := dataset.SQLParameter{VariableName: name}
+		switch value := values[name].(type) {
+		case nil:
+			continue
+		case []interface{}:
+			param.Value = value
+		default:
+			param.Value = []interface{}{value}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
 func (s *TicketService) DeleteTicket(req *ticket.TicketDeleteRequest) error {
 	return s.repo.Delete(req.Ticket)
 }
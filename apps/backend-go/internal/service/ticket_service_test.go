@@ -0,0 +1,17 @@
+package service
+
+import "testing"
+
+func TestTicketParameters(t *testing.T) {
+	params, err := ticketParameters(`{"region":"east","ids":[1,2],"empty":null}`)
+	if err != nil {
+		t.Fatalf("ticketParameters: %v", err)
+	}
+	if len(params) != 2 || params[0].VariableName != "ids" || len(params[0].Value) != 2 ||
+		params[1].VariableName != "region" || params[1].Value[0] != "east" {
+		t.Fatalf("unexpected parameters: %+v", params)
+	}
+	if _, err = ticketParameters("not json"); err == nil {
+		t.Fatal("expected invalid args error")
+	}
+}
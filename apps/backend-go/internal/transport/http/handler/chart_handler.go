@@ -38,7 +38,7 @@ func (h *ChartHandler) Data(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.QueryData(&req)
+	result, err := h.service.QueryData(queryContext(c), &req)
 	if err != nil {
 		response.Error(c, "500000", "Failed: "+err.Error())
 		return
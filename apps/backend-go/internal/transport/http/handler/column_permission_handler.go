@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"strconv"
+
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ColumnPermissionHandler struct {
+	service *service.ColumnPermissionService
+}
+
+func NewColumnPermissionHandler(service *service.ColumnPermissionService) *ColumnPermissionHandler {
+	return &ColumnPermissionHandler{service: service}
+}
+
+// List returns the column permissions of a dataset.
+func (h *ColumnPermissionHandler) List(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("datasetId"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid dataset ID")
+		return
+	}
+	result, err := h.service.List(id)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// Save creates or updates a column permission.
+func (h *ColumnPermissionHandler) Save(c *gin.Context) {
+	var req permission.CoreColumnPermission
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, "500000", "Invalid request: "+err.Error())
+		return
+	}
+	result, err := h.service.Save(&req)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+func (h *ColumnPermissionHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid column permission ID")
+		return
+	}
+	if err = h.service.Delete(id); err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, true)
+}
+
+func RegisterColumnPermissionRoutes(r gin.IRouter, h *ColumnPermissionHandler) {
+	group := r.Group("/dataset/columnPermissions", adminOnly())
+	{
+		group.POST("/list/:datasetId", h.List)
+		group.POST("/save", h.Save)
+		group.POST("/delete/:id", h.Delete)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -15,34 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *OrgHandler, datasourceHandler *DatasourceHandler, datasetHandler *DatasetHandler, chartHandler *ChartHandler) {
+func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *OrgHandler, datasourceHandler *DatasourceHandler, datasetHandler *DatasetHandler, chartHandler *ChartHandler, exportHandler *ExportHandler) {
 	_ = user
 	_ = org
-	getCurrentUserID := func(c *gin.Context) int64 {
-		if uid, exists := c.Get("user_id"); exists {
-			switch v := uid.(type) {
-			case int64:
-				return v
-			case uint64:
-				return int64(v)
-			case int:
-				return int64(v)
-			case float64:
-				return int64(v)
-			}
-		}
-		return 1
-	}
-
-	getCurrentUsername := func(c *gin.Context) string {
-		if username, exists := c.Get("username"); exists {
-			if s, ok := username.(string); ok {
-				return s
-			}
-		}
-		return "admin"
-	}
-
 	if datasourceHandler != nil {
 		datasourceGroup := r.Group("/datasource")
 		{
@@ -76,20 +52,14 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, result)
 			})
 			datasourceGroup.POST("/types", func(c *gin.Context) {
-				response.Success(c, []map[string]string{
-					{"type": "MySQL", "name": "MySQL"},
-					{"type": "PostgreSQL", "name": "PostgreSQL"},
-					{"type": "SQLServer", "name": "SQL Server"},
-					{"type": "Oracle", "name": "Oracle"},
-					{"type": "Excel", "name": "Excel"},
-				})
+				response.Success(c, datasourceHandler.service.Types())
 			})
 			datasourceGroup.POST("/getTables", func(c *gin.Context) {
 				req, ok := parseTableRequest(c)
 				if !ok {
 					return
 				}
-				result, err := datasourceHandler.service.GetTables(req)
+				result, err := datasourceHandler.service.GetTables(queryContext(c), req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -101,7 +71,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasourceHandler.service.GetTableStatus(req)
+				result, err := datasourceHandler.service.GetTableStatus(queryContext(c), req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -121,7 +91,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasourceHandler.service.GetTableField(req)
+				result, err := datasourceHandler.service.GetTableField(queryContext(c), req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -133,7 +103,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasourceHandler.service.PreviewData(req)
+				result, err := datasourceHandler.service.PreviewData(queryContext(c), req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -180,7 +150,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, gin.H{"id": result.ID, "name": result.Name, "type": result.Type})
 			})
 			datasourceGroup.GET("/showFinishPage", func(c *gin.Context) {
-				userID := getCurrentUserID(c)
+				userID := currentUserID(c)
 				result, err := datasourceHandler.service.ShowFinishPage(userID)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
@@ -189,7 +159,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, result)
 			})
 			datasourceGroup.POST("/setShowFinishPage", func(c *gin.Context) {
-				userID := getCurrentUserID(c)
+				userID := currentUserID(c)
 				if err := datasourceHandler.service.SetShowFinishPage(userID); err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -197,7 +167,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, nil)
 			})
 			datasourceGroup.POST("/latestUse", func(c *gin.Context) {
-				username := getCurrentUsername(c)
+				username := currentUsername(c)
 				result, err := datasourceHandler.service.LatestTypes(username)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
@@ -210,9 +180,10 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasourceHandler.service.Save(req)
+				req.CreateBy = currentUsername(c)
+				result, err := datasourceHandler.service.Save(queryContext(c), req)
 				if err != nil {
-					response.Error(c, "500000", "Failed: "+err.Error())
+					datasourceWriteError(c, err)
 					return
 				}
 				response.Success(c, result)
@@ -222,9 +193,9 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasourceHandler.service.Update(req)
+				result, err := datasourceHandler.service.Update(queryContext(c), req)
 				if err != nil {
-					response.Error(c, "500000", "Failed: "+err.Error())
+					datasourceWriteError(c, err)
 					return
 				}
 				response.Success(c, result)
@@ -301,22 +272,73 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, result)
 			})
 			datasourceGroup.POST("/checkApiDatasource", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: checkApiDatasource")
+				var req datasource.APIDefinition
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				result, err := datasourceHandler.service.CheckAPIDatasource(&req)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
 			})
 			datasourceGroup.POST("/loadRemoteFile", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: loadRemoteFile")
+				var req datasource.RemoteFileRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				result, err := datasourceHandler.service.LoadRemoteFile(&req)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
 			})
 			datasourceGroup.POST("/syncApiTable", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: syncApiTable")
+				var req datasource.APISyncRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				if err := datasourceHandler.service.SyncAPITable(queryContext(c), &req); err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, true)
 			})
 			datasourceGroup.POST("/syncApiDs", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: syncApiDs")
+				var req datasource.APISyncRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				if err := datasourceHandler.service.SyncAPIDatasource(queryContext(c), req.DatasourceID); err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, true)
 			})
 			datasourceGroup.POST("/uploadFile", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: uploadFile")
-			})
-			datasourceGroup.POST("/listSyncRecord/:dsId/:page/:limit", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: listSyncRecord")
+				fileHeader, err := c.FormFile("file")
+				if err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				file, err := fileHeader.Open()
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				defer file.Close()
+				result, err := datasourceHandler.service.UploadFile(fileHeader.Filename, file)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
 			})
 			datasourceGroup.GET("/delete/:id", func(c *gin.Context) {
 				id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -356,7 +378,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Error(c, "500000", "Invalid dataset ID")
 					return
 				}
-				result, err := buildDatasetDetail(datasetHandler, id)
+				result, err := buildDatasetDetail(queryContext(c), datasetHandler, id)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -369,7 +391,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Error(c, "500000", "Invalid dataset ID")
 					return
 				}
-				result, err := buildDatasetDetail(datasetHandler, id)
+				result, err := buildDatasetDetail(queryContext(c), datasetHandler, id)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -383,7 +405,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				}
 				result := make([]gin.H, 0, len(ids))
 				for _, id := range ids {
-					detail, err := buildDatasetDetail(datasetHandler, id)
+					detail, err := buildDatasetDetail(queryContext(c), datasetHandler, id)
 					if err != nil {
 						continue
 					}
@@ -398,7 +420,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				}
 				result := make([]gin.H, 0, len(ids))
 				for _, id := range ids {
-					detail, err := buildDatasetDetail(datasetHandler, id)
+					detail, err := buildDatasetDetail(queryContext(c), datasetHandler, id)
 					if err != nil {
 						continue
 					}
@@ -423,6 +445,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
+				req.UserID = currentUserID(c)
 				result, err := datasetHandler.service.Save(req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
@@ -435,6 +458,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
+				req.UserID = currentUserID(c)
 				result, err := datasetHandler.service.Create(req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
@@ -504,11 +528,19 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, result)
 			})
 			datasetTreeGroup.GET("/barInfo/:id", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: barInfo")
-			})
-			datasetTreeGroup.POST("/exportDataset", func(c *gin.Context) {
-				response.Error(c, "501000", "Not implemented: exportDataset")
+				id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+				if err != nil {
+					response.Error(c, "500000", "Invalid dataset ID")
+					return
+				}
+				result, err := datasetHandler.service.BarInfo(id)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
 			})
+			datasetTreeGroup.POST("/exportDataset", exportHandler.ExportDataset)
 		}
 
 		datasetDataGroup := r.Group("/datasetData")
@@ -526,7 +558,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Success(c, int64(0))
 					return
 				}
-				preview, err := datasetHandler.service.Preview(&dataset.PreviewRequest{DatasetGroupID: id, Limit: 1})
+				preview, err := datasetHandler.service.Preview(queryContext(c), &dataset.PreviewRequest{DatasetGroupID: id, Limit: 1})
 				if err != nil {
 					response.Success(c, int64(0))
 					return
@@ -539,7 +571,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Error(c, "500000", "Invalid request: "+err.Error())
 					return
 				}
-				result, err := datasetHandler.service.PreviewSQL(&req)
+				result, err := datasetHandler.service.PreviewSQL(queryContext(c), &req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -551,7 +583,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasetHandler.service.GetFieldEnumObj(req)
+				result, err := datasetHandler.service.GetFieldEnumObj(queryContext(c), req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -563,7 +595,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasetHandler.service.GetFieldEnumDs(fieldID)
+				result, err := datasetHandler.service.GetFieldEnumDs(queryContext(c), fieldID)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -575,7 +607,70 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				if !ok {
 					return
 				}
-				result, err := datasetHandler.service.GetFieldEnum(req)
+				result, err := datasetHandler.service.GetFieldEnum(queryContext(c), req)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+		}
+
+		datasetFieldGroup := r.Group("/datasetField")
+		{
+			datasetFieldGroup.POST("/save", func(c *gin.Context) {
+				var field dataset.CoreDatasetTableField
+				if err := c.ShouldBindJSON(&field); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				result, err := datasetHandler.service.SaveField(&field)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+			datasetFieldGroup.POST("/getFunction", func(c *gin.Context) {
+				response.Success(c, datasetHandler.service.CalcFunctions())
+			})
+		}
+
+		datasetVersionGroup := r.Group("/datasetVersion")
+		{
+			datasetVersionGroup.POST("/list/:id", func(c *gin.Context) {
+				id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+				if err != nil {
+					response.Error(c, "500000", "Invalid dataset ID")
+					return
+				}
+				result, err := datasetHandler.service.ListVersions(id)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+			datasetVersionGroup.POST("/diff", func(c *gin.Context) {
+				var req dataset.VersionDiffRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				result, err := datasetHandler.service.DiffVersions(&req)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+			datasetVersionGroup.POST("/rollback", func(c *gin.Context) {
+				var req dataset.VersionRollbackRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				result, err := datasetHandler.service.Rollback(&req)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -583,6 +678,60 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 				response.Success(c, result)
 			})
 		}
+
+		datasetProfileGroup := r.Group("/datasetProfile")
+		{
+			datasetProfileGroup.POST("/get/:id", func(c *gin.Context) {
+				id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+				if err != nil {
+					response.Error(c, "500000", "Invalid dataset ID")
+					return
+				}
+				result, err := datasetHandler.service.Profile(queryContext(c), id)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+			datasetProfileGroup.POST("/refresh/:id", func(c *gin.Context) {
+				id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+				if err != nil {
+					response.Error(c, "500000", "Invalid dataset ID")
+					return
+				}
+				result, err := datasetHandler.service.RefreshProfile(queryContext(c), id)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+		}
+
+		sqlLogGroup := r.Group("/datasetTableSqlLog")
+		{
+			sqlLogGroup.POST("/listByTableId", func(c *gin.Context) {
+				var req dataset.SQLLogRequest
+				if err := c.ShouldBindJSON(&req); err != nil {
+					response.Error(c, "500000", "Invalid request: "+err.Error())
+					return
+				}
+				result, err := datasetHandler.service.SQLLogs(&req)
+				if err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, result)
+			})
+			sqlLogGroup.POST("/deleteByTableId/:id", func(c *gin.Context) {
+				if err := datasetHandler.service.DeleteSQLLogs(c.Param("id")); err != nil {
+					response.Error(c, "500000", "Failed: "+err.Error())
+					return
+				}
+				response.Success(c, nil)
+			})
+		}
 	}
 
 	if chartHandler != nil {
@@ -599,7 +748,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Success(c, []string{})
 					return
 				}
-				result, err := datasetHandler.service.GetFieldEnum(&dataset.MultFieldValuesRequest{FieldIDs: []int64{fieldID}, ResultMode: 1})
+				result, err := datasetHandler.service.GetFieldEnum(queryContext(c), &dataset.MultFieldValuesRequest{FieldIDs: []int64{fieldID}, ResultMode: 1})
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -616,7 +765,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Success(c, []string{})
 					return
 				}
-				result, err := datasetHandler.service.GetFieldEnumDs(fieldID)
+				result, err := datasetHandler.service.GetFieldEnumDs(queryContext(c), fieldID)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -717,7 +866,7 @@ func RegisterCompatibilityBridgeRoutes(r gin.IRouter, user *UserHandler, org *Or
 					response.Error(c, "500000", "Invalid chart ID")
 					return
 				}
-				result, err := chartHandler.service.ListByDQ(datasetGroupID, chartID)
+				result, err := chartHandler.service.ListByDQ(queryContext(c), datasetGroupID, chartID)
 				if err != nil {
 					response.Error(c, "500000", "Failed: "+err.Error())
 					return
@@ -827,6 +976,17 @@ func parseTableRequest(c *gin.Context) (*datasource.TableRequest, bool) {
 	return request, true
 }
 
+// datasourceWriteError reports a failed save or update, listing the invalid
+// configuration fields in the data of the response.
+func datasourceWriteError(c *gin.Context, err error) {
+	var cfgErr *datasource.ConfigError
+	if errors.As(err, &cfgErr) {
+		response.ErrorWithData(c, "500000", "Failed: "+err.Error(), cfgErr.Fields)
+		return
+	}
+	response.Error(c, "500000", "Failed: "+err.Error())
+}
+
 func parseDatasourceWriteRequest(c *gin.Context, requireName bool) (*datasource.WriteRequest, bool) {
 	var body map[string]interface{}
 	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
@@ -893,6 +1053,20 @@ func parseDatasourceWriteRequest(c *gin.Context, requireName bool) (*datasource.
 		req.EnableDataFill = &enable
 	}
 
+	if fileID, ok := body["fileId"].(string); ok {
+		req.FileID = fileID
+	}
+	if sheets, ok := body["sheets"].([]interface{}); ok {
+		b, err := json.Marshal(sheets)
+		if err == nil {
+			err = json.Unmarshal(b, &req.Sheets)
+		}
+		if err != nil {
+			response.Error(c, "500000", "Invalid sheets")
+			return nil, false
+		}
+	}
+
 	if requireName && strings.TrimSpace(req.Name) == "" {
 		response.Error(c, "500000", "datasource name is required")
 		return nil, false
@@ -935,6 +1109,15 @@ func parseDatasetWriteRequest(c *gin.Context, requireName bool) (*dataset.WriteR
 	if isCross, ok := body["isCross"].(bool); ok {
 		req.IsCross = &isCross
 	}
+	if rawModel, ok := body["model"].(map[string]interface{}); ok {
+		data, _ := json.Marshal(rawModel)
+		var model dataset.Model
+		if err := json.Unmarshal(data, &model); err != nil {
+			response.Error(c, "500000", "Invalid request: "+err.Error())
+			return nil, false
+		}
+		req.Model = &model
+	}
 
 	if requireName && strings.TrimSpace(req.Name) == "" {
 		response.Error(c, "500000", "dataset name is required")
@@ -944,7 +1127,7 @@ func parseDatasetWriteRequest(c *gin.Context, requireName bool) (*dataset.WriteR
 	return req, true
 }
 
-func buildDatasetDetail(h *DatasetHandler, datasetGroupID int64) (gin.H, error) {
+func buildDatasetDetail(ctx context.Context, h *DatasetHandler, datasetGroupID int64) (gin.H, error) {
 	fields, err := h.service.Fields(&dataset.FieldsRequest{DatasetGroupID: datasetGroupID})
 	if err != nil {
 		return nil, err
@@ -952,7 +1135,7 @@ func buildDatasetDetail(h *DatasetHandler, datasetGroupID int64) (gin.H, error)
 
 	previewData := make([]map[string]interface{}, 0)
 	total := int64(0)
-	preview, err := h.service.Preview(&dataset.PreviewRequest{DatasetGroupID: datasetGroupID, Limit: 100})
+	preview, err := h.service.Preview(ctx, &dataset.PreviewRequest{DatasetGroupID: datasetGroupID, Limit: 100})
 	if err == nil {
 		previewData = preview.Rows
 		total = preview.Total
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http/httptest"
 	"strconv"
@@ -9,6 +10,8 @@ import (
 
 	"dataease/backend/internal/domain/chart"
 	"dataease/backend/internal/domain/dataset"
+	"dataease/backend/internal/domain/permission"
+	"dataease/backend/internal/pkg/datasetsql"
 	"dataease/backend/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -65,7 +68,7 @@ func (r *fakeBridgeChartRepo) Update(view *chart.CoreChartView) error {
 	return nil
 }
 
-func (r *fakeBridgeChartRepo) QueryRows(chartID int64, limit int) ([]map[string]interface{}, int64, error) {
+func (r *fakeBridgeChartRepo) QueryRows(_ context.Context, chartID int64, limit int, _ *datasetsql.Values, _ permission.MaskSource) ([]map[string]interface{}, int64, error) {
 	return []map[string]interface{}{}, 0, nil
 }
 
@@ -203,7 +206,7 @@ func (r *fakeBridgeChartRepo) DeleteDatasetFieldsByChart(chartID int64) error {
 func TestChartDataGetFieldDataInvalidID(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, &ChartHandler{})
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, &ChartHandler{}, nil)
 
 	req := httptest.NewRequest("POST", "/chartData/getFieldData/not-number/xAxis", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -226,7 +229,7 @@ func TestChartDataGetFieldDataInvalidID(t *testing.T) {
 func TestChartDataGetFieldDataFallbackEmptyWhenDatasetHandlerNil(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, &ChartHandler{})
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, &ChartHandler{}, nil)
 
 	req := httptest.NewRequest("POST", "/chartData/getFieldData/100/xAxis", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -252,7 +255,7 @@ func TestChartDataGetFieldDataFallbackEmptyWhenDatasetHandlerNil(t *testing.T) {
 func TestChartDataGetDrillFieldDataFallbackEmptyWhenDatasetHandlerNil(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, &ChartHandler{})
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, &ChartHandler{}, nil)
 
 	req := httptest.NewRequest("POST", "/chartData/getDrillFieldData/100", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -283,7 +286,7 @@ func TestChartSaveRouteUpdatesCoreFields(t *testing.T) {
 	chartHandler := NewChartHandler(service.NewChartService(repo))
 
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler, nil)
 
 	reqBody := `{"id":101,"title":"new-title","resultMode":"all"}`
 	req := httptest.NewRequest("POST", "/chart/save", strings.NewReader(reqBody))
@@ -334,7 +337,7 @@ func TestChartListByDQRouteReturnsDimensionAndQuota(t *testing.T) {
 	chartHandler := NewChartHandler(service.NewChartService(repo))
 
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler, nil)
 
 	req := httptest.NewRequest("POST", "/chart/listByDQ/11/9", strings.NewReader(`{"type":"bar"}`))
 	req.Header.Set("Content-Type", "application/json")
@@ -388,7 +391,7 @@ func TestApiAliasChartSaveAndListByDQ(t *testing.T) {
 
 	r := gin.New()
 	api := r.Group("/api")
-	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, chartHandler)
+	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, chartHandler, nil)
 
 	saveReq := httptest.NewRequest("POST", "/api/chart/save", strings.NewReader(`{"id":201,"title":"alias-title"}`))
 	saveReq.Header.Set("Content-Type", "application/json")
@@ -457,7 +460,7 @@ func TestChartCopyAndDeleteFieldRoutes(t *testing.T) {
 	chartHandler := NewChartHandler(service.NewChartService(repo))
 
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler, nil)
 
 	copyReq := httptest.NewRequest("POST", "/chart/copyField/10/300", strings.NewReader("{}"))
 	copyReq.Header.Set("Content-Type", "application/json")
@@ -528,7 +531,7 @@ func TestApiAliasChartCopyAndDeleteFieldByChart(t *testing.T) {
 
 	r := gin.New()
 	api := r.Group("/api")
-	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, chartHandler)
+	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, chartHandler, nil)
 
 	copyReq := httptest.NewRequest("POST", "/api/chart/copyField/11/400", strings.NewReader("{}"))
 	copyReq.Header.Set("Content-Type", "application/json")
@@ -570,7 +573,7 @@ func TestApiAliasChartCopyAndDeleteFieldByChart(t *testing.T) {
 func TestOldPathDatasourceList(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/datasource/list", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -585,7 +588,7 @@ func TestOldPathDatasourceList(t *testing.T) {
 func TestOldPathDatasetTree(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/datasetTree/tree", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -601,7 +604,7 @@ func TestApiAliasDatasourceList(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	api := r.Group("/api")
-	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/api/datasource/list", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -617,7 +620,7 @@ func TestApiAliasDatasetTree(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	api := r.Group("/api")
-	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/api/datasetTree/tree", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -640,7 +643,7 @@ type bridgePaginationResp struct {
 func TestPaginationResponseFormat(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/chart/listByDQ/1/1", strings.NewReader(`{"type":"bar"}`))
 	req.Header.Set("Content-Type", "application/json")
@@ -665,7 +668,7 @@ func TestErrorResponseFormat(t *testing.T) {
 	chartHandler := NewChartHandler(service.NewChartService(repo))
 
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, chartHandler, nil)
 
 	req := httptest.NewRequest("POST", "/chartData/getFieldData/invalid/xAxis", strings.NewReader("{}"))
 	req.Header.Set("Content-Type", "application/json")
@@ -689,7 +692,7 @@ func TestErrorResponseFormat(t *testing.T) {
 func TestOldPathChartSaveWithNilHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(r, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/chart/save", strings.NewReader(`{"id":1}`))
 	req.Header.Set("Content-Type", "application/json")
@@ -705,7 +708,7 @@ func TestApiAliasChartDataGetData(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	api := r.Group("/api")
-	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, nil)
+	RegisterCompatibilityBridgeRoutes(api, nil, nil, nil, nil, nil, nil)
 
 	req := httptest.NewRequest("POST", "/api/chartData/getData", strings.NewReader(`{}`))
 	req.Header.Set("Content-Type", "application/json")
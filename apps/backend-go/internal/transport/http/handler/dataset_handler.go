@@ -55,7 +55,7 @@ func (h *DatasetHandler) Preview(c *gin.Context) {
 		return
 	}
 
-	result, err := h.service.Preview(&req)
+	result, err := h.service.Preview(queryContext(c), &req)
 	if err != nil {
 		response.Error(c, "500000", "Failed: "+err.Error())
 		return
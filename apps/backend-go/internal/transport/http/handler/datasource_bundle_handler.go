@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DatasourceBundleHandler struct {
+	service *service.DatasourceBundleService
+}
+
+func NewDatasourceBundleHandler(service *service.DatasourceBundleService) *DatasourceBundleHandler {
+	return &DatasourceBundleHandler{service: service}
+}
+
+// Export returns the bundle of a datasource folder subtree.
+func (h *DatasourceBundleHandler) Export(c *gin.Context) {
+	var req datasource.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, "500000", "Invalid request: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Export(&req)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Import recreates the content of a bundle and reports every entry.
+func (h *DatasourceBundleHandler) Import(c *gin.Context) {
+	var req datasource.ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, "500000", "Invalid request: "+err.Error())
+		return
+	}
+	req.CreateBy = currentUsername(c)
+
+	result, err := h.service.Import(queryContext(c), &req)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func RegisterDatasourceBundleRoutes(r *gin.RouterGroup, h *DatasourceBundleHandler) {
+	bundleGroup := r.Group("/ds/bundle", adminOnly())
+	{
+		bundleGroup.POST("/export", h.Export)
+		bundleGroup.POST("/import", h.Import)
+	}
+}
@@ -48,10 +48,29 @@ func (h *DatasourceHandler) Validate(c *gin.Context) {
 	response.Success(c, result)
 }
 
+// RunningQueries lists the queries currently running on datasources.
+func (h *DatasourceHandler) RunningQueries(c *gin.Context) {
+	response.Success(c, h.service.RunningQueries())
+}
+
+// KillQuery cancels a running query.
+func (h *DatasourceHandler) KillQuery(c *gin.Context) {
+	if err := h.service.KillQuery(c.Param("id")); err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, true)
+}
+
 func RegisterDatasourceRoutes(r *gin.RouterGroup, h *DatasourceHandler) {
 	dsGroup := r.Group("/ds")
 	{
 		dsGroup.POST("/list", h.List)
 		dsGroup.POST("/validate", h.Validate)
 	}
+	queryGroup := r.Group("/ds/queries", adminOnly())
+	{
+		queryGroup.GET("", h.RunningQueries)
+		queryGroup.POST("/kill/:id", h.KillQuery)
+	}
 }
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"strconv"
+
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DatasourceMonitorHandler struct {
+	service *service.DatasourceMonitorService
+}
+
+func NewDatasourceMonitorHandler(service *service.DatasourceMonitorService) *DatasourceMonitorHandler {
+	return &DatasourceMonitorHandler{service: service}
+}
+
+// History returns the recent health checks of a datasource. The optional
+// limit query parameter bounds the number of checks.
+func (h *DatasourceMonitorHandler) History(c *gin.Context) {
+	dsID, err := strconv.ParseInt(c.Param("dsId"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid datasource id")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	result, err := h.service.History(dsID, limit)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// Check revalidates a datasource now and returns the recorded check.
+func (h *DatasourceMonitorHandler) Check(c *gin.Context) {
+	dsID, err := strconv.ParseInt(c.Param("dsId"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid datasource id")
+		return
+	}
+	result, err := h.service.Check(dsID)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+func RegisterDatasourceMonitorRoutes(r gin.IRouter, h *DatasourceMonitorHandler) {
+	dsGroup := r.Group("/datasource")
+	{
+		dsGroup.GET("/health/:dsId", h.History)
+		dsGroup.POST("/health/check/:dsId", h.Check)
+	}
+}
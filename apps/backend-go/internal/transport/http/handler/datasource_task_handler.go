@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"strconv"
+
+	"dataease/backend/internal/domain/datasource"
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DatasourceTaskHandler struct {
+	service *service.DatasourceTaskService
+}
+
+func NewDatasourceTaskHandler(service *service.DatasourceTaskService) *DatasourceTaskHandler {
+	return &DatasourceTaskHandler{service: service}
+}
+
+func (h *DatasourceTaskHandler) Save(c *gin.Context) {
+	var req datasource.TaskWriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, "500000", "Invalid request: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Save(&req)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func (h *DatasourceTaskHandler) List(c *gin.Context) {
+	dsID, err := strconv.ParseInt(c.Param("dsId"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid datasource id")
+		return
+	}
+
+	result, err := h.service.List(dsID)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func (h *DatasourceTaskHandler) Pause(c *gin.Context) {
+	h.withTaskID(c, h.service.Pause)
+}
+
+func (h *DatasourceTaskHandler) Resume(c *gin.Context) {
+	h.withTaskID(c, h.service.Resume)
+}
+
+func (h *DatasourceTaskHandler) Execute(c *gin.Context) {
+	h.withTaskID(c, h.service.RunNow)
+}
+
+func (h *DatasourceTaskHandler) ListSyncRecord(c *gin.Context) {
+	dsID, err := strconv.ParseInt(c.Param("dsId"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid datasource id")
+		return
+	}
+	page, _ := strconv.Atoi(c.Param("page"))
+	limit, _ := strconv.Atoi(c.Param("limit"))
+
+	result, err := h.service.ListLogs(dsID, page, limit)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func (h *DatasourceTaskHandler) withTaskID(c *gin.Context, action func(int64) error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid task id")
+		return
+	}
+	if err = action(id); err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, true)
+}
+
+func RegisterDatasourceTaskRoutes(r gin.IRouter, h *DatasourceTaskHandler) {
+	dsGroup := r.Group("/datasource")
+	{
+		dsGroup.POST("/listSyncRecord/:dsId/:page/:limit", h.ListSyncRecord)
+		dsGroup.POST("/task/save", h.Save)
+		dsGroup.GET("/task/list/:dsId", h.List)
+		dsGroup.POST("/task/pause/:id", h.Pause)
+		dsGroup.POST("/task/resume/:id", h.Resume)
+		dsGroup.POST("/task/execute/:id", h.Execute)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"dataease/backend/internal/domain/dataset"
 	"dataease/backend/internal/domain/export"
 	"dataease/backend/internal/pkg/response"
 	"dataease/backend/internal/service"
@@ -104,7 +105,12 @@ func (h *ExportHandler) Download(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, &export.DownloadResponse{URL: "/downloads/" + task.ID})
+	path, err := h.service.FilePath(task)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	c.FileAttachment(path, task.FileName)
 }
 
 func (h *ExportHandler) GenerateDownloadURI(c *gin.Context) {
@@ -132,6 +138,21 @@ func (h *ExportHandler) Retry(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+func (h *ExportHandler) ExportDataset(c *gin.Context) {
+	var req dataset.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, "400000", "Invalid request: "+err.Error())
+		return
+	}
+
+	task, err := h.service.ExportDataset(queryContext(c), int64(middleware.GetUserID(c)), &req)
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, task)
+}
+
 func (h *ExportHandler) ExportLimit(c *gin.Context) {
 	result := h.service.ExportLimit()
 	response.Success(c, result.Limit)
@@ -1 +1,71 @@
 package handler
+
+import (
+	"context"
+
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// builtinAdminID is the id of the admin account created on install.
+const builtinAdminID int64 = 1
+
+// currentUserID returns the user set by the JWT middleware, or the built-in
+// admin when the request went through no authentication.
+func currentUserID(c *gin.Context) int64 {
+	if uid, exists := c.Get("user_id"); exists {
+		switch v := uid.(type) {
+		case int64:
+			return v
+		case uint64:
+			return int64(v)
+		case int:
+			return int64(v)
+		case float64:
+			return int64(v)
+		}
+	}
+	return builtinAdminID
+}
+
+func currentUsername(c *gin.Context) string {
+	if username, exists := c.Get("username"); exists {
+		if s, ok := username.(string); ok {
+			return s
+		}
+	}
+	return "admin"
+}
+
+// currentIsAdmin reports whether the current user is an admin: the role of
+// the token when there is one, else whether it is the built-in admin.
+func currentIsAdmin(c *gin.Context) bool {
+	if role, exists := c.Get("role"); exists {
+		s, _ := role.(string)
+		return s == "admin"
+	}
+	return currentUserID(c) == builtinAdminID
+}
+
+// adminOnly rejects the requests of users who are not admins.
+func adminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !currentIsAdmin(c) {
+			response.Forbidden(c, "insufficient permissions")
+			return
+		}
+		c.Next()
+	}
+}
+
+// queryContext is the context of the datasource queries run for a request.
+// It ends when the client disconnects and carries the user for the per-user
+// query timeout, the running queries list and the column permissions.
+func queryContext(c *gin.Context) context.Context {
+	userID := currentUserID(c)
+	ctx := dsconn.WithUser(c.Request.Context(), userID, currentUsername(c))
+	return service.WithViewer(ctx, userID, currentIsAdmin(c))
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dataease/backend/internal/pkg/dsconn"
+	"dataease/backend/internal/service"
+	"dataease/backend/internal/transport/http/middleware"
+	"dataease/backend/internal/transport/ws"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// serveAs calls a route with the token of a user of the given role, or
+// without any authentication when role is empty.
+func serveAs(t *testing.T, r *gin.Engine, method, path, body, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if role != "" {
+		token, err := testJWT().GenerateToken(7, "someone", role)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// authenticated runs the JWT middleware only for the requests that carry a
+// token, the others going through as the built-in admin.
+func authenticated() gin.HandlerFunc {
+	auth := middleware.Auth(testJWT())
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			auth(c)
+		}
+	}
+}
+
+func TestRunningQueriesRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authenticated())
+	h := NewDatasourceHandler(service.NewDatasourceService(nil, dsconn.NewManager(dsconn.DefaultOptions())))
+	RegisterDatasourceRoutes(r.Group("/api"), h)
+
+	for role, status := range map[string]int{"": 200, "admin": 200, "user": 403} {
+		w := serveAs(t, r, "GET", "/api/ds/queries", "", role)
+		if w.Code != status {
+			t.Errorf("role %q: status = %d, want %d (%s)", role, w.Code, status, w.Body.String())
+		}
+		if status == 200 && !strings.Contains(w.Body.String(), `"code":"000000"`) {
+			t.Errorf("role %q: body = %s", role, w.Body.String())
+		}
+	}
+}
+
+func TestColumnPermissionRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authenticated())
+	RegisterColumnPermissionRoutes(r.Group("/api"), NewColumnPermissionHandler(nil))
+
+	for role, status := range map[string]int{"": 200, "admin": 200, "user": 403} {
+		w := serveAs(t, r, "POST", "/api/dataset/columnPermissions/delete/x", "", role)
+		if w.Code != status {
+			t.Errorf("role %q: status = %d, want %d (%s)", role, w.Code, status, w.Body.String())
+		}
+		if status == 200 && !strings.Contains(w.Body.String(), "Invalid column permission ID") {
+			t.Errorf("role %q: body = %s", role, w.Body.String())
+		}
+	}
+}
+
+func TestDatasourceBundleRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authenticated())
+	RegisterDatasourceBundleRoutes(r.Group("/api"), NewDatasourceBundleHandler(nil))
+
+	for role, status := range map[string]int{"": 200, "admin": 200, "user": 403} {
+		w := serveAs(t, r, "POST", "/api/ds/bundle/import", "{", role)
+		if w.Code != status {
+			t.Errorf("role %q: status = %d, want %d (%s)", role, w.Code, status, w.Body.String())
+		}
+		if status == 200 && !strings.Contains(w.Body.String(), "Invalid request") {
+			t.Errorf("role %q: body = %s", role, w.Body.String())
+		}
+	}
+}
+
+func TestWebSocketRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(authenticated())
+	hub := ws.NewHub()
+	go hub.Run()
+	RegisterWebSocketRoutes(r, hub)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for role, userID := range map[string]uint64{"": uint64(builtinAdminID), "user": 7} {
+		header := http.Header{}
+		if role != "" {
+			token, err := testJWT().GenerateToken(7, "someone", role)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			header.Set("Authorization", "Bearer "+token)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/websocket", header)
+		if err != nil {
+			t.Fatalf("role %q: unexpected error: %v", role, err)
+		}
+		// The hub registers the client asynchronously.
+		deadline := time.Now().Add(time.Second)
+		for hub.ClientCount() == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		hub.SendToUser(userID, []byte("hello"))
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil || string(msg) != "hello" {
+			t.Errorf("role %q: message = %q, %v", role, msg, err)
+		}
+		conn.Close()
+		for hub.ClientCount() != 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
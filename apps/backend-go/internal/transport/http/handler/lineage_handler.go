@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"strconv"
+
+	"dataease/backend/internal/domain/lineage"
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LineageHandler struct {
+	service *service.LineageService
+}
+
+func NewLineageHandler(service *service.LineageService) *LineageHandler {
+	return &LineageHandler{service: service}
+}
+
+// Graph returns the lineage of one resource. The direction query parameter
+// is upstream, downstream or both (the default).
+func (h *LineageHandler) Graph(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, "500000", "Invalid resource id")
+		return
+	}
+	result, err := h.service.Graph(&lineage.Request{
+		Type:      c.Param("type"),
+		ID:        id,
+		Direction: c.Query("direction"),
+	})
+	if err != nil {
+		response.Error(c, "500000", "Failed: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+func RegisterLineageRoutes(r gin.IRouter, h *LineageHandler) {
+	r.GET("/lineage/:type/:id", h.Graph)
+}
@@ -4,6 +4,7 @@ import (
 	"dataease/backend/internal/domain/msgcenter"
 	"dataease/backend/internal/pkg/response"
 	"dataease/backend/internal/service"
+	"dataease/backend/internal/transport/http/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +20,7 @@ func NewMsgCenterHandler(service *service.MsgCenterService) *MsgCenterHandler {
 func (h *MsgCenterHandler) Count(c *gin.Context) {
 	var req msgcenter.CountRequest
 	_ = c.ShouldBindJSON(&req)
-	response.Success(c, h.service.Count(&req))
+	response.Success(c, h.service.Count(&req, currentMsgUserID(c)))
 }
 
 func (h *MsgCenterHandler) List(c *gin.Context) {
@@ -28,7 +29,7 @@ func (h *MsgCenterHandler) List(c *gin.Context) {
 		response.Error(c, "500000", "Invalid request: "+err.Error())
 		return
 	}
-	response.Success(c, h.service.List(&req))
+	response.Success(c, h.service.List(&req, currentMsgUserID(c)))
 }
 
 func (h *MsgCenterHandler) Read(c *gin.Context) {
@@ -38,14 +39,7 @@ func (h *MsgCenterHandler) Read(c *gin.Context) {
 		return
 	}
 
-	userID := int64(0)
-	if uid, exists := c.Get("userId"); exists {
-		if id, ok := uid.(int64); ok {
-			userID = id
-		}
-	}
-
-	response.Success(c, h.service.Read(&req, userID))
+	response.Success(c, h.service.Read(&req, currentMsgUserID(c)))
 }
 
 func (h *MsgCenterHandler) ReadBatch(c *gin.Context) {
@@ -55,14 +49,21 @@ func (h *MsgCenterHandler) ReadBatch(c *gin.Context) {
 		return
 	}
 
-	userID := int64(0)
+	response.Success(c, h.service.ReadBatch(&req, currentMsgUserID(c)))
+}
+
+// currentMsgUserID reads the user id set either by the legacy "userId" key
+// or by the JWT middleware.
+func currentMsgUserID(c *gin.Context) int64 {
 	if uid, exists := c.Get("userId"); exists {
 		if id, ok := uid.(int64); ok {
-			userID = id
+			return id
 		}
 	}
-
-	response.Success(c, h.service.ReadBatch(&req, userID))
+	if uid := middleware.GetUserID(c); uid > 0 {
+		return int64(uid)
+	}
+	return 0
 }
 
 func RegisterMsgCenterRoutes(r gin.IRouter, h *MsgCenterHandler) {
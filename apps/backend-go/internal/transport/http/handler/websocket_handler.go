@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"dataease/backend/internal/pkg/response"
+	"dataease/backend/internal/transport/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebSocketRoutes exposes the hub used to push messages to the
+// signed-in user.
+func RegisterWebSocketRoutes(r gin.IRouter, hub *ws.Hub) {
+	r.GET("/websocket", func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID <= 0 {
+			response.Unauthorized(c, "authentication required")
+			return
+		}
+		if err := ws.Serve(hub, c.Writer, c.Request, uint64(userID)); err != nil {
+			response.Error(c, "500000", "Failed: "+err.Error())
+		}
+	})
+}
@@ -8,12 +8,17 @@ import (
 	"time"
 
 	"dataease/backend/internal/app"
+	"dataease/backend/internal/domain/datasource"
+	scheduler "dataease/backend/internal/job"
+	"dataease/backend/internal/pkg/dsconn"
 	"dataease/backend/internal/pkg/logger"
 	"dataease/backend/internal/pkg/metrics"
+	"dataease/backend/internal/pkg/secret"
 	"dataease/backend/internal/repository"
 	"dataease/backend/internal/service"
 	"dataease/backend/internal/transport/http/handler"
 	"dataease/backend/internal/transport/http/middleware"
+	"dataease/backend/internal/transport/ws"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -107,12 +112,20 @@ type Router struct {
 	mapHandler            *handler.MapHandler
 	authHandler           *handler.AuthHandler
 	datasourceHandler     *handler.DatasourceHandler
+	datasourceBundle      *handler.DatasourceBundleHandler
+	datasourceTaskHandler *handler.DatasourceTaskHandler
+	datasourceTasks       *service.DatasourceTaskService
+	datasourceMonitor     *handler.DatasourceMonitorHandler
+	datasourceHealth      *service.DatasourceMonitorService
 	datasetHandler        *handler.DatasetHandler
+	lineageHandler        *handler.LineageHandler
+	columnPermHandler     *handler.ColumnPermissionHandler
 	chartHandler          *handler.ChartHandler
 	visualHandler         *handler.VisualizationHandler
 	systemParamHandler    *handler.SystemParamHandler
 	licenseHandler        *handler.LicenseHandler
 	msgCenterHandler      *handler.MsgCenterHandler
+	hub                   *ws.Hub
 	shareHandler          *handler.ShareHandler
 	ticketHandler         *handler.TicketHandler
 	geoHandler            *handler.GeoHandler
@@ -181,16 +194,62 @@ func NewRouter(application *app.Application, db *gorm.DB) *Router {
 	authService := service.NewAuthService(userRepo)
 	authHandler := handler.NewAuthHandler(authService)
 
+	dsConns := dsconn.NewManager(dsconn.DefaultOptions())
+	if sqlDB, err := db.DB(); err == nil {
+		engineConn, connErr := dsconn.NewConn(sqlDB, "mysql", &datasource.ConnectionConfig{Database: application.Config.Database.Name})
+		if connErr == nil {
+			dsConns.SetEngine(engineConn)
+		}
+	}
+	secretKey := application.Config.Datasource.SecretKey
+	if secretKey == "" {
+		if key, keyErr := repository.NewRsaRepository(db).GetAESKey(); keyErr == nil {
+			secretKey = key
+		} else {
+			logger.Warn("Failed to load core_rsa aes key", zap.Error(keyErr))
+		}
+	}
+	if keyring, keyErr := secret.NewKeyring(secretKey); keyErr == nil {
+		dsConns.SetKeyring(keyring)
+	} else {
+		logger.Warn("Datasource credentials are stored unencrypted", zap.Error(keyErr))
+	}
+
+	dsConns.SetQueryLimits(queryLimits(application.Config.Datasource))
+
 	datasourceRepo := repository.NewDatasourceRepository(db)
-	datasourceService := service.NewDatasourceService(datasourceRepo)
+	datasourceService := service.NewDatasourceService(datasourceRepo, dsConns)
+	datasourceService.SetUploadOptions(application.Config.Datasource.UploadDir, application.Config.Datasource.MaxUploadSize)
+	datasourceService.SetRemoteFileHosts(application.Config.Datasource.RemoteFileHosts)
 	datasourceHandler := handler.NewDatasourceHandler(datasourceService)
 
+	datasourceTaskRepo := repository.NewDatasourceTaskRepository(db)
+	datasourceTaskService := service.NewDatasourceTaskService(datasourceTaskRepo, datasourceRepo, datasourceService, scheduler.NewScheduler())
+	datasourceTaskHandler := handler.NewDatasourceTaskHandler(datasourceTaskService)
+
 	datasetRepo := repository.NewDatasetRepository(db)
-	datasetService := service.NewDatasetService(datasetRepo)
+	datasetService := service.NewDatasetService(datasetRepo, datasourceRepo, dsConns)
+	datasetService.SetDeniedSQLFunctions(application.Config.Datasource.DeniedSQLFunctions)
 	datasetHandler := handler.NewDatasetHandler(datasetService)
 
-	chartRepo := repository.NewChartRepository(db)
+	lineageService := service.NewLineageService(repository.NewLineageRepository(db))
+	datasourceService.SetLineage(lineageService)
+	datasetService.SetLineage(lineageService)
+	datasetService.SetRowPermissions(service.NewRowPermissionService(datasetRepo, userRoleRepo))
+	datasetService.SetUsers(userRepo)
+	dsConns.SetQueryLogger(datasetService.LogQuery)
+	columnPermService := service.NewColumnPermissionService(datasetRepo, userRoleRepo)
+	columnPermService.SetExemptAdmins(application.Config.Dataset.MaskExemptAdmins)
+	datasetService.SetColumnPermissions(columnPermService)
+	columnPermHandler := handler.NewColumnPermissionHandler(columnPermService)
+	datasourceTaskService.SetDatasets(datasetService)
+	lineageHandler := handler.NewLineageHandler(lineageService)
+	datasourceBundleHandler := handler.NewDatasourceBundleHandler(service.NewDatasourceBundleService(datasourceService, datasetService))
+
+	chartRepo := repository.NewChartRepository(db, dsConns)
 	chartService := service.NewChartService(chartRepo)
+	chartService.SetColumnPermissions(columnPermService)
+	chartService.SetSQLCheck(datasetService.CheckSQL)
 	chartHandler := handler.NewChartHandler(chartService)
 
 	visualRepo := repository.NewVisualizationRepository(db)
@@ -205,16 +264,29 @@ func NewRouter(application *app.Application, db *gorm.DB) *Router {
 	licenseService := service.NewLicenseService(licenseRepo)
 	licenseHandler := handler.NewLicenseHandler(licenseService)
 
+	hub := ws.NewHub()
+	hub.SetAllowedOrigins(application.Config.Server.AllowedOrigins)
+	go hub.Run()
 	msgCenterRepo := repository.NewMsgCenterRepository(db)
 	msgCenterService := service.NewMsgCenterService(msgCenterRepo)
+	msgCenterService.SetHub(hub)
 	msgCenterHandler := handler.NewMsgCenterHandler(msgCenterService)
 
+	datasourceMonitorService := service.NewDatasourceMonitorService(datasourceRepo, datasourceService, userRepo, msgCenterService, scheduler.NewScheduler())
+	datasourceMonitorService.SetPeriods(
+		time.Duration(application.Config.Datasource.HealthInterval)*time.Second,
+		time.Duration(application.Config.Datasource.HealthRetention)*24*time.Hour,
+	)
+	datasourceMonitorHandler := handler.NewDatasourceMonitorHandler(datasourceMonitorService)
+
 	shareRepo := repository.NewShareRepository(db)
 	shareService := service.NewShareService(shareRepo)
 	shareHandler := handler.NewShareHandler(shareService)
 
 	ticketRepo := repository.NewTicketRepository(db)
 	ticketService := service.NewTicketService(ticketRepo)
+	ticketService.SetShares(shareRepo)
+	chartService.SetTickets(ticketService)
 	ticketHandler := handler.NewTicketHandler(ticketService)
 
 	// Geo module initialization
@@ -232,6 +304,8 @@ func NewRouter(application *app.Application, db *gorm.DB) *Router {
 	// Export module initialization
 	exportRepo := repository.NewExportRepository(db)
 	exportService := service.NewExportService(exportRepo)
+	exportService.SetDatasets(datasetService)
+	exportService.SetOptions(application.Config.Export.Dir, application.Config.Export.Limit)
 	exportHandler := handler.NewExportHandler(exportService)
 
 	// Engine module initialization
@@ -265,12 +339,20 @@ func NewRouter(application *app.Application, db *gorm.DB) *Router {
 		mapHandler:            mapHandler,
 		authHandler:           authHandler,
 		datasourceHandler:     datasourceHandler,
+		datasourceTaskHandler: datasourceTaskHandler,
+		datasourceTasks:       datasourceTaskService,
+		datasourceMonitor:     datasourceMonitorHandler,
+		datasourceHealth:      datasourceMonitorService,
 		datasetHandler:        datasetHandler,
+		lineageHandler:        lineageHandler,
+		columnPermHandler:     columnPermHandler,
+		datasourceBundle:      datasourceBundleHandler,
 		chartHandler:          chartHandler,
 		visualHandler:         visualHandler,
 		systemParamHandler:    systemParamHandler,
 		licenseHandler:        licenseHandler,
 		msgCenterHandler:      msgCenterHandler,
+		hub:                   hub,
 		shareHandler:          shareHandler,
 		ticketHandler:         ticketHandler,
 		geoHandler:            geoHandler,
@@ -283,6 +365,18 @@ func NewRouter(application *app.Application, db *gorm.DB) *Router {
 	}
 }
 
+// queryLimits converts the configured query timeouts, in seconds.
+func queryLimits(cfg app.DatasourceConfig) dsconn.QueryLimits {
+	limits := dsconn.QueryLimits{
+		Default: time.Duration(cfg.QueryTimeout) * time.Second,
+		PerUser: make(map[string]time.Duration, len(cfg.UserQueryTimeouts)),
+	}
+	for username, seconds := range cfg.UserQueryTimeouts {
+		limits.PerUser[username] = time.Duration(seconds) * time.Second
+	}
+	return limits
+}
+
 func requestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -334,7 +428,12 @@ func (r *Router) RegisterRoutes() {
 	handler.RegisterLicenseRoutes(r.engine, r.licenseHandler)
 	handler.RegisterMsgCenterRoutes(r.engine, r.msgCenterHandler)
 	handler.RegisterTicketRoutes(r.engine, r.ticketHandler)
-	handler.RegisterCompatibilityBridgeRoutes(r.engine, r.userHandler, r.orgHandler, r.datasourceHandler, r.datasetHandler, r.chartHandler)
+	handler.RegisterCompatibilityBridgeRoutes(r.engine, r.userHandler, r.orgHandler, r.datasourceHandler, r.datasetHandler, r.chartHandler, r.exportHandler)
+	handler.RegisterDatasourceTaskRoutes(r.engine, r.datasourceTaskHandler)
+	handler.RegisterDatasourceMonitorRoutes(r.engine, r.datasourceMonitor)
+	handler.RegisterLineageRoutes(r.engine, r.lineageHandler)
+	handler.RegisterColumnPermissionRoutes(r.engine, r.columnPermHandler)
+	handler.RegisterWebSocketRoutes(r.engine, r.hub)
 	handler.RegisterFrontendCompatRoutes(r.engine, r.frontendCompatHandler)
 
 	api := r.engine.Group("/api")
@@ -354,6 +453,7 @@ func (r *Router) RegisterRoutes() {
 		handler.RegisterMenuRoutes(api, r.menuHandler)
 		handler.RegisterMapRoutes(api, r.mapHandler)
 		handler.RegisterDatasourceRoutes(api, r.datasourceHandler)
+		handler.RegisterDatasourceBundleRoutes(api, r.datasourceBundle)
 		handler.RegisterDatasetRoutes(api, r.datasetHandler)
 		handler.RegisterChartRoutes(api, r.chartHandler)
 		handler.RegisterVisualizationRoutes(api, r.visualHandler)
@@ -368,7 +468,11 @@ func (r *Router) RegisterRoutes() {
 		handler.RegisterEngineRoutes(api, r.engineHandler)
 		handler.RegisterDriverRoutes(api, r.driverHandler)
 		handler.RegisterTemplateRoutes(api, r.templateHandler)
-		handler.RegisterCompatibilityBridgeRoutes(api, r.userHandler, r.orgHandler, r.datasourceHandler, r.datasetHandler, r.chartHandler)
+		handler.RegisterCompatibilityBridgeRoutes(api, r.userHandler, r.orgHandler, r.datasourceHandler, r.datasetHandler, r.chartHandler, r.exportHandler)
+		handler.RegisterDatasourceTaskRoutes(api, r.datasourceTaskHandler)
+		handler.RegisterDatasourceMonitorRoutes(api, r.datasourceMonitor)
+		handler.RegisterLineageRoutes(api, r.lineageHandler)
+		handler.RegisterColumnPermissionRoutes(api, r.columnPermHandler)
 	}
 }
 
@@ -380,6 +484,11 @@ func Start(application *app.Application, db *gorm.DB) error {
 	router := NewRouter(application, db)
 	router.RegisterRoutes()
 
+	if err := router.datasourceTasks.Start(); err != nil {
+		logger.Warn("Failed to start datasource sync tasks", zap.Error(err))
+	}
+	router.datasourceHealth.Start()
+
 	routes := collectRoutesFromEngine(router.engine)
 	conflicts := detectRouteConflicts(routes)
 	for _, conflict := range conflicts {
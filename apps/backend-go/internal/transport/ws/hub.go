@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"strings"
 	"sync"
 
 	"github.com/gorilla/websocket"
@@ -20,6 +21,9 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	// origins are the origins allowed besides the server's own.
+	origins map[string]bool
 }
 
 func NewHub() *Hub {
@@ -31,6 +35,18 @@ func NewHub() *Hub {
 	}
 }
 
+// SetAllowedOrigins sets the origins, such as "https://bi.example.com", whose
+// pages may open a socket besides those served by the server itself.
+func (h *Hub) SetAllowedOrigins(origins []string) {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")] = true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.origins = allowed
+}
+
 func (h *Hub) Run() {
 	for {
 		select {
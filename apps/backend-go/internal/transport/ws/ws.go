@@ -1 +1,62 @@
 package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Serve upgrades the request to a websocket and registers it with the hub as
+// a client of userID. Requests from pages of another origin than the server
+// and the allowed ones are refused. It returns once the pumps are started.
+func Serve(hub *Hub, w http.ResponseWriter, r *http.Request, userID uint64) error {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     hub.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+	client := &Client{
+		ID:     newClientID(),
+		UserID: userID,
+		Conn:   conn,
+		Send:   make(chan []byte, 256),
+		Hub:    hub,
+	}
+	hub.Register(client)
+	go client.WritePump()
+	go client.ReadPump()
+	return nil
+}
+
+func newClientID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// checkOrigin accepts clients sending no origin, which are not browsers,
+// pages of the server itself and the allowed origins.
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.origins[strings.TrimRight(strings.ToLower(origin), "/")]
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"net/http/httptest"
 	"testing"
 )
 
@@ -60,3 +61,27 @@ func TestHub_SendToUser_NoClients(t *testing.T) {
 	h := NewHub()
 	h.SendToUser(1, []byte("test message"))
 }
+
+func TestHub_CheckOrigin(t *testing.T) {
+	h := NewHub()
+	h.SetAllowedOrigins([]string{"https://bi.example.com/"})
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://localhost:8100", true},
+		{"https://bi.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://localhost:9999", false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "http://localhost:8100/websocket", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if got := h.checkOrigin(r); got != tc.want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tc.origin, got, tc.want)
+		}
+	}
+}
@@ -166,7 +166,7 @@ export const exportLimit = async (): Promise<boolean> => {
 
 export const perDelete = async (id): Promise<boolean> => {
   return request.post({ url: `/datasetTree/perDelete/${id}`, data: {} }).then(res => {
-    return res?.data
+    return res?.data?.inUse
   })
 }
 